@@ -0,0 +1,87 @@
+package main
+
+import (
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/check"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const checkUsage = `usage: server check [-db-path path] [-repair] [-tolerance amount]
+
+Reports orphan items and tags, the rows the cascade migration set aside in
+the *_orphan tables, tag links to missing tags, bills whose items
+do not sum up to the price, unparseable dates, currencies and countries,
+and bills sharing a link. With -repair the problems that have an automatic
+repair are fixed in one transaction.
+
+The database path defaults to BILLDB_DB_PATH. The checks run on the SQLite
+file only, the command refuses to run when BILLDB_POSTGRES_DSN or
+-postgres-dsn is set.
+`
+
+// errProblems makes the command exit with a non-zero status
+var errProblems = errors.New("database has unrepaired problems")
+
+// runCheck handles the "check" subcommand
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), checkUsage) }
+	dbPath := fs.String("db-path", os.Getenv("BILLDB_DB_PATH"), "path to DB (BILLDB_DB_PATH)")
+	postgresDsn := fs.String("postgres-dsn", os.Getenv("BILLDB_POSTGRES_DSN"), "PostgreSQL DSN (BILLDB_POSTGRES_DSN)")
+	repair := fs.Bool("repair", false, "apply the automatic repairs")
+	tolerance := fs.Float64("tolerance", check.DefaultTolerance, "allowed difference between the bill price and the items sum")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *postgresDsn != "" {
+		return errors.New("the bills are stored in PostgreSQL, the checks run on the SQLite file only")
+	}
+	if *dbPath == "" {
+		return errors.New("database path is not set")
+	}
+
+	db, err := repository.OpenSqlite(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	checker := check.New(db)
+	checker.Tolerance = *tolerance
+
+	var report *check.Report
+	if *repair {
+		report, err = checker.Repair(context.Background())
+	} else {
+		report, err = checker.Run(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, p := range report.Problems {
+		line := fmt.Sprintf("%-16s %s  %s", p.Kind, p.Id, p.Detail)
+		if p.Repairable() {
+			if *repair {
+				line += "  (repaired: " + p.Repair + ")"
+			} else {
+				line += "  (repair: " + p.Repair + ")"
+			}
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d problems, %d repairable, %d repaired\n",
+		len(report.Problems), report.Repairable(), report.Repaired)
+	if len(report.Problems) > report.Repaired {
+		return errProblems
+	}
+	return nil
+}
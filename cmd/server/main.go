@@ -1,17 +1,22 @@
 package main
 
 import (
+	"billdb/internal/parser"
+	"billdb/internal/parser/plugin"
+	"billdb/internal/qrcode"
+	"billdb/internal/repository/backup"
 	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/check"
+	diagnosticRepository "billdb/internal/repository/diagnostic"
+	jobRepository "billdb/internal/repository/job"
 	"billdb/internal/server"
-	"billdb/internal/server/api"
-	"billdb/internal/server/web"
+	"billdb/internal/server/app"
+	"billdb/internal/worker"
 	"context"
-	"database/sql"
-	"html/template"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -26,37 +31,111 @@ func main() {
 	logger, _ := zap.NewDevelopment()
 	defer logger.Sync()
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err := runMigrate(os.Args[2:])
+			if err != nil {
+				logger.Fatal("Migration failed", zap.Error(err))
+			}
+			return
+		case "check":
+			err := runCheck(os.Args[2:])
+			if errors.Is(err, errProblems) {
+				os.Exit(1)
+			}
+			if err != nil {
+				logger.Fatal("Check failed", zap.Error(err))
+			}
+			return
+		}
+	}
+
 	cfg, err := server.LoadConfig()
 	if err != nil {
 		logger.Fatal(err.Error())
 		return
 	}
 
-	db, err := sql.Open("sqlite3", cfg.DbPath)
+	db, err := repository.OpenSqlite(cfg.DbPath)
 	if err != nil {
 		logger.Fatal("Error on sqlite3 db open")
 		return
 	}
 	defer db.Close()
 
-	billRepo := repository.NewSqliteBillRepository(db)
-	s := server.Server{
-		BillRepo: billRepo,
-		Config:   cfg,
+	applied, err := repository.NewMigrator(db).Up()
+	for _, name := range applied {
+		logger.Info("Applied migration", zap.String("name", name))
 	}
-
-	pattern := filepath.Join(cfg.TemplatesPath, "*.html")
-	t := &server.Template{
-		Templates: template.Must(template.ParseGlob(pattern)),
+	if err != nil {
+		logger.Fatal("Error on applying migrations", zap.Error(err))
+		return
+	}
+	orphans, err := repository.CountOrphans(context.Background(), db)
+	if err != nil {
+		logger.Fatal("Error on counting orphan rows", zap.Error(err))
+		return
+	}
+	for table, n := range orphans {
+		if n > 0 {
+			logger.Warn("Rows set aside by the migrations, see server check",
+				zap.String("table", table), zap.Int("rows", n))
+		}
 	}
 
-	e := echo.New()
+	if cfg.ZbarFallback {
+		qrcode.SetFallback(&qrcode.Zbar{})
+	}
 
-	e.Renderer = t
+	var billRepo repository.BillRepository = repository.NewSqliteBillRepository(db)
+	if cfg.PostgresDsn != "" {
+		pg, err := repository.OpenPostgres(cfg.PostgresDsn)
+		if err != nil {
+			logger.Fatal("Error on postgres db open", zap.Error(err))
+			return
+		}
+		defer pg.Close()
 
-	// call to /index-style.css will redirect to cfg.StaticPath/index-style.css
-	e.Static("/static", cfg.StaticPath)
-	e.Static("/uploaded", cfg.QrPath)
+		applied, err := repository.NewPostgresMigrator(pg).Up()
+		for _, name := range applied {
+			logger.Info("Applied postgres migration", zap.String("name", name))
+		}
+		if err != nil {
+			logger.Fatal("Error on applying postgres migrations", zap.Error(err))
+			return
+		}
+		billRepo = repository.NewPostgresBillRepository(pg)
+	}
+	jobRepo := jobRepository.NewSqliteJobRepository(db)
+	diagnosticRepo := diagnosticRepository.NewSqliteDiagnosticRepository(db)
+	parser.SetDiagnostics(diagnosticRepo)
+	if cfg.ParserPluginsPath != "" {
+		plugins, err := plugin.Load(cfg.ParserPluginsPath)
+		if err != nil {
+			logger.Fatal("Error on parser plugins load", zap.Error(err))
+			return
+		}
+		parser.SetPlugins(plugins)
+	}
+	jobPool := worker.NewPool(
+		jobRepo,
+		worker.NewParseHandler(billRepo),
+		cfg.JobWorkers,
+		cfg.JobHostLimit,
+	)
+	backups := backup.New(db, cfg.BackupDir(), cfg.BackupPolicy())
+	e, err := app.New(cfg, app.Deps{
+		BillRepo:       billRepo,
+		Jobs:           jobPool,
+		DiagnosticRepo: diagnosticRepo,
+		Checker:        check.New(db),
+		Backups:        backups,
+	})
+	if err != nil {
+		logger.Fatal("Error on app setup", zap.Error(err))
+		return
+	}
 
 	e.Logger.SetLevel(log.INFO)
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
@@ -72,16 +151,25 @@ func main() {
 			return nil
 		},
 	}))
-	s.Echo = e
-
-	// handlers
-	webGroup := e.Group("")
-	webHandlers := web.NewWebHandlers(cfg, e, billRepo)
-	webHandlers.RegisterRoutes(webGroup)
-	api.ApiRoutes(&s)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
+
+	// Start background parse workers
+	jobsDone := make(chan struct{})
+	go func() {
+		jobPool.Run(ctx)
+		close(jobsDone)
+	}()
+
+	// Purge the bills kept in the trash past the retention
+	go worker.PurgeTrash(ctx, billRepo, cfg.TrashRetention())
+
+	// Back up the SQLite file of the bills every day
+	if cfg.PostgresDsn == "" {
+		go worker.Backup(ctx, backups)
+	}
+
 	// Start server
 	go func() {
 		if err := e.Start(":" + cfg.Port); err != nil && err != http.ErrServerClosed {
@@ -96,4 +184,9 @@ func main() {
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		logger.Warn("background jobs did not finish before shutdown")
+	}
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/migration"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const migrateUsage = `usage: server migrate [-db-path path | -postgres-dsn dsn] status|up
+
+  status  list migrations and whether they are applied
+  up      apply pending migrations
+
+The database path defaults to BILLDB_DB_PATH. With -postgres-dsn,
+defaulting to BILLDB_POSTGRES_DSN, the PostgreSQL schema is migrated.
+`
+
+// runMigrate handles the "migrate" subcommand
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), migrateUsage) }
+	dbPath := fs.String("db-path", os.Getenv("BILLDB_DB_PATH"), "path to DB (BILLDB_DB_PATH)")
+	postgresDsn := fs.String("postgres-dsn", os.Getenv("BILLDB_POSTGRES_DSN"), "PostgreSQL DSN (BILLDB_POSTGRES_DSN)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one command: status or up")
+	}
+	var m *migration.Migrator
+	switch {
+	case *postgresDsn != "":
+		db, err := repository.OpenPostgres(*postgresDsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		m = repository.NewPostgresMigrator(db)
+	case *dbPath != "":
+		db, err := repository.OpenSqlite(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		m = repository.NewMigrator(db)
+	default:
+		return errors.New("database path is not set")
+	}
+
+	switch fs.Arg(0) {
+	case "status":
+		migrations, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			state := "pending"
+			if migration.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %s\n", state, migration.Name)
+		}
+	case "up":
+		applied, err := m.Up()
+		for _, name := range applied {
+			fmt.Printf("applied %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		if *postgresDsn == "" {
+			orphans, err := repository.CountOrphans(context.Background(), m.DB)
+			if err != nil {
+				return err
+			}
+			for _, table := range repository.OrphanTables {
+				if orphans[table] > 0 {
+					fmt.Printf("%d rows set aside in %s, see server check\n", orphans[table], table)
+				}
+			}
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command: %s", fs.Arg(0))
+	}
+	return nil
+}
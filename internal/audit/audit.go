@@ -0,0 +1,132 @@
+// Package audit describes the append-only log of the changes
+// made to bills, items and tags, and where they came from.
+package audit
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+// Source is where a change came from
+type Source string
+
+const (
+	SourceWeb    Source = "web"
+	SourceAPI    Source = "api"
+	SourceParser Source = "parser"
+	SourceImport Source = "import"
+	// SourceSystem is the server itself, such as the trash retention,
+	// and changes made without a source in the context
+	SourceSystem Source = "system"
+)
+
+type sourceKey struct{}
+
+// WithSource returns ctx with the source of the changes made with it
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceOf returns the source of ctx, SourceSystem when it has none
+func SourceOf(ctx context.Context) Source {
+	if source, ok := ctx.Value(sourceKey{}).(Source); ok {
+		return source
+	}
+	return SourceSystem
+}
+
+// Action is the kind of a change
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	// Delete moves a bill to the trash, items are deleted for good
+	Delete  Action = "delete"
+	Restore Action = "restore"
+	Purge   Action = "purge"
+)
+
+// Entity is the kind of a changed row
+type Entity string
+
+const (
+	Bill Entity = "bill"
+	Item Entity = "item"
+	Tag  Entity = "tag"
+)
+
+// Entry is one change. Old and New are the values of the row before
+// and after it by field name, the changed fields only for updates.
+type Entry struct {
+	Id       int64
+	Time     time.Time
+	Source   Source
+	Action   Action
+	Entity   Entity
+	EntityId string
+	// BillId is the bill of the history the entry belongs to
+	BillId string
+	Old    map[string]string
+	New    map[string]string
+	// Reverts is the entry undone by this one, zero otherwise
+	Reverts int64
+}
+
+// Change is a field of an entry with its old and new value
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// fieldOrder is the order of the known fields in Changes,
+// the other fields follow by name
+var fieldOrder = []string{
+	"name", "date", "price", "price_one", "quantity",
+	"currency", "country", "tag", "link", "text", "bill_id",
+}
+
+// Changes returns the fields of the entry in a stable order
+func (e *Entry) Changes() []Change {
+	var fields []string
+	for _, values := range []map[string]string{e.Old, e.New} {
+		for field := range values {
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	slices.SortFunc(fields, func(a, b string) int {
+		i, j := slices.Index(fieldOrder, a), slices.Index(fieldOrder, b)
+		switch {
+		case i == j:
+			return cmp.Compare(a, b)
+		case i == -1:
+			return 1
+		case j == -1:
+			return -1
+		}
+		return i - j
+	})
+
+	changes := make([]Change, len(fields))
+	for i, field := range fields {
+		changes[i] = Change{Field: field, Old: e.Old[field], New: e.New[field]}
+	}
+	return changes
+}
+
+// Revertible tells if the change can be undone,
+// purged bills and created tags cannot
+func (e *Entry) Revertible() bool {
+	switch e.Entity {
+	case Bill:
+		return e.Action != Purge
+	case Item:
+		return e.Action == Create || e.Action == Delete
+	}
+	return false
+}
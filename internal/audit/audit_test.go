@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	ctx := context.Background()
+	if got := SourceOf(ctx); got != SourceSystem {
+		t.Errorf("Expected %s without a source, got %s", SourceSystem, got)
+	}
+	if got := SourceOf(WithSource(ctx, SourceAPI)); got != SourceAPI {
+		t.Errorf("Expected %s, got %s", SourceAPI, got)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	e := &Entry{
+		Old: map[string]string{"tag": "food", "price": "100", "zeta": "1"},
+		New: map[string]string{"tag": "home", "price": "120", "name": "Maxi", "alpha": "2"},
+	}
+	want := []Change{
+		{"name", "", "Maxi"},
+		{"price", "100", "120"},
+		{"tag", "food", "home"},
+		{"alpha", "", "2"},
+		{"zeta", "1", ""},
+	}
+	if got := e.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+}
+
+func TestRevertible(t *testing.T) {
+	cases := []struct {
+		entity Entity
+		action Action
+		want   bool
+	}{
+		{Bill, Update, true},
+		{Bill, Delete, true},
+		{Bill, Purge, false},
+		{Item, Delete, true},
+		{Item, Update, false},
+		{Tag, Create, false},
+	}
+	for _, tc := range cases {
+		e := &Entry{Entity: tc.entity, Action: tc.action}
+		if got := e.Revertible(); got != tc.want {
+			t.Errorf("%s %s: Revertible() = %v, want %v", tc.action, tc.entity, got, tc.want)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +24,9 @@ type Bill struct {
 	Tag      *tag.Tag // TODO add posibility to be nil
 	Link     string
 	BillText string // TODO transform into a struct
+	// FiscalId is the fiscal key of the receipt found by the parser,
+	// see FiscalKey, which falls back to the link when it is empty
+	FiscalId string
 }
 
 func New(
@@ -65,6 +71,48 @@ func (b *Bill) GetCountryString() string {
 	return b.Country.String()
 }
 
+// Validate checks a bill coming from a parser before it is stored
+func (b *Bill) Validate() error {
+	if b.Id == "" {
+		return errors.New("bill id is empty")
+	}
+	if strings.TrimSpace(b.Name) == "" {
+		return errors.New("bill name is empty")
+	}
+	if b.Date.IsZero() {
+		return errors.New("bill date is empty")
+	}
+	if math.IsNaN(b.Price) || math.IsInf(b.Price, 0) || b.Price < 0 {
+		return fmt.Errorf("invalid bill price: %v", b.Price)
+	}
+	if !b.Currency.Valid() {
+		return fmt.Errorf("invalid bill currency: %d", b.Currency)
+	}
+	if !b.Country.Valid() {
+		return fmt.Errorf("invalid bill country: %d", b.Country)
+	}
+	if b.Tag == nil {
+		return errors.New("bill tag is nil")
+	}
+	for i, it := range b.Items {
+		if it.ItemId == "" {
+			return fmt.Errorf("item %d: id is empty", i)
+		}
+		if it.BillId != b.Id {
+			return fmt.Errorf("item %d: belongs to bill %s, not %s", i, it.BillId, b.Id)
+		}
+		if strings.TrimSpace(it.Name) == "" {
+			return fmt.Errorf("item %d: name is empty", i)
+		}
+		for _, v := range []float64{it.Price, it.PriceOne, it.Quantity} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("item %d: invalid number %v", i, v)
+			}
+		}
+	}
+	return nil
+}
+
 func UpdateBillProperty(bill *Bill, property string, value interface{}) error {
 	switch property {
 	case "name":
@@ -104,6 +152,8 @@ func UpdateBillProperty(bill *Bill, property string, value interface{}) error {
 		bill.Tag = tag.New(value.(string))
 	case "link":
 		bill.Link = value.(string)
+		// the key of the new link
+		bill.FiscalId = ""
 	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package bill
+
+import (
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestBill() *Bill {
+	b := New(
+		"bill-id",
+		"Maxi",
+		time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		100.0,
+		currency.RSD,
+		country.SERBIA,
+		[]*item.Item{},
+		tag.Empty(),
+		"link",
+		"",
+	)
+	b.AddItem(item.New("item-id", "bill-id", "Mleko", 100.0, 100.0, 1.0))
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	b := newTestBill()
+	if err := b.Validate(); err != nil {
+		t.Errorf("Expected valid bill, got: %v", err)
+	}
+
+	b = newTestBill()
+	b.Name = " "
+	if err := b.Validate(); err == nil {
+		t.Error("Expected error for empty name")
+	}
+
+	b = newTestBill()
+	b.Price = math.NaN()
+	if err := b.Validate(); err == nil {
+		t.Error("Expected error for NaN price")
+	}
+
+	b = newTestBill()
+	b.Currency = currency.Currency(42)
+	if err := b.Validate(); err == nil {
+		t.Error("Expected error for unknown currency")
+	}
+
+	b = newTestBill()
+	b.Items[0].BillId = "other-bill"
+	if err := b.Validate(); err == nil {
+		t.Error("Expected error for item of another bill")
+	}
+}
+
+// serbianLink is a real Serbian link, the verification page shows
+// the invoice number U6EUQH8T-U6EUQH8T-310438 issued at 18:39:54
+const serbianLink = "https://suf.purs.gov.rs/v/?vl=A1U2RVVRSDhUVTZFVVFIOFSmvAQAJ7oEAMRvQQMAAAAAAAABi8nEtiwAAACJEXBZdZJy%2FNmApRiEns0Sgulz4SpsZpL0dvJtAbJh7IOyoE6pEx%2B1qDfy59VX5fVpHsJwdGLNUg1a0R%2Fy4%2BmVo85QwP7TNH4N%2FyzwrA%3D%3D"
+
+func TestFiscalKey(t *testing.T) {
+	for _, c := range []struct {
+		link string
+		want string
+	}{
+		{"https://suf.purs.gov.rs/v/?vl=A0Q2+Nz%3D", "rs:A0Q2+Nz="},
+		{"http://suf.purs.gov.rs/v/?lang=en&vl=A0Q2+Nz=", "rs:A0Q2+Nz="},
+		{"https://suf.purs.gov.rs/v/", ""},
+		{"t=20240501T1200&s=100.00&fn=9960440300&i=1234&fp=567890&n=1", "ru:9960440300/1234/567890"},
+		{"t=20240501T1200&s=100.00&fn=9960440300&n=1", ""},
+		{"https://example.com/?vl=A0Q2", ""},
+		{serbianLink, "rs:U6EUQH8T-U6EUQH8T-310438"},
+		{"https://porezna.gov.hr/rn?jir=6F9B2C4A-1D3E-4F5A-8B7C-0D1E2F3A4B5C&datv=20240501_1215&izn=1250", "hr:6f9b2c4a-1d3e-4f5a-8b7c-0d1e2f3a4b5c"},
+		{"", ""},
+	} {
+		b := newTestBill()
+		b.Link = c.link
+		if got := b.FiscalKey(); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.link, c.want, got)
+		}
+	}
+
+	b := newTestBill()
+	b.Link = serbianLink
+	b.FiscalId = SerbianFiscalId(" U6EUQH8T-U6EUQH8T-310438\n")
+	if key := b.FiscalKey(); key != "rs:U6EUQH8T-U6EUQH8T-310438" {
+		t.Errorf("Expected the invoice number of the page, got %q", key)
+	}
+	if err := UpdateBillProperty(b, "link", "t=20240501T1200&fn=1&i=2&fp=3"); err != nil {
+		t.Fatal(err)
+	}
+	if key := b.FiscalKey(); key != "ru:1/2/3" {
+		t.Errorf("Expected the key of the new link, got %q", key)
+	}
+}
+
+func TestReceiptTime(t *testing.T) {
+	for _, c := range []struct {
+		link string
+		want time.Time
+	}{
+		{serbianLink, time.Date(2023, 11, 13, 17, 39, 54, 28e6, time.UTC)},
+		{"t=20240501T121530&s=10.00&fn=1&i=2&fp=3", time.Date(2024, 5, 1, 12, 15, 30, 0, time.UTC)},
+		{"t=20240501T1215&s=10.00", time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC)},
+		{"https://porezna.gov.hr/rn?jir=abc&datv=20240501_1215", time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC)},
+	} {
+		b := newTestBill()
+		b.Link = c.link
+		if got, ok := b.ReceiptTime(); !ok || !got.Equal(c.want) {
+			t.Errorf("%s: expected %v, got %v, %v", c.link, c.want, got, ok)
+		}
+	}
+	for _, link := range []string{"", "link", "https://suf.purs.gov.rs/v/?vl=A0Q2", "s=10.00&t=soon"} {
+		b := newTestBill()
+		b.Link = link
+		if got, ok := b.ReceiptTime(); ok {
+			t.Errorf("%s: expected no time, got %v", link, got)
+		}
+	}
+}
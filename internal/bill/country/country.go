@@ -17,6 +17,11 @@ func (c Country) String() string {
 	return countryToString[c]
 }
 
+// Valid reports whether c is one of the known countries
+func (c Country) Valid() bool {
+	return c >= 0 && int(c) < len(countryToString)
+}
+
 func Parse(countryString string) (Country, error) {
 	switch countryString {
 	case "serbia":
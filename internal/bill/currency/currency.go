@@ -21,6 +21,11 @@ func (c Currency) String() string {
 	return currencyToString[c]
 }
 
+// Valid reports whether c is one of the known currencies
+func (c Currency) Valid() bool {
+	return c >= 0 && int(c) < len(currencyToString)
+}
+
 func Available() []string {
 	currencyList := append([]string{}, currencyToString...)
 	return currencyList
@@ -0,0 +1,156 @@
+package bill
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hosts of the verification sites linked by the QR codes
+const (
+	serbiaHost  = "suf.purs.gov.rs"
+	croatiaHost = "porezna.gov.hr"
+)
+
+// prefixes of the fiscal keys, by tax authority
+const (
+	serbiaKey  = "rs:"
+	russiaKey  = "ru:"
+	croatiaKey = "hr:"
+)
+
+// layouts of the receipt times of the Russian and Croatian links
+var (
+	russiaTimeLayouts = []string{"20060102T150405", "20060102T1504"}
+	croatiaTimeLayout = "20060102_1504"
+)
+
+// SerbianFiscalId returns the fiscal key of a Serbian receipt
+// from its invoice number, as the verification page shows it,
+// empty for an empty number
+func SerbianFiscalId(invoiceNumber string) string {
+	invoiceNumber = strings.TrimSpace(invoiceNumber)
+	if invoiceNumber == "" {
+		return ""
+	}
+	return serbiaKey + invoiceNumber
+}
+
+// FiscalKey returns the identifier the tax authority gave the receipt:
+// FiscalId when the parser found it, otherwise the one of the link, the
+// invoice number in the verification data of a Serbian link, the fiscal
+// drive, document and sign numbers of a Russian QR string and the JIR of
+// a Croatian link. The same receipt has the same key however its link
+// was written, empty when the link has none.
+func (b *Bill) FiscalKey() string {
+	if b.FiscalId != "" {
+		return b.FiscalId
+	}
+	key, _, _ := readLink(b.Link)
+	return key
+}
+
+// ReceiptTime returns the time of the receipt written in its link,
+// the stored date of a bill has the day only. The Serbian time is in
+// UTC, the Russian and Croatian ones in the local time of the receipt
+// read as UTC. ok is false when the link has no time.
+func (b *Bill) ReceiptTime() (time.Time, bool) {
+	_, t, ok := readLink(b.Link)
+	return t, ok
+}
+
+// readLink returns the fiscal key and the time of the receipt of a link
+func readLink(link string) (string, time.Time, bool) {
+	link = strings.TrimSpace(link)
+	if strings.HasPrefix(link, "t=") {
+		return readRussian(link)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	switch {
+	case isHost(u.Hostname(), serbiaHost):
+		return readSerbian(u.RawQuery)
+	case isHost(u.Hostname(), croatiaHost):
+		return readCroatian(u.Query())
+	}
+	return "", time.Time{}, false
+}
+
+func isHost(hostname string, host string) bool {
+	return hostname == host || strings.HasSuffix(hostname, "."+host)
+}
+
+// readRussian reads a Russian QR string, t=20240501T1200&s=10.00&fn=...
+func readRussian(link string) (string, time.Time, bool) {
+	values, err := url.ParseQuery(link)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	key := ""
+	fn, fd, fp := values.Get("fn"), values.Get("i"), values.Get("fp")
+	if fn != "" && fd != "" && fp != "" {
+		key = russiaKey + fn + "/" + fd + "/" + fp
+	}
+	for _, layout := range russiaTimeLayouts {
+		if t, err := time.Parse(layout, values.Get("t")); err == nil {
+			return key, t, true
+		}
+	}
+	return key, time.Time{}, false
+}
+
+// readSerbian reads the verification data of a Serbian link, the vl
+// parameter. It is the base64 of the receipt: a version byte, the ids
+// of the requester and the signer of 8 characters, the invoice counter
+// and the counter of its type as little-endian uint32, the amount as
+// uint64, then the time as big-endian milliseconds since the epoch.
+// The invoice number is requester-signer-counter.
+func readSerbian(query string) (string, time.Time, bool) {
+	for _, param := range strings.Split(query, "&") {
+		value, ok := strings.CutPrefix(param, "vl=")
+		if !ok {
+			continue
+		}
+		// the verification data is base64, a + is not a space
+		vl, err := url.PathUnescape(value)
+		if err != nil || vl == "" {
+			return "", time.Time{}, false
+		}
+		data, err := base64.StdEncoding.DecodeString(vl)
+		if err != nil || len(data) < 41 || !printable(data[1:17]) {
+			// not a format we know, the same data is the same receipt
+			return serbiaKey + vl, time.Time{}, false
+		}
+		counter := binary.LittleEndian.Uint32(data[17:21])
+		number := string(data[1:9]) + "-" + string(data[9:17]) + "-" + strconv.FormatUint(uint64(counter), 10)
+		t := time.UnixMilli(int64(binary.BigEndian.Uint64(data[33:41]))).UTC()
+		return SerbianFiscalId(number), t, true
+	}
+	return "", time.Time{}, false
+}
+
+// readCroatian reads the JIR, the unique receipt identifier,
+// and the time of the datv parameter of a Croatian link
+func readCroatian(values url.Values) (string, time.Time, bool) {
+	key := ""
+	if jir := strings.TrimSpace(values.Get("jir")); jir != "" {
+		key = croatiaKey + strings.ToLower(jir)
+	}
+	t, err := time.Parse(croatiaTimeLayout, values.Get("datv"))
+	return key, t, err == nil
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package job
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+type Status int
+
+const (
+	PENDING Status = iota // int 0
+	RUNNING
+	DONE
+	FAILED
+)
+
+// must align with the Status enum
+var statusToString = []string{"pending", "running", "done", "failed"}
+
+func (s Status) String() string {
+	return statusToString[s]
+}
+
+func ParseStatus(statusString string) (Status, error) {
+	switch statusString {
+	case "pending":
+		return PENDING, nil
+	case "running":
+		return RUNNING, nil
+	case "done":
+		return DONE, nil
+	case "failed":
+		return FAILED, nil
+	default:
+		return -1, fmt.Errorf("Job status %s not found", statusString)
+	}
+}
+
+// Job is a single link (or scanned string) waiting to be parsed
+// into a bill by the background workers.
+type Job struct {
+	Id        string
+	Link      string
+	Host      string
+	Status    Status
+	Attempts  int
+	Error     string
+	BillId    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// NextRun is set only for failed jobs which will be retried
+	NextRun *time.Time
+}
+
+func New(link string) *Job {
+	now := time.Now().UTC()
+	return &Job{
+		Id:        ksuid.New().String(),
+		Link:      link,
+		Host:      HostOf(link),
+		Status:    PENDING,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// HostOf returns the host the link will be fetched from.
+// Strings which are not URLs (e.g. russian "t=..." qr data)
+// are grouped under the "local" host.
+func HostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return "local"
+	}
+	return u.Hostname()
+}
+
+// Retrying reports whether a failed job is scheduled for another attempt.
+func (j *Job) Retrying() bool {
+	return j.Status == FAILED && j.NextRun != nil
+}
+
+func (j *Job) GetStatusString() string {
+	return j.Status.String()
+}
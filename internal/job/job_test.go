@@ -0,0 +1,32 @@
+package job
+
+import "testing"
+
+func TestStatusToString(t *testing.T) {
+	status := FAILED
+	if status.String() != "failed" {
+		t.Errorf("Status as string: `%s`, expected `failed`", status)
+	}
+	status, err := ParseStatus("running")
+	if err != nil {
+		t.Error("Error parsing status string:", err)
+	}
+	if status != RUNNING {
+		t.Errorf("Status: `%s`, expected `running`", status)
+	}
+	_, err = ParseStatus("unknown")
+	if err == nil {
+		t.Error("Expected error for unknown status")
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	host := HostOf("https://suf.purs.gov.rs/v/?vl=abc")
+	if host != "suf.purs.gov.rs" {
+		t.Errorf("Expected host `suf.purs.gov.rs`, got `%s`", host)
+	}
+	host = HostOf("t=20240101T1200&s=100.00&fn=1&i=2&fp=3&n=1")
+	if host != "local" {
+		t.Errorf("Expected host `local`, got `%s`", host)
+	}
+}
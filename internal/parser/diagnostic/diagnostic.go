@@ -0,0 +1,113 @@
+package diagnostic
+
+import (
+	"time"
+)
+
+// token extraction outcomes for parsers which need a page token
+const (
+	TokenNotReached = ""
+	TokenFound      = "found"
+	TokenMissing    = "missing"
+	TokenRejected   = "rejected"
+)
+
+// Attempt describes a single try of a parser to fetch a bill.
+type Attempt struct {
+	Parser  string
+	Link    string
+	Attempt int
+	// HTTPStatus of the bill page, 0 if the request was not made
+	HTTPStatus int
+	// ItemsStatus of the items request, 0 if the request was not made
+	ItemsStatus    int
+	FailedSelector string
+	Token          string
+	Success        bool
+	Error          string
+	Duration       time.Duration
+	CreatedAt      time.Time
+}
+
+func NewAttempt(parser string, link string, attempt int) *Attempt {
+	return &Attempt{
+		Parser:    parser,
+		Link:      link,
+		Attempt:   attempt,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Finish sets the outcome of the attempt
+func (a *Attempt) Finish(err error) {
+	a.Duration = time.Since(a.CreatedAt)
+	a.Success = err == nil
+	if err != nil {
+		a.Error = err.Error()
+	}
+}
+
+// Recorder stores parser attempts
+type Recorder interface {
+	Record(a *Attempt)
+}
+
+// Record passes the attempt to the recorder if one is set
+func Record(r Recorder, a *Attempt) {
+	if r == nil {
+		return
+	}
+	r.Record(a)
+}
+
+type Stats struct {
+	Attempts int
+	Failures int
+}
+
+func (s Stats) FailureRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Attempts)
+}
+
+func (s Stats) SuccessRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return 1 - s.FailureRate()
+}
+
+func (s Stats) FailurePercent() float64 {
+	return s.FailureRate() * 100
+}
+
+func (s Stats) SuccessPercent() float64 {
+	return s.SuccessRate() * 100
+}
+
+// DayStats are the stats of one parser for a single day (YYYY-MM-DD, UTC)
+type DayStats struct {
+	Parser string
+	Day    string
+	Stats
+}
+
+const (
+	// spikeMinAttempts is the least number of recent attempts
+	// to say anything about the failure rate
+	spikeMinAttempts = 3
+	// spikeMinIncrease is the least increase of the failure rate
+	// over the baseline to call it a spike
+	spikeMinIncrease = 0.3
+)
+
+// IsSpike reports whether the recent failure rate jumped
+// compared to the baseline period.
+func IsSpike(recent Stats, baseline Stats) bool {
+	if recent.Attempts < spikeMinAttempts {
+		return false
+	}
+	return recent.FailureRate()-baseline.FailureRate() >= spikeMinIncrease
+}
@@ -0,0 +1,38 @@
+package diagnostic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAttemptFinish(t *testing.T) {
+	a := NewAttempt("rs", "https://suf.purs.gov.rs/v/?vl=abc", 1)
+	a.Finish(nil)
+	if !a.Success {
+		t.Error("Expected attempt to be successful")
+	}
+	a = NewAttempt("rs", "https://suf.purs.gov.rs/v/?vl=abc", 2)
+	a.Finish(errors.New("token not found"))
+	if a.Success {
+		t.Error("Expected attempt to fail")
+	}
+	if a.Error != "token not found" {
+		t.Errorf("Expected error 'token not found', got '%s'", a.Error)
+	}
+}
+
+func TestIsSpike(t *testing.T) {
+	baseline := Stats{Attempts: 100, Failures: 5}
+	if IsSpike(Stats{Attempts: 2, Failures: 2}, baseline) {
+		t.Error("Expected no spike with too few attempts")
+	}
+	if IsSpike(Stats{Attempts: 10, Failures: 1}, baseline) {
+		t.Error("Expected no spike for a stable failure rate")
+	}
+	if !IsSpike(Stats{Attempts: 10, Failures: 6}, baseline) {
+		t.Error("Expected spike for a jump in failure rate")
+	}
+	if !IsSpike(Stats{Attempts: 4, Failures: 4}, Stats{}) {
+		t.Error("Expected spike without a baseline")
+	}
+}
@@ -2,9 +2,14 @@ package parser
 
 import (
 	"billdb/internal/bill"
+	"billdb/internal/parser/diagnostic"
+	"billdb/internal/parser/plugin"
 	rs "billdb/internal/parser/serbia"
 	ru "billdb/internal/parser/russia"
+	"fmt"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // Parser defines the interface for parsing URLs.
@@ -28,13 +33,142 @@ func NewUnimplementedError(message string) *UnimplementedError {
 	return &UnimplementedError{message: message}
 }
 
+// diagnostics receives the attempts of every parser created by GetBillParser
+var diagnostics diagnostic.Recorder
+
+// SetDiagnostics sets the recorder for parse attempts.
+// Should be called once on startup, before any parser is created.
+func SetDiagnostics(r diagnostic.Recorder) {
+	diagnostics = r
+}
+
+// plugins are external parsers, consulted after the built-in ones
+var plugins []*plugin.Plugin
+
+// SetPlugins sets the external parser plugins.
+// Should be called once on startup, before any parser is created.
+func SetPlugins(p []*plugin.Plugin) {
+	plugins = p
+}
+
+// overrides are parsers consulted before the built-in ones, see Override
+var (
+	overridesMu sync.RWMutex
+	overrides   []*override
+)
+
+type override struct {
+	prefix string
+	parser Parser
+}
+
+// Override makes GetBillParser return p for the data starting with prefix,
+// before the built-in parsers and plugins, until restore is called.
+// It is meant for tests replacing the parsers of the tax sites
+// with canned bills.
+func Override(prefix string, p Parser) (restore func()) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	o := &override{prefix: prefix, parser: p}
+	overrides = append([]*override{o}, overrides...)
+	return func() {
+		overridesMu.Lock()
+		defer overridesMu.Unlock()
+		overrides = slices.DeleteFunc(overrides, func(other *override) bool {
+			return other == o
+		})
+	}
+}
+
+// overrideOf returns the parser overriding the data, nil if there is none
+func overrideOf(data string) Parser {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	for _, o := range overrides {
+		if strings.HasPrefix(data, o.prefix) {
+			return o.parser
+		}
+	}
+	return nil
+}
+
 // GetBillParser creates a parser for a given URL.
 func GetBillParser(data string) (Parser, error) {
+	if p := overrideOf(data); p != nil {
+		return &validatingParser{Parser: p}, nil
+	}
   if strings.HasPrefix(data, "https://suf.purs.gov.rs") {
-    return &rs.Parser{}, nil
+    return validating(diagnostics, func(r diagnostic.Recorder) Parser {
+      return &rs.Parser{Diagnostics: r}
+    }), nil
   }
   if strings.HasPrefix(data, "t=") {
-    return &ru.Parser{}, nil
+    return validating(diagnostics, func(r diagnostic.Recorder) Parser {
+      return &ru.Parser{Diagnostics: r}
+    }), nil
   }
+	for _, p := range plugins {
+		if p.Match(data) {
+			return validating(diagnostics, func(r diagnostic.Recorder) Parser {
+				pluginParser := *p
+				pluginParser.Diagnostics = r
+				return &pluginParser
+			}), nil
+		}
+	}
 	return nil, NewUnimplementedError("No parser available for the given URL")
 }
+
+// validatingParser checks every parsed bill with the same rules,
+// so built-in parsers and plugins can't store broken bills.
+// The attempts of the parser are held until its bill is validated,
+// an invalid bill fails the attempt that fetched it.
+type validatingParser struct {
+	Parser
+	// recorder receives the attempts once the bill is validated
+	recorder diagnostic.Recorder
+
+	mu   sync.Mutex
+	held []*diagnostic.Attempt
+}
+
+// validating returns the parser of newParser validating its bills,
+// newParser gets the recorder of its attempts
+func validating(recorder diagnostic.Recorder, newParser func(r diagnostic.Recorder) Parser) *validatingParser {
+	v := &validatingParser{recorder: recorder}
+	v.Parser = newParser(v)
+	return v
+}
+
+// Record holds an attempt of the parser until the end of Parse
+func (v *validatingParser) Record(a *diagnostic.Attempt) {
+	v.held = append(v.held, a)
+}
+
+func (v *validatingParser) Parse(u string) (*bill.Bill, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	defer func() {
+		for _, a := range v.held {
+			diagnostic.Record(v.recorder, a)
+		}
+		v.held = nil
+	}()
+
+	b, err := v.Parser.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	invalid := b.Validate()
+	if invalid == nil {
+		return b, nil
+	}
+	err = fmt.Errorf("%s parser returned invalid bill: %w", v.Type(), invalid)
+	if len(v.held) == 0 {
+		v.held = append(v.held, diagnostic.NewAttempt(v.Type(), u, 1))
+	}
+	last := v.held[len(v.held)-1]
+	last.Success = false
+	last.Error = "validate: " + invalid.Error()
+	return nil, err
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/tag"
+	"billdb/internal/parser/diagnostic"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cannedParser struct {
+	bill        *bill.Bill
+	diagnostics diagnostic.Recorder
+}
+
+func (p *cannedParser) Type() string {
+	return "canned"
+}
+
+func (p *cannedParser) Parse(u string) (*bill.Bill, error) {
+	d := diagnostic.NewAttempt(p.Type(), u, 1)
+	d.Finish(nil)
+	diagnostic.Record(p.diagnostics, d)
+	return p.bill, nil
+}
+
+type attempts []*diagnostic.Attempt
+
+func (a *attempts) Record(d *diagnostic.Attempt) {
+	*a = append(*a, d)
+}
+
+func TestOverride(t *testing.T) {
+	link := "https://suf.purs.gov.rs/v/?vl=canned"
+	canned := &cannedParser{bill: bill.New("id", "Maxi", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 10,
+		currency.RSD, country.SERBIA, nil, tag.New(""), link, "")}
+	restore := Override("https://suf.purs.gov.rs", canned)
+
+	p, err := GetBillParser(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type() != "canned" {
+		t.Fatalf("Expected the overriding parser, got %s", p.Type())
+	}
+	if b, err := p.Parse(link); err != nil || b.Name != "Maxi" {
+		t.Errorf("Expected the canned bill, got %v, %v", b, err)
+	}
+
+	canned.bill = bill.New("", "Maxi", time.Time{}, 10, currency.RSD, country.SERBIA, nil, tag.New(""), link, "")
+	if _, err := p.Parse(link); err == nil {
+		t.Errorf("Expected the invalid canned bill rejected")
+	}
+
+	restore()
+	p, err = GetBillParser(link)
+	if err != nil || p.Type() != "rs" {
+		t.Errorf("Expected the built-in parser after restore, got %v, %v", p, err)
+	}
+}
+
+func TestValidatingDiagnostics(t *testing.T) {
+	link := "https://example.com/bill"
+	recorded := &attempts{}
+	canned := &cannedParser{bill: bill.New("id", "Maxi", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 10,
+		currency.RSD, country.SERBIA, nil, tag.New(""), link, "")}
+	p := validating(recorded, func(r diagnostic.Recorder) Parser {
+		canned.diagnostics = r
+		return canned
+	})
+
+	if _, err := p.Parse(link); err != nil {
+		t.Fatal(err)
+	}
+	if len(*recorded) != 1 || !(*recorded)[0].Success {
+		t.Fatalf("Expected a successful attempt, got %+v", *recorded)
+	}
+
+	// the fetch went fine, the bill didn't
+	canned.bill = bill.New("", "Maxi", time.Time{}, 10, currency.RSD, country.SERBIA, nil, tag.New(""), link, "")
+	if _, err := p.Parse(link); err == nil {
+		t.Fatal("Expected the invalid bill rejected")
+	}
+	if len(*recorded) != 2 {
+		t.Fatalf("Expected one attempt per parse, got %d", len(*recorded))
+	}
+	if a := (*recorded)[1]; a.Success || !strings.HasPrefix(a.Error, "validate: ") {
+		t.Errorf("Expected the attempt failed by the validation, got %+v", a)
+	}
+}
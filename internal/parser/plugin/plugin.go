@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"billdb/internal/parser/diagnostic"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	// maxOutputSize limits what is read from the plugin stdout
+	maxOutputSize = 10 << 20
+)
+
+// Plugin is an external parser executable.
+//
+// The scanned string is written to the plugin stdin,
+// the plugin answers with a BillJson document on stdout.
+// See README.md for the protocol description.
+type Plugin struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	// Timeout as a time.Duration string, e.g. "30s"
+	Timeout string `json:"timeout"`
+
+	// Diagnostics receives every run of the plugin, can be nil
+	Diagnostics diagnostic.Recorder `json:"-"`
+}
+
+// BillJson is the document a plugin writes to stdout
+type BillJson struct {
+	Error    string     `json:"error"`
+	Name     string     `json:"name"`
+	Date     string     `json:"date"`
+	Price    float64    `json:"price"`
+	Currency string     `json:"currency"`
+	Country  string     `json:"country"`
+	Link     string     `json:"link"`
+	Text     string     `json:"text"`
+	Items    []ItemJson `json:"items"`
+}
+
+type ItemJson struct {
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"`
+	PriceOne float64 `json:"price_one"`
+	Quantity float64 `json:"quantity"`
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Load reads plugin declarations from a JSON file with a list of plugins
+func Load(path string) ([]*Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*Plugin
+	err = json.Unmarshal(data, &plugins)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	for i, p := range plugins {
+		if p.Name == "" {
+			return nil, fmt.Errorf("plugin %d: name is empty", i)
+		}
+		if p.Command == "" {
+			return nil, fmt.Errorf("plugin %s: command is empty", p.Name)
+		}
+		if len(p.Prefixes) == 0 {
+			return nil, fmt.Errorf("plugin %s: no prefixes", p.Name)
+		}
+		if p.Timeout != "" {
+			if _, err := time.ParseDuration(p.Timeout); err != nil {
+				return nil, fmt.Errorf("plugin %s: invalid timeout: %w", p.Name, err)
+			}
+		}
+	}
+	return plugins, nil
+}
+
+// Match reports whether the plugin handles the scanned string
+func (p *Plugin) Match(data string) bool {
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) Type() string {
+	return p.Name
+}
+
+func (p *Plugin) timeout() time.Duration {
+	timeout, err := time.ParseDuration(p.Timeout)
+	if err != nil || timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+func (p *Plugin) Parse(u string) (*bill.Bill, error) {
+	d := diagnostic.NewAttempt(p.Type(), u, 1)
+	b, err := p.parse(u)
+	d.Finish(err)
+	diagnostic.Record(p.Diagnostics, d)
+	return b, err
+}
+
+func (p *Plugin) parse(u string) (*bill.Bill, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout())
+	defer cancel()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdin = strings.NewReader(u)
+	cmd.Stdout = &limitedWriter{w: &stdout, n: maxOutputSize}
+	cmd.Stderr = &limitedWriter{w: &stderr, n: maxOutputSize}
+	// children of a killed plugin may keep the pipes open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s timed out after %s", p.Name, p.timeout())
+	}
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("plugin %s failed: %s", p.Name, message)
+	}
+
+	var billJson BillJson
+	err = json.Unmarshal(stdout.Bytes(), &billJson)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid json: %w", p.Name, err)
+	}
+	if billJson.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Name, billJson.Error)
+	}
+	b, err := billJson.toBill(u)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	return b, nil
+}
+
+func (b *BillJson) toBill(u string) (*bill.Bill, error) {
+	billDate, err := parseDate(b.Date)
+	if err != nil {
+		return nil, err
+	}
+	billCurrency, err := currency.Parse(strings.ToLower(b.Currency))
+	if err != nil {
+		return nil, err
+	}
+	billCountry, err := country.Parse(strings.ToLower(b.Country))
+	if err != nil {
+		return nil, err
+	}
+	link := b.Link
+	if link == "" {
+		link = u
+	}
+
+	billId := ksuid.New().String()
+	items := make([]*item.Item, 0, len(b.Items))
+	for _, it := range b.Items {
+		items = append(items, item.New(
+			ksuid.New().String(),
+			billId,
+			it.Name,
+			it.Price,
+			it.PriceOne,
+			it.Quantity,
+		))
+	}
+
+	return bill.New(
+		billId,
+		b.Name,
+		billDate,
+		b.Price,
+		billCurrency,
+		billCountry,
+		items,
+		tag.Empty(),
+		link,
+		b.Text,
+	), nil
+}
+
+func parseDate(date string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, date)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %q", date)
+}
+
+var errOutputTooLarge = errors.New("plugin output is too large")
+
+// limitedWriter fails the command when the plugin writes too much
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > l.n {
+		return 0, errOutputTooLarge
+	}
+	l.n -= len(b)
+	return l.w.Write(b)
+}
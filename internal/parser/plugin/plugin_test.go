@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"billdb/internal/bill/currency"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParse(t *testing.T) {
+	script := writeScript(t, `read link
+cat <<JSON
+{
+  "name": "Migros",
+  "date": "2024-05-01T12:30:00Z",
+  "price": 5,
+  "currency": "TRY",
+  "country": "turkey",
+  "items": [{"name": "Milk", "price": 5, "price_one": 2.5, "quantity": 2}]
+}
+JSON
+`)
+	p := &Plugin{Name: "tr", Command: script}
+	b, err := p.Parse("https://example.com/bill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Name != "Migros" || b.Currency != currency.TRY {
+		t.Errorf("unexpected bill: %+v", b)
+	}
+	if b.Link != "https://example.com/bill" {
+		t.Errorf("link should default to the input, got %q", b.Link)
+	}
+	if len(b.Items) != 1 || b.Items[0].BillId != b.Id {
+		t.Errorf("unexpected items: %+v", b.Items)
+	}
+	if err := b.Validate(); err != nil {
+		t.Errorf("plugin bill is invalid: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"exit code", "echo broken >&2\nexit 1\n", "broken"},
+		{"invalid json", "echo not json\n", "invalid json"},
+		{"error field", `echo '{"error": "unknown bill"}'` + "\n", "unknown bill"},
+		{"unknown currency", `echo '{"name": "a", "date": "2024-05-01", "price": 1, "currency": "xxx", "country": "turkey"}'` + "\n", "xxx"},
+		{"invalid date", `echo '{"name": "a", "date": "yesterday", "price": 1, "currency": "try", "country": "turkey"}'` + "\n", "invalid date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Plugin{Name: "test", Command: writeScript(t, tt.script)}
+			_, err := p.Parse("input")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q should contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	p := &Plugin{Name: "slow", Command: writeScript(t, "sleep 5\n"), Timeout: "100ms"}
+	_, err := p.Parse("input")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugins.json")
+	os.WriteFile(path, []byte(`[{"name": "tr", "prefixes": ["https://tr"], "command": "/bin/true"}]`), 0o644)
+	plugins, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || !plugins[0].Match("https://tr/bill") || plugins[0].Match("t=1") {
+		t.Errorf("unexpected plugins: %+v", plugins)
+	}
+
+	os.WriteFile(path, []byte(`[{"name": "tr", "command": "/bin/true"}]`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("plugin without prefixes should fail to load")
+	}
+}
@@ -2,6 +2,7 @@ package russia
 
 import (
 	B "billdb/internal/bill"
+	"billdb/internal/parser/diagnostic"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 )
 
 type Parser struct {
-  Password string
+	Password string
+	// Diagnostics receives every fetch attempt, can be nil
+	Diagnostics diagnostic.Recorder
 }
 
 func (p *Parser) Type() string {
@@ -18,6 +21,14 @@ func (p *Parser) Type() string {
 }
 
 func (p *Parser) Parse(qrString string) (*B.Bill, error) {
+	d := diagnostic.NewAttempt(p.Type(), qrString, 1)
+	bill, err := p.parse(qrString, d)
+	d.Finish(err)
+	diagnostic.Record(p.Diagnostics, d)
+	return bill, err
+}
+
+func (p *Parser) parse(qrString string, d *diagnostic.Attempt) (*B.Bill, error) {
 	qrParams, err := parseQrString(qrString)
 	if err != nil {
 		return nil, err
@@ -54,6 +65,7 @@ func (p *Parser) Parse(qrString string) (*B.Bill, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	d.HTTPStatus = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("error status code: %d", resp.StatusCode)
@@ -84,5 +96,7 @@ func (p *Parser) Parse(qrString string) (*B.Bill, error) {
 	if err != nil {
 		return nil, err
 	}
+	// keep the fiscal fields, the QR code can be restored from them
+	bill.Link = qrString
 	return bill, nil
 }
@@ -189,3 +189,33 @@ func TestSendFormatData(t *testing.T) {
 		)
 	}
 }
+
+func TestReconstructQrString(t *testing.T) {
+	qrString, err := ReconstructQrString("fn=9960440300084132&i=12345&fp=3522222222&n=1&s=1250.50&t=20240501T1230&extra=1")
+	if err == nil {
+		t.Errorf("unknown parameters should fail, got %s", qrString)
+	}
+
+	qrString, err = ReconstructQrString("fn=9960440300084132&i=12345&fp=3522222222&n=1&s=1250.50&t=20240501T1230")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "t=20240501T1230&s=1250.50&fn=9960440300084132&i=12345&fp=3522222222&n=1"
+	if qrString != want {
+		t.Errorf("Expected %s, got %s", want, qrString)
+	}
+
+	qrString, err = ReconstructQrString("fn=9960440300084132&i=12345&fp=3522222222&n=1&s=1250.50&t=20240501T135400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "t=20240501T135400&s=1250.50&fn=9960440300084132&i=12345&fp=3522222222&n=1"
+	if qrString != want {
+		t.Errorf("Expected the seconds kept, %s, got %s", want, qrString)
+	}
+
+	_, err = ReconstructQrString("t=20240501T1230&s=1250.50&fn=&i=12345&fp=3522222222&n=1")
+	if err == nil {
+		t.Error("missing fiscal fields should fail")
+	}
+}
@@ -7,14 +7,21 @@ import (
 )
 
 type QrRus struct {
-	Fn   string
-	Fd   string
-	Fp   string
-	N    string
-	Sum  string
-	Time time.Time
+	Fn  string
+	Fd  string
+	Fp  string
+	N   string
+	Sum string
+	// Amount is the sum with kopecks, as written in the QR code
+	Amount string
+	Time   time.Time
+	// timeLayout is the layout t was written with, with or without seconds
+	timeLayout string
 }
 
+// layouts of the t parameter, the seconds are optional
+var qrTimeLayouts = []string{"20060102T150405", "20060102T1504"}
+
 func (qrRus *QrRus) String() string {
 	return fmt.Sprintf("Fn: %s, Fd: %s, Fp: %s, N: %s, Sum: %s, Time: %s",
 		qrRus.Fn, qrRus.Fd, qrRus.Fp, qrRus.N, qrRus.Sum, qrRus.Time)
@@ -24,6 +31,31 @@ func (qrRus *QrRus) TimeString() string {
 	return qrRus.Time.Format("02.01.2006") + " " + qrRus.Time.Format("15:04")
 }
 
+// QrString formats the fiscal fields back into the string printed in the QR code,
+// the time has its seconds when the parsed string had them
+func (qrRus *QrRus) QrString() string {
+	layout := qrRus.timeLayout
+	if layout == "" {
+		layout = qrTimeLayouts[len(qrTimeLayouts)-1]
+	}
+	return fmt.Sprintf("t=%s&s=%s&fn=%s&i=%s&fp=%s&n=%s",
+		qrRus.Time.Format(layout), qrRus.Amount, qrRus.Fn, qrRus.Fd, qrRus.Fp, qrRus.N)
+}
+
+// ReconstructQrString returns the canonical QR string for the fiscal fields
+// of a stored "t=..." string, reordered parameters are put back in order
+// and unknown ones are an error.
+func ReconstructQrString(qrString string) (string, error) {
+	qrRus, err := parseQrString(qrString)
+	if err != nil {
+		return "", err
+	}
+	if qrRus.Fn == "" || qrRus.Fd == "" || qrRus.Fp == "" || qrRus.Time.IsZero() {
+		return "", fmt.Errorf("fiscal fields are missing")
+	}
+	return qrRus.QrString(), nil
+}
+
 func setParameter(qrRus *QrRus, key string, value string) error {
 	switch key {
 	case "fn":
@@ -37,13 +69,20 @@ func setParameter(qrRus *QrRus, key string, value string) error {
 	case "s":
 		sumValue := strings.Split(value, ".")
 		qrRus.Sum = sumValue[0]
+		qrRus.Amount = value
 	case "t":
-		layout := "20060102T1504"
-		t, err := time.Parse(layout, value)
+		var err error
+		for _, layout := range qrTimeLayouts {
+			var t time.Time
+			if t, err = time.Parse(layout, value); err == nil {
+				qrRus.Time = t
+				qrRus.timeLayout = layout
+				break
+			}
+		}
 		if err != nil {
 			return fmt.Errorf("error parsing time: %v", err)
 		}
-		qrRus.Time = t
 	default:
 		return fmt.Errorf("unknown key: %s", key)
 	}
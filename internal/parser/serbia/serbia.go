@@ -6,7 +6,9 @@ import (
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
+	"billdb/internal/parser/diagnostic"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -51,8 +53,44 @@ type ItemJson struct {
 	VatAmount     float64 `json:"VatAmount"`
 }
 
+var (
+	// ErrTokenNotFound is returned when the page has no token for the items request
+	ErrTokenNotFound = errors.New("token not found in document")
+	// ErrItemsRejected is returned when the items request was refused,
+	// usually because of an expired token
+	ErrItemsRejected = errors.New("Error fetching invoce items")
+)
+
+// SelectorError is returned when a xpath selector didn't match the page
+type SelectorError struct {
+	Xpath string
+	Err   error
+}
+
+func (e *SelectorError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("error querying xpath %s: %v", e.Xpath, e.Err)
+	}
+	return fmt.Sprintf("xpath %s not found", e.Xpath)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned for an unexpected HTTP status of the items request
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
 // Parser is a parser for variant 1 of the URL.
 type Parser struct {
+	// Diagnostics receives every fetch attempt, can be nil
+	Diagnostics diagnostic.Recorder
 }
 
 func (p *Parser) Type() string {
@@ -73,15 +111,15 @@ func cleanWhiteSpace(s string) string {
 
 func queryNode(doc *html.Node, xpath string) (*html.Node, error) {
 	resultNode, err := htmlquery.Query(doc, xpath)
-	if resultNode == nil {
-		log.WithField("xpath", xpath).Error(
-			"Didn't find xpath.")
-		return nil, err
-	}
 	if err != nil {
 		log.WithField("xpath", xpath).Error(
 			"Error querying xpath")
-		return nil, err
+		return nil, &SelectorError{Xpath: xpath, Err: err}
+	}
+	if resultNode == nil {
+		log.WithField("xpath", xpath).Error(
+			"Didn't find xpath.")
+		return nil, &SelectorError{Xpath: xpath}
 	}
 	return resultNode, nil
 }
@@ -104,7 +142,7 @@ func fetchItems(
 	tokenSubmatches := pattern.FindStringSubmatch(htmlquery.InnerText(tokenNode))
 	if len(tokenSubmatches) == 0 {
 		log.Error("Error finding token string")
-		return nil, fmt.Errorf("token not found in document")
+		return nil, ErrTokenNotFound
 	}
 	// The first element is the full match
 	// The second element (index 1) is the first capture group
@@ -141,7 +179,7 @@ func fetchItems(
 
 	if postR.StatusCode != 200 {
 		log.WithField("statusCode", postR.StatusCode).Error("Error fetching items. Status code: ", postR.StatusCode)
-		return nil, fmt.Errorf("unexpected status code: %d", postR.StatusCode)
+		return nil, &StatusError{Code: postR.StatusCode}
 	}
 
 	var rJson PostResponseJson
@@ -156,7 +194,7 @@ func fetchItems(
 			WithField("Token", token).
 			WithField("invoceNumber", invoceNumber).
 			Error("Error fetching items")
-		return nil, fmt.Errorf("Error fetching invoce items")
+		return nil, ErrItemsRejected
 	}
 
 	items := make([]*item.Item, 0)
@@ -182,6 +220,29 @@ func dateParse(dateLayout string, dateString string) (*time.Time, error) {
 	return &dateTime, nil
 }
 
+// diagnose fills the attempt diagnostics from an error of the parser
+func diagnose(d *diagnostic.Attempt, err error) {
+	var selectorErr *SelectorError
+	if errors.As(err, &selectorErr) {
+		d.FailedSelector = selectorErr.Xpath
+		if selectorErr.Xpath == tokenXpath {
+			d.Token = diagnostic.TokenMissing
+		}
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		d.Token = diagnostic.TokenFound
+		d.ItemsStatus = statusErr.Code
+	}
+	switch {
+	case errors.Is(err, ErrTokenNotFound):
+		d.Token = diagnostic.TokenMissing
+	case errors.Is(err, ErrItemsRejected):
+		d.Token = diagnostic.TokenRejected
+		d.ItemsStatus = http.StatusOK
+	}
+}
+
 func (p *Parser) Parse(u string) (*bill.Bill, error) {
 	maxAttempts := 3
 	var doc *html.Node
@@ -199,6 +260,12 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 			log.WithField("attempt", attempt).Info("Refetching page for new token")
 			time.Sleep(1 * time.Second)
 		}
+		d := diagnostic.NewAttempt(p.Type(), u, attempt)
+		record := func(err error) {
+			diagnose(d, err)
+			d.Finish(err)
+			diagnostic.Record(p.Diagnostics, d)
+		}
 
 		client := &http.Client{
 			Timeout: 15 * time.Second,
@@ -206,6 +273,7 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		req, err := http.NewRequest(http.MethodGet, u, nil)
 		if err != nil {
 			log.WithField("attempt", attempt).Error("creating request: ", err)
+			record(err)
 			if attempt == maxAttempts {
 				return nil, err
 			}
@@ -218,18 +286,22 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		resp, err := client.Do(req)
 		if err != nil {
 			log.WithField("attempt", attempt).Error("request failed: ", err)
+			record(err)
 			if attempt == maxAttempts {
 				return nil, err
 			}
 			continue
 		}
+		d.HTTPStatus = resp.StatusCode
 
 		// check the HTTP status code
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
 			log.WithField("attempt", attempt).Errorf("unexpected status %d (%s) for %s", resp.StatusCode, resp.Status, u)
+			err = fmt.Errorf("bad response: %d %s", resp.StatusCode, resp.Status)
+			record(err)
 			if attempt == maxAttempts {
-				return nil, fmt.Errorf("bad response: %d %s", resp.StatusCode, resp.Status)
+				return nil, err
 			}
 			continue
 		}
@@ -239,14 +311,15 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		resp.Body.Close()
 		if err != nil {
 			log.WithField("attempt", attempt).Error("parsing HTML: ", err)
+			record(err)
 			if attempt == maxAttempts {
 				return nil, err
 			}
 			continue
 		}
 
-		// Only parse these on first attempt
-		if attempt == 1 {
+		// Only parse these on first fetched page
+		if nodesStrings == nil {
 			nodes = make(map[string]*html.Node)
 			for _, nodeXpath := range []string{
 				invoiceXpath,
@@ -257,6 +330,7 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 			} {
 				node, err := queryNode(doc, nodeXpath)
 				if err != nil {
+					record(err)
 					return nil, err
 				}
 				nodes[nodeXpath] = node
@@ -272,6 +346,8 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 			if err != nil {
 				log.WithField("date", nodesStrings[buyDateXpath]).Error(
 					"Error parsing date: ", err)
+				d.FailedSelector = buyDateXpath
+				record(err)
 				return nil, err
 			}
 
@@ -280,18 +356,22 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 			if err != nil {
 				log.WithField("priceString", priceString).Error(
 					"Error parsing price: ", err)
+				d.FailedSelector = priceXpath
+				record(err)
 				return nil, err
 			}
 
 			countryBill, err = country.Parse("serbia")
 			if err != nil {
 				log.Error("Error parsing country string: ", err)
+				record(err)
 				return nil, err
 			}
 
 			currencyBill, err = currency.Parse("rsd")
 			if err != nil {
 				log.Error("Error parsing currency string: ", err)
+				record(err)
 				return nil, err
 			}
 
@@ -301,7 +381,8 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		// Try to fetch items
 		items, err = fetchItems(doc, &billId, client)
 		if err != nil {
-			if err.Error() == "Error fetching invoce items" {
+			record(err)
+			if errors.Is(err, ErrItemsRejected) {
 				log.WithField("attempt", attempt).Error("Failed to fetch items, will retry")
 				if attempt == maxAttempts {
 					return nil, fmt.Errorf("failed to fetch items after %d attempts", maxAttempts)
@@ -314,6 +395,9 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		}
 
 		// Success! Break out of retry loop
+		d.Token = diagnostic.TokenFound
+		d.ItemsStatus = http.StatusOK
+		record(nil)
 		break
 	}
 
@@ -331,5 +415,6 @@ func (p *Parser) Parse(u string) (*bill.Bill, error) {
 		u,
 		nodesStrings[billXpath],
 	)
+	billObject.FiscalId = bill.SerbianFiscalId(nodesStrings[invoiceXpath])
 	return billObject, nil
 }
@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"billdb/internal/parser/diagnostic"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +31,43 @@ func TestCleanPrice(t *testing.T) {
 	}
 }
 
+func TestQueryNodeMissing(t *testing.T) {
+	doc, err := htmlquery.Parse(strings.NewReader("<html><head></head><body></body></html>"))
+	if err != nil {
+		t.Errorf("Failed to parse html: %v", err)
+		return
+	}
+	_, err = queryNode(doc, tokenXpath)
+	var selectorErr *SelectorError
+	if !errors.As(err, &selectorErr) {
+		t.Errorf("Expected SelectorError, got %v", err)
+		return
+	}
+	if selectorErr.Xpath != tokenXpath {
+		t.Errorf("Expected xpath %s, got %s", tokenXpath, selectorErr.Xpath)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	d := diagnostic.NewAttempt("rs", urlLink, 1)
+	diagnose(d, &SelectorError{Xpath: tokenXpath})
+	if d.FailedSelector != tokenXpath || d.Token != diagnostic.TokenMissing {
+		t.Errorf("Expected missing token at %s, got '%s' at '%s'", tokenXpath, d.Token, d.FailedSelector)
+	}
+
+	d = diagnostic.NewAttempt("rs", urlLink, 2)
+	diagnose(d, ErrItemsRejected)
+	if d.Token != diagnostic.TokenRejected {
+		t.Errorf("Expected rejected token, got '%s'", d.Token)
+	}
+
+	d = diagnostic.NewAttempt("rs", urlLink, 3)
+	diagnose(d, &StatusError{Code: 500})
+	if d.ItemsStatus != 500 {
+		t.Errorf("Expected items status 500, got %d", d.ItemsStatus)
+	}
+}
+
 func TestQueryNode(t *testing.T) {
 	xpath := "//*[@id='invoiceNumberLabel']"
 	u, err := url.Parse(urlLink)
@@ -0,0 +1,105 @@
+package qrcode
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/parser/russia"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+const (
+	DefaultSize = 256
+	MaxSize     = 2048
+	// quiet zone around the code, in modules
+	margin = 4
+)
+
+var ErrNoLink = errors.New("bill has no verification link")
+
+var encodeHints = map[gozxing.EncodeHintType]interface{}{
+	// receipts get crumpled, medium level survives some damage
+	gozxing.EncodeHintType_ERROR_CORRECTION: "M",
+	gozxing.EncodeHintType_MARGIN:           margin,
+}
+
+// Payload returns the string to encode for the stored bill.
+// Russian bills keep the fiscal fields in the link, the QR string
+// is rebuilt from them in the form the tax service expects.
+func Payload(b *bill.Bill) (string, error) {
+	link := strings.TrimSpace(b.Link)
+	if link == "" {
+		return "", ErrNoLink
+	}
+	if strings.HasPrefix(link, "t=") {
+		return russia.ReconstructQrString(link)
+	}
+	return link, nil
+}
+
+// matrix encodes the text with one pixel per module
+func matrix(text string) (*gozxing.BitMatrix, error) {
+	return qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, 0, 0, encodeHints)
+}
+
+// EncodePNG renders the text as a size x size PNG image
+func EncodePNG(text string, size int) ([]byte, error) {
+	if size <= 0 || size > MaxSize {
+		return nil, fmt.Errorf("size should be between 1 and %d", MaxSize)
+	}
+	m, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, size, size, encodeHints)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewGray(image.Rect(0, 0, m.GetWidth(), m.GetHeight()))
+	for y := 0; y < m.GetHeight(); y++ {
+		for x := 0; x < m.GetWidth(); x++ {
+			c := color.Gray{Y: 255}
+			if m.Get(x, y) {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeSVG renders the text as a scalable SVG image, one unit per module
+func EncodeSVG(text string) ([]byte, error) {
+	m, err := matrix(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		m.GetWidth(), m.GetHeight())
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, m.GetWidth(), m.GetHeight())
+	for y := 0; y < m.GetHeight(); y++ {
+		for x := 0; x < m.GetWidth(); x++ {
+			if !m.Get(x, y) {
+				continue
+			}
+			// merge the dark modules of a row into one rectangle
+			start := x
+			for x < m.GetWidth() && m.Get(x, y) {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package qrcode
+
+import (
+	"billdb/internal/bill"
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncodePNG(t *testing.T) {
+	data, err := EncodePNG(testLink, DefaultSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != DefaultSize {
+		t.Errorf("expected width %d, got %d", DefaultSize, img.Bounds().Dx())
+	}
+	qrString, err := Decode(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qrString != testLink {
+		t.Errorf("qrString %s", qrString)
+	}
+
+	if _, err := EncodePNG(testLink, MaxSize+1); err == nil {
+		t.Error("expected error for a too large size")
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	data, err := EncodeSVG("t=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("not an svg document: %s", svg)
+	}
+	// version 1 code: 21 modules and the quiet zone
+	if !strings.Contains(svg, `viewBox="0 0 29 29"`) {
+		t.Errorf("unexpected size: %s", svg[:80])
+	}
+}
+
+func TestPayload(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+		err  bool
+	}{
+		{testLink, testLink, false},
+		{" " + testLink + "\n", testLink, false},
+		{"t=20240501T1230&fn=9960440300084132&i=12345&fp=3522222222&n=1&s=1250.50", "t=20240501T1230&s=1250.50&fn=9960440300084132&i=12345&fp=3522222222&n=1", false},
+		{"t=20240501T1230&s=1250.50", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Payload(&bill.Bill{Link: tt.link})
+		if (err != nil) != tt.err {
+			t.Errorf("%s: unexpected error %v", tt.link, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.link, got, tt.want)
+		}
+	}
+
+	_, err := Payload(&bill.Bill{})
+	if !errors.Is(err, ErrNoLink) {
+		t.Errorf("expected ErrNoLink, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package qrcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// maxPdfImages limits how many embedded images are scanned in one document
+const maxPdfImages = 50
+
+// maxPdfImageSide limits the width and height of an embedded image, it
+// keeps the sizes computed from them far from overflowing an int
+const maxPdfImageSide = 10000
+
+var (
+	pdfStreamStart = regexp.MustCompile(`\d+\s+\d+\s+obj\s*(<<[\s\S]*?>>)\s*stream\r?\n`)
+	pdfImageType   = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	pdfFilter      = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/\w+)`)
+	pdfFilterName  = regexp.MustCompile(`/(\w+)`)
+	pdfPredictor   = regexp.MustCompile(`/Predictor\s+(\d+)`)
+	pdfColorSpace  = regexp.MustCompile(`/ColorSpace\s*/(\w+)`)
+	pdfImageMask   = regexp.MustCompile(`/ImageMask\s+true`)
+)
+
+// IsPdf reports whether the data is a PDF document
+func IsPdf(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// pdfImages returns the images embedded in the PDF document.
+// Only the formats receipts use are supported: JPEG and
+// uncompressed or deflated gray and RGB images, others are skipped.
+func pdfImages(data []byte) ([]image.Image, error) {
+	var images []image.Image
+	for _, m := range pdfStreamStart.FindAllSubmatchIndex(data, -1) {
+		dict := data[m[2]:m[3]]
+		if !pdfImageType.Match(dict) {
+			continue
+		}
+		end := bytes.Index(data[m[1]:], []byte("endstream"))
+		if end == -1 {
+			continue
+		}
+		stream := bytes.TrimRight(data[m[1]:m[1]+end], "\r\n")
+		img, err := pdfImage(dict, stream)
+		if err != nil {
+			continue
+		}
+		images = append(images, img)
+		if len(images) == maxPdfImages {
+			break
+		}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no supported images found in the pdf")
+	}
+	return images, nil
+}
+
+func pdfImage(dict []byte, stream []byte) (image.Image, error) {
+	var filters []string
+	if m := pdfFilter.FindSubmatch(dict); m != nil {
+		for _, f := range pdfFilterName.FindAllSubmatch(m[1], -1) {
+			filters = append(filters, string(f[1]))
+		}
+	}
+
+	for i, filter := range filters {
+		switch filter {
+		case "FlateDecode":
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				return nil, err
+			}
+			stream, err = io.ReadAll(io.LimitReader(r, 64<<20))
+			if err != nil {
+				return nil, err
+			}
+		case "DCTDecode":
+			if i != len(filters)-1 {
+				return nil, fmt.Errorf("unsupported filter chain: %v", filters)
+			}
+			return jpeg.Decode(bytes.NewReader(stream))
+		default:
+			return nil, fmt.Errorf("unsupported filter: %s", filter)
+		}
+	}
+	return rawImage(dict, stream)
+}
+
+// rawImage builds an image from the decoded samples
+func rawImage(dict []byte, samples []byte) (image.Image, error) {
+	width := dictInt(dict, "Width")
+	height := dictInt(dict, "Height")
+	bpc := dictInt(dict, "BitsPerComponent")
+	if bpc == 0 && pdfImageMask.Match(dict) {
+		bpc = 1
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("invalid image size")
+	}
+	if width > maxPdfImageSide || height > maxPdfImageSide {
+		return nil, fmt.Errorf("image is too large: %dx%d", width, height)
+	}
+	if bpc != 1 && bpc != 8 {
+		return nil, fmt.Errorf("unsupported bits per component: %d", bpc)
+	}
+	predictor := 0
+	if m := pdfPredictor.FindSubmatch(dict); m != nil {
+		predictor, _ = strconv.Atoi(string(m[1]))
+	}
+
+	components := dictComponents(dict)
+	if components == 0 {
+		// the color space can be an indirect ICC profile,
+		// the number of components is derived from the size instead
+		size := len(samples)
+		if predictor >= 10 {
+			size -= height
+		}
+		components = size * 8 / bpc / (width * height)
+	}
+	if components != 1 && components != 3 && components != 4 {
+		return nil, fmt.Errorf("unsupported number of components: %d", components)
+	}
+	if bpc == 1 && components != 1 {
+		return nil, errors.New("unsupported color bitmap")
+	}
+
+	rowSize := (width*components*bpc + 7) / 8
+	rows := samples
+	if predictor >= 10 {
+		if len(samples) < (rowSize+1)*height {
+			return nil, errors.New("image data is too short")
+		}
+		var err error
+		rows, err = unpredict(samples, rowSize, (components*bpc+7)/8, height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(rows) < rowSize*height {
+		return nil, errors.New("image data is too short")
+	}
+	return samplesImage(rows, width, height, rowSize, components, bpc), nil
+}
+
+func samplesImage(rows []byte, width, height, rowSize, components, bpc int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := rows[y*rowSize : (y+1)*rowSize]
+		for x := 0; x < width; x++ {
+			var v uint8
+			switch {
+			case bpc == 1:
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					v = 255
+				}
+			case components == 1:
+				v = row[x]
+			case components == 3:
+				v = color.GrayModel.Convert(color.RGBA{row[x*3], row[x*3+1], row[x*3+2], 255}).(color.Gray).Y
+			default:
+				v = color.GrayModel.Convert(color.CMYK{row[x*4], row[x*4+1], row[x*4+2], row[x*4+3]}).(color.Gray).Y
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+	return img
+}
+
+// unpredict reverses the PNG predictors, every row starts with the predictor type
+func unpredict(data []byte, rowSize, components, height int) ([]byte, error) {
+	out := make([]byte, rowSize*height)
+	prev := make([]byte, rowSize)
+	for y := 0; y < height; y++ {
+		row := data[y*(rowSize+1) : (y+1)*(rowSize+1)]
+		cur := out[y*rowSize : (y+1)*rowSize]
+		copy(cur, row[1:])
+		for i := range cur {
+			var left, upLeft byte
+			if i >= components {
+				left = cur[i-components]
+				upLeft = prev[i-components]
+			}
+			up := prev[i]
+			switch row[0] {
+			case 0:
+			case 1:
+				cur[i] += left
+			case 2:
+				cur[i] += up
+			case 3:
+				cur[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				cur[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("unknown predictor type: %d", row[0])
+			}
+		}
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// dictComponents returns the number of color components, 0 if unknown
+func dictComponents(dict []byte) int {
+	if pdfImageMask.Match(dict) {
+		return 1
+	}
+	m := pdfColorSpace.FindSubmatch(dict)
+	if m == nil {
+		return 0
+	}
+	switch string(m[1]) {
+	case "DeviceGray", "CalGray":
+		return 1
+	case "DeviceRGB", "CalRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	}
+	return 0
+}
+
+func dictInt(dict []byte, key string) int {
+	m := regexp.MustCompile(`/` + key + `\s+(\d+)`).FindSubmatch(dict)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(string(m[1]))
+	return n
+}
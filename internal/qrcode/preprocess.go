@@ -0,0 +1,187 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+)
+
+// maxSide is the longest side images are scaled down to before decoding.
+// Phone photos are much larger than needed and the finder pattern
+// search gets slower and less reliable with the noise.
+const maxSide = 1600
+
+// variant is a preprocessing step, applied to the grayscale image
+type variant struct {
+	name  string
+	apply func(*image.Gray) *image.Gray
+}
+
+// variants are tried in order until one of them decodes.
+// Cheap ones go first, most of the scans decode without any help.
+var variants = []variant{
+	{"gray", func(g *image.Gray) *image.Gray { return g }},
+	{"contrast", stretchContrast},
+	{"threshold", func(g *image.Gray) *image.Gray { return threshold(stretchContrast(g)) }},
+	{"blur threshold", func(g *image.Gray) *image.Gray { return threshold(boxBlur(g)) }},
+	{"rotate 90", func(g *image.Gray) *image.Gray { return rotate90(threshold(stretchContrast(g))) }},
+	{"crop", func(g *image.Gray) *image.Gray { return scaleUp(crop(stretchContrast(g), 0.6)) }},
+	{"crop threshold", func(g *image.Gray) *image.Gray { return threshold(scaleUp(crop(stretchContrast(g), 0.6))) }},
+}
+
+// grayscale converts the image to grayscale scaling it down to maxSide
+func grayscale(img image.Image) *image.Gray {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	step := 1
+	for w/step > maxSide || h/step > maxSide {
+		step++
+	}
+	g := image.NewGray(image.Rect(0, 0, w/step, h/step))
+	for y := 0; y < h/step; y++ {
+		for x := 0; x < w/step; x++ {
+			// average the step x step block
+			var sum, n int
+			for dy := 0; dy < step; dy++ {
+				for dx := 0; dx < step; dx++ {
+					c := color.GrayModel.Convert(img.At(b.Min.X+x*step+dx, b.Min.Y+y*step+dy)).(color.Gray)
+					sum += int(c.Y)
+					n++
+				}
+			}
+			g.Pix[y*g.Stride+x] = uint8(sum / n)
+		}
+	}
+	return g
+}
+
+// stretchContrast maps the darkest pixel to black and the lightest to white.
+// Thermal receipts are usually printed in faded gray on grayish paper.
+func stretchContrast(g *image.Gray) *image.Gray {
+	var hist [256]int
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+	// ignore 1% of the outliers on both sides
+	cut := len(g.Pix) / 100
+	low, high := 0, 255
+	for sum := 0; low < 255 && sum+hist[low] <= cut; low++ {
+		sum += hist[low]
+	}
+	for sum := 0; high > 0 && sum+hist[high] <= cut; high-- {
+		sum += hist[high]
+	}
+	out := image.NewGray(g.Rect)
+	if high <= low {
+		copy(out.Pix, g.Pix)
+		return out
+	}
+	for i, p := range g.Pix {
+		v := (int(p) - low) * 255 / (high - low)
+		out.Pix[i] = uint8(min(max(v, 0), 255))
+	}
+	return out
+}
+
+// threshold binarizes the image with the Otsu method
+func threshold(g *image.Gray) *image.Gray {
+	var hist [256]int
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+	total := len(g.Pix)
+	var sumAll float64
+	for i, n := range hist {
+		sumAll += float64(i * n)
+	}
+	var sumBack, best float64
+	var weightBack int
+	level := 127
+	for i, n := range hist {
+		weightBack += n
+		if weightBack == 0 {
+			continue
+		}
+		weightFore := total - weightBack
+		if weightFore == 0 {
+			break
+		}
+		sumBack += float64(i * n)
+		meanBack := sumBack / float64(weightBack)
+		meanFore := (sumAll - sumBack) / float64(weightFore)
+		between := float64(weightBack) * float64(weightFore) * (meanBack - meanFore) * (meanBack - meanFore)
+		if between > best {
+			best = between
+			level = i
+		}
+	}
+	out := image.NewGray(g.Rect)
+	for i, p := range g.Pix {
+		if int(p) > level {
+			out.Pix[i] = 255
+		}
+	}
+	return out
+}
+
+// boxBlur smooths the print dots and the paper texture with a 3x3 box
+func boxBlur(g *image.Gray) *image.Gray {
+	b := g.Rect
+	out := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var sum, n int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					p := image.Pt(x+dx, y+dy)
+					if p.In(b) {
+						sum += int(g.GrayAt(p.X, p.Y).Y)
+						n++
+					}
+				}
+			}
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return out
+}
+
+// rotate90 rotates the image clockwise
+func rotate90(g *image.Gray) *image.Gray {
+	b := g.Rect
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.Pix[x*out.Stride+(b.Dy()-1-y)] = g.Pix[y*g.Stride+x]
+		}
+	}
+	return out
+}
+
+// crop keeps the centered part of the image, fraction is the side ratio
+func crop(g *image.Gray, fraction float64) *image.Gray {
+	b := g.Rect
+	w := int(float64(b.Dx()) * fraction)
+	h := int(float64(b.Dy()) * fraction)
+	x0 := (b.Dx() - w) / 2
+	y0 := (b.Dy() - h) / 2
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		copy(out.Pix[y*out.Stride:y*out.Stride+w], g.Pix[(y0+y)*g.Stride+x0:])
+	}
+	return out
+}
+
+// scaleUp doubles the image size, small codes have too few pixels per module
+func scaleUp(g *image.Gray) *image.Gray {
+	b := g.Rect
+	if b.Dx()*2 > maxSide || b.Dy()*2 > maxSide {
+		return g
+	}
+	out := image.NewGray(image.Rect(0, 0, b.Dx()*2, b.Dy()*2))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			out.Pix[y*out.Stride+x] = g.Pix[(y/2)*g.Stride+x/2]
+		}
+	}
+	return out
+}
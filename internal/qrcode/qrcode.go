@@ -3,43 +3,207 @@ package qrcode
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"os"
-	"os/exec"
-	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiQrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var (
+	ErrNotExist    = errors.New("file is not exist")
+	ErrNotDetected = errors.New("qr code was not detected")
 )
 
+// Backend decodes QR codes from an image file
+type Backend interface {
+	Name() string
+	// ParseImage returns all the QR codes found on the image
+	ParseImage(filePath string) ([]string, error)
+}
+
+// fallback is tried when the in-process decoder fails, can be nil
+var fallback Backend
+
+// SetFallback sets the backend used when the image can't be decoded in-process.
+// Should be called once on startup.
+func SetFallback(b Backend) {
+	fallback = b
+}
+
+// ParseImage returns the string encoded in the first QR code on the image
 func ParseImage(filePath string) (string, error) {
-  _, err := os.Stat(filePath)
-  if os.IsNotExist(err) {
-    return "", errors.New("file is not exist")
-  }
+	codes, err := ParseFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return codes[0], nil
+}
 
-	cmd := exec.Command("zbarimg", filePath)
+// ParseFile returns the strings of all QR codes in the file.
+// The file is an image or a PDF document, for PDFs the embedded
+// images are scanned.
+func ParseFile(filePath string) ([]string, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	if IsPdf(data) {
+		return parsePdf(data)
+	}
 
-  var out bytes.Buffer
-  var outErr bytes.Buffer
+	codes, err := parseImage(data)
+	if err == nil {
+		return codes, nil
+	}
+	if fallback == nil {
+		return nil, err
+	}
+	codes, fallbackErr := fallback.ParseImage(filePath)
+	if fallbackErr != nil {
+		// the in-process error is more telling, unless the image format is unknown
+		if errors.Is(err, ErrNotDetected) {
+			return nil, err
+		}
+		return nil, fallbackErr
+	}
+	return codes, nil
+}
 
-	cmd.Stdout = &out
-	cmd.Stderr = &outErr
+func parseImage(data []byte) ([]string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	return DecodeAll(img)
+}
 
-  err = cmd.Run()
+func parsePdf(data []byte) ([]string, error) {
+	images, err := pdfImages(data)
 	if err != nil {
-    if "exit status 4" == err.Error() {
-      return "", errors.New("qr code was not detected")
-    }
-    return "", errors.New(string(outErr.Bytes()))
-	}
-  qrString, err := out.ReadString('\n')
-  if !strings.HasPrefix(qrString, "QR-Code:") {
-    return "", errors.New("qr code was not decoded")
-  }
-
-  qrString, found := strings.CutPrefix(qrString, "QR-Code:")
-  // just to be sure
-  if !found {
-    return "", errors.New("string from qr code was not founded")
-  }
-  qrString = strings.Trim(qrString, "\n")
-
-  return qrString, nil
+		return nil, err
+	}
+	var codes []string
+	for _, img := range images {
+		found, err := DecodeAll(img)
+		if err != nil {
+			continue
+		}
+		codes = appendUnique(codes, found...)
+	}
+	if len(codes) == 0 {
+		return nil, ErrNotDetected
+	}
+	return codes, nil
+}
+
+// Decode returns the string encoded in the QR code on the image.
+// The image is preprocessed step by step until the code is found.
+func Decode(img image.Image) (string, error) {
+	qrString, err := decode(img)
+	if err == nil {
+		return qrString, nil
+	}
+	gray := grayscale(img)
+	for _, v := range variants {
+		qrString, err = decode(v.apply(gray))
+		if err == nil {
+			return qrString, nil
+		}
+	}
+	return "", ErrNotDetected
+}
+
+// DecodeAll returns the strings of all QR codes on the image,
+// in the order they were found.
+// Unlike Decode every preprocessing step is tried, the codes of
+// several receipts on one photo rarely decode with the same one.
+func DecodeAll(img image.Image) ([]string, error) {
+	codes := decodeMultiple(img)
+	gray := grayscale(img)
+	for _, v := range variants {
+		codes = appendUnique(codes, decodeMultiple(v.apply(gray))...)
+	}
+	if len(codes) == 0 {
+		return nil, ErrNotDetected
+	}
+	return codes, nil
+}
+
+var hints = map[gozxing.DecodeHintType]interface{}{
+	gozxing.DecodeHintType_TRY_HARDER: true,
+}
+
+func binarizers(img image.Image) []gozxing.Binarizer {
+	source := gozxing.NewLuminanceSourceFromImage(img)
+	return []gozxing.Binarizer{
+		gozxing.NewHybridBinarizer(source),
+		gozxing.NewGlobalHistgramBinarizer(source),
+		// white code on dark background
+		gozxing.NewHybridBinarizer(gozxing.NewInvertedLuminanceSource(source)),
+	}
+}
+
+func decode(img image.Image) (string, error) {
+	reader := qrcode.NewQRCodeReader()
+	var lastErr error
+	for _, binarizer := range binarizers(img) {
+		bmp, err := gozxing.NewBinaryBitmap(binarizer)
+		if err != nil {
+			return "", err
+		}
+		result, err := reader.Decode(bmp, hints)
+		if err == nil {
+			return result.GetText(), nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+func decodeMultiple(img image.Image) []string {
+	reader := multiQrcode.NewQRCodeMultiReader()
+	var codes []string
+	for _, binarizer := range binarizers(img) {
+		bmp, err := gozxing.NewBinaryBitmap(binarizer)
+		if err != nil {
+			continue
+		}
+		results, _ := reader.DecodeMultiple(bmp, hints)
+		for _, result := range results {
+			codes = appendUnique(codes, result.GetText())
+		}
+	}
+	// the multi detector skips codes the single one finds,
+	// e.g. when the finder patterns are partially damaged
+	if len(codes) == 0 {
+		if qrString, err := decode(img); err == nil {
+			codes = append(codes, qrString)
+		}
+	}
+	return codes
+}
+
+func appendUnique(codes []string, found ...string) []string {
+	for _, code := range found {
+		duplicate := false
+		for _, c := range codes {
+			if c == code {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			codes = append(codes, code)
+		}
+	}
+	return codes
 }
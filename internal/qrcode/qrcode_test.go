@@ -1,15 +1,327 @@
 package qrcode
 
 import (
-  "testing"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
 
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
 )
 
+const testLink = "https://suf.purs.gov.rs/v/?vl=A1NQRFI2RVRBU1BEUjZFVEEJTgAAJk4AAEDBPQIAAAAAAAABjypuO4MAAAAAAAAAAAA"
+
+// testImage renders text as a QR code with a quiet zone on a size x size image
+func testImage(t *testing.T, text string, size int) *image.Gray {
+	t.Helper()
+	m, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, size, size, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if m.Get(x, y) {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func writePng(t *testing.T, img image.Image) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "qr.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
 func TestParseImage(t *testing.T) {
-  qrString, err := ParseImage("/home/qq/qr-test.png")
-  if err != nil {
-    t.Error(err)
-    return
-  }
-  t.Errorf("qrString %s", qrString)
+	qrString, err := ParseImage(writePng(t, testImage(t, testLink, 400)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qrString != testLink {
+		t.Errorf("qrString %s", qrString)
+	}
+}
+
+func TestParseImageErrors(t *testing.T) {
+	_, err := ParseImage(filepath.Join(t.TempDir(), "missing.png"))
+	if !errors.Is(err, ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 200, 200))
+	_, err = ParseImage(writePng(t, blank))
+	if !errors.Is(err, ErrNotDetected) {
+		t.Errorf("expected ErrNotDetected, got %v", err)
+	}
+}
+
+type fakeBackend struct{ calls int }
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) ParseImage(filePath string) ([]string, error) {
+	f.calls++
+	return []string{"t=fallback"}, nil
+}
+
+func TestParseImageFallback(t *testing.T) {
+	backend := &fakeBackend{}
+	SetFallback(backend)
+	defer SetFallback(nil)
+
+	path := filepath.Join(t.TempDir(), "qr.avif")
+	os.WriteFile(path, []byte("not an image"), 0o644)
+	qrString, err := ParseImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qrString != "t=fallback" || backend.calls != 1 {
+		t.Errorf("fallback was not used: %q, %d calls", qrString, backend.calls)
+	}
+
+	// decoded in-process, fallback is not needed
+	_, err = ParseImage(writePng(t, testImage(t, testLink, 400)))
+	if err != nil || backend.calls != 1 {
+		t.Errorf("fallback should not be called: %v, %d calls", err, backend.calls)
+	}
+}
+
+// fade imitates a faded thermal print: dark gray code on light gray paper
+func fade(g *image.Gray) *image.Gray {
+	out := image.NewGray(g.Rect)
+	for i, p := range g.Pix {
+		out.Pix[i] = 150 + p/255*40
+	}
+	return out
+}
+
+func TestDecodePreprocessing(t *testing.T) {
+	code := testImage(t, testLink, 300)
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"faded", fade(code)},
+		{"blurred", boxBlur(boxBlur(code))},
+		{"rotated", rotate90(code)},
+		{"small in a large photo", pad(code, 1200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qrString, err := Decode(tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if qrString != testLink {
+				t.Errorf("qrString %s", qrString)
+			}
+		})
+	}
+}
+
+// pad places the image in the center of a size x size white image
+func pad(g *image.Gray, size int) *image.Gray {
+	out := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range out.Pix {
+		out.Pix[i] = 255
+	}
+	offset := (size - g.Rect.Dx()) / 2
+	for y := 0; y < g.Rect.Dy(); y++ {
+		copy(out.Pix[(offset+y)*out.Stride+offset:], g.Pix[y*g.Stride:y*g.Stride+g.Rect.Dx()])
+	}
+	return out
+}
+
+func TestThreshold(t *testing.T) {
+	g := fade(testImage(t, "t=1", 100))
+	for _, p := range threshold(g).Pix {
+		if p != 0 && p != 255 {
+			t.Fatalf("pixel %d is not binary", p)
+		}
+	}
+}
+
+func TestParseZbarOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{"QR-Code:t=20240501T1230&s=100.00\n", []string{"t=20240501T1230&s=100.00"}},
+		{"QR-Code:first line\nsecond line\n", []string{"first line\nsecond line"}},
+		{"QR-Code:first\nQR-Code:second\n", []string{"first", "second"}},
+		{"EAN-13:4006381333931\nQR-Code:t=1\n", []string{"t=1"}},
+		{"QR-Code:t=1\nEAN-13:4006381333931\nQR-Code:t=2\n", []string{"t=1", "t=2"}},
+	}
+	for _, tt := range tests {
+		got, err := parseZbarOutput(tt.output)
+		if err != nil {
+			t.Errorf("%q: %v", tt.output, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.output, got, tt.want)
+		}
+	}
+	if _, err := parseZbarOutput("EAN-13:4006381333931\n"); err == nil {
+		t.Error("expected error without a qr code")
+	}
+}
+
+// sideBySide places the images next to each other on a white background
+func sideBySide(images ...*image.Gray) *image.Gray {
+	width, height := 0, 0
+	for _, img := range images {
+		width += img.Rect.Dx() + 100
+		height = max(height, img.Rect.Dy()+100)
+	}
+	out := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range out.Pix {
+		out.Pix[i] = 255
+	}
+	x := 50
+	for _, img := range images {
+		draw.Draw(out, img.Rect.Add(image.Pt(x, 50)), img, image.Point{}, draw.Src)
+		x += img.Rect.Dx() + 100
+	}
+	return out
+}
+
+func TestParseFileMultiple(t *testing.T) {
+	links := []string{"t=20240501T1230&s=100.00&fn=1&i=1&fp=1&n=1", testLink, "t=20240502T0900&s=5.00&fn=2&i=2&fp=2&n=1"}
+	img := sideBySide(testImage(t, links[0], 250), testImage(t, links[1], 300), testImage(t, links[2], 250))
+	codes, err := ParseFile(writePng(t, img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(codes)
+	slices.Sort(links)
+	if !slices.Equal(codes, links) {
+		t.Errorf("got %q, want %q", codes, links)
+	}
+}
+
+// testPdf builds a document with one image object per stream dictionary
+func testPdf(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func imageObject(dict string, stream []byte) string {
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n%s\nendstream", dict, len(stream), stream)
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// bitmap packs the image into 1 bit per pixel rows
+func bitmap(g *image.Gray) []byte {
+	rowSize := (g.Rect.Dx() + 7) / 8
+	out := make([]byte, rowSize*g.Rect.Dy())
+	for y := 0; y < g.Rect.Dy(); y++ {
+		for x := 0; x < g.Rect.Dx(); x++ {
+			if g.Pix[y*g.Stride+x] > 127 {
+				out[y*rowSize+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return out
+}
+
+func TestParseFilePdf(t *testing.T) {
+	first := "t=20240501T1230&s=100.00&fn=1&i=1&fp=1&n=1"
+	gray := testImage(t, first, 300)
+	second := testImage(t, testLink, 305)
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, second, nil); err != nil {
+		t.Fatal(err)
+	}
+	pdf := testPdf(
+		"<< /Type /Catalog >>",
+		imageObject("/Width 300 /Height 300 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", deflate(t, gray.Pix)),
+		imageObject("/Width 305 /Height 305 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode", jpegData.Bytes()),
+		// same code again as a bitmap, reported once
+		imageObject("/Width 300 /Height 300 /ImageMask true /Filter /FlateDecode", deflate(t, bitmap(gray))),
+	)
+	path := filepath.Join(t.TempDir(), "receipt.pdf")
+	os.WriteFile(path, pdf, 0o644)
+
+	codes, err := ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(codes, []string{first, testLink}) {
+		t.Errorf("got %q", codes)
+	}
+}
+
+func TestParseFilePdfWithoutImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pdf")
+	os.WriteFile(path, testPdf("<< /Type /Catalog >>"), 0o644)
+	if _, err := ParseFile(path); err == nil {
+		t.Error("expected error for a pdf without images")
+	}
+}
+
+func TestRawImageSize(t *testing.T) {
+	for _, dict := range []string{
+		// width*height overflows to 0 without a color space
+		"/Width 4294967296 /Height 4294967296 /BitsPerComponent 8",
+		// rowSize*height wraps to a small size
+		"/Width 3074457345618258603 /Height 4 /ColorSpace /DeviceRGB /BitsPerComponent 8",
+		"/Width 10001 /Height 10 /ColorSpace /DeviceGray /BitsPerComponent 8",
+		"/Width 99999999999999999999999 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8",
+	} {
+		if _, err := rawImage([]byte(dict), make([]byte, 64)); err == nil {
+			t.Errorf("%s: expected the image refused", dict)
+		}
+	}
+	if _, err := rawImage([]byte("/Width 8 /Height 8 /ColorSpace /DeviceGray /BitsPerComponent 8"), make([]byte, 64)); err != nil {
+		t.Errorf("Expected a small image decoded, got %v", err)
+	}
+}
+
+func TestUnpredict(t *testing.T) {
+	// two rows of 3 gray pixels: "sub" and "up" predictors
+	data := []byte{1, 10, 5, 5, 2, 1, 1, 1}
+	got, err := unpredict(data, 3, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{10, 15, 20, 11, 16, 21}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
 }
@@ -0,0 +1,87 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// zbarimg exits with 4 when no symbols were found
+const zbarNotFoundCode = 4
+
+// Zbar decodes images with the zbarimg executable.
+// Needs zbar (and imagemagick for formats like avif) to be installed.
+type Zbar struct {
+	// Path to the executable, "zbarimg" from PATH if empty
+	Path string
+}
+
+func (z *Zbar) Name() string {
+	return "zbar"
+}
+
+func (z *Zbar) ParseImage(filePath string) ([]string, error) {
+	path := z.Path
+	if path == "" {
+		path = "zbarimg"
+	}
+	cmd := exec.Command(path, "--quiet", filePath)
+
+	var out bytes.Buffer
+	var outErr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &outErr
+
+	err := cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == zbarNotFoundCode {
+			return nil, ErrNotDetected
+		}
+		if outErr.Len() == 0 {
+			return nil, err
+		}
+		return nil, errors.New(strings.TrimSpace(outErr.String()))
+	}
+	return parseZbarOutput(out.String())
+}
+
+// parseZbarOutput returns all QR codes from the zbarimg output.
+// Decoded data can span several lines, the symbol prefix only
+// marks the first one.
+func parseZbarOutput(output string) ([]string, error) {
+	var codes []string
+	for {
+		_, rest, found := strings.Cut(output, "QR-Code:")
+		if !found {
+			break
+		}
+		qrString := rest
+		// the next symbol starts with its own prefix
+		if i := nextSymbol(rest); i != -1 {
+			qrString = rest[:i]
+			output = rest[i:]
+		} else {
+			output = ""
+		}
+		codes = append(codes, strings.TrimRight(qrString, "\n"))
+	}
+	if len(codes) == 0 {
+		return nil, errors.New("qr code was not decoded")
+	}
+	return codes, nil
+}
+
+var zbarSymbols = []string{"\nQR-Code:", "\nEAN-13:", "\nEAN-8:", "\nCODE-128:", "\nCODE-39:", "\nI2/5:", "\nUPC-A:", "\nUPC-E:", "\nPDF417:"}
+
+func nextSymbol(s string) int {
+	next := -1
+	for _, symbol := range zbarSymbols {
+		i := strings.Index(s, symbol)
+		if i != -1 && (next == -1 || i < next) {
+			next = i
+		}
+	}
+	return next
+}
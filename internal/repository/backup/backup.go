@@ -0,0 +1,314 @@
+// Package backup copies the SQLite database with the online backup API,
+// which gives a consistent copy while the server keeps writing to it.
+// The copies are kept in a directory, the scheduled ones are pruned by a
+// daily, weekly and monthly retention policy, only the last copies made
+// before a restore, upload or merge are kept, and any of them can be
+// restored into the live database.
+package backup
+
+import (
+	repository "billdb/internal/repository/bill"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type Kind string
+
+const (
+	Scheduled Kind = "scheduled"
+	Manual    Kind = "manual"
+	// Restore is the copy of the database made before a restore replaces it
+	Restore Kind = "restore"
+	// Upload is the copy of the database made before an upload replaces it
+	Upload Kind = "upload"
+	// Merge is the copy of the database made before a merge changes it
+	Merge Kind = "merge"
+)
+
+// timeLayout is the UTC time in the backup file names
+const timeLayout = "20060102T150405.000Z"
+
+const ext = ".db"
+
+// busyWait is how long a copy waits for the lock of a busy database
+const busyWait = 10 * time.Millisecond
+
+// Backup is a copy of the database in the backup directory
+type Backup struct {
+	Name string
+	Kind Kind
+	Time time.Time
+	Size int64
+}
+
+// Manager makes, lists and restores the backups of db kept in dir
+type Manager struct {
+	db     *sql.DB
+	dir    string
+	policy Policy
+
+	// mu serializes the backups and restores
+	mu sync.Mutex
+}
+
+func New(db *sql.DB, dir string, policy Policy) *Manager {
+	return &Manager{db: db, dir: dir, policy: policy}
+}
+
+// Dir returns the directory of the backups
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Policy returns the retention of the backups
+func (m *Manager) Policy() Policy {
+	return m.policy
+}
+
+// Copy writes a consistent copy of the database to path, which must not exist
+func (m *Manager) Copy(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup: %s already exists", path)
+	}
+	dst, err := repository.OpenSqlite(path)
+	if err != nil {
+		return err
+	}
+	err = copyDatabase(ctx, dst, m.db)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// Create makes a backup of the kind in the backup directory
+func (m *Manager) Create(ctx context.Context, kind Kind) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(ctx, kind, time.Now())
+}
+
+func (m *Manager) create(ctx context.Context, kind Kind, now time.Time) (*Backup, error) {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, err
+	}
+	// copy under a temporary name, so a partial copy is never listed
+	tmp, err := os.MkdirTemp(m.dir, ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	name := string(kind) + "-" + now.UTC().Format(timeLayout) + ext
+	path := filepath.Join(tmp, name)
+	if err := m.Copy(ctx, path); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, name)); err == nil {
+		return nil, fmt.Errorf("backup: %s already exists", name)
+	}
+	if err := os.Rename(path, filepath.Join(m.dir, name)); err != nil {
+		return nil, err
+	}
+	if kind == Restore || kind == Upload || kind == Merge {
+		if err := m.prune(kind); err != nil {
+			return nil, err
+		}
+	}
+	return m.stat(name)
+}
+
+// List returns the backups, newest first
+func (m *Manager) List() ([]*Backup, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []*Backup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, _, ok := parseName(e.Name()); !ok {
+			continue
+		}
+		b, err := m.stat(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, b)
+	}
+	slices.SortFunc(backups, func(a, b *Backup) int {
+		return b.Time.Compare(a.Time)
+	})
+	return backups, nil
+}
+
+// Path returns the file of the backup named name
+func (m *Manager) Path(name string) (string, error) {
+	if _, _, ok := parseName(name); !ok || filepath.Base(name) != name {
+		return "", fmt.Errorf("backup %s: %w", name, repository.ErrNotFound)
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("backup %s: %w", name, repository.ErrNotFound)
+	}
+	return path, nil
+}
+
+// Delete removes the backup named name
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path, err := m.Path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Restore replaces the live database by the backup named name,
+// see swap. The database is backed up first, that backup is returned.
+func (m *Manager) Restore(ctx context.Context, name string) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path, err := m.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// migrations are applied to a copy, the backup stays as it was
+	token, err := m.stage(f)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(m.stagedPath(token))
+	previous, err := m.swap(ctx, m.stagedPath(token), Restore)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: %w", name, err)
+	}
+	return previous, nil
+}
+
+// Verify runs the integrity check of SQLite on db
+func Verify(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (m *Manager) stat(name string) (*Backup, error) {
+	kind, t, _ := parseName(name)
+	info, err := os.Stat(filepath.Join(m.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &Backup{Name: name, Kind: kind, Time: t, Size: info.Size()}, nil
+}
+
+// parseName returns the kind and time of a backup file name
+func parseName(name string) (Kind, time.Time, bool) {
+	base, ok := strings.CutSuffix(name, ext)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	kind, stamp, ok := strings.Cut(base, "-")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	switch Kind(kind) {
+	case Scheduled, Manual, Restore, Upload, Merge:
+	default:
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(timeLayout, stamp)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return Kind(kind), t, true
+}
+
+// copyDatabase replaces the main database of dst by the one of src
+// with the online backup API, waiting while either one is locked
+func copyDatabase(ctx context.Context, dst *sql.DB, src *sql.DB) error {
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			d, ok := dstDriver.(*sqlite3.SQLiteConn)
+			s, ok2 := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("backup: not a SQLite connection")
+			}
+			b, err := d.Backup("main", s, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := b.Step(-1)
+				if err != nil {
+					b.Finish()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+				// busy or locked, try again
+				select {
+				case <-ctx.Done():
+					b.Finish()
+					return ctx.Err()
+				case <-time.After(busyWait):
+				}
+			}
+		})
+	})
+}
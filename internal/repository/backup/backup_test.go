@@ -0,0 +1,301 @@
+package backup
+
+import (
+	repository "billdb/internal/repository/bill"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := repository.OpenSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := repository.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func insertBill(t *testing.T, db *sql.DB, id string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO invoice VALUES (?, 'Shop', '2024-01-02', 30, 'rsd', 'serbia', ?, '', NULL, NULL)`,
+		id, "link-"+id)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countBills(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM invoice").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), DefaultPolicy)
+	ctx := context.Background()
+
+	insertBill(t, db, "a")
+	b, err := m.Create(ctx, Manual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertBill(t, db, "b")
+
+	backups, err := m.List()
+	if err != nil || len(backups) != 1 || backups[0].Name != b.Name || backups[0].Kind != Manual {
+		t.Fatalf("Expected the manual backup listed, got %v, %v", backups, err)
+	}
+	path, err := m.Path(b.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countBills(t, openDB(t, path)); n != 1 {
+		t.Errorf("Expected the bill of the backup, got %d", n)
+	}
+
+	previous, err := m.Restore(ctx, b.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Kind != Restore {
+		t.Errorf("Expected a restore backup, got %v", previous)
+	}
+	if n := countBills(t, db); n != 1 {
+		t.Errorf("Expected the backup restored, got %d bills", n)
+	}
+	if _, err := m.Restore(ctx, previous.Name); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBills(t, db); n != 2 {
+		t.Errorf("Expected the previous database restored, got %d bills", n)
+	}
+
+	for _, name := range []string{"../billdb.sqlite", "manual-today.db", "missing-20240101T000000.000Z.db"} {
+		if _, err := m.Path(name); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("%s: expected not found, got %v", name, err)
+		}
+	}
+	if err := m.Delete(b.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the backup deleted, got %v", err)
+	}
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), DefaultPolicy)
+	insertBill(t, db, "a")
+
+	if err := os.MkdirAll(m.Dir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	name := "manual-20240101T000000.000Z.db"
+	if err := os.WriteFile(filepath.Join(m.Dir(), name), []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Restore(context.Background(), name); err == nil {
+		t.Fatal("Expected the corrupt backup rejected")
+	}
+	if n := countBills(t, db); n != 1 {
+		t.Errorf("Expected the database untouched, got %d bills", n)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), Policy{Daily: 2, Weekly: 2, Monthly: 2})
+	ctx := context.Background()
+
+	// a backup every day from sunday 2024-02-25 to tuesday 2024-03-05
+	start := time.Date(2024, 2, 25, 12, 0, 0, 0, time.Local)
+	for day := 0; day < 10; day++ {
+		created, _, err := m.Schedule(ctx, start.AddDate(0, 0, day))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created == nil {
+			t.Fatalf("Expected a backup on day %d", day)
+		}
+	}
+	created, _, err := m.Schedule(ctx, start.AddDate(0, 0, 9).Add(time.Hour))
+	if err != nil || created != nil {
+		t.Fatalf("Expected no second backup on the same day, got %v, %v", created, err)
+	}
+	if _, err := m.Create(ctx, Manual); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var days []string
+	manual := 0
+	for _, b := range backups {
+		if b.Kind == Manual {
+			manual++
+			continue
+		}
+		days = append(days, b.Time.Local().Format("2006-01-02"))
+	}
+	// the last 2 days, the last day of each of the last 2 weeks
+	// and of each of the last 2 months
+	want := []string{"2024-03-05", "2024-03-04", "2024-03-03", "2024-02-29"}
+	if len(days) != len(want) {
+		t.Fatalf("Expected %v kept, got %v", want, days)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Fatalf("Expected %v kept, got %v", want, days)
+		}
+	}
+	if manual != 1 {
+		t.Errorf("Expected the manual backup kept, got %d", manual)
+	}
+}
+
+func TestPruneSafety(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), Policy{Safety: 2})
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		for _, kind := range []Kind{Restore, Upload, Merge, Manual} {
+			if _, err := m.create(ctx, kind, start.Add(time.Duration(i)*time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := make(map[Kind][]time.Time)
+	for _, b := range backups {
+		kept[b.Kind] = append(kept[b.Kind], b.Time)
+	}
+	for _, kind := range []Kind{Restore, Upload, Merge} {
+		times := kept[kind]
+		if len(times) != 2 || !times[0].Equal(start.Add(3*time.Minute)) || !times[1].Equal(start.Add(2*time.Minute)) {
+			t.Errorf("Expected the last 2 %s backups kept, got %v", kind, times)
+		}
+	}
+	if len(kept[Manual]) != 4 {
+		t.Errorf("Expected the manual backups kept, got %d", len(kept[Manual]))
+	}
+}
+
+func TestStageAndApply(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), DefaultPolicy)
+	ctx := context.Background()
+
+	uploadPath := filepath.Join(dir, "upload.sqlite")
+	upload := openDB(t, uploadPath)
+	insertBill(t, upload, "a")
+	insertBill(t, upload, "b")
+	upload.Close()
+
+	f, err := os.Open(uploadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	staged, err := m.Stage(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staged.Stats.Bills != 2 || staged.Stats.First != "2024-01-02" || len(staged.Stats.Pending) != 0 {
+		t.Errorf("Expected the stats of the upload, got %+v", staged.Stats)
+	}
+	if backups, _ := m.List(); len(backups) != 0 {
+		t.Errorf("Expected the upload hidden from the backups, got %v", backups)
+	}
+
+	previous, err := m.Apply(ctx, staged.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Kind != Upload {
+		t.Errorf("Expected an upload backup, got %v", previous)
+	}
+	if n := countBills(t, db); n != 2 {
+		t.Errorf("Expected the upload in use, got %d bills", n)
+	}
+	if _, err := m.Apply(ctx, staged.Token); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected the upload applied once, got %v", err)
+	}
+	if err := m.Discard("../billdb"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected an invalid token rejected, got %v", err)
+	}
+}
+
+func TestStageInvalid(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, filepath.Join(dir, "billdb.sqlite"))
+	m := New(db, filepath.Join(dir, "backups"), DefaultPolicy)
+	ctx := context.Background()
+
+	other, err := repository.OpenSqlite(filepath.Join(dir, "other.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec(`CREATE TABLE notes (text TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+	newer := openDB(t, filepath.Join(dir, "newer.sqlite"))
+	if _, err := newer.Exec(`INSERT INTO migration (name) VALUES ('999_future.sql')`); err != nil {
+		t.Fatal(err)
+	}
+	newer.Close()
+
+	for _, c := range []struct {
+		name string
+		path string
+		want string
+	}{
+		{"not sqlite", "", "not a database"},
+		{"other schema", "other.sqlite", "not a bill database"},
+		{"newer schema", "newer.sqlite", "999_future.sql"},
+	} {
+		content := []byte("not a database, but long enough to look like a header of a file")
+		if c.path != "" {
+			content, err = os.ReadFile(filepath.Join(dir, c.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := m.Stage(ctx, strings.NewReader(string(content)))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected an error with %q, got %v", c.name, c.want, err)
+		}
+	}
+	staged, _ := filepath.Glob(filepath.Join(m.Dir(), stagedPrefix+"*"))
+	if len(staged) != 0 {
+		t.Errorf("Expected the rejected uploads deleted, got %v", staged)
+	}
+}
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Policy is how many scheduled backups are kept: the newest backup of
+// each of the last Daily days, Weekly ISO weeks and Monthly months.
+// A backup kept by any of them stays.
+// Safety is how many copies made before a restore, upload or merge are
+// kept of each kind, the older ones are deleted when a new one is made,
+// zero keeps them all. Backups made by hand are never deleted.
+type Policy struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+	Safety  int
+}
+
+var DefaultPolicy = Policy{Daily: 7, Weekly: 4, Monthly: 12, Safety: 10}
+
+// Enabled returns false when the policy keeps no backup,
+// then no scheduled backups are made
+func (p Policy) Enabled() bool {
+	return p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+// expired returns the backups, newest first, that the policy doesn't keep
+func (p Policy) expired(backups []*Backup) []*Backup {
+	periods := []struct {
+		keep   int
+		period func(t time.Time) string
+	}{
+		{p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	kept := make(map[*Backup]bool)
+	for _, r := range periods {
+		seen := make(map[string]bool)
+		for _, b := range backups {
+			if len(seen) >= r.keep {
+				break
+			}
+			period := r.period(b.Time.Local())
+			if !seen[period] {
+				seen[period] = true
+				kept[b] = true
+			}
+		}
+	}
+	var expired []*Backup
+	for _, b := range backups {
+		if !kept[b] {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// Schedule makes a scheduled backup unless one was made on the day of now,
+// then deletes the scheduled backups the policy doesn't keep.
+// It returns the new backup, nil when none was due, and the deleted ones.
+func (m *Manager) Schedule(ctx context.Context, now time.Time) (*Backup, []*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	backups, err := m.scheduled()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var created *Backup
+	day := now.Local().Format("2006-01-02")
+	if len(backups) == 0 || backups[0].Time.Local().Format("2006-01-02") != day {
+		created, err = m.create(ctx, Scheduled, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		backups = append([]*Backup{created}, backups...)
+	}
+
+	expired := m.policy.expired(backups)
+	for _, b := range expired {
+		if err := os.Remove(filepath.Join(m.dir, b.Name)); err != nil {
+			return created, nil, err
+		}
+	}
+	return created, expired, nil
+}
+
+// scheduled returns the scheduled backups, newest first
+func (m *Manager) scheduled() ([]*Backup, error) {
+	backups, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	var scheduled []*Backup
+	for _, b := range backups {
+		if b.Kind == Scheduled {
+			scheduled = append(scheduled, b)
+		}
+	}
+	return scheduled, nil
+}
+
+// prune deletes the backups of the safety kind beyond the newest
+// m.policy.Safety ones, it keeps them all when Safety isn't positive
+func (m *Manager) prune(kind Kind) error {
+	if m.policy.Safety <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, b := range backups {
+		if b.Kind != kind {
+			continue
+		}
+		if kept < m.policy.Safety {
+			kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, b.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
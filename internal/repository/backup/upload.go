@@ -0,0 +1,269 @@
+package backup
+
+import (
+	repository "billdb/internal/repository/bill"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stagedPrefix starts the names of the uploaded databases waiting for
+// a confirmation, they are hidden from List
+const stagedPrefix = ".upload-"
+
+// stagedTTL is how long an unconfirmed upload is kept
+const stagedTTL = 24 * time.Hour
+
+// maxIdleConns is the default of database/sql, restored after the pool
+// dropped its connections
+const maxIdleConns = 2
+
+var ErrNotBillDb = errors.New("not a bill database")
+
+// Stats describe the content of a database for the upload preview
+type Stats struct {
+	Bills int
+	Items int
+	Tags  int
+	// First and Last are the dates of the oldest and newest bill
+	First string
+	Last  string
+	// Pending are the migrations the database lacks,
+	// they are applied when it replaces the live one
+	Pending []string
+}
+
+// Staged is an uploaded database waiting for a confirmation
+type Staged struct {
+	Token string
+	Stats *Stats
+}
+
+// Inspect checks the integrity of db, that it is a bill database this
+// version can migrate, and returns its stats.
+// It may create the migration table of a database from before it existed.
+func Inspect(ctx context.Context, db *sql.DB) (*Stats, error) {
+	if err := Verify(ctx, db); err != nil {
+		return nil, err
+	}
+	var isBillDb bool
+	err := db.QueryRowContext(ctx,
+		`SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'invoice'`).Scan(&isBillDb)
+	if err != nil {
+		return nil, err
+	}
+	if !isBillDb {
+		return nil, ErrNotBillDb
+	}
+
+	migrator := repository.NewMigrator(db)
+	unknown, err := migrator.Unknown()
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("the database was migrated by a newer version: %s", strings.Join(unknown, ", "))
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := readStats(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	stats.Pending = pending
+	return stats, nil
+}
+
+// Stats returns the stats of the live database
+func (m *Manager) Stats(ctx context.Context) (*Stats, error) {
+	return readStats(ctx, m.db)
+}
+
+// readStats counts the rows of the tables found in every schema version
+func readStats(ctx context.Context, db *sql.DB) (*Stats, error) {
+	s := &Stats{}
+	var first, last sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT
+		(SELECT count(*) FROM invoice),
+		(SELECT count(*) FROM item),
+		(SELECT count(*) FROM tag),
+		(SELECT min(invoice_date) FROM invoice),
+		(SELECT max(invoice_date) FROM invoice)`).Scan(&s.Bills, &s.Items, &s.Tags, &first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotBillDb, err)
+	}
+	s.First = first.String
+	s.Last = last.String
+	return s, nil
+}
+
+// Stage keeps an uploaded database in a file of the backup directory
+// named by the server and checks it with Inspect. Apply replaces the
+// live database by it and Discard deletes it.
+func (m *Manager) Stage(ctx context.Context, r io.Reader) (*Staged, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeStale(time.Now())
+	token, err := m.stage(r)
+	if err != nil {
+		return nil, err
+	}
+	path := m.stagedPath(token)
+	stats, err := inspectFile(ctx, path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &Staged{Token: token, Stats: stats}, nil
+}
+
+// Apply replaces the live database by the upload of token, see swap.
+// The database is backed up first, that backup is returned.
+func (m *Manager) Apply(ctx context.Context, token string) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path, err := m.staged(token)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	return m.swap(ctx, path, Upload)
+}
+
+// OpenStaged opens the upload of token migrated to the schema of this
+// version, to read its bills instead of replacing the live database
+func (m *Manager) OpenStaged(ctx context.Context, token string) (*sql.DB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path, err := m.staged(token)
+	if err != nil {
+		return nil, err
+	}
+	db, err := repository.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repository.NewMigrator(db).Up(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating the database: %w", err)
+	}
+	return db, nil
+}
+
+// Discard deletes the upload of token
+func (m *Manager) Discard(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path, err := m.staged(token)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// swap replaces the live database by the one of path. The file is
+// checked again and migrated, then the live database is backed up as
+// kind and the file copied into it with the online backup API, in a
+// single write transaction of SQLite: the open connections see either
+// database and a failure leaves the live one as it was, which renaming
+// the file over the live one can't give. The pool then drops its
+// connections, so every query after the swap opens a new one.
+func (m *Manager) swap(ctx context.Context, path string, kind Kind) (*Backup, error) {
+	src, err := repository.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if _, err := Inspect(ctx, src); err != nil {
+		return nil, err
+	}
+	if _, err := repository.NewMigrator(src).Up(); err != nil {
+		return nil, fmt.Errorf("migrating the database: %w", err)
+	}
+	// a WAL header would be copied into the live database
+	if _, err := src.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return nil, err
+	}
+
+	previous, err := m.create(ctx, kind, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("backing up the database: %w", err)
+	}
+	if err := copyDatabase(ctx, m.db, src); err != nil {
+		return nil, err
+	}
+	m.db.SetMaxIdleConns(0)
+	m.db.SetMaxIdleConns(maxIdleConns)
+	return previous, nil
+}
+
+// stage copies r into a new staged file and returns its token
+func (m *Manager) stage(r io.Reader) (string, error) {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return "", err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	f, err := os.OpenFile(m.stagedPath(token), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(m.stagedPath(token))
+		return "", err
+	}
+	return token, nil
+}
+
+// staged returns the file of the upload of token
+func (m *Manager) staged(token string) (string, error) {
+	if _, err := hex.DecodeString(token); err != nil || len(token) != 32 {
+		return "", fmt.Errorf("upload %s: %w", token, repository.ErrNotFound)
+	}
+	path := m.stagedPath(token)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("upload %s: %w", token, repository.ErrNotFound)
+	}
+	return path, nil
+}
+
+func (m *Manager) stagedPath(token string) string {
+	return filepath.Join(m.dir, stagedPrefix+token+ext)
+}
+
+// removeStale deletes the uploads left unconfirmed for longer than stagedTTL
+func (m *Manager) removeStale(now time.Time) {
+	paths, _ := filepath.Glob(filepath.Join(m.dir, stagedPrefix+"*"+ext))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > stagedTTL {
+			os.Remove(path)
+		}
+	}
+}
+
+// inspectFile runs Inspect on the database file of path
+func inspectFile(ctx context.Context, path string) (*Stats, error) {
+	db, err := repository.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return Inspect(ctx, db)
+}
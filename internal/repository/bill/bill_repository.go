@@ -1,26 +1,81 @@
 package repository
 
 import (
+	"billdb/internal/audit"
 	bl "billdb/internal/bill"
 	"billdb/internal/bill/item"
-	"database/sql"
+	"context"
+	"time"
 )
 
 type BillRepository interface {
-	GetDb() *sql.DB
-	ApplyMigration(sqlFilePath string) error
-	CheckDuplicateBill(bill *bl.Bill) (int, error)
-	CheckDuplicateBillByUrl(url string) (int, error)
-	InsertBill(bill *bl.Bill) error
-	InsertBillWithItems(bill *bl.Bill) error
-	GetBillByID(id string) (*bl.Bill, error)
-	UpdateBill(bill *bl.Bill) error
-	DeleteBill(id string) error
-	InsertItems(items []*item.Item) error
-	GetItemsByID(billId string) ([]*item.Item, error)
-	UpdateItems(items []*item.Item) error
-	DeleteItems(items []*item.Item) error
-	GetCurrencies() ([]string, error)
-	GetCountries() ([]string, error)
-	GetTags() ([]string, error)
+	ApplyMigration(ctx context.Context, sqlFilePath string) error
+	// DuplicateCandidates returns the bills but b that may be its receipt:
+	// those with its link or fiscal key and those in its currency of the
+	// days around its date, without items
+	DuplicateCandidates(ctx context.Context, b *bl.Bill) ([]*bl.Bill, error)
+	// DismissDuplicate records that two bills found alike are different
+	// receipts, see DismissedDuplicates
+	DismissDuplicate(ctx context.Context, id string, other string) error
+	DismissedDuplicates(ctx context.Context) ([]DuplicatePair, error)
+	InsertBill(ctx context.Context, bill *bl.Bill) error
+	InsertBillWithItems(ctx context.Context, bill *bl.Bill) error
+	GetBillByID(ctx context.Context, id string) (*bl.Bill, error)
+	UpdateBill(ctx context.Context, bill *bl.Bill) error
+	// UpdateTag links the stored bill of bill.Id to bill.Tag, or unlinks
+	// it when the tag isn't valid, the other fields of bill are ignored
+	UpdateTag(ctx context.Context, bill *bl.Bill) error
+	// DeleteBill moves a bill to the trash, it is hidden with its items
+	// from the listings, searches and stats until it is restored or purged
+	DeleteBill(ctx context.Context, id string) error
+	RestoreBill(ctx context.Context, id string) error
+	ListTrash(ctx context.Context) ([]*TrashedBill, error)
+	// PurgeBill deletes a bill of the trash for good
+	PurgeBill(ctx context.Context, id string) error
+	// PurgeTrash deletes for good the bills deleted before the time
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	InsertItems(ctx context.Context, items []*item.Item) error
+	GetItemsByID(ctx context.Context, billId string) ([]*item.Item, error)
+	UpdateItems(ctx context.Context, items []*item.Item) error
+	DeleteItems(ctx context.Context, items []*item.Item) error
+	GetCurrencies(ctx context.Context) ([]string, error)
+	GetCountries(ctx context.Context) ([]string, error)
+	GetTags(ctx context.Context) ([]string, error)
+	// ListBills returns bills without items and journal, BillText is
+	// empty: they are not to be written back, GetBillByID returns the
+	// whole bill
+	ListBills(ctx context.Context, filter BillFilter) ([]*bl.Bill, error)
+	ListItems(ctx context.Context, filter ItemFilter) ([]*ItemWithBill, error)
+	// SearchBills and SearchItems are ListBills and ListItems with
+	// the snippets of the full-text search and the cursor of the next page
+	SearchBills(ctx context.Context, filter BillFilter) (*BillPage, error)
+	SearchItems(ctx context.Context, filter ItemFilter) (*ItemPage, error)
+	// History returns the audit log of a bill and its items, the last change first
+	History(ctx context.Context, billId string) ([]*audit.Entry, error)
+	GetAuditEntry(ctx context.Context, id int64) (*audit.Entry, error)
+	// Revert undoes a single change of the audit log
+	Revert(ctx context.Context, id int64) error
+	// WithTx runs fn as one unit of work, writes made through tx
+	// are committed together or not at all
+	WithTx(ctx context.Context, fn func(tx BillRepository) error) error
+}
+
+// AllBills returns every bill of the repository but the trash, without
+// items and journal as ListBills, the oldest first
+func AllBills(ctx context.Context, repo BillRepository) ([]*bl.Bill, error) {
+	var bills []*bl.Bill
+	filter := BillFilter{Sort: SortDateAsc, Limit: MaxPageSize}
+	for {
+		page, err := repo.SearchBills(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range page.Hits {
+			bills = append(bills, hit.Bill)
+		}
+		if page.Next == "" {
+			return bills, nil
+		}
+		filter.Cursor = page.Next
+	}
 }
@@ -0,0 +1,575 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// testBillRepository is the behaviour shared by the BillRepository
+// implementations, open returns a repository on an empty migrated database
+func testBillRepository(t *testing.T, open func(t *testing.T) BillRepository) {
+	ctx := audit.WithSource(context.Background(), audit.SourceWeb)
+
+	t.Run("Bills", func(t *testing.T) {
+		repo := open(t)
+		b := newSearchBill("Maxi", "food", "milk", "bread")
+		b.Link = "https://suf.purs.gov.rs/v/?vl=maxi"
+		b.BillText = "ПФР време: 01.05.2024."
+		if err := repo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatalf("InsertBillWithItems: %v", err)
+		}
+		if err := repo.InsertBill(ctx, b); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("InsertBill twice: expected ErrDuplicate, got %v", err)
+		}
+		got, err := repo.GetBillByID(ctx, b.Id)
+		if err != nil {
+			t.Fatalf("GetBillByID: %v", err)
+		}
+		if got.Name != b.Name || got.Price != b.Price || got.Tag.String != "food" ||
+			got.Link != b.Link || got.BillText != b.BillText || !got.Date.Equal(b.Date) ||
+			got.Currency != b.Currency || got.Country != b.Country {
+			t.Errorf("Expected the stored bill %+v, got %+v", b, got)
+		}
+		items, err := repo.GetItemsByID(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := itemNames(items); len(names) != 2 || !slices.Contains(names, "milk") || !slices.Contains(names, "bread") {
+			t.Errorf("Expected milk and bread, got %v", names)
+		}
+
+		b.Name = "Maxi 24"
+		b.Price = 250.5
+		b.Tag = tag.New("home")
+		if err := repo.UpdateBill(ctx, b); err != nil {
+			t.Fatalf("UpdateBill: %v", err)
+		}
+		got, err = repo.GetBillByID(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Maxi 24" || got.Price != 250.5 || got.Tag.String != "home" {
+			t.Errorf("Expected the updated bill, got %+v", got)
+		}
+		b.Tag = tag.New("food")
+		if err := repo.UpdateBill(ctx, b); err != nil {
+			t.Fatalf("UpdateBill with an existing tag: %v", err)
+		}
+		tags, err := repo.GetTags(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Sort(tags); !slices.Equal(tags, []string{"food", "home"}) {
+			t.Errorf("Expected the tags food and home, got %v", tags)
+		}
+		currencies, err := repo.GetCurrencies(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		countries, err := repo.GetCountries(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(currencies, []string{"rsd"}) || len(countries) != 1 {
+			t.Errorf("Expected one currency and country, got %v and %v", currencies, countries)
+		}
+
+		if got.FiscalId != "rs:maxi" {
+			t.Errorf("Expected the fiscal key of the link stored, got %q", got.FiscalId)
+		}
+
+		// UpdateTag ignores the other fields of the bill
+		tagged := newSearchBill("Ignored", "travel")
+		tagged.Id = b.Id
+		if err := repo.UpdateTag(ctx, tagged); err != nil {
+			t.Fatalf("UpdateTag: %v", err)
+		}
+		got, err = repo.GetBillByID(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Tag.String != "travel" || got.Name != "Maxi 24" || got.BillText != b.BillText {
+			t.Errorf("Expected only the tag changed, got %+v", got)
+		}
+
+		missing := newSearchBill("Idea", "food")
+		if _, err := repo.GetBillByID(ctx, missing.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBillByID: expected ErrNotFound, got %v", err)
+		}
+		if err := repo.UpdateBill(ctx, missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateBill: expected ErrNotFound, got %v", err)
+		}
+		if err := repo.UpdateTag(ctx, missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateTag: expected ErrNotFound, got %v", err)
+		}
+		orphan := item.New(ksuid.New().String(), missing.Id, "orphan", 1, 1, 1)
+		if err := repo.InsertItems(ctx, []*item.Item{orphan}); !errors.Is(err, ErrConflict) {
+			t.Errorf("InsertItems of a missing bill: expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("Items", func(t *testing.T) {
+		repo := open(t)
+		b := newSearchBill("Maxi", "food", "milk")
+		if err := repo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		extra := item.New(ksuid.New().String(), b.Id, "bread", 5.5, 2.75, 2)
+		if err := repo.InsertItems(ctx, []*item.Item{extra}); err != nil {
+			t.Fatalf("InsertItems: %v", err)
+		}
+		if err := repo.InsertItems(ctx, []*item.Item{extra}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("InsertItems twice: expected ErrDuplicate, got %v", err)
+		}
+		if err := repo.DeleteItems(ctx, []*item.Item{b.Items[0], item.New("missing", b.Id, "", 0, 0, 0)}); err != nil {
+			t.Fatalf("DeleteItems: %v", err)
+		}
+		items, err := repo.GetItemsByID(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].Name != "bread" || items[0].Price != 5.5 ||
+			items[0].PriceOne != 2.75 || items[0].Quantity != 2 {
+			t.Errorf("Expected the bread item only, got %v", items)
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		repo := open(t)
+		b := newSearchBill("Maxi", "tx-tag", "milk", "bread")
+		b.Items[1].ItemId = b.Items[0].ItemId
+		if err := repo.InsertBillWithItems(ctx, b); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("Expected ErrDuplicate for the repeated item, got %v", err)
+		}
+		if _, err := repo.GetBillByID(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the bill rolled back, got %v", err)
+		}
+
+		failed := errors.New("failed")
+		b = newSearchBill("Idea", "tx-tag", "milk")
+		err := repo.WithTx(ctx, func(tx BillRepository) error {
+			if err := tx.InsertBillWithItems(ctx, b); err != nil {
+				return err
+			}
+			if _, err := tx.GetBillByID(ctx, b.Id); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Expected the error of fn, got %v", err)
+		}
+		if _, err := repo.GetBillByID(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the bill rolled back, got %v", err)
+		}
+		if tags, err := repo.GetTags(ctx); err != nil || len(tags) != 0 {
+			t.Errorf("Expected no tags after the rollbacks, got %v, %v", tags, err)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		repo := open(t)
+		bills := []*bill.Bill{
+			newSearchBill("Maxi", "food", "Milk 2.8%"),
+			newSearchBill("Lidl", "home", "soap"),
+			newSearchBill("Migros", "food", "cheese"),
+		}
+		bills[1].Date = time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+		bills[2].Currency = currency.TRY
+		bills[2].Country = country.TURKEY
+		bills[2].Price = 40
+		for _, b := range bills {
+			if err := repo.InsertBillWithItems(ctx, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		low := 50.0
+		from, to := MonthRange(2024, time.June)
+		for _, c := range []struct {
+			filter BillFilter
+			want   []*bill.Bill
+		}{
+			{BillFilter{Merchant: "maxi"}, bills[:1]},
+			{BillFilter{Text: "lid"}, bills[1:2]},
+			{BillFilter{Text: "100%"}, nil},
+			{BillFilter{Tags: []string{"food"}, Sort: SortName}, []*bill.Bill{bills[0], bills[2]}},
+			{BillFilter{Currencies: []currency.Currency{currency.TRY}}, bills[2:]},
+			{BillFilter{MaxPrice: &low}, bills[2:]},
+			{BillFilter{From: from, To: to}, bills[1:2]},
+		} {
+			got, err := repo.ListBills(ctx, c.filter)
+			if err != nil {
+				t.Fatalf("ListBills(%+v): %v", c.filter, err)
+			}
+			if ids, want := billIds(got), billIds(c.want); !slices.Equal(ids, want) {
+				t.Errorf("ListBills(%+v): expected %v, got %v", c.filter, want, ids)
+			}
+		}
+
+		items, err := repo.ListItems(ctx, ItemFilter{Text: "milk"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].BillName != "Maxi" || items[0].Tag.Valid {
+			t.Errorf("Expected the milk of Maxi, got %v", items)
+		}
+		items, err = repo.ListItems(ctx, ItemFilter{BillId: bills[1].Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].Name != "soap" {
+			t.Errorf("Expected the items of the bill, got %v", items)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repo := open(t)
+		bills := []*bill.Bill{
+			newSearchBill("Shop", "bakery", "bread"),
+			newSearchBill("Bakery Jovanović", "food", "bread", "bakery bag"),
+			newSearchBill("МАКСИ", "храна", "ХЛЕБ БЕЛИ"),
+		}
+		bills[0].BillText = "thank you for shopping"
+		for _, b := range bills {
+			if err := repo.InsertBillWithItems(ctx, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		search := func(text string, sort Sort) []string {
+			t.Helper()
+			page, err := repo.SearchBills(ctx, BillFilter{Search: text, Sort: sort})
+			if err != nil {
+				t.Fatalf("SearchBills(%q): %v", text, err)
+			}
+			ids := make([]string, len(page.Hits))
+			for i, hit := range page.Hits {
+				ids[i] = hit.Id
+			}
+			return ids
+		}
+
+		// the merchant weighs more than the tag
+		if ids := search("bake", SortRelevance); !slices.Equal(ids, []string{bills[1].Id, bills[0].Id}) {
+			t.Errorf("Unexpected ranking: %v", ids)
+		}
+		for text, want := range map[string][]*bill.Bill{
+			"jovanovic":       bills[1:2],
+			"ЈОВАНОВИЋ":       bills[1:2],
+			"hleb":            bills[2:],
+			"maks":            bills[2:],
+			"shopping":        bills[:1],
+			`"hleb beli"`:     bills[2:],
+			`"hleb maksi"`:    nil,
+			`jovan "OR" maxi`: nil,
+		} {
+			if ids := search(text, SortDateDesc); !slices.Equal(ids, billIds(want)) {
+				t.Errorf("Search %q: expected %v, got %v", text, billIds(want), ids)
+			}
+		}
+
+		// the index follows the updates
+		bills[2].Name = "Idea"
+		bills[2].Tag = tag.New("home")
+		if err := repo.UpdateBill(ctx, bills[2]); err != nil {
+			t.Fatal(err)
+		}
+		if ids := search("maksi", SortDateDesc); len(ids) != 0 {
+			t.Errorf("Expected no match for the old name, got %v", ids)
+		}
+		if ids := search("idea home", SortDateDesc); !slices.Equal(ids, billIds(bills[2:])) {
+			t.Errorf("Expected the renamed bill, got %v", ids)
+		}
+		if err := repo.DeleteItems(ctx, bills[0].Items); err != nil {
+			t.Fatal(err)
+		}
+		if ids := search("bread", SortDateDesc); !slices.Equal(ids, billIds(bills[1:2])) {
+			t.Errorf("Expected the bill with bread left, got %v", ids)
+		}
+
+		page, err := repo.SearchItems(ctx, ItemFilter{Search: "hleb idea"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Hits) != 1 || page.Hits[0].Snippet != SnippetStart+"ХЛЕБ"+SnippetEnd+" БЕЛИ "+SnippetStart+"Idea"+SnippetEnd {
+			t.Errorf("Expected the bread of Idea with a snippet, got %+v", page.Hits)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := open(t)
+		for i := 0; i < 5; i++ {
+			b := newSearchBill([]string{"Maxi", "Lidl"}[i%2], "food", "milk")
+			b.Date = b.Date.AddDate(0, 0, i%3)
+			b.Price = float64(10 * (i % 2))
+			if err := repo.InsertBillWithItems(ctx, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, sort := range []Sort{SortDateDesc, SortPriceAsc, SortName, SortRelevance} {
+			for _, text := range []string{"", "milk"} {
+				all, err := repo.SearchBills(ctx, BillFilter{Search: text, Sort: sort})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var paged []string
+				filter := BillFilter{Search: text, Sort: sort, Limit: 2}
+				for {
+					page, err := repo.SearchBills(ctx, filter)
+					if err != nil {
+						t.Fatalf("%s %q: %v", sort, text, err)
+					}
+					for _, hit := range page.Hits {
+						paged = append(paged, hit.Id)
+					}
+					if page.Next == "" {
+						break
+					}
+					filter.Cursor = page.Next
+				}
+				want := make([]string, len(all.Hits))
+				for i, hit := range all.Hits {
+					want[i] = hit.Id
+				}
+				if len(want) != 5 || !slices.Equal(paged, want) {
+					t.Errorf("%s %q: expected the pages %v, got %v", sort, text, want, paged)
+				}
+			}
+		}
+		if _, err := repo.SearchBills(ctx, BillFilter{Cursor: "x"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		repo := open(t)
+		b := newSearchBill("Migros", "food", "cheese")
+		if err := repo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteBill(ctx, b.Id); err != nil {
+			t.Fatalf("DeleteBill: %v", err)
+		}
+		if err := repo.DeleteBill(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteBill twice: expected ErrNotFound, got %v", err)
+		}
+		if _, err := repo.GetBillByID(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBillByID: expected ErrNotFound, got %v", err)
+		}
+		if bills, err := repo.ListBills(ctx, BillFilter{Search: "migros"}); err != nil || len(bills) != 0 {
+			t.Errorf("Expected the deleted bill hidden, got %v, %v", bills, err)
+		}
+		trash, err := repo.ListTrash(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trash) != 1 || trash[0].Id != b.Id || trash[0].Items != 1 || time.Since(trash[0].DeletedAt) > time.Minute {
+			t.Errorf("Expected the bill in the trash, got %+v", trash)
+		}
+
+		if err := repo.RestoreBill(ctx, b.Id); err != nil {
+			t.Fatalf("RestoreBill: %v", err)
+		}
+		if err := repo.RestoreBill(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("RestoreBill twice: expected ErrNotFound, got %v", err)
+		}
+		if err := repo.PurgeBill(ctx, b.Id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("PurgeBill of a live bill: expected ErrNotFound, got %v", err)
+		}
+
+		if err := repo.DeleteBill(ctx, b.Id); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := repo.PurgeTrash(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Errorf("Expected nothing purged before the delete, got %d, %v", n, err)
+		}
+		if n, err := repo.PurgeTrash(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+			t.Errorf("Expected the bill purged, got %d, %v", n, err)
+		}
+		if trash, err := repo.ListTrash(ctx); err != nil || len(trash) != 0 {
+			t.Errorf("Expected an empty trash, got %v, %v", trash, err)
+		}
+		if items, err := repo.GetItemsByID(ctx, b.Id); err != nil || len(items) != 0 {
+			t.Errorf("Expected the items purged, got %v, %v", items, err)
+		}
+		// the id is free again
+		if err := repo.InsertBillWithItems(ctx, b); err != nil {
+			t.Errorf("Expected the purged bill inserted again, got %v", err)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		repo := open(t)
+		receipt := newSearchBill("Lenta", "food")
+		receipt.Link = "t=20240501T1200&s=100.00&fn=1&i=2&fp=3&n=1"
+		sameDay := newSearchBill("Maxi", "food")
+		nextDay := newSearchBill("Maxi", "food")
+		nextDay.Date = nextDay.Date.AddDate(0, 0, 1)
+		later := newSearchBill("Maxi", "food")
+		later.Date = later.Date.AddDate(0, 0, 3)
+		euro := newSearchBill("Maxi", "food")
+		euro.Currency = currency.EUR
+		for _, b := range []*bill.Bill{receipt, sameDay, nextDay, later, euro} {
+			if err := repo.InsertBill(ctx, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids := func(b *bill.Bill) []string {
+			t.Helper()
+			bills, err := repo.DuplicateCandidates(ctx, b)
+			if err != nil {
+				t.Fatalf("DuplicateCandidates: %v", err)
+			}
+			var ids []string
+			for _, c := range bills {
+				ids = append(ids, c.Id)
+			}
+			slices.Sort(ids)
+			return ids
+		}
+
+		// the same receipt scanned a month later, its link written in another order
+		scan := newSearchBill("Lenta", "")
+		scan.Date = scan.Date.AddDate(0, 1, 0)
+		scan.Link = "t=20240501T1200&fn=1&i=2&fp=3&s=100.00&n=1"
+		if got := ids(scan); !slices.Equal(got, []string{receipt.Id}) {
+			t.Errorf("Expected the receipt found by its fiscal key, got %v", got)
+		}
+		want := []string{receipt.Id, sameDay.Id, nextDay.Id}
+		slices.Sort(want)
+		if got := ids(newSearchBill("Idea", "")); !slices.Equal(got, want) {
+			t.Errorf("Expected the bills of the days around, got %v", got)
+		}
+		if got := ids(sameDay); slices.Contains(got, sameDay.Id) {
+			t.Errorf("Expected the bill not a candidate of itself, got %v", got)
+		}
+
+		if err := repo.DismissDuplicate(ctx, nextDay.Id, sameDay.Id); err != nil {
+			t.Fatalf("DismissDuplicate: %v", err)
+		}
+		if err := repo.DismissDuplicate(ctx, sameDay.Id, nextDay.Id); err != nil {
+			t.Errorf("DismissDuplicate twice: %v", err)
+		}
+		pairs, err := repo.DismissedDuplicates(ctx)
+		if err != nil || !slices.Equal(pairs, []DuplicatePair{NewDuplicatePair(sameDay.Id, nextDay.Id)}) {
+			t.Errorf("Expected the dismissed pair, got %v, %v", pairs, err)
+		}
+		if err := repo.DismissDuplicate(ctx, sameDay.Id, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DismissDuplicate of a missing bill: expected ErrNotFound, got %v", err)
+		}
+		if err := repo.DismissDuplicate(ctx, sameDay.Id, sameDay.Id); !errors.Is(err, ErrConflict) {
+			t.Errorf("DismissDuplicate of a bill with itself: expected ErrConflict, got %v", err)
+		}
+
+		if err := repo.DeleteBill(ctx, nextDay.Id); err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(sameDay); slices.Contains(got, nextDay.Id) {
+			t.Errorf("Expected the deleted bill no candidate, got %v", got)
+		}
+		if err := repo.PurgeBill(ctx, nextDay.Id); err != nil {
+			t.Fatal(err)
+		}
+		if pairs, err := repo.DismissedDuplicates(ctx); err != nil || len(pairs) != 0 {
+			t.Errorf("Expected the dismissal purged with the bill, got %v, %v", pairs, err)
+		}
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		repo := open(t)
+		b := newSearchBill("Maxi", "food", "milk")
+		if err := repo.InsertBillWithItems(audit.WithSource(ctx, audit.SourceAPI), b); err != nil {
+			t.Fatal(err)
+		}
+		b.Name = "Lidl"
+		if err := repo.UpdateBill(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := repo.History(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"update bill", "create item", "create tag", "create bill"}
+		if got := actions(entries); !slices.Equal(got, want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		if entries[3].Source != audit.SourceAPI || entries[0].Source != audit.SourceWeb ||
+			entries[0].Old["name"] != "Maxi" || entries[0].New["name"] != "Lidl" {
+			t.Errorf("Unexpected update entry %+v", entries[0])
+		}
+		if e, err := repo.GetAuditEntry(ctx, entries[0].Id); err != nil || e.Id != entries[0].Id {
+			t.Errorf("GetAuditEntry: got %+v, %v", e, err)
+		}
+
+		if err := repo.Revert(ctx, entries[0].Id); err != nil {
+			t.Fatalf("Revert: %v", err)
+		}
+		if got, err := repo.GetBillByID(ctx, b.Id); err != nil || got.Name != "Maxi" {
+			t.Errorf("Expected the name reverted, got %v, %v", got, err)
+		}
+		if err := repo.Revert(ctx, entries[0].Id); !errors.Is(err, ErrConflict) {
+			t.Errorf("Revert twice: expected ErrConflict, got %v", err)
+		}
+		if err := repo.Revert(ctx, entries[1].Id); err != nil {
+			t.Fatalf("Revert of the item: %v", err)
+		}
+		if items, err := repo.GetItemsByID(ctx, b.Id); err != nil || len(items) != 0 {
+			t.Errorf("Expected the item removed, got %v, %v", items, err)
+		}
+		if err := repo.Revert(ctx, entries[0].Id+1000); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Revert of a missing entry: expected ErrNotFound, got %v", err)
+		}
+		entries, err = repo.History(ctx, b.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries[0].Reverts == 0 || entries[1].Reverts == 0 {
+			t.Errorf("Expected the reverting entries, got %+v", entries[:2])
+		}
+	})
+}
+
+func itemNames(items []*item.Item) []string {
+	names := make([]string, len(items))
+	for i, it := range items {
+		names[i] = it.Name
+	}
+	return names
+}
+
+func billIds(bills []*bill.Bill) []string {
+	ids := make([]string, len(bills))
+	for i, b := range bills {
+		ids[i] = b.Id
+	}
+	return ids
+}
+
+func TestSqliteBillRepository(t *testing.T) {
+	testBillRepository(t, func(t *testing.T) BillRepository {
+		return setUpSearchDB(t)
+	})
+}
+
+func TestPostgresBillRepository(t *testing.T) {
+	dsn := postgresDSN(t)
+	testBillRepository(t, func(t *testing.T) BillRepository {
+		return setUpPostgresDB(t, dsn)
+	})
+}
+
+func TestMemoryBillRepository(t *testing.T) {
+	testBillRepository(t, func(t *testing.T) BillRepository {
+		return NewMemoryBillRepository()
+	})
+}
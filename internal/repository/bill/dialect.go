@@ -0,0 +1,25 @@
+package repository
+
+// dialect is the SQL of the shared queries that differs
+// between SQLite and PostgreSQL
+type dialect struct {
+	// concat aggregates the text of a column with spaces, a format of the column
+	concat string
+	// like is the case-insensitive LIKE operator
+	like string
+	// fold is the function folding text like search.Fold,
+	// empty when the database has none
+	fold string
+}
+
+var (
+	sqliteDialect = dialect{
+		concat: "group_concat(%s, ' ')",
+		like:   "LIKE",
+		fold:   "billdb_fold",
+	}
+	postgresDialect = dialect{
+		concat: "string_agg(%s, ' ')",
+		like:   "ILIKE",
+	}
+)
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when the requested bill or item doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a bill, item or tag with the same id or name exists
+	ErrDuplicate = errors.New("already exists")
+	// ErrConflict is returned when a change contradicts other stored data,
+	// e.g. items of a missing bill
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidCursor is returned for a page cursor that was not
+	// returned by the listing or belongs to another sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// mapError wraps the constraint errors of SQLite and PostgreSQL
+// into the repository errors
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			return fmt.Errorf("%w: %v", ErrDuplicate, err)
+		case "23503": // foreign_key_violation
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return err
+	}
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"fmt"
+	"time"
+)
+
+// Sort is the order of listed bills and items
+type Sort int
+
+const (
+	SortDateDesc Sort = iota
+	SortDateAsc
+	SortPriceDesc
+	SortPriceAsc
+	SortName
+	// SortMerchant orders by the bill name, for items too
+	SortMerchant
+	// SortRelevance orders full-text search results best match first,
+	// it is SortDateDesc without a search
+	SortRelevance
+)
+
+var sortToString = []string{
+	"date_desc",
+	"date_asc",
+	"price_desc",
+	"price_asc",
+	"name",
+	"merchant",
+	"relevance",
+}
+
+func (s Sort) String() string {
+	if s < 0 || int(s) >= len(sortToString) {
+		return sortToString[SortDateDesc]
+	}
+	return sortToString[s]
+}
+
+// ParseSort parses the sort query parameter, empty is SortDateDesc
+func ParseSort(s string) (Sort, error) {
+	if s == "" {
+		return SortDateDesc, nil
+	}
+	for i, name := range sortToString {
+		if name == s {
+			return Sort(i), nil
+		}
+	}
+	return SortDateDesc, fmt.Errorf("unknown sort: %s", s)
+}
+
+// BillFilter selects bills for ListBills and SearchBills, zero fields don't filter
+type BillFilter struct {
+	// From is inclusive, To is exclusive, compared by day
+	From time.Time
+	To   time.Time
+	// Tags matches bills with any of the tags
+	Tags       []string
+	Currencies []currency.Currency
+	Countries  []country.Country
+	// Merchant is a substring of the bill name
+	Merchant string
+	// Text is a substring of the name, tag, date, currency or country
+	Text string
+	// Search is matched against the full-text index of the merchant,
+	// tag, item names and journal, every word as a prefix
+	// and quoted words as a phrase
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+
+	Sort Sort
+	// Limit is the page size, zero is DefaultPageSize
+	// and it is at most MaxPageSize
+	Limit int
+	// Cursor continues after the page it was returned with,
+	// it must be used with the same sort
+	Cursor string
+	Offset int
+}
+
+// ItemFilter selects items for ListItems and SearchItems, zero fields don't filter
+type ItemFilter struct {
+	// From is inclusive, To is exclusive, compared by the bill day
+	From time.Time
+	To   time.Time
+	// Tags matches items with any of the tags
+	Tags []string
+	// BillId limits items to one bill
+	BillId string
+	// Merchant is a substring of the bill name
+	Merchant string
+	// Text is a substring of the item name, tag or date
+	Text string
+	// Search is matched against the full-text index of the item name
+	// and merchant, every word as a prefix and quoted words as a phrase
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+
+	Sort Sort
+	// Limit is the page size, zero is DefaultPageSize
+	// and it is at most MaxPageSize
+	Limit int
+	// Cursor continues after the page it was returned with,
+	// it must be used with the same sort
+	Cursor string
+	Offset int
+}
+
+// ItemWithBill is an item listed together with its bill fields
+type ItemWithBill struct {
+	*item.Item
+	Date     time.Time
+	BillName string
+	Currency currency.Currency
+	Tag      *tag.Tag
+}
+
+func (i *ItemWithBill) GetDateString() string {
+	return bl.DateToString(i.Date)
+}
+
+// BillHit is a bill found by SearchBills, Snippet is the matched text
+// with the matches between SnippetStart and SnippetEnd
+type BillHit struct {
+	*bl.Bill
+	Snippet string
+}
+
+// ItemHit is an item found by SearchItems
+type ItemHit struct {
+	*ItemWithBill
+	Snippet string
+}
+
+// BillPage is a page of SearchBills, Next is the cursor
+// of the following page, empty on the last page
+type BillPage struct {
+	Hits []*BillHit
+	Next string
+}
+
+// ItemPage is a page of SearchItems
+type ItemPage struct {
+	Hits []*ItemHit
+	Next string
+}
+
+// MonthRange returns the From and To bounds of a calendar month
+func MonthRange(year int, month time.Month) (time.Time, time.Time) {
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
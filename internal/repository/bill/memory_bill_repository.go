@@ -0,0 +1,686 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryBillRepository keeps the bills in memory, for tests and demos
+// without a database. It behaves like SqliteBillRepository, with the
+// audit log, the trash and the ranked search, but every write copies
+// all the data, so it is not meant for large collections.
+type MemoryBillRepository struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// set on the repository passed to WithTx,
+	// which works on a copy of the data and holds mu
+	tx bool
+}
+
+func NewMemoryBillRepository() *MemoryBillRepository {
+	return &MemoryBillRepository{mu: &sync.Mutex{}, data: &memoryData{}}
+}
+
+// memoryData are the rows of the tables of the SQL repositories,
+// in the order they were inserted
+type memoryData struct {
+	bills []*memoryBill
+	items []*item.Item
+	tags  []string // the id of a tag is its index plus one
+	audit []*audit.Entry
+	// dismissed are the rows of the duplicate_dismissal table
+	dismissed []DuplicatePair
+}
+
+// memoryBill is a row of the invoice table
+type memoryBill struct {
+	id, name, date    string
+	price             float64
+	currency, country string
+	link, text        string
+	fiscalId          string
+	tagId             int64
+	deletedAt         time.Time
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		bills:     make([]*memoryBill, len(d.bills)),
+		items:     make([]*item.Item, len(d.items)),
+		tags:      slices.Clone(d.tags),
+		audit:     slices.Clone(d.audit),
+		dismissed: slices.Clone(d.dismissed),
+	}
+	for i, b := range d.bills {
+		row := *b
+		c.bills[i] = &row
+	}
+	for i, it := range d.items {
+		row := *it
+		c.items[i] = &row
+	}
+	return c
+}
+
+// WithTx runs fn as one unit of work, see SqliteBillRepository.WithTx.
+// The writes of fn are made on a copy of the data, which replaces
+// the data when fn returns nil.
+func (r *MemoryBillRepository) WithTx(ctx context.Context, fn func(tx BillRepository) error) error {
+	return r.inTx(func(d *memoryData) error {
+		return fn(&MemoryBillRepository{mu: r.mu, data: d, tx: true})
+	})
+}
+
+// inTx runs fn on the data of the unit of work,
+// or on a copy committed when fn returns nil
+func (r *MemoryBillRepository) inTx(fn func(d *memoryData) error) error {
+	if r.tx {
+		return fn(r.data)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.data.clone()
+	err := fn(d)
+	if err != nil {
+		return err
+	}
+	*r.data = *d
+	return nil
+}
+
+// read runs fn on the data of the unit of work or on the committed data
+func (r *MemoryBillRepository) read(fn func(d *memoryData) error) error {
+	if !r.tx {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+	return fn(r.data)
+}
+
+// bill returns the row of a bill, of the trash too
+func (d *memoryData) bill(id string) *memoryBill {
+	i := slices.IndexFunc(d.bills, func(b *memoryBill) bool {
+		return b.id == id
+	})
+	if i < 0 {
+		return nil
+	}
+	return d.bills[i]
+}
+
+// liveBill returns the row of a bill which is not in the trash
+func (d *memoryData) liveBill(id string) (*memoryBill, error) {
+	b := d.bill(id)
+	if b == nil || !b.deletedAt.IsZero() {
+		return nil, fmt.Errorf("bill %s: %w", id, ErrNotFound)
+	}
+	return b, nil
+}
+
+// trashedBill returns the row of a bill in the trash
+func (d *memoryData) trashedBill(id string) (*memoryBill, error) {
+	b := d.bill(id)
+	if b == nil || b.deletedAt.IsZero() {
+		return nil, fmt.Errorf("bill %s in the trash: %w", id, ErrNotFound)
+	}
+	return b, nil
+}
+
+func (d *memoryData) tagName(b *memoryBill) string {
+	if b.tagId == 0 {
+		return ""
+	}
+	return d.tags[b.tagId-1]
+}
+
+// toBill returns the bill of a row without items, as scanBill does
+func (d *memoryData) toBill(b *memoryBill) (*bl.Bill, error) {
+	date, err := bl.StringToDate(b.date)
+	if err != nil {
+		return nil, err
+	}
+	billCurrency, err := currency.Parse(b.currency)
+	if err != nil {
+		return nil, err
+	}
+	billCountry, err := country.Parse(b.country)
+	if err != nil {
+		return nil, err
+	}
+	bill := bl.New(
+		b.id,
+		b.name,
+		*date,
+		b.price,
+		billCurrency,
+		billCountry,
+		[]*item.Item{},
+		tag.New(d.tagName(b)),
+		b.link,
+		b.text,
+	)
+	bill.FiscalId = b.fiscalId
+	return bill, nil
+}
+
+// values returns the audited fields of a row, see billValues
+func (d *memoryData) values(b *memoryBill) map[string]string {
+	return map[string]string{
+		"name":     b.name,
+		"date":     b.date,
+		"price":    formatPrice(b.price),
+		"currency": b.currency,
+		"country":  b.country,
+		"tag":      d.tagName(b),
+		"link":     b.link,
+		"text":     b.text,
+	}
+}
+
+// record appends a change to the audit log with the source of ctx
+func (d *memoryData) record(ctx context.Context, e *audit.Entry) {
+	e.Id = int64(len(d.audit) + 1)
+	e.Time = time.Now().UTC().Truncate(time.Second)
+	e.Source = audit.SourceOf(ctx)
+	if id, ok := ctx.Value(revertKey{}).(int64); ok {
+		e.Reverts = id
+	}
+	d.audit = append(d.audit, e)
+}
+
+// setTag links a bill to its tag, creating the tag if it is new
+func (d *memoryData) setTag(ctx context.Context, row *memoryBill, bill *bl.Bill) {
+	if !bill.Tag.Valid {
+		row.tagId = 0
+		return
+	}
+	i := slices.Index(d.tags, bill.Tag.String)
+	if i < 0 {
+		d.tags = append(d.tags, bill.Tag.String)
+		i = len(d.tags) - 1
+		d.record(ctx, &audit.Entry{
+			Action:   audit.Create,
+			Entity:   audit.Tag,
+			EntityId: fmt.Sprint(i + 1),
+			BillId:   bill.Id,
+			New:      map[string]string{"name": bill.Tag.String},
+		})
+	}
+	row.tagId = int64(i + 1)
+}
+
+func (r *MemoryBillRepository) InsertBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(func(d *memoryData) error {
+		if d.bill(bill.Id) != nil {
+			return fmt.Errorf("bill %s: %w", bill.Id, ErrDuplicate)
+		}
+		row := &memoryBill{
+			id:       bill.Id,
+			name:     bill.Name,
+			date:     bill.GetDateString(),
+			price:    bill.Price,
+			currency: bill.GetCurrencyString(),
+			country:  bill.GetCountryString(),
+			link:     bill.Link,
+			text:     bill.BillText,
+			fiscalId: bill.FiscalKey(),
+		}
+		d.bills = append(d.bills, row)
+		d.record(ctx, &audit.Entry{
+			Action:   audit.Create,
+			Entity:   audit.Bill,
+			EntityId: bill.Id,
+			BillId:   bill.Id,
+			New:      billValues(bill),
+		})
+		d.setTag(ctx, row, bill)
+		return nil
+	})
+}
+
+// InsertBillWithItems stores the bill, its tag and its items all-or-nothing
+func (r *MemoryBillRepository) InsertBillWithItems(ctx context.Context, bill *bl.Bill) error {
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		err := tx.InsertBill(ctx, bill)
+		if err != nil {
+			return err
+		}
+		return tx.InsertItems(ctx, bill.Items)
+	})
+}
+
+func (r *MemoryBillRepository) GetBillByID(ctx context.Context, id string) (*bl.Bill, error) {
+	var bill *bl.Bill
+	err := r.read(func(d *memoryData) error {
+		row, err := d.liveBill(id)
+		if err != nil {
+			return err
+		}
+		bill, err = d.toBill(row)
+		return err
+	})
+	return bill, err
+}
+
+func (r *MemoryBillRepository) UpdateBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(func(d *memoryData) error {
+		row, err := d.liveBill(bill.Id)
+		if err != nil {
+			return err
+		}
+		old := d.values(row)
+		row.name = bill.Name
+		row.date = bill.GetDateString()
+		row.price = bill.Price
+		row.currency = bill.GetCurrencyString()
+		row.country = bill.GetCountryString()
+		row.link = bill.Link
+		row.text = bill.BillText
+		row.fiscalId = bill.FiscalKey()
+		before, after := changed(old, billValues(bill))
+		if len(after) != 0 {
+			d.record(ctx, &audit.Entry{
+				Action:   audit.Update,
+				Entity:   audit.Bill,
+				EntityId: bill.Id,
+				BillId:   bill.Id,
+				Old:      before,
+				New:      after,
+			})
+		}
+		d.setTag(ctx, row, bill)
+		return nil
+	})
+}
+
+// UpdateTag changes only the tag of a bill
+func (r *MemoryBillRepository) UpdateTag(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(func(d *memoryData) error {
+		row, err := d.liveBill(bill.Id)
+		if err != nil {
+			return err
+		}
+		old := d.values(row)
+		before, after := changed(old, map[string]string{"tag": tagName(bill)})
+		if len(after) != 0 {
+			d.record(ctx, &audit.Entry{
+				Action:   audit.Update,
+				Entity:   audit.Bill,
+				EntityId: bill.Id,
+				BillId:   bill.Id,
+				Old:      before,
+				New:      after,
+			})
+		}
+		d.setTag(ctx, row, bill)
+		return nil
+	})
+}
+
+// DeleteBill moves a bill to the trash, see PurgeBill for the delete for good
+func (r *MemoryBillRepository) DeleteBill(ctx context.Context, id string) error {
+	return r.inTx(func(d *memoryData) error {
+		row, err := d.liveBill(id)
+		if err != nil {
+			return err
+		}
+		row.deletedAt = time.Now().UTC().Truncate(time.Second)
+		d.record(ctx, &audit.Entry{
+			Action:   audit.Delete,
+			Entity:   audit.Bill,
+			EntityId: id,
+			BillId:   id,
+		})
+		return nil
+	})
+}
+
+// RestoreBill takes a bill out of the trash
+func (r *MemoryBillRepository) RestoreBill(ctx context.Context, id string) error {
+	return r.inTx(func(d *memoryData) error {
+		row, err := d.trashedBill(id)
+		if err != nil {
+			return err
+		}
+		row.deletedAt = time.Time{}
+		d.record(ctx, &audit.Entry{
+			Action:   audit.Restore,
+			Entity:   audit.Bill,
+			EntityId: id,
+			BillId:   id,
+		})
+		return nil
+	})
+}
+
+// ListTrash returns the bills in the trash, the last deleted first
+func (r *MemoryBillRepository) ListTrash(ctx context.Context) ([]*TrashedBill, error) {
+	trash := []*TrashedBill{}
+	err := r.read(func(d *memoryData) error {
+		for _, row := range d.bills {
+			if row.deletedAt.IsZero() {
+				continue
+			}
+			bill, err := d.toBill(row)
+			if err != nil {
+				return err
+			}
+			bill.BillText = ""
+			trash = append(trash, &TrashedBill{
+				Bill:      bill,
+				DeletedAt: row.deletedAt,
+				Items:     len(d.itemsOf(row.id)),
+			})
+		}
+		return nil
+	})
+	slices.SortStableFunc(trash, func(a, b *TrashedBill) int {
+		if c := b.DeletedAt.Compare(a.DeletedAt); c != 0 {
+			return c
+		}
+		return compareValues(a.Id, b.Id)
+	})
+	return trash, err
+}
+
+// PurgeBill deletes a bill of the trash for good with its items
+func (r *MemoryBillRepository) PurgeBill(ctx context.Context, id string) error {
+	return r.inTx(func(d *memoryData) error {
+		row, err := d.trashedBill(id)
+		if err != nil {
+			return err
+		}
+		d.purge(ctx, row)
+		return nil
+	})
+}
+
+// PurgeTrash deletes for good the bills deleted before the time,
+// it returns how many were purged
+func (r *MemoryBillRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	err := r.inTx(func(d *memoryData) error {
+		purged = 0
+		for _, row := range slices.Clone(d.bills) {
+			if !row.deletedAt.IsZero() && row.deletedAt.Before(before) {
+				d.purge(ctx, row)
+				purged++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (d *memoryData) purge(ctx context.Context, row *memoryBill) {
+	old := d.values(row)
+	d.items = slices.DeleteFunc(d.items, func(it *item.Item) bool {
+		return it.BillId == row.id
+	})
+	d.bills = slices.DeleteFunc(d.bills, func(b *memoryBill) bool {
+		return b == row
+	})
+	d.dismissed = slices.DeleteFunc(d.dismissed, func(p DuplicatePair) bool {
+		return p.Id == row.id || p.Other == row.id
+	})
+	d.record(ctx, &audit.Entry{
+		Action:   audit.Purge,
+		Entity:   audit.Bill,
+		EntityId: row.id,
+		BillId:   row.id,
+		Old:      old,
+	})
+}
+
+func (r *MemoryBillRepository) InsertItems(ctx context.Context, items []*item.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return r.inTx(func(d *memoryData) error {
+		for _, it := range items {
+			if d.item(it.ItemId) != nil {
+				return fmt.Errorf("item %s: %w", it.ItemId, ErrDuplicate)
+			}
+			if d.bill(it.BillId) == nil {
+				return fmt.Errorf("item %s: %w: bill %s does not exist", it.ItemId, ErrConflict, it.BillId)
+			}
+			row := *it
+			d.items = append(d.items, &row)
+			d.record(ctx, &audit.Entry{
+				Action:   audit.Create,
+				Entity:   audit.Item,
+				EntityId: it.ItemId,
+				BillId:   it.BillId,
+				New:      itemValues(it),
+			})
+		}
+		return nil
+	})
+}
+
+func (d *memoryData) item(id string) *item.Item {
+	i := slices.IndexFunc(d.items, func(it *item.Item) bool {
+		return it.ItemId == id
+	})
+	if i < 0 {
+		return nil
+	}
+	return d.items[i]
+}
+
+// itemsOf returns the rows of the items of a bill
+func (d *memoryData) itemsOf(billId string) []*item.Item {
+	var items []*item.Item
+	for _, it := range d.items {
+		if it.BillId == billId {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+func (r *MemoryBillRepository) GetItemsByID(ctx context.Context, billId string) ([]*item.Item, error) {
+	var items []*item.Item
+	err := r.read(func(d *memoryData) error {
+		for _, it := range d.itemsOf(billId) {
+			row := *it
+			items = append(items, &row)
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (r *MemoryBillRepository) UpdateItems(ctx context.Context, items []*item.Item) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (r *MemoryBillRepository) DeleteItems(ctx context.Context, items []*item.Item) error {
+	return r.inTx(func(d *memoryData) error {
+		for _, it := range items {
+			row := d.item(it.ItemId)
+			if row == nil {
+				continue
+			}
+			d.items = slices.DeleteFunc(d.items, func(other *item.Item) bool {
+				return other == row
+			})
+			d.record(ctx, &audit.Entry{
+				Action:   audit.Delete,
+				Entity:   audit.Item,
+				EntityId: row.ItemId,
+				BillId:   row.BillId,
+				Old:      itemValues(row),
+			})
+		}
+		return nil
+	})
+}
+
+// distinct returns the distinct values of a field of the live bills
+func (r *MemoryBillRepository) distinct(field func(b *memoryBill) string) ([]string, error) {
+	values := []string{}
+	err := r.read(func(d *memoryData) error {
+		for _, row := range d.bills {
+			if row.deletedAt.IsZero() && !slices.Contains(values, field(row)) {
+				values = append(values, field(row))
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (r *MemoryBillRepository) GetCountries(ctx context.Context) ([]string, error) {
+	return r.distinct(func(b *memoryBill) string { return b.country })
+}
+
+func (r *MemoryBillRepository) GetCurrencies(ctx context.Context) ([]string, error) {
+	return r.distinct(func(b *memoryBill) string { return b.currency })
+}
+
+func (r *MemoryBillRepository) GetTags(ctx context.Context) ([]string, error) {
+	var tags []string
+	err := r.read(func(d *memoryData) error {
+		tags = slices.Clone(d.tags)
+		return nil
+	})
+	return tags, err
+}
+
+// ApplyMigration fails, the in-memory repository has no schema
+func (r *MemoryBillRepository) ApplyMigration(ctx context.Context, sqlFilePath string) error {
+	return errors.New("the in-memory repository has no schema to migrate")
+}
+
+// DuplicateCandidates returns the bills but b that may be its receipt,
+// see SqliteBillRepository.DuplicateCandidates
+func (r *MemoryBillRepository) DuplicateCandidates(ctx context.Context, b *bl.Bill) ([]*bl.Bill, error) {
+	key := b.FiscalKey()
+	from := bl.DateToString(b.Date.Add(-duplicateWindow))
+	to := bl.DateToString(b.Date.Add(duplicateWindow))
+	bills := []*bl.Bill{}
+	err := r.read(func(d *memoryData) error {
+		for _, row := range d.bills {
+			if !row.deletedAt.IsZero() || row.id == b.Id {
+				continue
+			}
+			if (b.Link == "" || row.link != b.Link) &&
+				(key == "" || row.fiscalId != key) &&
+				(b.Date.IsZero() || row.currency != b.GetCurrencyString() || row.date < from || row.date > to) {
+				continue
+			}
+			bill, err := d.toBill(row)
+			if err != nil {
+				return err
+			}
+			bill.BillText = ""
+			bills = append(bills, bill)
+		}
+		return nil
+	})
+	slices.SortStableFunc(bills, func(a, b *bl.Bill) int {
+		if c := a.Date.Compare(b.Date); c != 0 {
+			return c
+		}
+		return compareValues(a.Id, b.Id)
+	})
+	return bills, err
+}
+
+// DismissDuplicate records that two bills are different receipts
+func (r *MemoryBillRepository) DismissDuplicate(ctx context.Context, id string, other string) error {
+	return r.inTx(func(d *memoryData) error {
+		if id == other {
+			return fmt.Errorf("%w: bill %s is not a duplicate of itself", ErrConflict, id)
+		}
+		for _, billId := range []string{id, other} {
+			if _, err := d.liveBill(billId); err != nil {
+				return err
+			}
+		}
+		pair := NewDuplicatePair(id, other)
+		if !slices.Contains(d.dismissed, pair) {
+			d.dismissed = append(d.dismissed, pair)
+		}
+		return nil
+	})
+}
+
+// DismissedDuplicates returns the pairs of DismissDuplicate
+func (r *MemoryBillRepository) DismissedDuplicates(ctx context.Context) ([]DuplicatePair, error) {
+	pairs := []DuplicatePair{}
+	err := r.read(func(d *memoryData) error {
+		pairs = append(pairs, d.dismissed...)
+		return nil
+	})
+	slices.SortFunc(pairs, func(a, b DuplicatePair) int {
+		if c := compareValues(a.Id, b.Id); c != 0 {
+			return c
+		}
+		return compareValues(a.Other, b.Other)
+	})
+	return pairs, err
+}
+
+// History returns the changes of a bill and its items, the last first
+func (r *MemoryBillRepository) History(ctx context.Context, billId string) ([]*audit.Entry, error) {
+	entries := []*audit.Entry{}
+	err := r.read(func(d *memoryData) error {
+		for i := len(d.audit) - 1; i >= 0; i-- {
+			if d.audit[i].BillId == billId {
+				e := *d.audit[i]
+				entries = append(entries, &e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// GetAuditEntry returns an entry of the audit log
+func (r *MemoryBillRepository) GetAuditEntry(ctx context.Context, id int64) (*audit.Entry, error) {
+	var e audit.Entry
+	err := r.read(func(d *memoryData) error {
+		if id < 1 || id > int64(len(d.audit)) {
+			return fmt.Errorf("audit entry %d: %w", id, ErrNotFound)
+		}
+		e = *d.audit[id-1]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Revert undoes a single change, see SqliteBillRepository.Revert
+func (r *MemoryBillRepository) Revert(ctx context.Context, id int64) error {
+	e, err := r.GetAuditEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !e.Revertible() {
+		return fmt.Errorf("%w: a %s %s cannot be reverted", ErrConflict, e.Entity, e.Action)
+	}
+	ctx = context.WithValue(ctx, revertKey{}, id)
+
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		return revert(ctx, tx, e)
+	})
+}
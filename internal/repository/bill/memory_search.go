@@ -0,0 +1,417 @@
+package repository
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"billdb/internal/search"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The in-memory search scores the folded words of the columns of
+// bill_fts and item_fts: every word of the query must start a word of
+// a column and every quoted phrase must be words of a column in order.
+// The rank is the weighted count of the hits, negated so that lower
+// ranks are better as with bm25. The listings sort the rows by the
+// columns of billSort and itemSort, so the cursors work the same way.
+
+// memoryQuery is a parsed full-text query, see ftsQuery
+type memoryQuery struct {
+	prefixes []string
+	phrases  [][]string
+	terms    []string
+}
+
+// newMemoryQuery returns nil when there is nothing to search for
+func newMemoryQuery(text string) *memoryQuery {
+	q := &memoryQuery{terms: search.Terms(text)}
+	for i, part := range strings.Split(text, `"`) {
+		words := search.Terms(part)
+		if len(words) == 0 {
+			continue
+		}
+		if i%2 == 1 {
+			q.phrases = append(q.phrases, words)
+			continue
+		}
+		q.prefixes = append(q.prefixes, words...)
+	}
+	if len(q.prefixes) == 0 && len(q.phrases) == 0 {
+		return nil
+	}
+	return q
+}
+
+// rank returns the rank of the columns with their weights,
+// false when they don't match the query
+func (q *memoryQuery) rank(columns []string, weights []float64) (float64, bool) {
+	words := make([][]string, len(columns))
+	for i, column := range columns {
+		words[i] = search.Terms(column)
+	}
+	score := 0.0
+	hit := func(count func(words []string) int) bool {
+		found := false
+		for i := range words {
+			if n := count(words[i]); n > 0 {
+				score += weights[i] * float64(n)
+				found = true
+			}
+		}
+		return found
+	}
+	for _, prefix := range q.prefixes {
+		if !hit(func(words []string) int { return countPrefix(words, prefix) }) {
+			return 0, false
+		}
+	}
+	for _, phrase := range q.phrases {
+		if !hit(func(words []string) int { return countPhrase(words, phrase) }) {
+			return 0, false
+		}
+	}
+	return -score, true
+}
+
+func countPrefix(words []string, prefix string) int {
+	n := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func countPhrase(words []string, phrase []string) int {
+	n := 0
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			n++
+		}
+	}
+	return n
+}
+
+func (q *memoryQuery) snippet(text string) string {
+	if q == nil {
+		return ""
+	}
+	return search.Snippet(text, q.terms, snippetWords)
+}
+
+// containsFold is the LIKE of a substring, empty text matches
+func containsFold(text string, columns ...string) bool {
+	if text == "" {
+		return true
+	}
+	text = strings.ToLower(text)
+	for _, column := range columns {
+		if strings.Contains(strings.ToLower(column), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues orders the values of sort keys like SQLite,
+// numbers before text
+func compareValues(a any, b any) int {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmp.Compare(a, b)
+		}
+		return -1
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+		return 1
+	}
+	return 0
+}
+
+func compareKeys(a []any, b []any, keys []sortKey) int {
+	for i, k := range keys {
+		c := compareValues(a[i], b[i])
+		if k.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// memoryRow is a listed row with the values of its sort keys
+type memoryRow[T any] struct {
+	hit    T
+	values []any
+}
+
+// memoryPage sorts the rows and returns the page of the filter
+// with the cursor of the next one
+func memoryPage[T any](rows []memoryRow[T], sort Sort, keys []sortKey, token string, limit int, offset int) ([]T, string, error) {
+	slices.SortFunc(rows, func(a, b memoryRow[T]) int {
+		return compareKeys(a.values, b.values, keys)
+	})
+	if token != "" {
+		after, err := decodeCursor(token, sort, keys)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range after {
+			switch v.(type) {
+			case float64, string:
+			default:
+				return nil, "", fmt.Errorf("%w: invalid value %v", ErrInvalidCursor, v)
+			}
+		}
+		rows = slices.DeleteFunc(rows, func(row memoryRow[T]) bool {
+			return compareKeys(row.values, after, keys) <= 0
+		})
+	}
+	rows = rows[min(max(offset, 0), len(rows)):]
+
+	size := pageSize(limit)
+	hits := []T{}
+	next := ""
+	for i, row := range rows {
+		if i == size {
+			next = encodeCursor(sort, rows[i-1].values)
+			break
+		}
+		hits = append(hits, row.hit)
+	}
+	return hits, next, nil
+}
+
+// ListBills returns the bills matching the filter, without items
+func (r *MemoryBillRepository) ListBills(ctx context.Context, filter BillFilter) ([]*bl.Bill, error) {
+	page, err := r.SearchBills(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	bills := make([]*bl.Bill, len(page.Hits))
+	for i, hit := range page.Hits {
+		bills[i] = hit.Bill
+	}
+	return bills, nil
+}
+
+// SearchBills returns the bills matching the filter, without items,
+// ranked by the full-text search with SortRelevance, a page at a time
+func (r *MemoryBillRepository) SearchBills(ctx context.Context, filter BillFilter) (*BillPage, error) {
+	q := newMemoryQuery(filter.Search)
+	keys := sortKeys(filter.Sort, billSort, q.match())
+	var rows []memoryRow[*BillHit]
+	err := r.read(func(d *memoryData) error {
+		for _, row := range d.bills {
+			hit, rank, ok, err := d.searchBill(row, &filter, q)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			values := make([]any, len(keys))
+			for i, k := range keys {
+				values[i] = billKey(k.column, row, rank)
+			}
+			rows = append(rows, memoryRow[*BillHit]{hit: hit, values: values})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	hits, next, err := memoryPage(rows, filter.Sort, keys, filter.Cursor, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &BillPage{Hits: hits, Next: next}, nil
+}
+
+// match stands for the query in sortKeys, which
+// only tells if there is a full-text search
+func (q *memoryQuery) match() *ftsMatch {
+	if q == nil {
+		return nil
+	}
+	return &ftsMatch{terms: q.terms}
+}
+
+// searchBill returns the hit of a bill row with its rank,
+// false when it does not match the filter
+func (d *memoryData) searchBill(row *memoryBill, f *BillFilter, q *memoryQuery) (*BillHit, float64, bool, error) {
+	tagName := d.tagName(row)
+	switch {
+	case !row.deletedAt.IsZero(),
+		!f.From.IsZero() && row.date < bl.DateToString(f.From),
+		!f.To.IsZero() && row.date >= bl.DateToString(f.To),
+		len(f.Tags) != 0 && !slices.Contains(f.Tags, tagName),
+		len(f.Currencies) != 0 && !slices.ContainsFunc(f.Currencies, func(c currency.Currency) bool {
+			return c.String() == row.currency
+		}),
+		len(f.Countries) != 0 && !slices.ContainsFunc(f.Countries, func(c country.Country) bool {
+			return c.String() == row.country
+		}),
+		!containsFold(f.Merchant, row.name),
+		!containsFold(f.Text, row.name, tagName, row.date, row.currency, row.country),
+		f.MinPrice != nil && row.price < *f.MinPrice,
+		f.MaxPrice != nil && row.price > *f.MaxPrice:
+		return nil, 0, false, nil
+	}
+	names := make([]string, 0)
+	for _, it := range d.itemsOf(row.id) {
+		names = append(names, it.Name)
+	}
+	items := strings.Join(names, " ")
+	rank := 0.0
+	if q != nil {
+		var ok bool
+		rank, ok = q.rank([]string{row.name, tagName, items, row.text}, billFtsWeights)
+		if !ok {
+			return nil, 0, false, nil
+		}
+	}
+	bill, err := d.toBill(row)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	bill.BillText = ""
+	return &BillHit{
+		Bill:    bill,
+		Snippet: q.snippet(row.name + " " + items + " " + row.text),
+	}, rank, true, nil
+}
+
+// billKey returns the value of a column of billSort
+func billKey(column string, row *memoryBill, rank float64) any {
+	switch column {
+	case "fts.rank":
+		return rank
+	case "invoice_date":
+		return row.date
+	case "invoice_price":
+		return row.price
+	case "invoice_name":
+		return row.name
+	}
+	return row.id
+}
+
+// ListItems returns the items matching the filter with their bill fields
+func (r *MemoryBillRepository) ListItems(ctx context.Context, filter ItemFilter) ([]*ItemWithBill, error) {
+	page, err := r.SearchItems(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*ItemWithBill, len(page.Hits))
+	for i, hit := range page.Hits {
+		items[i] = hit.ItemWithBill
+	}
+	return items, nil
+}
+
+// SearchItems returns the items matching the filter with their bill fields,
+// ranked by the full-text search with SortRelevance, a page at a time.
+// The items have no tags of their own here, so filtering by tags finds none.
+func (r *MemoryBillRepository) SearchItems(ctx context.Context, filter ItemFilter) (*ItemPage, error) {
+	q := newMemoryQuery(filter.Search)
+	keys := sortKeys(filter.Sort, itemSort, q.match())
+	var rows []memoryRow[*ItemHit]
+	err := r.read(func(d *memoryData) error {
+		for _, it := range d.items {
+			hit, rank, ok, err := d.searchItem(it, &filter, q)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			values := make([]any, len(keys))
+			for i, k := range keys {
+				values[i] = itemKey(k.column, hit, rank)
+			}
+			rows = append(rows, memoryRow[*ItemHit]{hit: hit, values: values})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	hits, next, err := memoryPage(rows, filter.Sort, keys, filter.Cursor, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemPage{Hits: hits, Next: next}, nil
+}
+
+// searchItem returns the hit of an item row with its rank,
+// false when it does not match the filter
+func (d *memoryData) searchItem(it *item.Item, f *ItemFilter, q *memoryQuery) (*ItemHit, float64, bool, error) {
+	row := d.bill(it.BillId)
+	switch {
+	case row == nil,
+		!row.deletedAt.IsZero(),
+		!f.From.IsZero() && row.date < bl.DateToString(f.From),
+		!f.To.IsZero() && row.date >= bl.DateToString(f.To),
+		len(f.Tags) != 0,
+		f.BillId != "" && it.BillId != f.BillId,
+		!containsFold(f.Merchant, row.name),
+		!containsFold(f.Text, it.Name, row.date),
+		f.MinPrice != nil && it.Price < *f.MinPrice,
+		f.MaxPrice != nil && it.Price > *f.MaxPrice:
+		return nil, 0, false, nil
+	}
+	rank := 0.0
+	if q != nil {
+		var ok bool
+		rank, ok = q.rank([]string{it.Name, row.name}, itemFtsWeights)
+		if !ok {
+			return nil, 0, false, nil
+		}
+	}
+	date, err := bl.StringToDate(row.date)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	itemCurrency, err := currency.Parse(row.currency)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	c := *it
+	return &ItemHit{ItemWithBill: &ItemWithBill{
+		Item:     &c,
+		Date:     *date,
+		BillName: row.name,
+		Currency: itemCurrency,
+		Tag:      tag.NewFromNullable(nil),
+	}, Snippet: q.snippet(it.Name + " " + row.name)}, rank, true, nil
+}
+
+// itemKey returns the value of a column of itemSort
+func itemKey(column string, hit *ItemHit, rank float64) any {
+	switch column {
+	case "fts.rank":
+		return rank
+	case "invoice_date":
+		return hit.GetDateString()
+	case "coalesce(item_price, 0)":
+		return hit.Price
+	case "coalesce(item_name, '')":
+		return hit.Name
+	case "invoice_name":
+		return hit.BillName
+	}
+	return hit.ItemId
+}
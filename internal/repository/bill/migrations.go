@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"billdb/internal/repository/migration"
+	"context"
+	"database/sql"
+	"embed"
+)
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// NewMigrator returns the migrator for the SQLite schema.
+// Databases from before the migration table already have
+// the initial schema, it is recorded without running.
+func NewMigrator(db *sql.DB) *migration.Migrator {
+	m := migration.New(db, Migrations, "migrations")
+	m.Funcs = map[string]func(tx *sql.Tx) error{
+		searchIndexMigration:     createSearchIndex,
+		foldSearchIndexMigration: rebuildSearchIndex,
+		fiscalIdMigration:        addFiscalIdSqlite,
+	}
+	m.Baseline = []string{"001_initial_schema.sql"}
+	m.BaselineCheck = `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'invoice'`
+	return m
+}
+
+// OrphanTables keep the rows 005_cascade_deletes.sql couldn't copy
+// back, as their bill, item or tag was missing, for billdb check
+var OrphanTables = []string{"item_orphan", "item_tag_orphan", "invoice_tag_orphan"}
+
+// CountOrphans returns the number of rows of every orphan table of a
+// SQLite database, the tables of a database migrated before they
+// existed are missing
+func CountOrphans(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, table := range OrphanTables {
+		var exists bool
+		err := db.QueryRowContext(ctx, `SELECT count(*) > 0 FROM sqlite_master
+			WHERE type = 'table' AND name = ?`, table).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		var n int
+		err = db.QueryRowContext(ctx, `SELECT count(*) FROM "`+table+`"`).Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+		counts[table] = n
+	}
+	return counts, nil
+}
+
+//go:embed postgres_migrations/*.sql
+var PostgresMigrations embed.FS
+
+// NewPostgresMigrator returns the migrator for the PostgreSQL schema
+func NewPostgresMigrator(db *sql.DB) *migration.Migrator {
+	m := migration.New(db, PostgresMigrations, "postgres_migrations")
+	m.Funcs = map[string]func(tx *sql.Tx) error{
+		postgresFiscalIdMigration: addFiscalIdPostgres,
+	}
+	return m
+}
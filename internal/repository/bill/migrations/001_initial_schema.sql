@@ -47,7 +47,7 @@ CREATE TABLE "exchange_rate_eur" (
 	"exchange_rate_eur_value" REAL NOT NULL,
 	PRIMARY KEY("exchange_rate_eur_id" AUTOINCREMENT)
 );
-CREATE TABLE "migration" (
+CREATE TABLE IF NOT EXISTS "migration" (
 	"name" TEXT UNIQUE,
 	PRIMARY KEY("name")
 );
\ No newline at end of file
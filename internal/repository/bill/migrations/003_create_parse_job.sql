@@ -0,0 +1,14 @@
+CREATE TABLE "parse_job" (
+	"job_id" TEXT NOT NULL UNIQUE,
+	"job_link" TEXT NOT NULL,
+	"job_host" TEXT NOT NULL,
+	"job_status" TEXT NOT NULL,
+	"job_attempts" INTEGER NOT NULL DEFAULT 0,
+	"job_error" TEXT,
+	"job_bill_id" TEXT,
+	"job_created" TEXT NOT NULL,
+	"job_updated" TEXT NOT NULL,
+	"job_next_run" TEXT,
+	PRIMARY KEY("job_id")
+);
+CREATE INDEX "parse_job_status_idx" ON "parse_job" ("job_status", "job_next_run");
@@ -0,0 +1,16 @@
+CREATE TABLE "parse_attempt" (
+	"attempt_id" INTEGER,
+	"attempt_parser" TEXT NOT NULL,
+	"attempt_link" TEXT NOT NULL,
+	"attempt_number" INTEGER NOT NULL,
+	"attempt_http_status" INTEGER NOT NULL DEFAULT 0,
+	"attempt_items_status" INTEGER NOT NULL DEFAULT 0,
+	"attempt_failed_selector" TEXT,
+	"attempt_token" TEXT,
+	"attempt_success" INTEGER NOT NULL,
+	"attempt_error" TEXT,
+	"attempt_duration_ms" INTEGER NOT NULL DEFAULT 0,
+	"attempt_created" TEXT NOT NULL,
+	PRIMARY KEY("attempt_id" AUTOINCREMENT)
+);
+CREATE INDEX "parse_attempt_created_idx" ON "parse_attempt" ("attempt_created", "attempt_parser");
@@ -0,0 +1,67 @@
+-- Recreate the link tables with ON DELETE CASCADE, so removing a bill,
+-- an item or a tag also removes the rows hanging off it.
+-- Children are copied aside and dropped first, so parents are never touched.
+-- Rows already pointing at missing parents are not copied back, they are
+-- kept in the *_orphan tables, reported and deleted by billdb check.
+CREATE TABLE "item_tag_old" AS SELECT * FROM "item_tag";
+CREATE TABLE "invoice_tag_old" AS SELECT * FROM "invoice_tag";
+CREATE TABLE "item_old" AS SELECT * FROM "item";
+DROP TABLE "item_tag";
+DROP TABLE "invoice_tag";
+DROP TABLE "item";
+
+CREATE TABLE "item" (
+	"item_id" TEXT NOT NULL UNIQUE,
+	"invoice_id" TEXT NOT NULL,
+	"item_name" TEXT,
+	"item_price" REAL,
+	"item_price_one" REAL,
+	"item_quantity" REAL,
+	"item_photo" TEXT,
+	PRIMARY KEY("item_id"),
+	FOREIGN KEY("invoice_id") REFERENCES "invoice"("invoice_id") ON DELETE CASCADE
+);
+CREATE INDEX "item_invoice_idx" ON "item" ("invoice_id");
+
+CREATE TABLE "item_tag" (
+	"item_id"	TEXT NOT NULL UNIQUE,
+	"tag_id"	NUMBER NOT NULL,
+	PRIMARY KEY("item_id","tag_id"),
+	FOREIGN KEY("tag_id") REFERENCES "tag"("tag_id") ON DELETE CASCADE,
+	FOREIGN KEY("item_id") REFERENCES "item"("item_id") ON DELETE CASCADE
+);
+
+CREATE TABLE "invoice_tag" (
+	"invoice_id" TEXT NOT NULL UNIQUE,
+	"tag_id" NUMBER NOT NULL,
+	PRIMARY KEY ("invoice_id", "tag_id"),
+	FOREIGN KEY ("invoice_id") REFERENCES invoice("invoice_id") ON DELETE CASCADE,
+	FOREIGN KEY ("tag_id") REFERENCES tag("tag_id") ON DELETE CASCADE
+);
+
+INSERT INTO "item"
+SELECT * FROM "item_old"
+WHERE "invoice_id" IN (SELECT "invoice_id" FROM "invoice");
+
+INSERT INTO "item_tag"
+SELECT * FROM "item_tag_old"
+WHERE "item_id" IN (SELECT "item_id" FROM "item")
+	AND "tag_id" IN (SELECT "tag_id" FROM "tag");
+
+INSERT INTO "invoice_tag"
+SELECT * FROM "invoice_tag_old"
+WHERE "invoice_id" IN (SELECT "invoice_id" FROM "invoice")
+	AND "tag_id" IN (SELECT "tag_id" FROM "tag");
+
+CREATE TABLE "item_orphan" AS SELECT * FROM "item_old"
+WHERE "item_id" NOT IN (SELECT "item_id" FROM "item");
+
+CREATE TABLE "item_tag_orphan" AS SELECT * FROM "item_tag_old"
+WHERE "item_id" NOT IN (SELECT "item_id" FROM "item_tag");
+
+CREATE TABLE "invoice_tag_orphan" AS SELECT * FROM "invoice_tag_old"
+WHERE "invoice_id" NOT IN (SELECT "invoice_id" FROM "invoice_tag");
+
+DROP TABLE "item_tag_old";
+DROP TABLE "invoice_tag_old";
+DROP TABLE "item_old";
@@ -0,0 +1,4 @@
+-- Deleted bills are kept in the trash until they are purged,
+-- deleted_at is the UTC time of the delete, NULL for live bills.
+ALTER TABLE "invoice" ADD COLUMN "deleted_at" TEXT;
+CREATE INDEX "invoice_deleted_idx" ON "invoice" ("deleted_at");
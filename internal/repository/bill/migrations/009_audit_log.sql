@@ -0,0 +1,25 @@
+-- Append-only log of the changes to bills, items and tags.
+-- Entries outlive the rows they describe, so there are no foreign keys.
+CREATE TABLE "audit" (
+	"audit_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"audit_time" TEXT NOT NULL,
+	"audit_source" TEXT NOT NULL,
+	"audit_action" TEXT NOT NULL,
+	"entity" TEXT NOT NULL,
+	"entity_id" TEXT NOT NULL,
+	"invoice_id" TEXT,
+	"old_values" TEXT,
+	"new_values" TEXT,
+	"reverts_id" INTEGER
+);
+CREATE INDEX "audit_invoice_idx" ON "audit" ("invoice_id", "audit_id");
+
+CREATE TRIGGER "audit_no_update" BEFORE UPDATE ON "audit"
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER "audit_no_delete" BEFORE DELETE ON "audit"
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Page sizes of SearchBills and SearchItems
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// pageSize returns the enforced size of a page for the requested limit
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+// sortKey is a column of the order of a listing. The keys of a sort end
+// with the id, so they order the rows totally and a cursor can continue
+// after the last row of a page.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+var billSort = map[Sort][]sortKey{
+	SortDateDesc:  {{"invoice_date", true}, {"invoice.invoice_id", true}},
+	SortDateAsc:   {{"invoice_date", false}, {"invoice.invoice_id", false}},
+	SortPriceDesc: {{"invoice_price", true}, {"invoice.invoice_id", true}},
+	SortPriceAsc:  {{"invoice_price", false}, {"invoice.invoice_id", false}},
+	SortName:      {{"invoice_name", false}, {"invoice.invoice_id", false}},
+	SortMerchant:  {{"invoice_name", false}, {"invoice.invoice_id", false}},
+	SortRelevance: {{"invoice_date", true}, {"invoice.invoice_id", true}},
+}
+
+var itemSort = map[Sort][]sortKey{
+	SortDateDesc:  {{"invoice_date", true}, {"item.item_id", true}},
+	SortDateAsc:   {{"invoice_date", false}, {"item.item_id", false}},
+	SortPriceDesc: {{"coalesce(item_price, 0)", true}, {"item.item_id", true}},
+	SortPriceAsc:  {{"coalesce(item_price, 0)", false}, {"item.item_id", false}},
+	SortName:      {{"coalesce(item_name, '')", false}, {"item.item_id", false}},
+	SortMerchant:  {{"invoice_name", false}, {"item.item_id", false}},
+	SortRelevance: {{"invoice_date", true}, {"item.item_id", true}},
+}
+
+// sortKeys returns the keys of a sort, searches sorted
+// by relevance start with the rank of the match
+func sortKeys(sort Sort, keys map[Sort][]sortKey, m *ftsMatch) []sortKey {
+	if m != nil && sort == SortRelevance {
+		return append([]sortKey{{"fts.rank", false}}, keys[sort]...)
+	}
+	return keys[sort]
+}
+
+func orderBy(keys []sortKey) string {
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		dir := "ASC"
+		if k.desc {
+			dir = "DESC"
+		}
+		order[i] = k.column + " " + dir
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// columns returns the key columns appended to the selected columns
+func columns(keys []sortKey) string {
+	cols := make([]string, len(keys))
+	for i, k := range keys {
+		cols[i] = k.column
+	}
+	return strings.Join(cols, ", ")
+}
+
+// pointers returns the scan destinations of the key values
+func pointers(values []any) []any {
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	return dest
+}
+
+// cursor is the position after a row, the values of the sort keys
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+func encodeCursor(sort Sort, values []any) string {
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+	b, _ := json.Marshal(cursor{Sort: sort.String(), Values: values})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the key values of a cursor of the sort
+func decodeCursor(token string, sort Sort, keys []sortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Sort != sort.String() || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: of another sort", ErrInvalidCursor)
+	}
+	return c.Values, nil
+}
+
+// after adds the condition of the rows following the cursor
+func (w *where) after(token string, sort Sort, keys []sortKey) error {
+	if token == "" {
+		return nil
+	}
+	values, err := decodeCursor(token, sort, keys)
+	if err != nil {
+		return err
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., < for descending keys
+	var or []string
+	var args []any
+	for i, k := range keys {
+		var and []string
+		for j := 0; j < i; j++ {
+			and = append(and, keys[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if k.desc {
+			op = " < ?"
+		}
+		and = append(and, k.column+op)
+		args = append(args, values[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	w.add("("+strings.Join(or, " OR ")+")", args...)
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// insertPageFixtures stores bills with repeated dates, prices and names,
+// so that only the id breaks the ties of the sorts
+func insertPageFixtures(t *testing.T, billRepo *SqliteBillRepository) {
+	t.Helper()
+	for i := 0; i < 7; i++ {
+		id := ksuid.New().String()
+		b := bill.New(id, fmt.Sprintf("Shop %d", i%2), time.Date(2024, 1, 1+i%3, 0, 0, 0, 0, time.UTC),
+			float64(100*(i%2)), currency.RSD, country.SERBIA, nil, tag.New("food"), "", "")
+		b.Items = []*item.Item{
+			item.New(ksuid.New().String(), id, fmt.Sprintf("milk %d", i%3), float64(10*(i%2)), 10, 1),
+			item.New(ksuid.New().String(), id, "bread", 5, 5, 1),
+		}
+		if i%3 == 0 {
+			b.Items[0].Name += " milk"
+		}
+		if err := billRepo.InsertBillWithItems(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	insertPageFixtures(t, billRepo)
+	ctx := context.Background()
+
+	sorts := []Sort{SortDateDesc, SortDateAsc, SortPriceDesc, SortPriceAsc, SortName, SortMerchant, SortRelevance}
+	for _, sort := range sorts {
+		for _, text := range []string{"", "milk"} {
+			t.Run(sort.String()+" "+text, func(t *testing.T) {
+				all, err := billRepo.SearchBills(ctx, BillFilter{Search: text, Sort: sort, Limit: MaxPageSize})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if all.Next != "" {
+					t.Errorf("Expected a single page, got cursor %s", all.Next)
+				}
+				var paged []string
+				filter := BillFilter{Search: text, Sort: sort, Limit: 2}
+				for {
+					page, err := billRepo.SearchBills(ctx, filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, hit := range page.Hits {
+						paged = append(paged, hit.Id)
+					}
+					if page.Next == "" {
+						break
+					}
+					filter.Cursor = page.Next
+				}
+				var want []string
+				for _, hit := range all.Hits {
+					want = append(want, hit.Id)
+				}
+				if len(want) != 7 || !slices.Equal(paged, want) {
+					t.Errorf("Pages %v differ from the listing %v", paged, want)
+				}
+
+				var items []string
+				itemFilter := ItemFilter{Search: text, Sort: sort, Limit: 3}
+				for {
+					page, err := billRepo.SearchItems(ctx, itemFilter)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, hit := range page.Hits {
+						items = append(items, hit.ItemId)
+					}
+					if page.Next == "" {
+						break
+					}
+					itemFilter.Cursor = page.Next
+				}
+				allItems, err := billRepo.ListItems(ctx, ItemFilter{Search: text, Sort: sort, Limit: MaxPageSize})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var wantItems []string
+				for _, it := range allItems {
+					wantItems = append(wantItems, it.ItemId)
+				}
+				if !slices.Equal(items, wantItems) {
+					t.Errorf("Item pages %v differ from the listing %v", items, wantItems)
+				}
+			})
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	insertPageFixtures(t, billRepo)
+	ctx := context.Background()
+
+	page, err := billRepo.SearchBills(ctx, BillFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range []BillFilter{
+		{Cursor: "not a cursor"},
+		{Cursor: "e30"},
+		{Cursor: page.Next, Sort: SortPriceAsc},
+	} {
+		_, err := billRepo.SearchBills(ctx, filter)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", filter.Cursor, err)
+		}
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	cases := map[int]int{0: DefaultPageSize, -1: DefaultPageSize, 10: 10, MaxPageSize + 1: MaxPageSize}
+	for limit, want := range cases {
+		if got := pageSize(limit); got != want {
+			t.Errorf("pageSize(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
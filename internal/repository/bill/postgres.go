@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// OpenPostgres opens the PostgreSQL database of a DSN,
+// a postgres:// URL or a key=value connection string
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	return sql.Open("pgx", dsn)
+}
+
+// rebound runs the queries of the repository on PostgreSQL,
+// their ? placeholders are numbered $1, $2, ... as it expects
+type rebound struct {
+	q querier
+}
+
+func (r rebound) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.q.ExecContext(ctx, rebind(query), args...)
+}
+
+func (r rebound) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, rebind(query), args...)
+}
+
+func (r rebound) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.q.QueryRowContext(ctx, rebind(query), args...)
+}
+
+func (r rebound) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.q.PrepareContext(ctx, rebind(query))
+}
+
+// rebind numbers the ? placeholders of a query,
+// question marks in quoted strings and identifiers are kept
+func rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/item"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PostgresBillRepository stores the bills in PostgreSQL, with the schema
+// of NewPostgresMigrator. It runs the queries of SqliteBillRepository,
+// the full-text search is on tsvector documents, see postgres_search.go.
+type PostgresBillRepository struct {
+	DB *sql.DB
+	// set on the repository passed to WithTx
+	tx *sql.Tx
+}
+
+func NewPostgresBillRepository(db *sql.DB) *PostgresBillRepository {
+	return &PostgresBillRepository{DB: db}
+}
+
+// conn returns the transaction of the unit of work if there is one
+func (r *PostgresBillRepository) conn() querier {
+	if r.tx != nil {
+		return rebound{r.tx}
+	}
+	return rebound{r.DB}
+}
+
+// WithTx runs fn as one unit of work, see SqliteBillRepository.WithTx
+func (r *PostgresBillRepository) WithTx(ctx context.Context, fn func(tx BillRepository) error) error {
+	return r.transact(ctx, func(tx *PostgresBillRepository) error {
+		return fn(tx)
+	})
+}
+
+// inTx runs fn in the transaction of the unit of work,
+// or in a new transaction when the repository is not bound to one
+func (r *PostgresBillRepository) inTx(ctx context.Context, fn func(q querier) error) error {
+	return r.transact(ctx, func(tx *PostgresBillRepository) error {
+		return fn(tx.conn())
+	})
+}
+
+// transact runs fn with the repository bound to a transaction
+func (r *PostgresBillRepository) transact(ctx context.Context, fn func(tx *PostgresBillRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&PostgresBillRepository{DB: r.DB, tx: tx})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresBillRepository) InsertBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		err := insertBill(ctx, tx, bill)
+		if err != nil {
+			return err
+		}
+		return indexBill(ctx, tx, bill.Id)
+	})
+}
+
+// InsertBillWithItems stores the bill, its tag and its items all-or-nothing
+func (r *PostgresBillRepository) InsertBillWithItems(ctx context.Context, bill *bl.Bill) error {
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		err := tx.InsertBill(ctx, bill)
+		if err != nil {
+			return err
+		}
+		return tx.InsertItems(ctx, bill.Items)
+	})
+}
+
+func (r *PostgresBillRepository) GetBillByID(ctx context.Context, id string) (*bl.Bill, error) {
+	return getBill(ctx, r.conn(), id)
+}
+
+func (r *PostgresBillRepository) UpdateBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		err := updateBill(ctx, tx, bill)
+		if err != nil {
+			return err
+		}
+		return indexBill(ctx, tx, bill.Id)
+	})
+}
+
+// UpdateTag changes only the tag of a bill
+func (r *PostgresBillRepository) UpdateTag(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		err := updateTag(ctx, tx, bill)
+		if err != nil {
+			return err
+		}
+		return indexBill(ctx, tx, bill.Id)
+	})
+}
+
+// DeleteBill moves a bill to the trash, see PurgeBill for the delete for good
+func (r *PostgresBillRepository) DeleteBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return deleteBill(ctx, tx, id)
+	})
+}
+
+// RestoreBill takes a bill out of the trash
+func (r *PostgresBillRepository) RestoreBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return restoreBill(ctx, tx, id)
+	})
+}
+
+// ListTrash returns the bills in the trash, the last deleted first
+func (r *PostgresBillRepository) ListTrash(ctx context.Context) ([]*TrashedBill, error) {
+	return listTrash(ctx, r.conn())
+}
+
+// PurgeBill deletes a bill of the trash for good, the search
+// documents go with the rows by the cascading foreign keys
+func (r *PostgresBillRepository) PurgeBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return purgeBill(ctx, tx, id)
+	})
+}
+
+// PurgeTrash deletes for good the bills deleted before the time,
+// it returns how many were purged
+func (r *PostgresBillRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	err := r.inTx(ctx, func(tx querier) error {
+		var err error
+		purged, err = purgeTrash(ctx, tx, before)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (r *PostgresBillRepository) InsertItems(ctx context.Context, items []*item.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return r.inTx(ctx, func(tx querier) error {
+		err := insertItems(ctx, tx, items)
+		if err != nil {
+			return err
+		}
+		bills, err := billsOfItems(ctx, tx, items)
+		if err != nil {
+			return err
+		}
+		return indexBills(ctx, tx, bills)
+	})
+}
+
+func (r *PostgresBillRepository) GetItemsByID(ctx context.Context, billId string) ([]*item.Item, error) {
+	return getItems(ctx, r.conn(), billId)
+}
+
+func (r *PostgresBillRepository) UpdateItems(ctx context.Context, items []*item.Item) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (r *PostgresBillRepository) DeleteItems(ctx context.Context, items []*item.Item) error {
+	return r.inTx(ctx, func(tx querier) error {
+		bills, err := billsOfItems(ctx, tx, items)
+		if err != nil {
+			return err
+		}
+		err = deleteItems(ctx, tx, items)
+		if err != nil {
+			return err
+		}
+		return indexBills(ctx, tx, bills)
+	})
+}
+
+// billsOfItems returns the ids of the bills of the stored items
+func billsOfItems(ctx context.Context, tx querier, items []*item.Item) ([]string, error) {
+	var bills []string
+	seen := make(map[string]bool)
+	for _, it := range items {
+		var billId string
+		err := tx.QueryRowContext(ctx,
+			`SELECT invoice_id FROM item WHERE item_id = ?`,
+			it.ItemId,
+		).Scan(&billId)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[billId] {
+			seen[billId] = true
+			bills = append(bills, billId)
+		}
+	}
+	return bills, nil
+}
+
+func (r *PostgresBillRepository) GetCountries(ctx context.Context) ([]string, error) {
+	return getCountries(ctx, r.conn())
+}
+
+func (r *PostgresBillRepository) GetCurrencies(ctx context.Context) ([]string, error) {
+	return getCurrencies(ctx, r.conn())
+}
+
+func (r *PostgresBillRepository) GetTags(ctx context.Context) ([]string, error) {
+	return getTags(ctx, r.conn())
+}
+
+// ApplyMigration applies a single migration file from disk in a transaction.
+// The embedded migrations are applied in order by NewPostgresMigrator.
+func (r *PostgresBillRepository) ApplyMigration(ctx context.Context, sqlFilePath string) error {
+	sqlFile, err := os.ReadFile(sqlFilePath)
+	if err != nil {
+		return fmt.Errorf("error reading SQL file: %w", err)
+	}
+
+	return r.inTx(ctx, func(tx querier) error {
+		return applyMigration(ctx, tx, filepath.Base(sqlFilePath), string(sqlFile))
+	})
+}
+
+func (r *PostgresBillRepository) DuplicateCandidates(ctx context.Context, b *bl.Bill) ([]*bl.Bill, error) {
+	return duplicateCandidates(ctx, r.conn(), b)
+}
+
+func (r *PostgresBillRepository) DismissDuplicate(ctx context.Context, id string, other string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return dismissDuplicate(ctx, tx, id, other)
+	})
+}
+
+func (r *PostgresBillRepository) DismissedDuplicates(ctx context.Context) ([]DuplicatePair, error) {
+	return dismissedDuplicates(ctx, r.conn())
+}
+
+// ListBills returns the bills matching the filter, without items
+func (r *PostgresBillRepository) ListBills(ctx context.Context, filter BillFilter) ([]*bl.Bill, error) {
+	page, err := r.SearchBills(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	bills := make([]*bl.Bill, len(page.Hits))
+	for i, hit := range page.Hits {
+		bills[i] = hit.Bill
+	}
+	return bills, nil
+}
+
+// SearchBills returns the bills matching the filter, without items,
+// ranked by the full-text search with SortRelevance, a page at a time
+func (r *PostgresBillRepository) SearchBills(ctx context.Context, filter BillFilter) (*BillPage, error) {
+	m := tsMatch(filter.Search, "bill_search", billFtsWeights, "fts.invoice_id = invoice.invoice_id")
+	return searchBills(ctx, r.conn(), postgresDialect, filter, m)
+}
+
+// ListItems returns the items matching the filter with their bill fields
+func (r *PostgresBillRepository) ListItems(ctx context.Context, filter ItemFilter) ([]*ItemWithBill, error) {
+	page, err := r.SearchItems(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*ItemWithBill, len(page.Hits))
+	for i, hit := range page.Hits {
+		items[i] = hit.ItemWithBill
+	}
+	return items, nil
+}
+
+// SearchItems returns the items matching the filter with their bill fields,
+// ranked by the full-text search with SortRelevance, a page at a time
+func (r *PostgresBillRepository) SearchItems(ctx context.Context, filter ItemFilter) (*ItemPage, error) {
+	m := tsMatch(filter.Search, "item_search", itemFtsWeights, "fts.item_id = item.item_id")
+	return searchItems(ctx, r.conn(), postgresDialect, filter, m)
+}
+
+// History returns the changes of a bill and its items, the last first
+func (r *PostgresBillRepository) History(ctx context.Context, billId string) ([]*audit.Entry, error) {
+	return auditHistory(ctx, r.conn(), billId)
+}
+
+// GetAuditEntry returns an entry of the audit log
+func (r *PostgresBillRepository) GetAuditEntry(ctx context.Context, id int64) (*audit.Entry, error) {
+	return getAuditEntry(ctx, r.conn(), id)
+}
+
+// Revert undoes a single change, see SqliteBillRepository.Revert
+func (r *PostgresBillRepository) Revert(ctx context.Context, id int64) error {
+	e, err := r.GetAuditEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !e.Revertible() {
+		return fmt.Errorf("%w: a %s %s cannot be reverted", ErrConflict, e.Entity, e.Action)
+	}
+	ctx = context.WithValue(ctx, revertKey{}, id)
+
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		return revert(ctx, tx, e)
+	})
+}
@@ -0,0 +1,52 @@
+-- The PostgreSQL schema of the bills, the same tables and columns
+-- as the SQLite one so that the repositories share their queries.
+-- Dates and times are TEXT as there, ordered like the values.
+CREATE TABLE "invoice" (
+	"invoice_id" TEXT NOT NULL,
+	"invoice_name" TEXT NOT NULL,
+	"invoice_date" TEXT NOT NULL,
+	"invoice_price" DOUBLE PRECISION NOT NULL,
+	"invoice_currency" TEXT,
+	"invoice_country" TEXT,
+	"invoice_link" TEXT,
+	"invoice_text" TEXT,
+	-- UTC time the bill was moved to the trash, NULL for live bills
+	"deleted_at" TEXT,
+	PRIMARY KEY ("invoice_id")
+);
+CREATE INDEX "invoice_deleted_idx" ON "invoice" ("deleted_at");
+
+CREATE TABLE "item" (
+	"item_id" TEXT NOT NULL,
+	"invoice_id" TEXT NOT NULL,
+	"item_name" TEXT,
+	"item_price" DOUBLE PRECISION,
+	"item_price_one" DOUBLE PRECISION,
+	"item_quantity" DOUBLE PRECISION,
+	"item_photo" TEXT,
+	PRIMARY KEY ("item_id"),
+	FOREIGN KEY ("invoice_id") REFERENCES "invoice" ("invoice_id") ON DELETE CASCADE
+);
+CREATE INDEX "item_invoice_idx" ON "item" ("invoice_id");
+
+CREATE TABLE "tag" (
+	"tag_id" BIGINT GENERATED BY DEFAULT AS IDENTITY,
+	"tag_name" TEXT NOT NULL,
+	PRIMARY KEY ("tag_id")
+);
+
+CREATE TABLE "item_tag" (
+	"item_id" TEXT NOT NULL UNIQUE,
+	"tag_id" BIGINT NOT NULL,
+	PRIMARY KEY ("item_id", "tag_id"),
+	FOREIGN KEY ("tag_id") REFERENCES "tag" ("tag_id") ON DELETE CASCADE,
+	FOREIGN KEY ("item_id") REFERENCES "item" ("item_id") ON DELETE CASCADE
+);
+
+CREATE TABLE "invoice_tag" (
+	"invoice_id" TEXT NOT NULL UNIQUE,
+	"tag_id" BIGINT NOT NULL,
+	PRIMARY KEY ("invoice_id", "tag_id"),
+	FOREIGN KEY ("invoice_id") REFERENCES "invoice" ("invoice_id") ON DELETE CASCADE,
+	FOREIGN KEY ("tag_id") REFERENCES "tag" ("tag_id") ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+-- Full-text documents of the bills and items. The repository writes
+-- them from the text folded by search.Fold on every change, the
+-- columns weighted A to D as the ranking weights of postgres_search.go.
+CREATE TABLE "bill_search" (
+	"invoice_id" TEXT NOT NULL,
+	"document" TSVECTOR NOT NULL,
+	PRIMARY KEY ("invoice_id"),
+	FOREIGN KEY ("invoice_id") REFERENCES "invoice" ("invoice_id") ON DELETE CASCADE
+);
+CREATE INDEX "bill_search_idx" ON "bill_search" USING GIN ("document");
+
+CREATE TABLE "item_search" (
+	"item_id" TEXT NOT NULL,
+	"document" TSVECTOR NOT NULL,
+	PRIMARY KEY ("item_id"),
+	FOREIGN KEY ("item_id") REFERENCES "item" ("item_id") ON DELETE CASCADE
+);
+CREATE INDEX "item_search_idx" ON "item_search" USING GIN ("document");
@@ -0,0 +1,31 @@
+-- Append-only log of the changes to bills, items and tags.
+-- Entries outlive the rows they describe, so there are no foreign keys.
+CREATE TABLE "audit" (
+	"audit_id" BIGINT GENERATED ALWAYS AS IDENTITY,
+	"audit_time" TEXT NOT NULL,
+	"audit_source" TEXT NOT NULL,
+	"audit_action" TEXT NOT NULL,
+	"entity" TEXT NOT NULL,
+	"entity_id" TEXT NOT NULL,
+	"invoice_id" TEXT,
+	"old_values" TEXT,
+	"new_values" TEXT,
+	"reverts_id" BIGINT,
+	PRIMARY KEY ("audit_id")
+);
+CREATE INDEX "audit_invoice_idx" ON "audit" ("invoice_id", "audit_id");
+
+CREATE FUNCTION "audit_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_no_update" BEFORE UPDATE ON "audit"
+FOR EACH ROW EXECUTE FUNCTION "audit_append_only"();
+
+CREATE TRIGGER "audit_no_delete" BEFORE DELETE ON "audit"
+FOR EACH ROW EXECUTE FUNCTION "audit_append_only"();
+
+CREATE TRIGGER "audit_no_truncate" BEFORE TRUNCATE ON "audit"
+FOR EACH STATEMENT EXECUTE FUNCTION "audit_append_only"();
@@ -0,0 +1,11 @@
+-- Pairs of bills the review of duplicates found to be different
+-- receipts, invoice_id is the smaller id of the pair.
+CREATE TABLE "duplicate_dismissal" (
+	"invoice_id" TEXT NOT NULL,
+	"other_id" TEXT NOT NULL,
+	"dismissed_at" TEXT NOT NULL,
+	PRIMARY KEY ("invoice_id", "other_id"),
+	FOREIGN KEY ("invoice_id") REFERENCES "invoice" ("invoice_id") ON DELETE CASCADE,
+	FOREIGN KEY ("other_id") REFERENCES "invoice" ("invoice_id") ON DELETE CASCADE
+);
+CREATE INDEX "duplicate_dismissal_other_idx" ON "duplicate_dismissal" ("other_id");
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"billdb/internal/search"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The full-text index of PostgreSQL is a tsvector document per bill in
+// bill_search and per item in item_search. PostgreSQL has no search.Fold,
+// so the repository writes the documents from the folded words on every
+// change of a bill or its items, with the columns of bill_fts and item_fts
+// weighted A, B, C and D in order. Purged rows drop their documents by
+// the cascading foreign keys.
+
+// indexBills refreshes the documents of the bills, see indexBill
+func indexBills(ctx context.Context, tx querier, ids []string) error {
+	for _, id := range ids {
+		err := indexBill(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexBill refreshes the documents of a bill and of its items,
+// which have the merchant too
+func indexBill(ctx context.Context, tx querier, id string) error {
+	var name, tags, items, text string
+	err := tx.QueryRowContext(ctx, `SELECT
+			invoice_name,
+			coalesce((SELECT string_agg(tag_name, ' ') FROM invoice_tag
+				JOIN tag ON tag.tag_id = invoice_tag.tag_id
+				WHERE invoice_tag.invoice_id = invoice.invoice_id), ''),
+			coalesce((SELECT string_agg(item_name, ' ') FROM item WHERE item.invoice_id = invoice.invoice_id), ''),
+			coalesce(invoice_text, '')
+		FROM invoice
+		WHERE invoice_id = ?`,
+		id,
+	).Scan(&name, &tags, &items, &text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("bill %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO bill_search (invoice_id, document)
+		VALUES (?, `+tsDocument(4)+`)
+		ON CONFLICT (invoice_id) DO UPDATE SET document = excluded.document`,
+		id,
+		tsWords(name),
+		tsWords(tags),
+		tsWords(items),
+		tsWords(text),
+	)
+	if err != nil {
+		return fmt.Errorf("search index of bill %s: %w", id, err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT item_id, coalesce(item_name, '') FROM item WHERE invoice_id = ?`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for rows.Next() {
+		var itemId, itemName string
+		if err := rows.Scan(&itemId, &itemName); err != nil {
+			rows.Close()
+			return err
+		}
+		names[itemId] = itemName
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for itemId, itemName := range names {
+		_, err = tx.ExecContext(ctx, `INSERT INTO item_search (item_id, document)
+			VALUES (?, `+tsDocument(2)+`)
+			ON CONFLICT (item_id) DO UPDATE SET document = excluded.document`,
+			itemId,
+			tsWords(itemName),
+			tsWords(name),
+		)
+		if err != nil {
+			return fmt.Errorf("search index of item %s: %w", itemId, err)
+		}
+	}
+	return nil
+}
+
+// tsDocument returns the tsvector of as many columns, weighted A, B, ...
+func tsDocument(columns int) string {
+	parts := make([]string, columns)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("setweight(to_tsvector('simple', ?), '%c')", 'A'+i)
+	}
+	return strings.Join(parts, " || ")
+}
+
+// tsWords returns the folded words of text separated by spaces,
+// the simple configuration indexes them as they are
+func tsWords(text string) string {
+	return strings.Join(search.Terms(text), " ")
+}
+
+// tsMatch returns the join of table to the documents matching text,
+// with fts.rank as the negated ts_rank so that lower ranks are better
+// as with bm25. It is nil when there is nothing to search for.
+func tsMatch(text string, table string, weights []float64, on string) *ftsMatch {
+	query := tsQuery(text)
+	if query == "" {
+		return nil
+	}
+	return &ftsMatch{
+		join: fmt.Sprintf(` JOIN (
+			SELECT %[1]s.*, -ts_rank(%[2]s::real[], document, query)::float8 AS rank
+			FROM %[1]s, to_tsquery('simple', ?) AS query
+			WHERE document @@ query
+		) AS fts ON %[3]s`, table, tsWeights(weights), on),
+		args:  []any{query},
+		terms: search.Terms(text),
+	}
+}
+
+// tsQuery is ftsQuery for to_tsquery: every word must match
+// as a prefix and quoted words as a phrase
+func tsQuery(text string) string {
+	var terms []string
+	for i, part := range strings.Split(text, `"`) {
+		words := search.Terms(part)
+		if len(words) == 0 {
+			continue
+		}
+		if i%2 == 1 {
+			terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			continue
+		}
+		for _, w := range words {
+			terms = append(terms, w+":*")
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// tsWeights returns the weights of ts_rank for the columns of a document,
+// relative to the largest and in its order of D, C, B and A
+func tsWeights(weights []float64) string {
+	top := slices.Max(weights)
+	w := []string{"0", "0", "0", "0"}
+	for i, weight := range weights {
+		w[3-i] = strconv.FormatFloat(weight/top, 'f', 2, 64)
+	}
+	return "'{" + strings.Join(w, ", ") + "}'"
+}
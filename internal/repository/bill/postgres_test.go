@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// postgresDSN returns the DSN of a PostgreSQL server for the tests:
+// BILLDB_TEST_POSTGRES_DSN, or a server started in a temporary directory
+// with the initdb and pg_ctl found on the PATH or in BILLDB_TEST_POSTGRES_BIN.
+// The test is skipped without either, and fails instead when
+// BILLDB_TEST_POSTGRES_REQUIRED is set, as in the CI job of PostgreSQL.
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+	skip := t.Skip
+	if os.Getenv("BILLDB_TEST_POSTGRES_REQUIRED") != "" {
+		skip = t.Fatal
+	}
+	if dsn := os.Getenv("BILLDB_TEST_POSTGRES_DSN"); dsn != "" {
+		return dsn
+	}
+	initdb, pgCtl := "initdb", "pg_ctl"
+	if bin := os.Getenv("BILLDB_TEST_POSTGRES_BIN"); bin != "" {
+		initdb, pgCtl = filepath.Join(bin, initdb), filepath.Join(bin, pgCtl)
+	}
+	if _, err := exec.LookPath(initdb); err != nil {
+		skip("no PostgreSQL: set BILLDB_TEST_POSTGRES_DSN or put initdb and pg_ctl on the PATH")
+	}
+	if os.Geteuid() == 0 {
+		skip("no PostgreSQL: initdb does not run as root, set BILLDB_TEST_POSTGRES_DSN")
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	out, err := exec.Command(initdb, "-D", data, "-U", "billdb", "-A", "trust", "-E", "UTF8", "--no-locale", "--no-sync").CombinedOutput()
+	if err != nil {
+		t.Fatalf("initdb: %v\n%s", err, out)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	out, err = exec.Command(pgCtl, "-D", data, "-o", options, "-l", filepath.Join(dir, "log"), "-w", "start").CombinedOutput()
+	if err != nil {
+		t.Fatalf("pg_ctl start: %v\n%s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+	})
+	return fmt.Sprintf("host=127.0.0.1 port=%d user=billdb dbname=postgres sslmode=disable", port)
+}
+
+var postgresSchemas atomic.Int64
+
+// setUpPostgresDB returns a repository on a new migrated schema
+// of the server, the schema is dropped after the test
+func setUpPostgresDB(t *testing.T, dsn string) *PostgresBillRepository {
+	t.Helper()
+	admin, err := OpenPostgres(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("billdb_test_%d_%d", os.Getpid(), postgresSchemas.Add(1))
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Failed to create the schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	db, err := OpenPostgres(withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := NewPostgresMigrator(db).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return NewPostgresBillRepository(db)
+}
+
+// withSearchPath adds the search_path parameter to a URL or key=value DSN
+func withSearchPath(dsn string, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+func TestPostgresAuditAppendOnly(t *testing.T) {
+	billRepo := setUpPostgresDB(t, postgresDSN(t))
+	b := newSearchBill("Maxi", "food")
+	if err := billRepo.InsertBill(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{`UPDATE audit SET audit_source = 'web'`, `DELETE FROM audit`, `TRUNCATE audit`} {
+		if _, err := billRepo.DB.Exec(query); err == nil {
+			t.Errorf("Expected the audit log to be append-only: %s", query)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	cases := map[string]string{
+		`SELECT 1`:                             `SELECT 1`,
+		`a = ? AND b IN (?, ?)`:                `a = $1 AND b IN ($2, $3)`,
+		`name LIKE ? ESCAPE '\'`:               `name LIKE $1 ESCAPE '\'`,
+		`x = '?' AND "y?" = ? AND z = 'it''s'`: `x = '?' AND "y?" = $1 AND z = 'it''s'`,
+	}
+	for in, want := range cases {
+		if got := rebind(in); got != want {
+			t.Errorf("rebind(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTsQuery(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"Milk":              "milk:*",
+		`  "bread" OR milk`: "(bread) & or:* & milk:*",
+		"mleko-3,2%":        "mleko:* & 3:* & 2:*",
+		"ХЛЕБ":              "hleb:*",
+		`"Бели хлеб" 500`:   "(beli <-> hleb) & 500:*",
+		`"" "-" !&|`:        "",
+	}
+	for in, want := range cases {
+		if got := tsQuery(in); got != want {
+			t.Errorf("tsQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTsWeights(t *testing.T) {
+	if got := tsWeights(billFtsWeights); got != "'{0.10, 0.20, 0.50, 1.00}'" {
+		t.Errorf("Unexpected bill weights %s", got)
+	}
+	if got := tsWeights(itemFtsWeights); got != "'{0, 0, 0.20, 1.00}'" {
+		t.Errorf("Unexpected item weights %s", got)
+	}
+}
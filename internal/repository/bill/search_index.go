@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"billdb/internal/search"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The full-text index is an FTS5 table when SQLite is built with it
+// (the sqlite_fts5 build tag of go-sqlite3) and FTS4 otherwise.
+// bill_fts has a row per bill with the merchant, tag, item names and journal,
+// item_fts a row per item with its name and merchant. The _doc tables give
+// the rows stable integer ids, the triggers keep both in sync with the data.
+// The indexed text is folded by billdb_fold (search.Fold), so Cyrillic
+// and Latin spellings match, the database needs the function registered
+// by OpenSqlite to write bills.
+const (
+	searchIndexMigration     = "006_search_index"
+	foldSearchIndexMigration = "007_fold_search_index"
+
+	fts5 = "fts5"
+	fts4 = "fts4"
+)
+
+// snippets mark the matched words with these, see SearchBills
+const (
+	SnippetStart = search.MarkStart
+	SnippetEnd   = search.MarkEnd
+)
+
+// snippetWords is the length of a snippet
+const snippetWords = 12
+
+// column weights of the ranking
+var (
+	billFtsWeights = []float64{10, 5, 2, 1} // merchant, tag, items, journal
+	itemFtsWeights = []float64{5, 1}        // name, merchant
+)
+
+// refreshBillFts rebuilds the bill_fts rows of the bills with invoice_id matching cond
+func refreshBillFts(cond string) string {
+	return fmt.Sprintf(`
+	DELETE FROM bill_fts WHERE rowid IN (SELECT doc_id FROM bill_fts_doc WHERE invoice_id %[1]s);
+	DELETE FROM bill_fts_doc WHERE invoice_id %[1]s;
+	INSERT INTO bill_fts_doc (invoice_id) SELECT invoice_id FROM invoice WHERE invoice_id %[1]s;
+	INSERT INTO bill_fts (rowid, merchant, tag, items, journal)
+	SELECT
+		bill_fts_doc.doc_id,
+		billdb_fold(invoice_name),
+		billdb_fold(coalesce((SELECT group_concat(tag_name, ' ') FROM invoice_tag
+			JOIN tag ON tag.tag_id = invoice_tag.tag_id
+			WHERE invoice_tag.invoice_id = invoice.invoice_id), '')),
+		billdb_fold(coalesce((SELECT group_concat(item_name, ' ') FROM item WHERE item.invoice_id = invoice.invoice_id), '')),
+		billdb_fold(coalesce(invoice_text, ''))
+	FROM invoice
+	JOIN bill_fts_doc ON bill_fts_doc.invoice_id = invoice.invoice_id
+	WHERE invoice.invoice_id %[1]s;`, cond)
+}
+
+// refreshItemFts rebuilds the item_fts rows of the items with item_id matching cond
+func refreshItemFts(cond string) string {
+	return fmt.Sprintf(`
+	DELETE FROM item_fts WHERE rowid IN (SELECT doc_id FROM item_fts_doc WHERE item_id %[1]s);
+	DELETE FROM item_fts_doc WHERE item_id %[1]s;
+	INSERT INTO item_fts_doc (item_id) SELECT item_id FROM item WHERE item_id %[1]s;
+	INSERT INTO item_fts (rowid, name, merchant)
+	SELECT item_fts_doc.doc_id, billdb_fold(coalesce(item_name, '')), billdb_fold(invoice_name)
+	FROM item
+	JOIN item_fts_doc ON item_fts_doc.item_id = item.item_id
+	JOIN invoice ON invoice.invoice_id = item.invoice_id
+	WHERE item.item_id %[1]s;`, cond)
+}
+
+func searchIndexSchema(module string) string {
+	tokenize := "tokenize = 'unicode61'"
+	if module == fts4 {
+		tokenize = "tokenize=unicode61"
+	}
+	billOf := func(row string) string {
+		return "= " + row + ".invoice_id"
+	}
+	itemsOfBill := func(row string) string {
+		return "IN (SELECT item_id FROM item WHERE invoice_id = " + row + ".invoice_id)"
+	}
+	billsOfTag := func(row string) string {
+		return "IN (SELECT invoice_id FROM invoice_tag WHERE tag_id = " + row + ".tag_id)"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `
+	CREATE VIRTUAL TABLE bill_fts USING %[1]s(merchant, tag, items, journal, %[2]s);
+	CREATE VIRTUAL TABLE item_fts USING %[1]s(name, merchant, %[2]s);
+	CREATE TABLE bill_fts_doc (doc_id INTEGER PRIMARY KEY, invoice_id TEXT NOT NULL UNIQUE);
+	CREATE TABLE item_fts_doc (doc_id INTEGER PRIMARY KEY, item_id TEXT NOT NULL UNIQUE);
+	`, module, tokenize)
+
+	fmt.Fprintf(&b, `
+	CREATE TRIGGER search_invoice_insert AFTER INSERT ON invoice BEGIN %s END;
+	CREATE TRIGGER search_invoice_update AFTER UPDATE OF invoice_id, invoice_name, invoice_text ON invoice BEGIN %s %s %s %s END;
+	CREATE TRIGGER search_invoice_delete AFTER DELETE ON invoice BEGIN %s END;
+	`,
+		refreshBillFts(billOf("NEW")),
+		refreshBillFts(billOf("OLD")),
+		refreshBillFts(billOf("NEW")),
+		refreshItemFts(itemsOfBill("OLD")),
+		refreshItemFts(itemsOfBill("NEW")),
+		refreshBillFts(billOf("OLD")),
+	)
+	fmt.Fprintf(&b, `
+	CREATE TRIGGER search_item_insert AFTER INSERT ON item BEGIN %s %s END;
+	CREATE TRIGGER search_item_update AFTER UPDATE OF item_id, invoice_id, item_name ON item BEGIN %s %s %s %s END;
+	CREATE TRIGGER search_item_delete AFTER DELETE ON item BEGIN %s %s END;
+	`,
+		refreshBillFts(billOf("NEW")),
+		refreshItemFts("= NEW.item_id"),
+		refreshBillFts(billOf("OLD")),
+		refreshBillFts(billOf("NEW")),
+		refreshItemFts("= OLD.item_id"),
+		refreshItemFts("= NEW.item_id"),
+		refreshBillFts(billOf("OLD")),
+		refreshItemFts("= OLD.item_id"),
+	)
+	fmt.Fprintf(&b, `
+	CREATE TRIGGER search_invoice_tag_insert AFTER INSERT ON invoice_tag BEGIN %s END;
+	CREATE TRIGGER search_invoice_tag_update AFTER UPDATE ON invoice_tag BEGIN %s %s END;
+	CREATE TRIGGER search_invoice_tag_delete AFTER DELETE ON invoice_tag BEGIN %s END;
+	CREATE TRIGGER search_tag_update AFTER UPDATE OF tag_name ON tag BEGIN %s END;
+	`,
+		refreshBillFts(billOf("NEW")),
+		refreshBillFts(billOf("OLD")),
+		refreshBillFts(billOf("NEW")),
+		refreshBillFts(billOf("OLD")),
+		refreshBillFts(billsOfTag("NEW")),
+	)
+
+	b.WriteString(refreshBillFts("IN (SELECT invoice_id FROM invoice)"))
+	b.WriteString(refreshItemFts("IN (SELECT item_id FROM item)"))
+	return b.String()
+}
+
+// createSearchIndex is the Go migration creating the full-text index
+// with the best module the SQLite library has
+func createSearchIndex(tx *sql.Tx) error {
+	module := fts4
+	var hasFts5 bool
+	err := tx.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&hasFts5)
+	if err != nil {
+		return err
+	}
+	if hasFts5 {
+		module = fts5
+	}
+	_, err = tx.Exec(searchIndexSchema(module))
+	return err
+}
+
+// rebuildSearchIndex is the Go migration recreating the index
+// of 006_search_index with folded text
+func rebuildSearchIndex(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT type, name FROM sqlite_master
+		WHERE (type = 'trigger' AND name LIKE 'search\_%' ESCAPE '\')
+		OR (type = 'table' AND name IN ('bill_fts', 'item_fts', 'bill_fts_doc', 'item_fts_doc'))`)
+	if err != nil {
+		return err
+	}
+	var drops []string
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		drops = append(drops, fmt.Sprintf("DROP %s %s;", strings.ToUpper(kind), name))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(strings.Join(drops, "\n")); err != nil {
+		return err
+	}
+	return createSearchIndex(tx)
+}
+
+// searchModule returns the module of the existing index, empty if there is none
+func searchModule(ctx context.Context, q querier) (string, error) {
+	var schema string
+	err := q.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'bill_fts'`).
+		Scan(&schema)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(strings.ToLower(schema), fts5) {
+		return fts5, nil
+	}
+	return fts4, nil
+}
+
+// ftsQuery turns user input into a match expression of the folded words:
+// every word must match as a prefix and quoted words as a phrase,
+// operators are not passed through
+func ftsQuery(text string) string {
+	var terms []string
+	for i, part := range strings.Split(text, `"`) {
+		words := search.Terms(part)
+		if len(words) == 0 {
+			continue
+		}
+		if i%2 == 1 {
+			terms = append(terms, `"`+strings.Join(words, " ")+`"`)
+			continue
+		}
+		for _, w := range words {
+			terms = append(terms, w+"*")
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// ftsRankColumn returns the rank expression of an index table,
+// lower ranks are better as with bm25
+func ftsRankColumn(module string, table string, weights []float64) string {
+	w := make([]string, len(weights))
+	for i, weight := range weights {
+		w[i] = fmt.Sprintf("%.2f", weight) // a float literal, fts_rank takes floats
+	}
+	if module == fts5 {
+		return fmt.Sprintf("bm25(%s, %s)", table, strings.Join(w, ", "))
+	}
+	return fmt.Sprintf("fts_rank(matchinfo(%s, 'pcnx'), %s)", table, strings.Join(w, ", "))
+}
+
+// ftsRank scores an FTS4 row from matchinfo 'pcnx' like bm25 without
+// the length normalization: the sum over the phrases and columns of
+// the weighted hits times the inverse document frequency, negated.
+func ftsRank(info []byte, weights ...float64) float64 {
+	// matchinfo is an array of unsigned 32-bit integers in native byte order
+	ints := make([]uint32, len(info)/4)
+	for i := range ints {
+		ints[i] = binary.NativeEndian.Uint32(info[4*i:])
+	}
+	if len(ints) < 3 {
+		return 0
+	}
+	phrases, columns, docs := int(ints[0]), int(ints[1]), float64(ints[2])
+	x := ints[3:]
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns; c++ {
+			i := 3 * (p*columns + c)
+			if i+2 >= len(x) {
+				return -score
+			}
+			hits, withHits := float64(x[i]), float64(x[i+2])
+			if hits == 0 {
+				continue
+			}
+			weight := 1.0
+			if c < len(weights) {
+				weight = weights[c]
+			}
+			idf := math.Log(1 + (docs-withHits+0.5)/(withHits+0.5))
+			score += weight * hits * idf
+		}
+	}
+	return -score
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+func setUpSearchDB(t *testing.T) *SqliteBillRepository {
+	t.Helper()
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	if _, err := NewMigrator(billRepo.DB).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return billRepo
+}
+
+func newSearchBill(name string, tagName string, items ...string) *bill.Bill {
+	id := ksuid.New().String()
+	b := bill.New(id, name, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 100,
+		currency.RSD, country.SERBIA, nil, tag.New(tagName), "", "")
+	for _, name := range items {
+		b.Items = append(b.Items, item.New(ksuid.New().String(), id, name, 10, 10, 1))
+	}
+	return b
+}
+
+func searchIds(t *testing.T, billRepo *SqliteBillRepository, filter BillFilter) []string {
+	t.Helper()
+	page, err := billRepo.SearchBills(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Failed to search %q: %v", filter.Search, err)
+	}
+	ids := make([]string, len(page.Hits))
+	for i, hit := range page.Hits {
+		ids[i] = hit.Id
+	}
+	return ids
+}
+
+func TestSearchIndexSync(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	ctx := context.Background()
+
+	// bills from before the index are indexed by the migration
+	b := newSearchBill("Maxi", "food", "milk")
+	if err := billRepo.InsertBillWithItems(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIds(t, billRepo, BillFilter{Search: "milk"}); len(ids) != 1 || ids[0] != b.Id {
+		t.Fatalf("Expected the bill by item name, got %v", ids)
+	}
+
+	b.Name = "Lidl"
+	b.Tag = tag.New("home")
+	if err := billRepo.UpdateBill(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"maxi", "food"} {
+		if ids := searchIds(t, billRepo, BillFilter{Search: text}); len(ids) != 0 {
+			t.Errorf("Expected no match for %q after the update, got %v", text, ids)
+		}
+	}
+	for _, text := range []string{"lidl", "home"} {
+		if ids := searchIds(t, billRepo, BillFilter{Search: text}); len(ids) != 1 {
+			t.Errorf("Expected a match for %q after the update, got %v", text, ids)
+		}
+	}
+
+	// renamed merchants are found by their items
+	items, err := billRepo.ListItems(ctx, ItemFilter{Search: "lidl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "milk" {
+		t.Errorf("Expected the item by its merchant, got %v", items)
+	}
+
+	if err := billRepo.DeleteItems(ctx, b.Items); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIds(t, billRepo, BillFilter{Search: "milk"}); len(ids) != 0 {
+		t.Errorf("Expected no match for a deleted item, got %v", ids)
+	}
+
+	if err := billRepo.DeleteBill(ctx, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIds(t, billRepo, BillFilter{Search: "lidl"}); len(ids) != 0 {
+		t.Errorf("Expected no match for a bill in the trash, got %v", ids)
+	}
+	if err := billRepo.PurgeBill(ctx, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"bill_fts", "bill_fts_doc", "item_fts", "item_fts_doc"} {
+		var n int
+		if err := billRepo.DB.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("Expected %s to be empty after the purge, got %d rows", table, n)
+		}
+	}
+}
+
+func TestSearchBills(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	ctx := context.Background()
+	bills := []*bill.Bill{
+		newSearchBill("Shop", "bakery", "bread"),
+		newSearchBill("Bakery Jovanović", "food", "bread", "bakery bag"),
+		newSearchBill("Maxi", "food", "milk"),
+	}
+	for _, b := range bills {
+		if err := billRepo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// prefix matching, the merchant weighs more than the tag
+	ids := searchIds(t, billRepo, BillFilter{Search: "bake", Sort: SortRelevance})
+	if len(ids) != 2 || ids[0] != bills[1].Id || ids[1] != bills[0].Id {
+		t.Errorf("Unexpected ranking: %v", ids)
+	}
+	// every word has to match, operators are ignored
+	ids = searchIds(t, billRepo, BillFilter{Search: `jovan "OR" maxi`})
+	if len(ids) != 0 {
+		t.Errorf("Expected no bill with both words, got %v", ids)
+	}
+	// diacritics are folded
+	for _, text := range []string{"jovanović", "jovanovic", "ЈОВАНОВИЋ"} {
+		ids = searchIds(t, billRepo, BillFilter{Search: text})
+		if len(ids) != 1 || ids[0] != bills[1].Id {
+			t.Errorf("Expected the bill by %q, got %v", text, ids)
+		}
+	}
+	// search combines with the other filters
+	ids = searchIds(t, billRepo, BillFilter{Search: "bread", Tags: []string{"bakery"}})
+	if len(ids) != 1 || ids[0] != bills[0].Id {
+		t.Errorf("Expected the filtered bill, got %v", ids)
+	}
+
+	page, err := billRepo.SearchBills(ctx, BillFilter{Search: "milk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := page.Hits
+	if len(hits) != 1 || !strings.Contains(hits[0].Snippet, SnippetStart+"milk"+SnippetEnd) {
+		t.Errorf("Expected a highlighted snippet, got %v", hits)
+	}
+}
+
+func TestSearchTransliteration(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	ctx := context.Background()
+	cyrillic := newSearchBill("МАКСИ", "храна", "ХЛЕБ БЕЛИ", "ЂУМБИР")
+	latin := newSearchBill("Idea", "hrana", "Hleb crni", "Čokolada")
+	for _, b := range []*bill.Bill{cyrillic, latin} {
+		if err := billRepo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, text := range []string{"hleb", "ХЛЕБ", "хле", "hrana"} {
+		ids := searchIds(t, billRepo, BillFilter{Search: text})
+		if len(ids) != 2 {
+			t.Errorf("Expected both bills for %q, got %v", text, ids)
+		}
+	}
+	for text, want := range map[string]string{"djumbir": cyrillic.Id, "đumbir": cyrillic.Id, "чоко": latin.Id, "maksi": cyrillic.Id} {
+		ids := searchIds(t, billRepo, BillFilter{Search: text})
+		if len(ids) != 1 || ids[0] != want {
+			t.Errorf("Expected one bill for %q, got %v", text, ids)
+		}
+	}
+
+	// snippets keep the original script
+	page, err := billRepo.SearchItems(ctx, ItemFilter{Search: "hleb", Sort: SortName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := page.Hits
+	if len(items) != 2 {
+		t.Fatalf("Expected both bread items, got %d", len(items))
+	}
+	for _, it := range items {
+		want := map[string]string{
+			"ХЛЕБ БЕЛИ": SnippetStart + "ХЛЕБ" + SnippetEnd + " БЕЛИ МАКСИ",
+			"Hleb crni": SnippetStart + "Hleb" + SnippetEnd + " crni Idea",
+		}[it.Name]
+		if it.Snippet != want {
+			t.Errorf("Expected snippet %q, got %q", want, it.Snippet)
+		}
+	}
+
+	// the substring fallback folds as well
+	if _, err := billRepo.DB.Exec(`DROP TABLE bill_fts`); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIds(t, billRepo, BillFilter{Search: "maks"}); len(ids) != 1 || ids[0] != cyrillic.Id {
+		t.Errorf("Expected the Cyrillic merchant by Latin text, got %v", ids)
+	}
+}
+
+func TestFtsQuery(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"Milk":              "milk*",
+		`  "bread" OR milk`: `"bread" or* milk*`,
+		"mleko-3,2%":        "mleko* 3* 2*",
+		"Čokolada NEAR(x":   "cokolada* near* x*",
+		"ХЛЕБ":              "hleb*",
+		`"Бели хлеб" 500`:   `"beli hleb" 500*`,
+		`"" "-"`:            "",
+		"*^-":               "",
+	}
+	for in, want := range cases {
+		if got := ftsQuery(in); got != want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFtsRank(t *testing.T) {
+	// one phrase over two columns of 10 rows,
+	// hits in row / hits in all rows / rows with hits for every column
+	info := func(x ...uint32) []byte {
+		b := make([]byte, 4*len(x))
+		for i, v := range x {
+			binary.NativeEndian.PutUint32(b[4*i:], v)
+		}
+		return b
+	}
+	first := ftsRank(info(1, 2, 10, 1, 1, 1, 0, 3, 3), 10, 1)
+	second := ftsRank(info(1, 2, 10, 0, 1, 1, 1, 3, 3), 10, 1)
+	if !(first < second && second < 0) {
+		t.Errorf("Expected the hit in the heavier column to rank first, got %f and %f", first, second)
+	}
+	if ftsRank(nil) != 0 {
+		t.Error("Expected no score without matchinfo")
+	}
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"billdb/internal/search"
+	"database/sql"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is go-sqlite3 with the functions used by the queries registered
+const driverName = "sqlite3_billdb"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.RegisterFunc("fts_rank", ftsRank, true)
+			if err != nil {
+				return err
+			}
+			return conn.RegisterFunc("billdb_fold", search.Fold, true)
+		},
+	})
+}
+
+// OpenSqlite opens the SQLite database at path with foreign keys enforced.
+// The pragma is per connection, so it is passed in the DSN
+// and applied by the driver to every connection of the pool.
+func OpenSqlite(path string) (*sql.DB, error) {
+	dsn := path
+	if !strings.Contains(dsn, "_foreign_keys=") && !strings.Contains(dsn, "_fk=") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_foreign_keys=on"
+	}
+	return sql.Open(driverName, dsn)
+}
@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/item"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// revertKey marks the context of the changes made by Revert
+// with the id of the reverted entry
+type revertKey struct{}
+
+// record appends a change to the audit log with the source of ctx
+func record(ctx context.Context, tx querier, e *audit.Entry) error {
+	before, err := marshalValues(e.Old)
+	if err != nil {
+		return err
+	}
+	after, err := marshalValues(e.New)
+	if err != nil {
+		return err
+	}
+	var reverts sql.NullInt64
+	if id, ok := ctx.Value(revertKey{}).(int64); ok {
+		reverts = sql.NullInt64{Int64: id, Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO audit (
+			audit_time,
+			audit_source,
+			audit_action,
+			entity,
+			entity_id,
+			invoice_id,
+			old_values,
+			new_values,
+			reverts_id
+		)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		formatTimestamp(time.Now()),
+		audit.SourceOf(ctx),
+		e.Action,
+		e.Entity,
+		e.EntityId,
+		e.BillId,
+		before,
+		after,
+		reverts,
+	)
+	if err != nil {
+		return fmt.Errorf("audit %s %s %s: %w", e.Action, e.Entity, e.EntityId, err)
+	}
+	return nil
+}
+
+// RecordAudit appends e to the audit log in tx, for the changes made
+// to the database outside of the repository, as by the check repairs
+func RecordAudit(ctx context.Context, tx *sql.Tx, e *audit.Entry) error {
+	return record(ctx, tx, e)
+}
+
+func marshalValues(values map[string]string) (sql.NullString, error) {
+	if values == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// billValues returns the audited fields of a bill
+func billValues(b *bl.Bill) map[string]string {
+	return map[string]string{
+		"name":     b.Name,
+		"date":     b.GetDateString(),
+		"price":    formatPrice(b.Price),
+		"currency": b.GetCurrencyString(),
+		"country":  b.GetCountryString(),
+		"tag":      tagName(b),
+		"link":     b.Link,
+		"text":     b.BillText,
+	}
+}
+
+// tagName returns the name of the tag of b, empty without a tag
+func tagName(b *bl.Bill) string {
+	if b.Tag != nil && b.Tag.Valid {
+		return b.Tag.String
+	}
+	return ""
+}
+
+// readBillValues returns the audited fields of a stored bill,
+// of the trash too
+func readBillValues(ctx context.Context, tx querier, id string) (map[string]string, error) {
+	var (
+		name, date, currency, country string
+		price                         float64
+		tagName, link, text           sql.NullString
+	)
+	err := tx.QueryRowContext(ctx, `SELECT
+			invoice_name,
+			invoice_date,
+			invoice_price,
+			invoice_currency,
+			invoice_country,
+			tag.tag_name,
+			invoice_link,
+			invoice_text
+		FROM invoice
+		LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
+		LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
+		WHERE invoice.invoice_id = ?`,
+		id,
+	).Scan(&name, &date, &price, &currency, &country, &tagName, &link, &text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bill %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"name":     name,
+		"date":     date,
+		"price":    formatPrice(price),
+		"currency": currency,
+		"country":  country,
+		"tag":      tagName.String,
+		"link":     link.String,
+		"text":     text.String,
+	}, nil
+}
+
+// setBillValue sets a field of billValues on a bill
+func setBillValue(b *bl.Bill, field string, value string) error {
+	switch field {
+	case "name":
+		b.Name = value
+	case "text":
+		b.BillText = value
+	case "date", "price", "currency", "country", "tag", "link":
+		return bl.UpdateBillProperty(b, field, value)
+	default:
+		return fmt.Errorf("unknown bill field %q", field)
+	}
+	return nil
+}
+
+// itemValues returns the audited fields of an item
+func itemValues(it *item.Item) map[string]string {
+	return map[string]string{
+		"bill_id":   it.BillId,
+		"name":      it.Name,
+		"price":     formatPrice(it.Price),
+		"price_one": formatPrice(it.PriceOne),
+		"quantity":  formatPrice(it.Quantity),
+	}
+}
+
+// readItemValues returns the audited fields of a stored item
+func readItemValues(ctx context.Context, tx querier, id string) (map[string]string, error) {
+	var billId string
+	var name sql.NullString
+	var price, priceOne, quantity sql.NullFloat64
+	err := tx.QueryRowContext(ctx, `SELECT
+			invoice_id,
+			item_name,
+			item_price,
+			item_price_one,
+			item_quantity
+		FROM item
+		WHERE item_id = ?`,
+		id,
+	).Scan(&billId, &name, &price, &priceOne, &quantity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("item %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return itemValues(item.New(id, billId, name.String, price.Float64, priceOne.Float64, quantity.Float64)), nil
+}
+
+// ReadItemValues returns the audited fields of a stored item in tx,
+// of an item whose bill is missing too
+func ReadItemValues(ctx context.Context, tx *sql.Tx, id string) (map[string]string, error) {
+	return readItemValues(ctx, tx, id)
+}
+
+// itemOfValues is the item of itemValues
+func itemOfValues(id string, values map[string]string) (*item.Item, error) {
+	numbers := make([]float64, 3)
+	for i, field := range []string{"price", "price_one", "quantity"} {
+		n, err := strconv.ParseFloat(values[field], 64)
+		if err != nil {
+			return nil, fmt.Errorf("item %s: %s: %w", id, field, err)
+		}
+		numbers[i] = n
+	}
+	return item.New(id, values["bill_id"], values["name"], numbers[0], numbers[1], numbers[2]), nil
+}
+
+// recordTag logs a tag created for a bill
+func recordTag(ctx context.Context, tx querier, tagID int64, bill *bl.Bill) error {
+	return record(ctx, tx, &audit.Entry{
+		Action:   audit.Create,
+		Entity:   audit.Tag,
+		EntityId: strconv.FormatInt(tagID, 10),
+		BillId:   bill.Id,
+		New:      map[string]string{"name": bill.Tag.String},
+	})
+}
+
+// changed returns the fields whose values differ, before and after
+func changed(old map[string]string, current map[string]string) (map[string]string, map[string]string) {
+	before, after := map[string]string{}, map[string]string{}
+	for field, value := range current {
+		if old[field] != value {
+			before[field] = old[field]
+			after[field] = value
+		}
+	}
+	return before, after
+}
+
+// History returns the changes of a bill and its items, the last first
+func (r *SqliteBillRepository) History(ctx context.Context, billId string) ([]*audit.Entry, error) {
+	return auditHistory(ctx, r.conn(), billId)
+}
+
+func auditHistory(ctx context.Context, q querier, billId string) ([]*audit.Entry, error) {
+	rows, err := q.QueryContext(ctx, auditSelect+`
+		WHERE invoice_id = ?
+		ORDER BY audit_id DESC`,
+		billId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*audit.Entry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetAuditEntry returns an entry of the audit log
+func (r *SqliteBillRepository) GetAuditEntry(ctx context.Context, id int64) (*audit.Entry, error) {
+	return getAuditEntry(ctx, r.conn(), id)
+}
+
+func getAuditEntry(ctx context.Context, q querier, id int64) (*audit.Entry, error) {
+	row := q.QueryRowContext(ctx, auditSelect+` WHERE audit_id = ?`, id)
+	e, err := scanAuditEntry(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("audit entry %d: %w", id, ErrNotFound)
+	}
+	return e, err
+}
+
+const auditSelect = `SELECT
+		audit_id,
+		audit_time,
+		audit_source,
+		audit_action,
+		entity,
+		entity_id,
+		invoice_id,
+		old_values,
+		new_values,
+		reverts_id
+	FROM audit`
+
+func scanAuditEntry(scan func(dest ...any) error) (*audit.Entry, error) {
+	var (
+		e             audit.Entry
+		at            string
+		billId        sql.NullString
+		before, after sql.NullString
+		reverts       sql.NullInt64
+	)
+	err := scan(
+		&e.Id,
+		&at,
+		&e.Source,
+		&e.Action,
+		&e.Entity,
+		&e.EntityId,
+		&billId,
+		&before,
+		&after,
+		&reverts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.Time, err = time.Parse(timestampLayout, at)
+	if err != nil {
+		return nil, fmt.Errorf("audit entry %d: time: %w", e.Id, err)
+	}
+	e.BillId = billId.String
+	e.Reverts = reverts.Int64
+	for _, v := range []struct {
+		raw    sql.NullString
+		values *map[string]string
+	}{{before, &e.Old}, {after, &e.New}} {
+		if !v.raw.Valid {
+			continue
+		}
+		err = json.Unmarshal([]byte(v.raw.String), v.values)
+		if err != nil {
+			return nil, fmt.Errorf("audit entry %d: values: %w", e.Id, err)
+		}
+	}
+	return &e, nil
+}
+
+// Revert undoes a single change. The changes it makes are logged
+// as reverting the entry. Updates are reverted only while the fields
+// still have the values they were changed to, otherwise and for
+// changes that cannot be undone it returns ErrConflict.
+func (r *SqliteBillRepository) Revert(ctx context.Context, id int64) error {
+	e, err := r.GetAuditEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !e.Revertible() {
+		return fmt.Errorf("%w: a %s %s cannot be reverted", ErrConflict, e.Entity, e.Action)
+	}
+	ctx = context.WithValue(ctx, revertKey{}, id)
+
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		return revert(ctx, tx, e)
+	})
+}
+
+// revert undoes the change of e through tx, the repository
+// bound to the unit of work of Revert
+func revert(ctx context.Context, tx BillRepository, e *audit.Entry) error {
+	switch {
+	case e.Entity == audit.Bill && (e.Action == audit.Create || e.Action == audit.Restore):
+		return tx.DeleteBill(ctx, e.EntityId)
+	case e.Entity == audit.Bill && e.Action == audit.Delete:
+		return tx.RestoreBill(ctx, e.EntityId)
+	case e.Entity == audit.Bill && e.Action == audit.Update:
+		bill, err := tx.GetBillByID(ctx, e.EntityId)
+		if err != nil {
+			return err
+		}
+		current := billValues(bill)
+		for field, value := range e.New {
+			if current[field] != value {
+				return fmt.Errorf("%w: %s of bill %s changed since", ErrConflict, field, e.EntityId)
+			}
+		}
+		for field, value := range e.Old {
+			err = setBillValue(bill, field, value)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.UpdateBill(ctx, bill)
+	case e.Entity == audit.Item && e.Action == audit.Create:
+		items, err := tx.GetItemsByID(ctx, e.BillId)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(items, func(it *item.Item) bool {
+			return it.ItemId == e.EntityId
+		})
+		if i < 0 {
+			return fmt.Errorf("item %s: %w", e.EntityId, ErrNotFound)
+		}
+		if !maps.Equal(itemValues(items[i]), e.New) {
+			return fmt.Errorf("%w: item %s changed since", ErrConflict, e.EntityId)
+		}
+		return tx.DeleteItems(ctx, []*item.Item{{ItemId: e.EntityId, BillId: e.BillId}})
+	case e.Entity == audit.Item && e.Action == audit.Delete:
+		it, err := itemOfValues(e.EntityId, e.Old)
+		if err != nil {
+			return err
+		}
+		return tx.InsertItems(ctx, []*item.Item{it})
+	}
+	return fmt.Errorf("%w: a %s %s cannot be reverted", ErrConflict, e.Entity, e.Action)
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	"billdb/internal/bill/tag"
+	"context"
+	"errors"
+	"testing"
+)
+
+// actions returns the action and entity of the entries, "update bill"
+func actions(entries []*audit.Entry) []string {
+	s := make([]string, len(entries))
+	for i, e := range entries {
+		s[i] = string(e.Action) + " " + string(e.Entity)
+	}
+	return s
+}
+
+func history(t *testing.T, billRepo *SqliteBillRepository, billId string) []*audit.Entry {
+	t.Helper()
+	entries, err := billRepo.History(context.Background(), billId)
+	if err != nil {
+		t.Fatalf("Failed to get the history: %v", err)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	web := audit.WithSource(context.Background(), audit.SourceWeb)
+	api := audit.WithSource(context.Background(), audit.SourceAPI)
+
+	b := newSearchBill("Maxi", "food", "milk", "bread")
+	if err := billRepo.InsertBillWithItems(api, b); err != nil {
+		t.Fatal(err)
+	}
+	b.Name = "Maxi 24"
+	b.Tag = tag.New("home")
+	if err := billRepo.UpdateBill(web, b); err != nil {
+		t.Fatal(err)
+	}
+	// an update without changes is not logged
+	if err := billRepo.UpdateBill(web, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := billRepo.DeleteItems(web, b.Items[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := history(t, billRepo, b.Id)
+	want := []string{"delete item", "create tag", "update bill", "create item", "create item", "create tag", "create bill"}
+	if got := actions(entries); len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Expected %q at %d, got %q", want[i], i, got[i])
+			}
+		}
+	}
+	if entries[len(entries)-1].Source != audit.SourceAPI || entries[0].Source != audit.SourceWeb {
+		t.Errorf("Expected the sources of the contexts, got %s and %s", entries[len(entries)-1].Source, entries[0].Source)
+	}
+	update := entries[2]
+	if len(update.New) != 2 || update.Old["name"] != "Maxi" || update.New["name"] != "Maxi 24" ||
+		update.Old["tag"] != "food" || update.New["tag"] != "home" {
+		t.Errorf("Expected the changed fields only, got %v -> %v", update.Old, update.New)
+	}
+	if entries[0].Old["name"] != "milk" || entries[0].Old["bill_id"] != b.Id {
+		t.Errorf("Expected the values of the deleted item, got %v", entries[0].Old)
+	}
+
+	_, err := billRepo.DB.Exec(`UPDATE audit SET audit_source = 'web'`)
+	if err == nil {
+		t.Errorf("Expected the audit log to be append-only")
+	}
+	_, err = billRepo.DB.Exec(`DELETE FROM audit`)
+	if err == nil {
+		t.Errorf("Expected the audit log to be append-only")
+	}
+}
+
+func TestRevert(t *testing.T) {
+	billRepo := setUpSearchDB(t)
+	ctx := audit.WithSource(context.Background(), audit.SourceWeb)
+
+	b := newSearchBill("Maxi", "food", "milk")
+	if err := billRepo.InsertBillWithItems(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	b.Name = "Lidl"
+	if err := billRepo.UpdateBill(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	rename := history(t, billRepo, b.Id)[0]
+
+	b.Price = 200
+	if err := billRepo.UpdateBill(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	// the rename is reverted without the later price change
+	if err := billRepo.Revert(ctx, rename.Id); err != nil {
+		t.Fatalf("Failed to revert the rename: %v", err)
+	}
+	got, err := billRepo.GetBillByID(ctx, b.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Maxi" || got.Price != 200 {
+		t.Errorf("Expected Maxi for 200, got %s for %v", got.Name, got.Price)
+	}
+	if e := history(t, billRepo, b.Id)[0]; e.Reverts != rename.Id || e.Action != audit.Update {
+		t.Errorf("Expected an update reverting %d, got %+v", rename.Id, e)
+	}
+	if err := billRepo.Revert(ctx, rename.Id); !errors.Is(err, ErrConflict) {
+		t.Errorf("Reverting twice: expected ErrConflict, got %v", err)
+	}
+
+	// items
+	if err := billRepo.DeleteItems(ctx, b.Items); err != nil {
+		t.Fatal(err)
+	}
+	if err := billRepo.Revert(ctx, history(t, billRepo, b.Id)[0].Id); err != nil {
+		t.Fatalf("Failed to revert the item delete: %v", err)
+	}
+	items, err := billRepo.GetItemsByID(ctx, b.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "milk" || items[0].Price != 10 {
+		t.Errorf("Expected the item back, got %v", items)
+	}
+
+	// trash
+	if err := billRepo.DeleteBill(ctx, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := billRepo.Revert(ctx, history(t, billRepo, b.Id)[0].Id); err != nil {
+		t.Fatalf("Failed to revert the delete: %v", err)
+	}
+	if _, err := billRepo.GetBillByID(ctx, b.Id); err != nil {
+		t.Errorf("Expected the bill restored, got %v", err)
+	}
+	if err := billRepo.DeleteBill(ctx, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := billRepo.PurgeBill(ctx, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	purge := history(t, billRepo, b.Id)[0]
+	if purge.Action != audit.Purge || purge.Old["name"] != "Maxi" {
+		t.Errorf("Expected the purge with the values of the bill, got %+v", purge)
+	}
+	if err := billRepo.Revert(ctx, purge.Id); !errors.Is(err, ErrConflict) {
+		t.Errorf("Reverting a purge: expected ErrConflict, got %v", err)
+	}
+	if err := billRepo.Revert(ctx, 1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Reverting a missing entry: expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,390 @@
+package repository
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"billdb/internal/search"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// where collects the conditions of a query with their arguments
+type where struct {
+	conds []string
+	args  []any
+	// like is the LIKE operator of the dialect
+	like string
+}
+
+func (w *where) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+// in adds "column IN (?, ...)", nothing for an empty list
+func (w *where) in(column string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	w.add(column+" IN ("+placeholders+")", args...)
+}
+
+// likeAny adds a substring match over any of the columns
+func (w *where) likeAny(text string, columns ...string) {
+	if text == "" {
+		return
+	}
+	pattern := "%" + escapeLike(text) + "%"
+	conds := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		conds[i] = column + " " + w.like + ` ? ESCAPE '\'`
+		args[i] = pattern
+	}
+	w.add("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f *BillFilter) where(d dialect) *where {
+	w := &where{like: d.like}
+	w.add("invoice.deleted_at IS NULL")
+	if !f.From.IsZero() {
+		w.add("invoice_date >= ?", bl.DateToString(f.From))
+	}
+	if !f.To.IsZero() {
+		w.add("invoice_date < ?", bl.DateToString(f.To))
+	}
+	w.in("tag.tag_name", f.Tags)
+	currencies := make([]string, len(f.Currencies))
+	for i, c := range f.Currencies {
+		currencies[i] = c.String()
+	}
+	w.in("invoice_currency", currencies)
+	countries := make([]string, len(f.Countries))
+	for i, c := range f.Countries {
+		countries[i] = c.String()
+	}
+	w.in("invoice_country", countries)
+	w.likeAny(f.Merchant, "invoice_name")
+	w.likeAny(f.Text, "invoice_name", "tag.tag_name", "invoice_date", "invoice_currency", "invoice_country")
+	if f.MinPrice != nil {
+		w.add("invoice_price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		w.add("invoice_price <= ?", *f.MaxPrice)
+	}
+	return w
+}
+
+func (f *ItemFilter) where(d dialect) *where {
+	w := &where{like: d.like}
+	w.add("invoice.deleted_at IS NULL")
+	if !f.From.IsZero() {
+		w.add("invoice_date >= ?", bl.DateToString(f.From))
+	}
+	if !f.To.IsZero() {
+		w.add("invoice_date < ?", bl.DateToString(f.To))
+	}
+	w.in("tag.tag_name", f.Tags)
+	if f.BillId != "" {
+		w.add("item.invoice_id = ?", f.BillId)
+	}
+	w.likeAny(f.Merchant, "invoice_name")
+	w.likeAny(f.Text, "item_name", "tag.tag_name", "invoice_date")
+	if f.MinPrice != nil {
+		w.add("item_price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		w.add("item_price <= ?", *f.MaxPrice)
+	}
+	return w
+}
+
+// ftsMatch is the join of a query to the rows of a full-text index
+type ftsMatch struct {
+	join  string
+	args  []any
+	terms []string
+}
+
+// snippet returns the words of text around the matches, empty without a search
+func (m *ftsMatch) snippet(text string) string {
+	if m == nil {
+		return ""
+	}
+	return search.Snippet(text, m.terms, snippetWords)
+}
+
+// match returns the join of table to the index rows matching text,
+// with fts.rank. It is nil when there is nothing to search for
+// or no index, then the text is matched as a substring.
+func (r *SqliteBillRepository) match(ctx context.Context, text string, index string, weights []float64, on string) (*ftsMatch, error) {
+	query := ftsQuery(text)
+	if query == "" {
+		return nil, nil
+	}
+	module, err := searchModule(ctx, r.conn())
+	if err != nil || module == "" {
+		return nil, err
+	}
+	return &ftsMatch{
+		join: fmt.Sprintf(` JOIN (
+			SELECT %[1]s_doc.*, %[2]s AS rank
+			FROM %[1]s JOIN %[1]s_doc ON %[1]s_doc.doc_id = %[1]s.rowid
+			WHERE %[1]s MATCH ?
+		) AS fts ON %[3]s`, index, ftsRankColumn(module, index, weights), on),
+		args:  []any{query},
+		terms: search.Terms(text),
+	}, nil
+}
+
+// likeFolded adds a match of the text folded by fold over any of the columns
+func (w *where) likeFolded(fold string, text string, columns ...string) {
+	folded := make([]string, len(columns))
+	for i, column := range columns {
+		folded[i] = fold + "(" + column + ")"
+	}
+	w.likeAny(search.Fold(strings.ReplaceAll(text, `"`, "")), folded...)
+}
+
+// ListBills returns the bills matching the filter, without items
+func (r *SqliteBillRepository) ListBills(ctx context.Context, filter BillFilter) ([]*bl.Bill, error) {
+	page, err := r.SearchBills(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	bills := make([]*bl.Bill, len(page.Hits))
+	for i, hit := range page.Hits {
+		bills[i] = hit.Bill
+	}
+	return bills, nil
+}
+
+// SearchBills returns the bills matching the filter, without items,
+// ranked by the full-text search with SortRelevance, a page at a time
+func (r *SqliteBillRepository) SearchBills(ctx context.Context, filter BillFilter) (*BillPage, error) {
+	m, err := r.match(ctx, filter.Search, "bill_fts", billFtsWeights, "fts.invoice_id = invoice.invoice_id")
+	if err != nil {
+		return nil, err
+	}
+	return searchBills(ctx, r.conn(), sqliteDialect, filter, m)
+}
+
+// searchBills is SearchBills with the full-text match m of the backend,
+// without a match the search is a substring of the folded text
+func searchBills(ctx context.Context, q querier, d dialect, filter BillFilter, m *ftsMatch) (*BillPage, error) {
+	w := filter.where(d)
+	var args []any
+	join := ""
+	if m != nil {
+		join = m.join
+		args = m.args
+	} else if d.fold != "" {
+		w.likeFolded(d.fold, filter.Search, "invoice_name", "tag.tag_name", "invoice_text")
+	}
+	keys := sortKeys(filter.Sort, billSort, m)
+	err := w.after(filter.Cursor, filter.Sort, keys)
+	if err != nil {
+		return nil, err
+	}
+	size := pageSize(filter.Limit)
+	// the text of the snippet, read for a full-text search only
+	text := "''"
+	if m != nil {
+		text = `invoice_name || ' ' ||
+				coalesce((SELECT ` + fmt.Sprintf(d.concat, "item_name") + ` FROM item WHERE item.invoice_id = invoice.invoice_id), '') || ' ' ||
+				coalesce(invoice_text, '')`
+	}
+	query := `SELECT
+			invoice.invoice_id,
+			invoice_name,
+			invoice_date,
+			invoice_price,
+			invoice_currency,
+			invoice_country,
+			tag.tag_name,
+			invoice_link,
+			coalesce(invoice_fiscal_id, ''),
+			` + text + `,
+			` + columns(keys) + `
+		FROM invoice` + join + `
+		LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
+		LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id` +
+		w.String() +
+		orderBy(keys) +
+		" LIMIT ? OFFSET ?"
+	args = append(append(args, w.args...), size+1, max(filter.Offset, 0))
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &BillPage{Hits: []*BillHit{}}
+	var last []any
+	for rows.Next() {
+		var fiscalId, text string
+		values := make([]any, len(keys))
+		bill, err := scanBill(rows.Scan, append([]any{&fiscalId, &text}, pointers(values)...)...)
+		if err != nil {
+			return nil, err
+		}
+		bill.FiscalId = fiscalId
+		if len(page.Hits) == size {
+			page.Next = encodeCursor(filter.Sort, last)
+			break
+		}
+		last = values
+		page.Hits = append(page.Hits, &BillHit{Bill: bill, Snippet: m.snippet(text)})
+	}
+	return page, rows.Err()
+}
+
+// ListItems returns the items matching the filter with their bill fields
+func (r *SqliteBillRepository) ListItems(ctx context.Context, filter ItemFilter) ([]*ItemWithBill, error) {
+	page, err := r.SearchItems(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*ItemWithBill, len(page.Hits))
+	for i, hit := range page.Hits {
+		items[i] = hit.ItemWithBill
+	}
+	return items, nil
+}
+
+// SearchItems returns the items matching the filter with their bill fields,
+// ranked by the full-text search with SortRelevance, a page at a time
+func (r *SqliteBillRepository) SearchItems(ctx context.Context, filter ItemFilter) (*ItemPage, error) {
+	m, err := r.match(ctx, filter.Search, "item_fts", itemFtsWeights, "fts.item_id = item.item_id")
+	if err != nil {
+		return nil, err
+	}
+	return searchItems(ctx, r.conn(), sqliteDialect, filter, m)
+}
+
+// searchItems is SearchItems with the full-text match m of the backend
+func searchItems(ctx context.Context, q querier, d dialect, filter ItemFilter, m *ftsMatch) (*ItemPage, error) {
+	w := filter.where(d)
+	var args []any
+	join := ""
+	if m != nil {
+		join = m.join
+		args = m.args
+	} else if d.fold != "" {
+		w.likeFolded(d.fold, filter.Search, "item_name", "invoice_name")
+	}
+	keys := sortKeys(filter.Sort, itemSort, m)
+	err := w.after(filter.Cursor, filter.Sort, keys)
+	if err != nil {
+		return nil, err
+	}
+	size := pageSize(filter.Limit)
+	query := `SELECT
+			item.item_id,
+			item.invoice_id,
+			item_name,
+			item_price,
+			item_price_one,
+			item_quantity,
+			invoice_date,
+			invoice_name,
+			invoice_currency,
+			tag.tag_name,
+			` + columns(keys) + `
+		FROM item` + join + `
+		JOIN invoice ON item.invoice_id = invoice.invoice_id
+		LEFT JOIN item_tag ON item_tag.item_id = item.item_id
+		LEFT JOIN tag ON tag.tag_id = item_tag.tag_id` +
+		w.String() +
+		orderBy(keys) +
+		" LIMIT ? OFFSET ?"
+	args = append(append(args, w.args...), size+1, max(filter.Offset, 0))
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &ItemPage{Hits: []*ItemHit{}}
+	var last []any
+	for rows.Next() {
+		var (
+			itemId       string
+			billId       string
+			name         sql.NullString
+			price        sql.NullFloat64
+			priceOne     sql.NullFloat64
+			quantity     sql.NullFloat64
+			date         string
+			billName     string
+			billCurrency sql.NullString
+			tagName      *string
+		)
+		values := make([]any, len(keys))
+		err := rows.Scan(append([]any{
+			&itemId,
+			&billId,
+			&name,
+			&price,
+			&priceOne,
+			&quantity,
+			&date,
+			&billName,
+			&billCurrency,
+			&tagName,
+		}, pointers(values)...)...)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Hits) == size {
+			page.Next = encodeCursor(filter.Sort, last)
+			break
+		}
+		last = values
+		itemDate, err := bl.StringToDate(date)
+		if err != nil {
+			return nil, err
+		}
+		itemCurrency, err := currency.Parse(billCurrency.String)
+		if err != nil {
+			return nil, err
+		}
+		page.Hits = append(page.Hits, &ItemHit{ItemWithBill: &ItemWithBill{
+			Item: item.New(
+				itemId,
+				billId,
+				name.String,
+				price.Float64,
+				priceOne.Float64,
+				quantity.Float64,
+			),
+			Date:     *itemDate,
+			BillName: billName,
+			Currency: itemCurrency,
+			Tag:      tag.NewFromNullable(tagName),
+		}, Snippet: m.snippet(name.String + " " + billName)})
+	}
+	return page, rows.Err()
+}
@@ -1,51 +1,57 @@
 package repository
 
 import (
+	"billdb/internal/audit"
 	bl "billdb/internal/bill"
 	"billdb/internal/bill/country"
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type SqliteBillRepository struct {
 	DB *sql.DB
+	// set on the repository passed to WithTx
+	tx *sql.Tx
 }
 
 func NewSqliteBillRepository(db *sql.DB) *SqliteBillRepository {
 	return &SqliteBillRepository{DB: db}
 }
 
-// Implementation for getting the database
-func (r *SqliteBillRepository) GetDb() *sql.DB {
-	return r.DB
+// Implementation for inserting a bill in the sqlite database
+func (r *SqliteBillRepository) InsertBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return insertBill(ctx, tx, bill)
+	})
 }
 
-// Implementation for inserting a bill in the sqlite database
-func (r *SqliteBillRepository) InsertBill(bill *bl.Bill) error {
-	// start transaction
-	tx, err := r.DB.Begin()
-	if err != nil {
-		return err
-	}
-	_, err = tx.Exec(`INSERT INTO invoice (
-			invoice_id, 
-			invoice_name, 
-			invoice_date, 
-			invoice_price, 
-			invoice_currency, 
-			invoice_country, 
-			invoice_link, 
-			invoice_text
-		)
-		VALUES (?,?,?,?,?,?,?,?)`,
+// insertBill stores a bill and its tag through tx. The queries of
+// the repository functions run on SQLite and on PostgreSQL, the
+// methods of both repositories call them in their transactions.
+func insertBill(ctx context.Context, tx querier, bill *bl.Bill) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO invoice (
+		invoice_id, 
+		invoice_name, 
+		invoice_date, 
+		invoice_price, 
+		invoice_currency, 
+		invoice_country, 
+		invoice_link, 
+		invoice_text,
+		invoice_fiscal_id
+	)
+	VALUES (?,?,?,?,?,?,?,?,?)`,
 		bill.Id,
 		bill.Name,
 		bill.GetDateString(),
@@ -56,74 +62,74 @@ func (r *SqliteBillRepository) InsertBill(bill *bl.Bill) error {
 		bill.GetCountryString(),
 		bill.Link,
 		bill.BillText,
+		fiscalId(bill),
 	)
 	if err != nil {
+		return fmt.Errorf("bill %s: %w", bill.Id, mapError(err))
+	}
+	err = record(ctx, tx, &audit.Entry{
+		Action:   audit.Create,
+		Entity:   audit.Bill,
+		EntityId: bill.Id,
+		BillId:   bill.Id,
+		New:      billValues(bill),
+	})
+	if err != nil {
+		return err
+	}
+	if !bill.Tag.Valid {
+		return nil
+	}
+
+	var tagID int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT tag_id FROM tag WHERE tag_name = ?",
+		bill.Tag.String,
+	).Scan(&tagID)
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	if bill.Tag.Valid {
-		var tagID int64
-		err = tx.QueryRow(
-			"SELECT tag_id FROM tag WHERE tag_name = ?",
+
+	if tagID == 0 { // Tag does not exist, insert it
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO tag (tag_name) VALUES (?) RETURNING tag_id",
 			bill.Tag.String,
 		).Scan(&tagID)
-		if err != nil && err != sql.ErrNoRows {
-			tx.Rollback()
+		if err != nil {
 			return err
 		}
-
-		if tagID == 0 { // Tag does not exist, insert it
-			result, err := tx.Exec(
-				"INSERT INTO tag (tag_name) VALUES (?)",
-				bill.Tag.String,
-			)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
-			tagID, err = result.LastInsertId()
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
-		}
-
-		// Link invoice and tag
-		_, err = tx.Exec(
-			"INSERT INTO invoice_tag (invoice_id, tag_id) VALUES (?, ?)",
-			bill.Id,
-			tagID,
-		)
+		err = recordTag(ctx, tx, tagID, bill)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
 	}
-	// Commit transaction
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	return nil
+
+	// Link invoice and tag
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO invoice_tag (invoice_id, tag_id) VALUES (?, ?)",
+		bill.Id,
+		tagID,
+	)
+	return err
 }
 
-func (r *SqliteBillRepository) InsertBillWithItems(bill *bl.Bill) error {
-  err := r.InsertBill(bill)
-  if err != nil {
-    return err
-  }
-  if len(bill.Items) == 0 {
-    return nil
-  }
-  err = r.InsertItems(bill.Items)
-  if err != nil {
-    return err
-  }
-  return nil
+// InsertBillWithItems stores the bill, its tag and its items all-or-nothing
+func (r *SqliteBillRepository) InsertBillWithItems(ctx context.Context, bill *bl.Bill) error {
+	return r.WithTx(ctx, func(tx BillRepository) error {
+		err := tx.InsertBill(ctx, bill)
+		if err != nil {
+			return err
+		}
+		return tx.InsertItems(ctx, bill.Items)
+	})
 }
 
 // fetching a bill from the database by ID without items
-func (r *SqliteBillRepository) GetBillByID(id string) (*bl.Bill, error) {
+func (r *SqliteBillRepository) GetBillByID(ctx context.Context, id string) (*bl.Bill, error) {
+	return getBill(ctx, r.conn(), id)
+}
+
+func getBill(ctx context.Context, q querier, id string) (*bl.Bill, error) {
 	query := `SELECT
 			invoice.invoice_id, 
 			invoice_name, 
@@ -132,37 +138,51 @@ func (r *SqliteBillRepository) GetBillByID(id string) (*bl.Bill, error) {
 			invoice_currency, 
 			invoice_country,
 			tag.tag_name,
-			invoice_link
+			invoice_link,
+			coalesce(invoice_fiscal_id, ''),
+			coalesce(invoice_text, '')
 		FROM invoice
 		LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
 		LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
-		WHERE invoice.invoice_id = ?`
-	row := r.DB.QueryRow(query, id)
-	bill, err := ScanToBill(row)
+		WHERE invoice.invoice_id = ? AND invoice.deleted_at IS NULL`
+	row := q.QueryRowContext(ctx, query, id)
+	var fiscalId, text string
+	bill, err := scanBill(row.Scan, &fiscalId, &text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bill %s: %w", id, ErrNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
+	bill.FiscalId = fiscalId
+	bill.BillText = text
 
 	return bill, nil
 }
 
 // Implementation for updating a bill in the database
-func (r *SqliteBillRepository) UpdateBill(bill *bl.Bill) error {
-	// start transaction
-	tx, err := r.DB.Begin()
+func (r *SqliteBillRepository) UpdateBill(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return updateBill(ctx, tx, bill)
+	})
+}
+
+func updateBill(ctx context.Context, tx querier, bill *bl.Bill) error {
+	old, err := readBillValues(ctx, tx, bill.Id)
 	if err != nil {
 		return err
 	}
-	result, err := tx.Exec(`UPDATE invoice
-		SET 
-			invoice_name = ?,
-			invoice_date = ?, 
-			invoice_price = ?, 
-			invoice_currency = ?, 
-			invoice_country = ?, 
-			invoice_link = ?, 
-			invoice_text = ?
-		WHERE invoice_id = ?`,
+	result, err := tx.ExecContext(ctx, `UPDATE invoice
+	SET 
+		invoice_name = ?,
+		invoice_date = ?, 
+		invoice_price = ?, 
+		invoice_currency = ?, 
+		invoice_country = ?, 
+		invoice_link = ?, 
+		invoice_text = ?,
+		invoice_fiscal_id = ?
+	WHERE invoice_id = ? AND deleted_at IS NULL`,
 		bill.Name,
 		bill.GetDateString(),
 		bill.Price,
@@ -172,114 +192,171 @@ func (r *SqliteBillRepository) UpdateBill(bill *bl.Bill) error {
 		bill.GetCountryString(),
 		bill.Link,
 		bill.BillText,
+		fiscalId(bill),
 		bill.Id,
 	)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 	// check if provided ID was in the db
 	// and was there any change after our UPDATE
 	rowsUpdated, err := result.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 	if rowsUpdated == 0 {
-		tx.Rollback()
-		return fmt.Errorf("no rows affected")
-	}
-
-	if !bill.Tag.Valid {
-		_, err = tx.Exec(
-			"DELETE FROM invoice_tag WHERE invoice_id = ?;",
-			bill.Id,
-		)
+		return fmt.Errorf("bill %s: %w", bill.Id, ErrNotFound)
+	}
+	before, after := changed(old, billValues(bill))
+	if len(after) != 0 {
+		err = record(ctx, tx, &audit.Entry{
+			Action:   audit.Update,
+			Entity:   audit.Bill,
+			EntityId: bill.Id,
+			BillId:   bill.Id,
+			Old:      before,
+			New:      after,
+		})
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
-		err = tx.Commit()
+	}
+
+	return setTag(ctx, tx, bill)
+}
+
+// UpdateTag changes only the tag of a bill
+func (r *SqliteBillRepository) UpdateTag(ctx context.Context, bill *bl.Bill) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return updateTag(ctx, tx, bill)
+	})
+}
+
+func updateTag(ctx context.Context, tx querier, bill *bl.Bill) error {
+	current, err := getBill(ctx, tx, bill.Id)
+	if err != nil {
+		return err
+	}
+	before, after := changed(billValues(current), map[string]string{"tag": tagName(bill)})
+	if len(after) != 0 {
+		err = record(ctx, tx, &audit.Entry{
+			Action:   audit.Update,
+			Entity:   audit.Bill,
+			EntityId: bill.Id,
+			BillId:   bill.Id,
+			Old:      before,
+			New:      after,
+		})
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
-		return nil
+	}
+	return setTag(ctx, tx, bill)
+}
+
+// setTag links a bill to its tag, creating the tag if it is new
+func setTag(ctx context.Context, tx querier, bill *bl.Bill) error {
+	if !bill.Tag.Valid {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM invoice_tag WHERE invoice_id = ?;",
+			bill.Id,
+		)
+		return err
 	}
 
 	// Prepare the INSERT statement to insert tag_name
 	// if it doesn't already exist
 	insertQuery := `
-	INSERT INTO tag (tag_name)
-	SELECT ? 
-	WHERE NOT EXISTS (SELECT 1 FROM tag WHERE tag_name = ?);
-	`
+INSERT INTO tag (tag_name)
+SELECT CAST(? AS TEXT)
+WHERE NOT EXISTS (SELECT 1 FROM tag WHERE tag_name = ?);
+`
 
 	// Execute the INSERT statement
-	_, err = tx.Exec(
+	result, err := tx.ExecContext(ctx,
 		insertQuery,
 		bill.Tag.String,
 		bill.Tag.String,
 	)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("error inserting tag: %w", err)
 	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
 
 	// Now retrieve the tag_id using the SELECT statement
 	selectQuery := `
-	SELECT tag_id FROM tag WHERE tag_name = ?;
-	`
+SELECT tag_id FROM tag WHERE tag_name = ?;
+`
 
 	var tagID int64
-	err = tx.QueryRow(selectQuery, bill.Tag.String).Scan(&tagID)
+	err = tx.QueryRowContext(ctx, selectQuery, bill.Tag.String).Scan(&tagID)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("error getting tag_id: %w", err)
 	}
+	if inserted != 0 {
+		err = recordTag(ctx, tx, tagID, bill)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Link invoice and tag
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		`INSERT INTO invoice_tag (invoice_id, tag_id) VALUES (?, ?)
     ON CONFLICT(invoice_id) DO UPDATE SET tag_id = excluded.tag_id`,
 		bill.Id,
 		tagID,
 	)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	return err
+}
 
-	return nil
+// DeleteBill moves a bill to the trash, see PurgeBill for the delete for good
+func (r *SqliteBillRepository) DeleteBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return deleteBill(ctx, tx, id)
+	})
 }
 
-// Implementation for deleting a bill from the database by ID
-func (r *SqliteBillRepository) DeleteBill(id string) error {
-	_, err := r.DB.Exec(
-		`DELETE FROM invoice WHERE invoice_id = ?;
-		DELETE FROM invoice_tag WHERE invoice_id = ?;`,
-		id,
+func deleteBill(ctx context.Context, tx querier, id string) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE invoice SET deleted_at = ? WHERE invoice_id = ? AND deleted_at IS NULL`,
+		formatTimestamp(time.Now()),
 		id,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("bill %s: %w", id, ErrNotFound)
+	}
+	return record(ctx, tx, &audit.Entry{
+		Action:   audit.Delete,
+		Entity:   audit.Bill,
+		EntityId: id,
+		BillId:   id,
+	})
 }
 
 // Implementation for checking unique item names
-func (r *SqliteBillRepository) InsertItems(items []*item.Item) error {
-  if len(items) == 0 {
-    return nil
-  }
+func (r *SqliteBillRepository) InsertItems(ctx context.Context, items []*item.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
 
-	stmt, err := r.DB.Prepare(
+	return r.inTx(ctx, func(tx querier) error {
+		return insertItems(ctx, tx, items)
+	})
+}
+
+func insertItems(ctx context.Context, tx querier, items []*item.Item) error {
+	stmt, err := tx.PrepareContext(ctx,
 		"INSERT INTO item ( item_id, invoice_id, item_name, item_price, item_price_one, item_quantity) VALUES (?,?,?,?,?,?)")
 	if err != nil {
 		return err
@@ -287,7 +364,7 @@ func (r *SqliteBillRepository) InsertItems(items []*item.Item) error {
 	defer stmt.Close()
 
 	for _, item := range items {
-		_, err := stmt.Exec(
+		_, err := stmt.ExecContext(ctx,
 			item.ItemId,
 			item.BillId,
 			item.Name,
@@ -295,17 +372,30 @@ func (r *SqliteBillRepository) InsertItems(items []*item.Item) error {
 			item.PriceOne,
 			item.Quantity,
 		)
+		if err != nil {
+			return fmt.Errorf("item %s: %w", item.ItemId, mapError(err))
+		}
+		err = record(ctx, tx, &audit.Entry{
+			Action:   audit.Create,
+			Entity:   audit.Item,
+			EntityId: item.ItemId,
+			BillId:   item.BillId,
+			New:      itemValues(item),
+		})
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
 // Implementation for getting an item from the database by ID
-func (r *SqliteBillRepository) GetItemsByID(billId string) ([]*item.Item, error) {
-	rows, err := r.DB.Query(`SELECT
+func (r *SqliteBillRepository) GetItemsByID(ctx context.Context, billId string) ([]*item.Item, error) {
+	return getItems(ctx, r.conn(), billId)
+}
+
+func getItems(ctx context.Context, q querier, billId string) ([]*item.Item, error) {
+	rows, err := q.QueryContext(ctx, `SELECT
 			item_id, 
 			invoice_id, 
 			item_name, 
@@ -356,43 +446,60 @@ func (r *SqliteBillRepository) GetItemsByID(billId string) ([]*item.Item, error)
 	return items, nil
 }
 
-func (r *SqliteBillRepository) UpdateItems(items []*item.Item) error {
+func (r *SqliteBillRepository) UpdateItems(ctx context.Context, items []*item.Item) error {
 	return fmt.Errorf("not implemented")
 }
 
 // Implementation for updating an item in the database
-func (r *SqliteBillRepository) UpdateItem(item *item.Item) error {
+func (r *SqliteBillRepository) UpdateItem(ctx context.Context, item *item.Item) error {
 	return fmt.Errorf("not implemented")
 }
 
 // Implementation for deleting an item from the database by ID
-func (r *SqliteBillRepository) DeleteItems(items []*item.Item) error {
-	tx, err := r.DB.Begin()
-	if err != nil {
-		return err
-	}
+func (r *SqliteBillRepository) DeleteItems(ctx context.Context, items []*item.Item) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return deleteItems(ctx, tx, items)
+	})
+}
+
+func deleteItems(ctx context.Context, tx querier, items []*item.Item) error {
 	for _, item := range items {
-		_, err := tx.Exec(
-			`DELETE FROM item WHERE item_id = ?;
-			DELETE FROM item_tag WHERE item_id = ?;`,
-			item.ItemId,
-			item.ItemId,
-		)
+		old, err := readItemValues(ctx, tx, item.ItemId)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, query := range []string{
+			`DELETE FROM item WHERE item_id = ?;`,
+			`DELETE FROM item_tag WHERE item_id = ?;`,
+		} {
+			_, err = tx.ExecContext(ctx, query, item.ItemId)
+			if err != nil {
+				return err
+			}
+		}
+		err = record(ctx, tx, &audit.Entry{
+			Action:   audit.Delete,
+			Entity:   audit.Item,
+			EntityId: item.ItemId,
+			BillId:   old["bill_id"],
+			Old:      old,
+		})
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
-	}
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return err
 	}
 	return nil
 }
 
-func (r *SqliteBillRepository) GetCountries() ([]string, error) {
-	rows, err := r.DB.Query("SELECT DISTINCT invoice_country FROM invoice;")
+func (r *SqliteBillRepository) GetCountries(ctx context.Context) ([]string, error) {
+	return getCountries(ctx, r.conn())
+}
+
+func getCountries(ctx context.Context, q querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT DISTINCT invoice_country FROM invoice WHERE deleted_at IS NULL;")
 	if err != nil {
 		log.Error("Error getting countries from db: ", err)
 		return nil, err
@@ -412,8 +519,12 @@ func (r *SqliteBillRepository) GetCountries() ([]string, error) {
 	return countries, nil
 }
 
-func (r *SqliteBillRepository) GetCurrencies() ([]string, error) {
-	rows, err := r.DB.Query("SELECT DISTINCT invoice_currency FROM invoice;")
+func (r *SqliteBillRepository) GetCurrencies(ctx context.Context) ([]string, error) {
+	return getCurrencies(ctx, r.conn())
+}
+
+func getCurrencies(ctx context.Context, q querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT DISTINCT invoice_currency FROM invoice WHERE deleted_at IS NULL;")
 	if err != nil {
 		log.Error("Error getting currencies from db: ", err)
 		return nil, err
@@ -433,8 +544,12 @@ func (r *SqliteBillRepository) GetCurrencies() ([]string, error) {
 	return currencies, nil
 }
 
-func (r *SqliteBillRepository) GetTags() ([]string, error) {
-	rows, err := r.DB.Query(`SELECT tag_name FROM tag;`)
+func (r *SqliteBillRepository) GetTags(ctx context.Context) ([]string, error) {
+	return getTags(ctx, r.conn())
+}
+
+func getTags(ctx context.Context, q querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT tag_name FROM tag;`)
 	if err != nil {
 		return nil, err
 	}
@@ -452,93 +567,29 @@ func (r *SqliteBillRepository) GetTags() ([]string, error) {
 	return tags, nil
 }
 
-// createTables creates necessary tables in the SQLite database.
-func (r *SqliteBillRepository) ApplyMigration(sqlFilePath string) error {
-
-	// Read migration sql file
+// ApplyMigration applies a single migration file from disk in a transaction.
+// The embedded migrations are applied in order by NewMigrator.
+func (r *SqliteBillRepository) ApplyMigration(ctx context.Context, sqlFilePath string) error {
 	sqlFile, err := os.ReadFile(sqlFilePath)
 	if err != nil {
-		log.Fatalf("Error reading SQL file: %v\n", err)
-	}
-
-	// Split SQL file content into individual statements based on ';'
-	sqlStatements := strings.Split(string(sqlFile), ";")
-
-	// Iterate over each SQL statement and execute it
-	for _, stmt := range sqlStatements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-
-		_, err := r.DB.Exec(stmt)
-		if err != nil {
-			log.Fatalf("Error executing SQL statement: %v\nSQL: %s\n", err, stmt)
-		}
-	}
-
-	_, err = r.DB.Exec(`INSERT INTO migration (name) VALUES (?)`, filepath.Base(sqlFilePath))
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (r *SqliteBillRepository) CheckDuplicateBill(bill *bl.Bill) (int, error) {
-	query := `SELECT invoice_id
-		FROM invoice
-		WHERE invoice_date = ?
-			AND invoice_price = ?
-			AND invoice_currency = ?;`
-	rows, err := r.DB.Query(
-		query,
-		bill.GetDateString(),
-		bill.Price,
-		bill.GetCurrencyString(),
-	)
-	if err != nil {
-		return 0, err
-	}
-	defer rows.Close()
-
-	var billArr []string
-	for rows.Next() {
-		var id string
-		err = rows.Scan(&id)
-		if err != nil {
-			return 0, err
-		}
-		billArr = append(billArr, id)
+		return fmt.Errorf("error reading SQL file: %w", err)
 	}
 
-	return len(billArr), nil
+	return r.inTx(ctx, func(tx querier) error {
+		return applyMigration(ctx, tx, filepath.Base(sqlFilePath), string(sqlFile))
+	})
 }
 
-func (r *SqliteBillRepository) CheckDuplicateBillByUrl(url string) (int, error) {
-	query := `SELECT invoice_id
-		FROM invoice
-		WHERE invoice_link = ?;`
-	rows, err := r.DB.Query(
-		query,
-    url,
-	)
-	if err != nil {
-		return 0, err
-	}
-	defer rows.Close()
-
-	var billArr []string
-	for rows.Next() {
-		var id string
-		err = rows.Scan(&id)
+func applyMigration(ctx context.Context, tx querier, name string, content string) error {
+	if strings.TrimSpace(content) != "" {
+		_, err := tx.ExecContext(ctx, content)
 		if err != nil {
-			return 0, err
+			return fmt.Errorf("error applying %s: %w", name, err)
 		}
-		billArr = append(billArr, id)
 	}
 
-	return len(billArr), nil
+	_, err := tx.ExecContext(ctx, `INSERT INTO migration (name) VALUES (?)`, name)
+	return err
 }
 
 // function to use in place .Scan()
@@ -548,6 +599,19 @@ func (r *SqliteBillRepository) CheckDuplicateBillByUrl(url string) (int, error)
 // you should use rows.Next()
 // and pass the rows to this function
 func ScanToBill(row interface{}) (*bl.Bill, error) {
+	switch r := row.(type) {
+	case *sql.Row:
+		return scanBill(r.Scan)
+	case *sql.Rows:
+		return scanBill(r.Scan)
+	default:
+		return nil, fmt.Errorf("invalid type %T", r)
+	}
+}
+
+// scanBill scans the bill columns of ScanToBill
+// followed by the extra columns of the query
+func scanBill(scan func(dest ...any) error, extra ...any) (*bl.Bill, error) {
 	var (
 		Id       string
 		Name     string
@@ -558,41 +622,21 @@ func ScanToBill(row interface{}) (*bl.Bill, error) {
 		Tag      *string
 		Link     string
 	)
-	switch r := row.(type) {
-	case *sql.Row:
-		err := r.Scan(
-			&Id,
-			&Name,
-			&Date,
-			&Price,
-			&Currency,
-			&Country,
-			&Tag,
-			&Link,
-		)
-		if err != nil {
-			log.Error(
-				"Error scaning bill: ", err)
-			return nil, err
-		}
-	case *sql.Rows:
-		err := r.Scan(
-			&Id,
-			&Name,
-			&Date,
-			&Price,
-			&Currency,
-			&Country,
-			&Tag,
-			&Link,
-		)
-		if err != nil {
-			log.Error(
-				"Error scaning bill: ", err)
-			return nil, err
-		}
-	default:
-		return nil, fmt.Errorf("invalid type %T", r)
+	dest := []any{
+		&Id,
+		&Name,
+		&Date,
+		&Price,
+		&Currency,
+		&Country,
+		&Tag,
+		&Link,
+	}
+	err := scan(append(dest, extra...)...)
+	if err != nil {
+		log.Error(
+			"Error scaning bill: ", err)
+		return nil, err
 	}
 	billDate, err := bl.StringToDate(Date)
 	if err != nil {
@@ -6,9 +6,12 @@ import (
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -31,7 +34,7 @@ func initEnv() {
 func setUpDB(t *testing.T) (*SqliteBillRepository, error) {
 	os.Remove(dbPath)
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := OpenSqlite(dbPath)
 	if err != nil {
 		t.Errorf("Failed to open sqlite database: %v", err)
 		return nil, err
@@ -49,6 +52,36 @@ func setUpDB(t *testing.T) (*SqliteBillRepository, error) {
 	return billRepository, nil
 }
 
+// migrate applies every migration, the repository
+// queries need more than the initial schema
+func migrate(billRepo *SqliteBillRepository) error {
+	_, err := NewMigrator(billRepo.DB).Up()
+	return err
+}
+
+// insertParentBill stores a bill for items of the test,
+// items of a missing bill are rejected by the foreign key
+func insertParentBill(t *testing.T, billRepo *SqliteBillRepository, id string) bool {
+	b := bill.New(
+		id,
+		"Parent bill",
+		time.Now(),
+		100.0,
+		currency.RSD,
+		country.SERBIA,
+		[]*item.Item{},
+		tag.New(""),
+		"",
+		"",
+	)
+	err := billRepo.InsertBill(context.Background(), b)
+	if err != nil {
+		t.Errorf("Failed to insert bill: %v", err)
+		return false
+	}
+	return true
+}
+
 func TestCreateTables(t *testing.T) {
 	// Implement tests for CreateTables function
 	t.Log("Testing CreateTables function")
@@ -60,7 +93,7 @@ func TestCreateTables(t *testing.T) {
 		return
 	}
 
-	err = billRepository.ApplyMigration(creationSql)
+	err = billRepository.ApplyMigration(context.Background(), creationSql)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -115,7 +148,7 @@ func TestInsertBill(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepository.ApplyMigration(creationSql)
+	err = migrate(billRepository)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -135,7 +168,7 @@ func TestInsertBill(t *testing.T) {
 		"linkString",
 		"billText",
 	)
-	err = billRepository.InsertBill(b)
+	err = billRepository.InsertBill(context.Background(), b)
 	if err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
@@ -218,7 +251,7 @@ func TestGetBillById(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepository.ApplyMigration(creationSql)
+	err = migrate(billRepository)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -238,12 +271,12 @@ func TestGetBillById(t *testing.T) {
 		"linkString",
 		"billText",
 	)
-	err = billRepository.InsertBill(b)
+	err = billRepository.InsertBill(context.Background(), b)
 	if err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
 	}
-	billById, err := billRepository.GetBillByID(id.String())
+	billById, err := billRepository.GetBillByID(context.Background(), id.String())
 	if err != nil {
 		t.Errorf("Failed to get bill by ID: %v", err)
 		return
@@ -290,7 +323,7 @@ func TestUpdateBill(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepository.ApplyMigration(creationSql)
+	err = migrate(billRepository)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -354,14 +387,14 @@ func TestUpdateBill(t *testing.T) {
 		),
 	}
 
-	err = billRepository.InsertBill(bills[0])
+	err = billRepository.InsertBill(context.Background(), bills[0])
 	if err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
 	}
 
 	for _, b := range bills {
-		err = billRepository.UpdateBill(b)
+		err = billRepository.UpdateBill(context.Background(), b)
 		if err != nil {
 			t.Errorf("Failed to update bill: %v", err)
 			return
@@ -458,7 +491,7 @@ func TestDeleteBill(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepository.ApplyMigration(creationSql)
+	err = migrate(billRepository)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -478,17 +511,22 @@ func TestDeleteBill(t *testing.T) {
 		"linkString",
 		"billText",
 	)
-	err = billRepository.InsertBill(b)
+	err = billRepository.InsertBill(context.Background(), b)
 	if err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
 	}
 
-	err = billRepository.DeleteBill(b.Id)
+	err = billRepository.DeleteBill(context.Background(), b.Id)
 	if err != nil {
 		t.Errorf("Failed to delete bill: %v", err)
 		return
 	}
+	err = billRepository.PurgeBill(context.Background(), b.Id)
+	if err != nil {
+		t.Errorf("Failed to purge bill: %v", err)
+		return
+	}
 
 	query :=
 		`SELECT * FROM invoice WHERE invoice_id = ?`
@@ -521,8 +559,8 @@ func TestDeleteBill(t *testing.T) {
 	}
 }
 
-func TestCheckDuplicateBill(t *testing.T) {
-	t.Log("Testing DuplicatesBills function")
+func TestRepositoryErrors(t *testing.T) {
+	t.Log("Testing typed repository errors")
 
 	initEnv()
 	billRepository, err := setUpDB(t)
@@ -530,43 +568,247 @@ func TestCheckDuplicateBill(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepository.ApplyMigration(creationSql)
+	ctx := context.Background()
+	err = migrate(billRepository)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
 	}
 
-	date := time.Now()
-	id := ksuid.New()
-	b := bill.New(
-		id.String(),
-		"Test bill",
-		date,
-		100.0,
+	missing := bill.New(
+		ksuid.New().String(),
+		"Missing bill",
+		time.Now(),
+		10.0,
 		currency.RSD,
-		country.RUSSIA,
+		country.SERBIA,
 		[]*item.Item{},
-		tag.New("tag1,tag2"),
-		"linkString",
-		"billText",
+		tag.New(""),
+		"",
+		"",
 	)
-	err = billRepository.InsertBill(b)
-	if err != nil {
+	if _, err = billRepository.GetBillByID(ctx, missing.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBillByID: expected ErrNotFound, got %v", err)
+	}
+	if err = billRepository.UpdateBill(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateBill: expected ErrNotFound, got %v", err)
+	}
+	if err = billRepository.DeleteBill(ctx, missing.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteBill: expected ErrNotFound, got %v", err)
+	}
+	if err = billRepository.RestoreBill(ctx, missing.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestoreBill: expected ErrNotFound, got %v", err)
+	}
+	if err = billRepository.PurgeBill(ctx, missing.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PurgeBill: expected ErrNotFound, got %v", err)
+	}
+
+	if err = billRepository.InsertBill(ctx, missing); err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
 	}
+	if err = billRepository.InsertBill(ctx, missing); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("InsertBill twice: expected ErrDuplicate, got %v", err)
+	}
+}
 
-	billDupCount, err := billRepository.CheckDuplicateBill(b)
+// countOrphans returns the link and item rows whose parent is missing
+func countOrphans(t *testing.T, billRepo *SqliteBillRepository) int {
+	t.Helper()
+	var n int
+	err := billRepo.DB.QueryRow(`SELECT
+		(SELECT count(*) FROM item WHERE invoice_id NOT IN (SELECT invoice_id FROM invoice)) +
+		(SELECT count(*) FROM item_tag WHERE item_id NOT IN (SELECT item_id FROM item)) +
+		(SELECT count(*) FROM invoice_tag WHERE invoice_id NOT IN (SELECT invoice_id FROM invoice))`,
+	).Scan(&n)
 	if err != nil {
-		t.Errorf("Failed to get duplicate bills: %v", err)
-		return
+		t.Fatalf("Failed to count orphans: %v", err)
 	}
+	rows, err := billRepo.DB.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		t.Fatalf("Failed to check foreign keys: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n++
+	}
+	return n
+}
 
-	if billDupCount != 1 {
-		t.Errorf("Expected 1 duplicate bill, got %d", billDupCount)
-		return
+func TestDeleteBillNoOrphans(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	ctx := context.Background()
+	_, err = NewMigrator(billRepo.DB).Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	var bills []*bill.Bill
+	for i := 0; i < 2; i++ {
+		id := ksuid.New().String()
+		b := bill.New(
+			id,
+			fmt.Sprintf("Bill %d", i),
+			time.Now(),
+			30.0,
+			currency.RSD,
+			country.SERBIA,
+			[]*item.Item{
+				item.New(ksuid.New().String(), id, "item1", 10.0, 10.0, 1.0),
+				item.New(ksuid.New().String(), id, "item2", 20.0, 20.0, 1.0),
+			},
+			tag.New("tag1"),
+			"",
+			"",
+		)
+		err = billRepo.InsertBillWithItems(ctx, b)
+		if err != nil {
+			t.Fatalf("Failed to insert bill: %v", err)
+		}
+		_, err = billRepo.DB.Exec(
+			`INSERT INTO item_tag (item_id, tag_id) SELECT ?, tag_id FROM tag WHERE tag_name = 'tag1'`,
+			b.Items[0].ItemId,
+		)
+		if err != nil {
+			t.Fatalf("Failed to tag item: %v", err)
+		}
+		bills = append(bills, b)
+	}
+
+	// repository delete
+	err = billRepo.DeleteBill(ctx, bills[0].Id)
+	if err != nil {
+		t.Fatalf("Failed to delete bill: %v", err)
+	}
+	err = billRepo.PurgeBill(ctx, bills[0].Id)
+	if err != nil {
+		t.Fatalf("Failed to purge bill: %v", err)
+	}
+	if n := countOrphans(t, billRepo); n != 0 {
+		t.Errorf("Expected no orphans after PurgeBill, got %d", n)
+	}
+
+	// cascading foreign keys
+	_, err = billRepo.DB.Exec(`DELETE FROM invoice WHERE invoice_id = ?`, bills[1].Id)
+	if err != nil {
+		t.Fatalf("Failed to delete invoice: %v", err)
+	}
+	if n := countOrphans(t, billRepo); n != 0 {
+		t.Errorf("Expected no orphans after cascading delete, got %d", n)
+	}
+
+	// enforcement
+	err = billRepo.InsertItems(ctx, []*item.Item{
+		item.New(ksuid.New().String(), bills[0].Id, "orphan", 1.0, 1.0, 1.0),
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for an item of a missing bill, got %v", err)
+	}
+}
+
+func TestCascadeMigrationSetsOrphansAside(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	ctx := context.Background()
+	err = billRepo.ApplyMigration(ctx, creationSql)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	// the repository writes the current schema, the rows of the
+	// initial schema are inserted as they were
+	_, err = billRepo.DB.ExecContext(ctx, `
+		INSERT INTO invoice (invoice_id, invoice_name, invoice_date, invoice_price, invoice_currency, invoice_country)
+		VALUES ('bill', 'Parent bill', '2024-01-02', 100, 'rsd', 'serbia');
+		INSERT INTO item (item_id, invoice_id, item_name, item_price, item_price_one, item_quantity)
+		VALUES ('kept', 'bill', 'kept', 1, 1, 1);`)
+	if err != nil {
+		t.Fatalf("Failed to insert bill: %v", err)
+	}
+
+	// orphans written before foreign keys were enforced
+	conn, err := billRepo.DB.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;
+		INSERT INTO item (item_id, invoice_id, item_name) VALUES ('orphan', 'missing', 'orphan');
+		INSERT INTO invoice_tag (invoice_id, tag_id) VALUES ('missing', 1);
+		PRAGMA foreign_keys = ON;`)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Failed to insert orphans: %v", err)
+	}
+
+	_, err = NewMigrator(billRepo.DB).Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	if n := countOrphans(t, billRepo); n != 0 {
+		t.Errorf("Expected no orphans after migration, got %d", n)
+	}
+	orphans, err := CountOrphans(ctx, billRepo.DB)
+	if err != nil {
+		t.Fatalf("Failed to count the rows set aside: %v", err)
+	}
+	expected := map[string]int{"item_orphan": 1, "item_tag_orphan": 0, "invoice_tag_orphan": 1}
+	if !maps.Equal(orphans, expected) {
+		t.Errorf("Expected rows set aside %v, got %v", expected, orphans)
+	}
+	var invoiceId string
+	err = billRepo.DB.QueryRowContext(ctx, `SELECT invoice_id FROM item_orphan WHERE item_id = 'orphan'`).Scan(&invoiceId)
+	if err != nil || invoiceId != "missing" {
+		t.Errorf("Expected the orphan item kept with its bill id, got %q, %v", invoiceId, err)
+	}
+	items, err := billRepo.GetItemsByID(ctx, "bill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected the valid item to be kept, got %d items", len(items))
+	}
+}
+
+func TestFiscalIdMigration(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	ctx := context.Background()
+	err = billRepo.ApplyMigration(ctx, creationSql)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	// bills stored before the fiscal keys, the same receipt twice
+	_, err = billRepo.DB.ExecContext(ctx, `
+		INSERT INTO invoice (invoice_id, invoice_name, invoice_date, invoice_price, invoice_currency, invoice_country, invoice_link)
+		VALUES ('scan', 'Lenta', '2024-05-01', 100, 'rub', 'russia', 't=20240501T1200&s=100.00&fn=1&i=2&fp=3&n=1'),
+			('rescan', 'Lenta', '2024-05-01', 100, 'rub', 'russia', 't=20240501T1200&fn=1&i=2&fp=3&s=100.00&n=1'),
+			('manual', 'Lenta', '2024-05-01', 100, 'rub', 'russia', '');`)
+	if err != nil {
+		t.Fatalf("Failed to insert bills: %v", err)
 	}
 
+	_, err = NewMigrator(billRepo.DB).Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	for id, want := range map[string]string{"scan": "ru:1/2/3", "rescan": "ru:1/2/3", "manual": ""} {
+		b, err := billRepo.GetBillByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.FiscalId != want {
+			t.Errorf("%s: expected the fiscal key %q, got %q", id, want, b.FiscalId)
+		}
+	}
 }
 
 func TestScanToBill(t *testing.T) {
@@ -577,7 +819,7 @@ func TestScanToBill(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepo.ApplyMigration(creationSql)
+	err = migrate(billRepo)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -596,7 +838,7 @@ func TestScanToBill(t *testing.T) {
 		"linkString",
 		"billText",
 	)
-	err = billRepo.InsertBill(b)
+	err = billRepo.InsertBill(context.Background(), b)
 	if err != nil {
 		t.Errorf("Failed to insert bill: %v", err)
 		return
@@ -655,7 +897,7 @@ func TestInsertItems(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepo.ApplyMigration(creationSql)
+	err = migrate(billRepo)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -686,7 +928,12 @@ func TestInsertItems(t *testing.T) {
 		1.0,
 	)
 	items = append(items, itemN)
-	err = billRepo.InsertItems(items)
+	err = billRepo.InsertBill(context.Background(), b)
+	if err != nil {
+		t.Errorf("Failed to insert bill: %v", err)
+		return
+	}
+	err = billRepo.InsertItems(context.Background(), items)
 	if err != nil {
 		t.Errorf("Failed to insert items: %v", err)
 		return
@@ -748,7 +995,7 @@ func TestGetItemsByID(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepo.ApplyMigration(creationSql)
+	err = migrate(billRepo)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -772,7 +1019,10 @@ func TestGetItemsByID(t *testing.T) {
 		102.0,
 		2.0,
 	)
-	err = billRepo.InsertItems([]*item.Item{
+	if !insertParentBill(t, billRepo, id.String()) {
+		return
+	}
+	err = billRepo.InsertItems(context.Background(), []*item.Item{
 		itemN,
 		item2,
 	})
@@ -781,7 +1031,7 @@ func TestGetItemsByID(t *testing.T) {
 		return
 	}
 
-	itemsByID, err := billRepo.GetItemsByID(itemN.BillId)
+	itemsByID, err := billRepo.GetItemsByID(context.Background(), itemN.BillId)
 	if err != nil {
 		t.Errorf("Failed to get items by ID: %v", err)
 		return
@@ -816,7 +1066,7 @@ func TestDeleteItems(t *testing.T) {
 		t.Errorf("Failed to set up database: %v", err)
 		return
 	}
-	err = billRepo.ApplyMigration(creationSql)
+	err = migrate(billRepo)
 	if err != nil {
 		t.Errorf("Failed to create tables: %v", err)
 		return
@@ -842,19 +1092,22 @@ func TestDeleteItems(t *testing.T) {
 		2.0,
 	)
 	items = append(items, itemN, item2)
-	err = billRepo.InsertItems(items)
+	if !insertParentBill(t, billRepo, id.String()) {
+		return
+	}
+	err = billRepo.InsertItems(context.Background(), items)
 	if err != nil {
 		t.Errorf("Failed to insert items: %v", err)
 		return
 	}
 
-	err = billRepo.DeleteItems(items)
+	err = billRepo.DeleteItems(context.Background(), items)
 	if err != nil {
 		t.Errorf("Failed to delete items by ID: %v", err)
 		return
 	}
 
-	itemsFromDb, err := billRepo.GetItemsByID(itemN.BillId)
+	itemsFromDb, err := billRepo.GetItemsByID(context.Background(), itemN.BillId)
 	if err != nil {
 		t.Errorf("Failed to get items by ID: %v", err)
 		return
@@ -880,3 +1133,178 @@ func TestDeleteItems(t *testing.T) {
 	}
 	rows.Close()
 }
+
+func TestEmbeddedMigrations(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+
+	m := NewMigrator(billRepo.DB)
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	names, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(names) {
+		t.Errorf("Expected %d migrations applied, got %v", len(names), applied)
+	}
+
+	err = billRepo.InsertBill(context.Background(), bill.New(
+		ksuid.New().String(),
+		"test",
+		time.Now(),
+		1,
+		currency.RSD,
+		country.SERBIA,
+		[]*item.Item{},
+		tag.Empty(),
+		"link",
+		"",
+	))
+	if err != nil {
+		t.Errorf("Failed to insert bill into migrated database: %v", err)
+	}
+}
+
+func TestMigrateInitialSchema(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	// a database made from the initial schema file has an empty migration table
+	schema, err := os.ReadFile("migrations/001_initial_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := billRepo.DB.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMigrator(billRepo.DB)
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	if slices.Contains(applied, "001_initial_schema.sql") {
+		t.Errorf("Expected the initial schema recorded as the baseline, got %v", applied)
+	}
+	if pending, err := m.Pending(); err != nil || len(pending) != 0 {
+		t.Errorf("Expected every migration applied, got %v, %v", pending, err)
+	}
+}
+
+func insertListFixtures(t *testing.T, billRepo *SqliteBillRepository) []*bill.Bill {
+	t.Helper()
+	bills := []*bill.Bill{
+		bill.New(ksuid.New().String(), "Maxi", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), 1200,
+			currency.RSD, country.SERBIA, nil, tag.New("food"), "https://suf.purs.gov.rs/v/?vl=1", ""),
+		bill.New(ksuid.New().String(), "Lidl 100%", time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), 300,
+			currency.RSD, country.SERBIA, nil, tag.New("home"), "https://suf.purs.gov.rs/v/?vl=2", ""),
+		bill.New(ksuid.New().String(), "Migros", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 50,
+			currency.TRY, country.TURKEY, nil, tag.Empty(), "", ""),
+	}
+	for _, b := range bills {
+		b.Items = []*item.Item{
+			item.New(ksuid.New().String(), b.Id, "bread "+b.Name, b.Price, b.Price, 1),
+		}
+		err := billRepo.InsertBillWithItems(context.Background(), b)
+		if err != nil {
+			t.Fatalf("Failed to insert bill: %v", err)
+		}
+	}
+	return bills
+}
+
+func TestListBills(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	if _, err := NewMigrator(billRepo.DB).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	bills := insertListFixtures(t, billRepo)
+	from, to := MonthRange(2024, time.March)
+	minPrice := 500.0
+
+	tests := []struct {
+		name   string
+		filter BillFilter
+		want   []*bill.Bill
+	}{
+		{"all, newest first", BillFilter{}, []*bill.Bill{bills[2], bills[1], bills[0]}},
+		{"month", BillFilter{From: from, To: to}, []*bill.Bill{bills[1], bills[0]}},
+		{"tag", BillFilter{Tags: []string{"food", "missing"}}, []*bill.Bill{bills[0]}},
+		{"currency", BillFilter{Currencies: []currency.Currency{currency.TRY}}, []*bill.Bill{bills[2]}},
+		{"country", BillFilter{Countries: []country.Country{country.SERBIA}, Sort: SortDateAsc}, []*bill.Bill{bills[0], bills[1]}},
+		{"merchant", BillFilter{Merchant: "lid"}, []*bill.Bill{bills[1]}},
+		{"text matches tag", BillFilter{Text: "home"}, []*bill.Bill{bills[1]}},
+		{"like is escaped", BillFilter{Text: "0%"}, []*bill.Bill{bills[1]}},
+		{"price", BillFilter{MinPrice: &minPrice}, []*bill.Bill{bills[0]}},
+		{"sort by price", BillFilter{Sort: SortPriceAsc}, []*bill.Bill{bills[2], bills[1], bills[0]}},
+		{"page", BillFilter{Limit: 1, Offset: 1}, []*bill.Bill{bills[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := billRepo.ListBills(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to list bills: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d bills, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if got[i].Id != tt.want[i].Id {
+					t.Errorf("Expected bill %s at %d, got %s", tt.want[i].Name, i, got[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestListItems(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	if _, err := NewMigrator(billRepo.DB).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	bills := insertListFixtures(t, billRepo)
+	from, to := MonthRange(2024, time.April)
+
+	items, err := billRepo.ListItems(context.Background(), ItemFilter{From: from, To: to})
+	if err != nil {
+		t.Fatalf("Failed to list items: %v", err)
+	}
+	if len(items) != 1 || items[0].BillId != bills[2].Id {
+		t.Fatalf("Expected the item of %s, got %v", bills[2].Name, items)
+	}
+	if items[0].BillName != "Migros" || items[0].GetDateString() != "2024-04-01" || items[0].Currency != currency.TRY {
+		t.Errorf("Unexpected bill fields: %+v", items[0])
+	}
+
+	items, err = billRepo.ListItems(context.Background(), ItemFilter{Text: "bread", Sort: SortPriceDesc, Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list items: %v", err)
+	}
+	if len(items) != 2 || items[0].BillId != bills[0].Id || items[1].BillId != bills[1].Id {
+		t.Errorf("Unexpected items order: %v", items)
+	}
+
+	items, err = billRepo.ListItems(context.Background(), ItemFilter{BillId: bills[1].Id})
+	if err != nil {
+		t.Fatalf("Failed to list items: %v", err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item, got %d", len(items))
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"context"
+	"fmt"
+	"time"
+)
+
+// timestampLayout is the format of deleted_at and audit_time, UTC
+// and ordered as text like SQLite's datetime()
+const timestampLayout = "2006-01-02 15:04:05"
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// TrashedBill is a bill in the trash with the time it was deleted
+type TrashedBill struct {
+	*bl.Bill
+	DeletedAt time.Time
+	Items     int
+}
+
+// ListTrash returns the bills in the trash, the last deleted first
+func (r *SqliteBillRepository) ListTrash(ctx context.Context) ([]*TrashedBill, error) {
+	return listTrash(ctx, r.conn())
+}
+
+func listTrash(ctx context.Context, q querier) ([]*TrashedBill, error) {
+	rows, err := q.QueryContext(ctx, `SELECT
+			invoice.invoice_id,
+			invoice_name,
+			invoice_date,
+			invoice_price,
+			invoice_currency,
+			invoice_country,
+			tag.tag_name,
+			invoice_link,
+			coalesce(invoice_fiscal_id, ''),
+			deleted_at,
+			(SELECT count(*) FROM item WHERE item.invoice_id = invoice.invoice_id)
+		FROM invoice
+		LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
+		LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, invoice.invoice_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []*TrashedBill{}
+	for rows.Next() {
+		var fiscalId, deletedAt string
+		var items int
+		bill, err := scanBill(rows.Scan, &fiscalId, &deletedAt, &items)
+		if err != nil {
+			return nil, err
+		}
+		bill.FiscalId = fiscalId
+		at, err := time.Parse(timestampLayout, deletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("bill %s: deleted_at: %w", bill.Id, err)
+		}
+		trash = append(trash, &TrashedBill{Bill: bill, DeletedAt: at, Items: items})
+	}
+	return trash, rows.Err()
+}
+
+// RestoreBill takes a bill out of the trash
+func (r *SqliteBillRepository) RestoreBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return restoreBill(ctx, tx, id)
+	})
+}
+
+func restoreBill(ctx context.Context, tx querier, id string) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE invoice SET deleted_at = NULL WHERE invoice_id = ? AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return fmt.Errorf("bill %s in the trash: %w", id, ErrNotFound)
+	}
+	return record(ctx, tx, &audit.Entry{
+		Action:   audit.Restore,
+		Entity:   audit.Bill,
+		EntityId: id,
+		BillId:   id,
+	})
+}
+
+// PurgeBill deletes a bill of the trash for good, together with its
+// tag link, its items and their tag links. The schema cascades these
+// deletes as well, they are explicit for databases created before
+// the cascading foreign keys.
+func (r *SqliteBillRepository) PurgeBill(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return purgeBill(ctx, tx, id)
+	})
+}
+
+func purgeBill(ctx context.Context, tx querier, id string) error {
+	var n int
+	err := tx.QueryRowContext(ctx,
+		`SELECT count(*) FROM invoice WHERE invoice_id = ? AND deleted_at IS NOT NULL`,
+		id,
+	).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("bill %s in the trash: %w", id, ErrNotFound)
+	}
+	return purge(ctx, tx, id)
+}
+
+// PurgeTrash deletes for good the bills deleted before the time,
+// it returns how many were purged
+func (r *SqliteBillRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	err := r.inTx(ctx, func(tx querier) error {
+		var err error
+		purged, err = purgeTrash(ctx, tx, before)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func purgeTrash(ctx context.Context, tx querier, before time.Time) (int, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT invoice_id FROM invoice WHERE deleted_at < ?`,
+		formatTimestamp(before),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := purge(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func purge(ctx context.Context, tx querier, id string) error {
+	old, err := readBillValues(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`DELETE FROM item_tag WHERE item_id IN (SELECT item_id FROM item WHERE invoice_id = ?);`,
+		`DELETE FROM item WHERE invoice_id = ?;`,
+		`DELETE FROM invoice_tag WHERE invoice_id = ?;`,
+		`DELETE FROM invoice WHERE invoice_id = ?;`,
+	} {
+		_, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return record(ctx, tx, &audit.Entry{
+		Action:   audit.Purge,
+		Entity:   audit.Bill,
+		EntityId: id,
+		BillId:   id,
+		Old:      old,
+	})
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"billdb/internal/bill"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	initEnv()
+	billRepo, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	if err := migrate(billRepo); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	ctx := context.Background()
+	bills := insertListFixtures(t, billRepo)
+	migros := bills[2]
+
+	if err := billRepo.DeleteBill(ctx, migros.Id); err != nil {
+		t.Fatalf("Failed to delete bill: %v", err)
+	}
+	if err := billRepo.DeleteBill(ctx, migros.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteBill twice: expected ErrNotFound, got %v", err)
+	}
+
+	// hidden from the listings, searches and stats
+	listed, err := billRepo.ListBills(ctx, BillFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || slices.ContainsFunc(listed, func(b *bill.Bill) bool { return b.Id == migros.Id }) {
+		t.Errorf("Expected the deleted bill to be hidden, got %d bills", len(listed))
+	}
+	if ids := searchIds(t, billRepo, BillFilter{Search: "migros"}); len(ids) != 0 {
+		t.Errorf("Expected no search match for the deleted bill, got %v", ids)
+	}
+	items, err := billRepo.ListItems(ctx, ItemFilter{Merchant: "Migros"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected the items of the deleted bill to be hidden, got %d", len(items))
+	}
+	if _, err := billRepo.GetBillByID(ctx, migros.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBillByID: expected ErrNotFound, got %v", err)
+	}
+	if err := billRepo.UpdateBill(ctx, migros); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateBill: expected ErrNotFound, got %v", err)
+	}
+	currencies, err := billRepo.GetCurrencies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(currencies, migros.GetCurrencyString()) {
+		t.Errorf("Expected no currency of the deleted bill, got %v", currencies)
+	}
+
+	trash, err := billRepo.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Id != migros.Id || trash[0].Items != 1 {
+		t.Fatalf("Expected the deleted bill with its item in the trash, got %v", trash)
+	}
+	if time.Since(trash[0].DeletedAt) > time.Minute {
+		t.Errorf("Expected the time of the delete, got %v", trash[0].DeletedAt)
+	}
+
+	if err := billRepo.RestoreBill(ctx, migros.Id); err != nil {
+		t.Fatalf("Failed to restore bill: %v", err)
+	}
+	if _, err := billRepo.GetBillByID(ctx, migros.Id); err != nil {
+		t.Errorf("Expected the restored bill, got %v", err)
+	}
+	if err := billRepo.RestoreBill(ctx, migros.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestoreBill of a live bill: expected ErrNotFound, got %v", err)
+	}
+	if err := billRepo.PurgeBill(ctx, migros.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PurgeBill of a live bill: expected ErrNotFound, got %v", err)
+	}
+
+	// retention
+	for _, b := range bills[:2] {
+		if err := billRepo.DeleteBill(ctx, b.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	purged, err := billRepo.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Errorf("Expected nothing deleted before an hour ago, purged %d", purged)
+	}
+	purged, err = billRepo.PurgeTrash(ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 bills purged, got %d", purged)
+	}
+	trash, err = billRepo.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %d bills", len(trash))
+	}
+	if n := countOrphans(t, billRepo); n != 0 {
+		t.Errorf("Expected no orphans after the purge, got %d", n)
+	}
+	if _, err := billRepo.GetBillByID(ctx, migros.Id); err != nil {
+		t.Errorf("Expected the live bill to be kept, got %v", err)
+	}
+}
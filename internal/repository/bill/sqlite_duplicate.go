@@ -0,0 +1,205 @@
+package repository
+
+import (
+	bl "billdb/internal/bill"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The fiscal key of a bill is stored in invoice_fiscal_id, found by the
+// parser or read from the link when the bill is written, so the bills of
+// a receipt are found by its key whatever the form of their links.
+// The migration adds the column and reads the keys of the stored links.
+const (
+	fiscalIdMigration         = "010_fiscal_id"
+	postgresFiscalIdMigration = "004_fiscal_id"
+)
+
+// duplicateWindow is how far apart the dates of the bills of
+// DuplicateCandidates are, a receipt of the night may be entered
+// on the next day
+const duplicateWindow = 24 * time.Hour
+
+// DuplicatePair is two bills the review of duplicates found to be
+// different receipts, Id is the smaller id
+type DuplicatePair struct {
+	Id    string
+	Other string
+}
+
+// NewDuplicatePair returns the pair of two bills in either order
+func NewDuplicatePair(id string, other string) DuplicatePair {
+	if other < id {
+		id, other = other, id
+	}
+	return DuplicatePair{Id: id, Other: other}
+}
+
+// fiscalId is the invoice_fiscal_id of a bill, NULL when it has no key
+func fiscalId(b *bl.Bill) sql.NullString {
+	key := b.FiscalKey()
+	return sql.NullString{String: key, Valid: key != ""}
+}
+
+func addFiscalIdSqlite(tx *sql.Tx) error {
+	return addFiscalId(context.Background(), tx)
+}
+
+func addFiscalIdPostgres(tx *sql.Tx) error {
+	return addFiscalId(context.Background(), rebound{tx})
+}
+
+// addFiscalId is the Go migration adding invoice_fiscal_id
+// with the keys of the links of the stored bills
+func addFiscalId(ctx context.Context, tx querier) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE invoice ADD COLUMN invoice_fiscal_id TEXT`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE INDEX invoice_fiscal_id_idx ON invoice (invoice_fiscal_id)`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT invoice_id, invoice_link FROM invoice
+		WHERE invoice_link IS NOT NULL AND invoice_link != ''`)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]string)
+	for rows.Next() {
+		b := &bl.Bill{}
+		if err := rows.Scan(&b.Id, &b.Link); err != nil {
+			rows.Close()
+			return err
+		}
+		if key := b.FiscalKey(); key != "" {
+			keys[b.Id] = key
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, key := range keys {
+		_, err := tx.ExecContext(ctx, `UPDATE invoice SET invoice_fiscal_id = ? WHERE invoice_id = ?`, key, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DuplicateCandidates returns the bills but b that may be its receipt,
+// see BillRepository
+func (r *SqliteBillRepository) DuplicateCandidates(ctx context.Context, b *bl.Bill) ([]*bl.Bill, error) {
+	return duplicateCandidates(ctx, r.conn(), b)
+}
+
+func duplicateCandidates(ctx context.Context, q querier, b *bl.Bill) ([]*bl.Bill, error) {
+	var conds []string
+	var args []any
+	if b.Link != "" {
+		conds = append(conds, "invoice_link = ?")
+		args = append(args, b.Link)
+	}
+	if key := b.FiscalKey(); key != "" {
+		conds = append(conds, "invoice_fiscal_id = ?")
+		args = append(args, key)
+	}
+	if !b.Date.IsZero() {
+		conds = append(conds, "(invoice_currency = ? AND invoice_date BETWEEN ? AND ?)")
+		args = append(args,
+			b.GetCurrencyString(),
+			bl.DateToString(b.Date.Add(-duplicateWindow)),
+			bl.DateToString(b.Date.Add(duplicateWindow)))
+	}
+	if len(conds) == 0 {
+		return []*bl.Bill{}, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT
+			invoice.invoice_id,
+			invoice_name,
+			invoice_date,
+			invoice_price,
+			invoice_currency,
+			invoice_country,
+			tag.tag_name,
+			invoice_link,
+			coalesce(invoice_fiscal_id, '')
+		FROM invoice
+		LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
+		LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
+		WHERE invoice.deleted_at IS NULL AND invoice.invoice_id != ?
+			AND (`+strings.Join(conds, " OR ")+`)
+		ORDER BY invoice_date, invoice.invoice_id`,
+		append([]any{b.Id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bills := []*bl.Bill{}
+	for rows.Next() {
+		var fiscalId string
+		bill, err := scanBill(rows.Scan, &fiscalId)
+		if err != nil {
+			return nil, err
+		}
+		bill.FiscalId = fiscalId
+		bills = append(bills, bill)
+	}
+	return bills, rows.Err()
+}
+
+// DismissDuplicate records that two bills are different receipts
+func (r *SqliteBillRepository) DismissDuplicate(ctx context.Context, id string, other string) error {
+	return r.inTx(ctx, func(tx querier) error {
+		return dismissDuplicate(ctx, tx, id, other)
+	})
+}
+
+func dismissDuplicate(ctx context.Context, tx querier, id string, other string) error {
+	if id == other {
+		return fmt.Errorf("%w: bill %s is not a duplicate of itself", ErrConflict, id)
+	}
+	for _, billId := range []string{id, other} {
+		if _, err := getBill(ctx, tx, billId); err != nil {
+			return err
+		}
+	}
+	pair := NewDuplicatePair(id, other)
+	_, err := tx.ExecContext(ctx, `INSERT INTO duplicate_dismissal (invoice_id, other_id, dismissed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		pair.Id, pair.Other, formatTimestamp(time.Now()))
+	return mapError(err)
+}
+
+// DismissedDuplicates returns the pairs of DismissDuplicate
+func (r *SqliteBillRepository) DismissedDuplicates(ctx context.Context) ([]DuplicatePair, error) {
+	return dismissedDuplicates(ctx, r.conn())
+}
+
+func dismissedDuplicates(ctx context.Context, q querier) ([]DuplicatePair, error) {
+	rows, err := q.QueryContext(ctx, `SELECT invoice_id, other_id FROM duplicate_dismissal
+		ORDER BY invoice_id, other_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pairs := []DuplicatePair{}
+	for rows.Next() {
+		var p DuplicatePair
+		if err := rows.Scan(&p.Id, &p.Other); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
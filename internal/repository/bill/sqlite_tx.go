@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier is the part of *sql.DB and *sql.Tx used by the repository,
+// so that the same queries run inside and outside of a unit of work
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// conn returns the transaction of the unit of work if there is one
+func (r *SqliteBillRepository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.DB
+}
+
+// WithTx runs fn as one unit of work. Every write made through the repository
+// passed to fn goes into the same transaction, which is committed when fn
+// returns nil and rolled back otherwise. Nested calls join the outer unit of work.
+func (r *SqliteBillRepository) WithTx(ctx context.Context, fn func(tx BillRepository) error) error {
+	return r.inTx(ctx, func(q querier) error {
+		return fn(&SqliteBillRepository{DB: r.DB, tx: q.(*sql.Tx)})
+	})
+}
+
+// inTx runs fn in the transaction of the unit of work,
+// or in a new transaction when the repository is not bound to one
+func (r *SqliteBillRepository) inTx(ctx context.Context, fn func(q querier) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
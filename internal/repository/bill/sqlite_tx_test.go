@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// newTxTestBill returns a bill with a fresh tag and two items
+func newTxTestBill(secondItem string) *bill.Bill {
+	id := ksuid.New().String()
+	b := bill.New(
+		id,
+		"Tx bill",
+		time.Now(),
+		30.0,
+		currency.RSD,
+		country.SERBIA,
+		[]*item.Item{},
+		tag.New("tx-tag"),
+		"",
+		"",
+	)
+	b.Items = []*item.Item{
+		item.New(ksuid.New().String(), id, "item1", 10.0, 10.0, 1.0),
+		item.New(ksuid.New().String(), id, secondItem, 20.0, 20.0, 1.0),
+	}
+	return b
+}
+
+// assertEmpty fails the test if a write of a rolled back unit of work persisted
+func assertEmpty(t *testing.T, r *SqliteBillRepository) {
+	t.Helper()
+	for _, table := range []string{"invoice", "invoice_tag", "tag", "item"} {
+		var n int
+		err := r.DB.QueryRow("SELECT count(*) FROM " + table).Scan(&n)
+		if err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("Expected no rows in %s after rollback, got %d", table, n)
+		}
+	}
+}
+
+func setUpTxDB(t *testing.T) *SqliteBillRepository {
+	t.Helper()
+	initEnv()
+	billRepository, err := setUpDB(t)
+	if err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	err = migrate(billRepository)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	// inject a failure on the insert of the second item
+	_, err = billRepository.DB.Exec(`CREATE TRIGGER fail_item BEFORE INSERT ON item
+		WHEN NEW.item_name = 'fail'
+		BEGIN SELECT RAISE(ABORT, 'injected failure'); END;`)
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	return billRepository
+}
+
+func TestInsertBillWithItemsRollback(t *testing.T) {
+	billRepository := setUpTxDB(t)
+	ctx := context.Background()
+
+	err := billRepository.InsertBillWithItems(ctx, newTxTestBill("fail"))
+	if err == nil {
+		t.Fatal("Expected the injected item failure")
+	}
+	assertEmpty(t, billRepository)
+
+	b := newTxTestBill("item2")
+	b.Items[1].ItemId = b.Items[0].ItemId
+	err = billRepository.InsertBillWithItems(ctx, b)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Expected ErrDuplicate for the repeated item id, got %v", err)
+	}
+	assertEmpty(t, billRepository)
+
+	b = newTxTestBill("item2")
+	err = billRepository.InsertBillWithItems(ctx, b)
+	if err != nil {
+		t.Fatalf("Failed to insert bill with items: %v", err)
+	}
+	items, err := billRepository.GetItemsByID(ctx, b.Id)
+	if err != nil {
+		t.Fatalf("Failed to get items: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(items))
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	billRepository := setUpTxDB(t)
+	ctx := context.Background()
+	injected := errors.New("injected failure")
+
+	// fails after the bill and its items are written
+	b := newTxTestBill("item2")
+	err := billRepository.WithTx(ctx, func(tx BillRepository) error {
+		if err := tx.InsertBill(ctx, b); err != nil {
+			return err
+		}
+		if err := tx.InsertItems(ctx, b.Items); err != nil {
+			return err
+		}
+		// writes are visible inside the unit of work
+		if _, err := tx.GetBillByID(ctx, b.Id); err != nil {
+			return err
+		}
+		return injected
+	})
+	if !errors.Is(err, injected) {
+		t.Fatalf("Expected the injected error, got %v", err)
+	}
+	assertEmpty(t, billRepository)
+
+	// nested units of work join the outer transaction
+	err = billRepository.WithTx(ctx, func(tx BillRepository) error {
+		if err := tx.InsertBillWithItems(ctx, b); err != nil {
+			return err
+		}
+		return tx.UpdateBill(ctx, newTxTestBill("item2"))
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from the update, got %v", err)
+	}
+	assertEmpty(t, billRepository)
+
+	err = billRepository.WithTx(ctx, func(tx BillRepository) error {
+		return tx.InsertBillWithItems(ctx, b)
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit the unit of work: %v", err)
+	}
+	if _, err = billRepository.GetBillByID(ctx, b.Id); err != nil {
+		t.Errorf("Committed bill is missing: %v", err)
+	}
+}
@@ -0,0 +1,580 @@
+// Package check finds rows of the bill database that break the assumptions
+// of the repository: orphans, broken tag links, prices that disagree with
+// the items, values ScanToBill can't parse and duplicate links.
+package check
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	OrphanItem      Kind = "orphan_item"     // item of a missing bill
+	OrphanItemTag   Kind = "orphan_item_tag" // item_tag of a missing item or tag
+	OrphanBillTag   Kind = "orphan_bill_tag" // invoice_tag of a missing bill
+	SetAside        Kind = "set_aside"       // orphan kept aside by the cascade migration
+	MissingTag      Kind = "missing_tag"     // invoice_tag pointing to a missing tag
+	UnusedTag       Kind = "unused_tag"      // tag without bills and items
+	PriceMismatch   Kind = "price_mismatch"  // item sum differs from the bill price
+	InvalidDate     Kind = "invalid_date"
+	InvalidCurrency Kind = "invalid_currency"
+	InvalidCountry  Kind = "invalid_country"
+	DuplicateLink   Kind = "duplicate_link"
+)
+
+// Kinds lists every check in the order of the report
+var Kinds = []Kind{
+	OrphanItem,
+	OrphanItemTag,
+	OrphanBillTag,
+	SetAside,
+	MissingTag,
+	UnusedTag,
+	PriceMismatch,
+	InvalidDate,
+	InvalidCurrency,
+	InvalidCountry,
+	DuplicateLink,
+}
+
+// DefaultTolerance is the allowed difference between
+// the bill price and the sum of its items
+const DefaultTolerance = 0.01
+
+// dateLayouts are the formats found in imported databases,
+// dates in one of them are rewritten to the bill date format
+var dateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"02.01.2006",
+	"02.01.2006 15:04",
+	"2006/01/02",
+	"20060102",
+}
+
+type Problem struct {
+	Kind Kind
+	// id of the bill, item or tag the problem is about
+	Id     string
+	Detail string
+	// Repair describes the automatic repair, empty if there is none
+	Repair string
+
+	fix func(ctx context.Context, tx *sql.Tx) error
+}
+
+func (p *Problem) Repairable() bool {
+	return p.fix != nil
+}
+
+type Report struct {
+	Problems []*Problem
+	// Repaired is the number of problems fixed by Checker.Repair
+	Repaired int
+}
+
+// Count returns the number of problems of the kind
+func (r *Report) Count(kind Kind) int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Repairable returns the number of problems with an automatic repair
+func (r *Report) Repairable() int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Repairable() {
+			n++
+		}
+	}
+	return n
+}
+
+// querier is the part of *sql.DB and *sql.Tx used by the checks
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type Checker struct {
+	DB        *sql.DB
+	Tolerance float64
+}
+
+func New(db *sql.DB) *Checker {
+	return &Checker{DB: db, Tolerance: DefaultTolerance}
+}
+
+// Run reports the problems without changing the database
+func (c *Checker) Run(ctx context.Context) (*Report, error) {
+	return c.run(ctx, c.DB)
+}
+
+// Repair runs the checks and applies every automatic repair in one transaction.
+// The changes to bills, items and tags are written to the audit log in it,
+// as made by the system. The returned report lists all problems found,
+// Repaired counts the fixed ones.
+func (c *Checker) Repair(ctx context.Context) (*Report, error) {
+	ctx = audit.WithSource(ctx, audit.SourceSystem)
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report, err := c.run(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range report.Problems {
+		if !p.Repairable() {
+			continue
+		}
+		err = p.fix(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("repair %s %s: %w", p.Kind, p.Id, err)
+		}
+		report.Repaired++
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *Checker) run(ctx context.Context, q querier) (*Report, error) {
+	report := &Report{}
+	checks := []func(context.Context, querier) ([]*Problem, error){
+		orphanItems,
+		orphanItemTags,
+		orphanBillTags,
+		setAside,
+		missingTags,
+		unusedTags,
+		c.priceMismatches,
+		invalidValues,
+		duplicateLinks,
+	}
+	for _, check := range checks {
+		problems, err := check(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		report.Problems = append(report.Problems, problems...)
+	}
+	return report, nil
+}
+
+// exec returns a fix running one statement
+func exec(query string, args ...any) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	}
+}
+
+// audited returns a fix running one statement and logging e
+func audited(e *audit.Entry, query string, args ...any) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		return repository.RecordAudit(ctx, tx, e)
+	}
+}
+
+// deleteItem returns the fix of an orphan item, logged
+// with the id of its missing bill
+func deleteItem(id string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		values, err := repository.ReadItemValues(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return audited(&audit.Entry{
+			Action:   audit.Delete,
+			Entity:   audit.Item,
+			EntityId: id,
+			BillId:   values["bill_id"],
+			Old:      values,
+		}, `DELETE FROM item_tag WHERE item_id = ?;
+			DELETE FROM item WHERE item_id = ?;`, id, id)(ctx, tx)
+	}
+}
+
+// deleteTagLink returns the fix removing the tag link of the row of
+// table whose column is id, logged as a deleted tag of bill
+func deleteTagLink(table string, column string, id string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		var (
+			tagId  int64
+			name   sql.NullString
+			billId string
+		)
+		err := tx.QueryRowContext(ctx, `SELECT link.tag_id, tag.tag_name
+			FROM `+table+` AS link
+			LEFT JOIN tag ON tag.tag_id = link.tag_id
+			WHERE link.`+column+` = ?`,
+			id,
+		).Scan(&tagId, &name)
+		if err != nil {
+			return err
+		}
+		if column == "invoice_id" {
+			billId = id
+		} else {
+			// empty when the item is missing too
+			err = tx.QueryRowContext(ctx, `SELECT invoice_id FROM item WHERE item_id = ?`, id).Scan(&billId)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		return audited(&audit.Entry{
+			Action:   audit.Delete,
+			Entity:   audit.Tag,
+			EntityId: strconv.FormatInt(tagId, 10),
+			BillId:   billId,
+			Old:      map[string]string{"name": name.String, column: id},
+		}, `DELETE FROM `+table+` WHERE `+column+` = ?`, id)(ctx, tx)
+	}
+}
+
+// deleteTag returns the fix of an unused tag
+func deleteTag(id string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		var name string
+		err := tx.QueryRowContext(ctx, `SELECT tag_name FROM tag WHERE tag_id = ?`, id).Scan(&name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("tag %s: %w", id, repository.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		return audited(&audit.Entry{
+			Action:   audit.Delete,
+			Entity:   audit.Tag,
+			EntityId: id,
+			Old:      map[string]string{"name": name},
+		}, `DELETE FROM tag WHERE tag_id = ?`, id)(ctx, tx)
+	}
+}
+
+// updateBill returns the fix setting the column of the bill field to value
+func updateBill(id string, field string, old sql.NullString, value string) func(ctx context.Context, tx *sql.Tx) error {
+	return audited(&audit.Entry{
+		Action:   audit.Update,
+		Entity:   audit.Bill,
+		EntityId: id,
+		BillId:   id,
+		Old:      map[string]string{field: old.String},
+		New:      map[string]string{field: value},
+	}, `UPDATE invoice SET invoice_`+field+` = ? WHERE invoice_id = ?`, value, id)
+}
+
+// queryProblems scans rows of (id, detail) into problems of the kind
+func queryProblems(
+	ctx context.Context,
+	q querier,
+	kind Kind,
+	query string,
+	repair func(p *Problem),
+	args ...any,
+) ([]*Problem, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("check %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var problems []*Problem
+	for rows.Next() {
+		p := &Problem{Kind: kind}
+		err = rows.Scan(&p.Id, &p.Detail)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", kind, err)
+		}
+		if repair != nil {
+			repair(p)
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+func orphanItems(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, OrphanItem,
+		`SELECT item_id, 'bill ' || invoice_id || ' does not exist'
+		FROM item
+		WHERE invoice_id NOT IN (SELECT invoice_id FROM invoice)
+		ORDER BY item_id`,
+		func(p *Problem) {
+			p.Repair = "delete the item"
+			p.fix = deleteItem(p.Id)
+		},
+	)
+}
+
+func orphanItemTags(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, OrphanItemTag,
+		`SELECT item_id,
+			CASE WHEN item_id NOT IN (SELECT item_id FROM item)
+				THEN 'item does not exist'
+				ELSE 'tag ' || tag_id || ' does not exist'
+			END
+		FROM item_tag
+		WHERE item_id NOT IN (SELECT item_id FROM item)
+			OR tag_id NOT IN (SELECT tag_id FROM tag)
+		ORDER BY item_id`,
+		func(p *Problem) {
+			p.Repair = "delete the item tag link"
+			p.fix = deleteTagLink("item_tag", "item_id", p.Id)
+		},
+	)
+}
+
+func orphanBillTags(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, OrphanBillTag,
+		`SELECT invoice_id, 'bill does not exist'
+		FROM invoice_tag
+		WHERE invoice_id NOT IN (SELECT invoice_id FROM invoice)
+		ORDER BY invoice_id`,
+		func(p *Problem) {
+			p.Repair = "delete the bill tag link"
+			p.fix = deleteTagLink("invoice_tag", "invoice_id", p.Id)
+		},
+	)
+}
+
+// setAsideTables are the tables 005_cascade_deletes.sql keeps the rows
+// it couldn't copy back in, with the column identifying a row
+var setAsideTables = []struct {
+	table  string
+	column string
+	detail string
+}{
+	{"item_orphan", "item_id", "'item of the missing bill ' || invoice_id"},
+	{"item_tag_orphan", "item_id", "'item tag link to tag ' || tag_id"},
+	{"invoice_tag_orphan", "invoice_id", "'bill tag link to tag ' || tag_id"},
+}
+
+// setAside reports the rows kept by the cascade migration, so they can be
+// looked at before the repair deletes them. They are no longer part of
+// the bills, their deletion isn't logged.
+func setAside(ctx context.Context, q querier) ([]*Problem, error) {
+	var problems []*Problem
+	for _, t := range setAsideTables {
+		exists, err := tableExists(ctx, q, t.table)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", SetAside, err)
+		}
+		if !exists {
+			continue
+		}
+		table := t.table
+		found, err := queryProblems(ctx, q, SetAside,
+			`SELECT `+t.column+`, '`+table+`: ' || `+t.detail+`
+			FROM `+table+`
+			ORDER BY `+t.column,
+			func(p *Problem) {
+				p.Repair = "delete the row from " + table
+				p.fix = exec(`DELETE FROM `+table+` WHERE `+t.column+` = ?`, p.Id)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, found...)
+	}
+	return problems, nil
+}
+
+func tableExists(ctx context.Context, q querier, table string) (bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT 1 FROM sqlite_master
+		WHERE type = 'table' AND name = ?`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+func missingTags(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, MissingTag,
+		`SELECT invoice_id, 'tag ' || tag_id || ' does not exist'
+		FROM invoice_tag
+		WHERE invoice_id IN (SELECT invoice_id FROM invoice)
+			AND tag_id NOT IN (SELECT tag_id FROM tag)
+		ORDER BY invoice_id`,
+		func(p *Problem) {
+			p.Repair = "remove the tag from the bill"
+			p.fix = deleteTagLink("invoice_tag", "invoice_id", p.Id)
+		},
+	)
+}
+
+func unusedTags(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, UnusedTag,
+		`SELECT tag_id, 'tag ' || tag_name || ' is not used'
+		FROM tag
+		WHERE tag_id NOT IN (SELECT tag_id FROM invoice_tag)
+			AND tag_id NOT IN (SELECT tag_id FROM item_tag)
+		ORDER BY tag_name`,
+		func(p *Problem) {
+			p.Repair = "delete the tag"
+			p.fix = deleteTag(p.Id)
+		},
+	)
+}
+
+// priceMismatches is report only, either the price or an item is wrong.
+// The bills in the trash are skipped as by the other bill checks.
+func (c *Checker) priceMismatches(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, PriceMismatch,
+		`SELECT invoice.invoice_id,
+			printf('price %.2f, items sum %.2f', invoice_price, sum(item_price))
+		FROM invoice
+		JOIN item ON item.invoice_id = invoice.invoice_id
+		WHERE invoice.deleted_at IS NULL
+		GROUP BY invoice.invoice_id
+		HAVING abs(invoice_price - sum(item_price)) > ?
+		ORDER BY invoice_date, invoice.invoice_id`,
+		nil,
+		c.Tolerance,
+	)
+}
+
+// invalidValues finds the bill columns ScanToBill would fail on,
+// the bills in the trash are left as they were deleted
+func invalidValues(ctx context.Context, q querier) ([]*Problem, error) {
+	rows, err := q.QueryContext(ctx, `SELECT
+			invoice_id,
+			invoice_date,
+			invoice_currency,
+			invoice_country
+		FROM invoice
+		WHERE deleted_at IS NULL
+		ORDER BY invoice_id`)
+	if err != nil {
+		return nil, fmt.Errorf("check values: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []*Problem
+	for rows.Next() {
+		var (
+			id       string
+			date     sql.NullString
+			currency sql.NullString
+			country  sql.NullString
+		)
+		err = rows.Scan(&id, &date, &currency, &country)
+		if err != nil {
+			return nil, fmt.Errorf("check values: %w", err)
+		}
+		if p := checkDate(id, date); p != nil {
+			problems = append(problems, p)
+		}
+		if p := checkCurrency(id, currency); p != nil {
+			problems = append(problems, p)
+		}
+		if p := checkCountry(id, country); p != nil {
+			problems = append(problems, p)
+		}
+	}
+	return problems, rows.Err()
+}
+
+func checkDate(id string, value sql.NullString) *Problem {
+	if _, err := bl.StringToDate(value.String); value.Valid && err == nil {
+		return nil
+	}
+	p := &Problem{Kind: InvalidDate, Id: id, Detail: describe("date", value)}
+	if date, ok := NormalizeDate(value.String); ok {
+		p.Repair = "set the date to " + date
+		p.fix = updateBill(id, "date", value, date)
+	}
+	return p
+}
+
+func checkCurrency(id string, value sql.NullString) *Problem {
+	if _, err := currency.Parse(value.String); value.Valid && err == nil {
+		return nil
+	}
+	p := &Problem{Kind: InvalidCurrency, Id: id, Detail: describe("currency", value)}
+	normalized := strings.ToLower(strings.TrimSpace(value.String))
+	if _, err := currency.Parse(normalized); err == nil {
+		p.Repair = "set the currency to " + normalized
+		p.fix = updateBill(id, "currency", value, normalized)
+	}
+	return p
+}
+
+func checkCountry(id string, value sql.NullString) *Problem {
+	if _, err := country.Parse(value.String); value.Valid && err == nil {
+		return nil
+	}
+	p := &Problem{Kind: InvalidCountry, Id: id, Detail: describe("country", value)}
+	normalized := strings.ToLower(strings.TrimSpace(value.String))
+	if _, err := country.Parse(normalized); err == nil {
+		p.Repair = "set the country to " + normalized
+		p.fix = updateBill(id, "country", value, normalized)
+	}
+	return p
+}
+
+func describe(column string, value sql.NullString) string {
+	if !value.Valid {
+		return column + " is NULL"
+	}
+	return fmt.Sprintf("%s %q can't be parsed", column, value.String)
+}
+
+// NormalizeDate converts a date of a known foreign format to the bill date format
+func NormalizeDate(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if _, err := bl.StringToDate(value); err == nil {
+		return value, true
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return bl.DateToString(t), true
+		}
+	}
+	return "", false
+}
+
+// duplicateLinks is report only, which bill to keep is up to the user.
+// A bill in the trash may share the link of the bill that replaced it.
+func duplicateLinks(ctx context.Context, q querier) ([]*Problem, error) {
+	return queryProblems(ctx, q, DuplicateLink,
+		`SELECT min(invoice_id),
+			count(*) || ' bills share the link ' || invoice_link || ': ' || group_concat(invoice_id, ', ')
+		FROM invoice
+		WHERE invoice_link IS NOT NULL AND invoice_link != ''
+			AND deleted_at IS NULL
+		GROUP BY invoice_link
+		HAVING count(*) > 1
+		ORDER BY invoice_link`,
+		nil,
+	)
+}
@@ -0,0 +1,193 @@
+package check
+
+import (
+	"billdb/internal/audit"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"database/sql"
+	"maps"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := repository.OpenSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = repository.NewMigrator(db).Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+// seed writes one valid bill, one row for every problem and a bill in the
+// trash with most of them, with foreign keys off as in databases from
+// before they were enforced
+func seed(t *testing.T, db *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;
+		INSERT INTO tag (tag_id, tag_name) VALUES (1, 'food'), (2, 'unused');
+		INSERT INTO invoice VALUES ('ok', 'Shop', '2024-01-02', 30, 'rsd', 'serbia', 'link-ok', '', NULL, NULL);
+		INSERT INTO invoice_tag VALUES ('ok', 1);
+		INSERT INTO item (item_id, invoice_id, item_name, item_price) VALUES
+			('ok-1', 'ok', 'a', 10), ('ok-2', 'ok', 'b', 20);
+
+		INSERT INTO item (item_id, invoice_id, item_name, item_price) VALUES ('orphan', 'gone', 'c', 1);
+		INSERT INTO item_tag VALUES ('orphan', 1), ('missing', 1);
+		INSERT INTO invoice_tag VALUES ('gone', 1);
+		INSERT INTO invoice VALUES ('no-tag', 'Shop', '2024-01-03', 5, 'rsd', 'serbia', '', '', NULL, NULL);
+		INSERT INTO invoice_tag VALUES ('no-tag', 99);
+		INSERT INTO invoice VALUES ('price', 'Shop', '2024-01-04', 50, 'rsd', 'serbia', 'link-dup', '', NULL, NULL);
+		INSERT INTO item (item_id, invoice_id, item_name, item_price) VALUES ('price-1', 'price', 'd', 10);
+		INSERT INTO invoice VALUES ('values', 'Shop', '04.01.2024', 5, 'RSD', 'Atlantis', 'link-dup', '', NULL, NULL);
+		INSERT INTO invoice VALUES ('null', 'Shop', 'someday', 5, NULL, 'serbia', '', '', NULL, NULL);
+		INSERT INTO invoice (invoice_id, invoice_name, invoice_date, invoice_price, invoice_currency, invoice_country, invoice_link, deleted_at)
+			VALUES ('trashed', 'Shop', '05.01.2024', 70, 'RSD', 'Atlantis', 'link-ok', '2024-02-01 10:00:00');
+		INSERT INTO item (item_id, invoice_id, item_name, item_price) VALUES ('trashed-1', 'trashed', 'f', 1);
+		INSERT INTO item_orphan (item_id, invoice_id, item_name) VALUES ('aside', 'gone', 'e');
+		PRAGMA foreign_keys = ON;`)
+	if err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	db := openDB(t)
+	seed(t, db)
+
+	report, err := New(db).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[Kind]int{
+		OrphanItem:      1,
+		OrphanItemTag:   1,
+		OrphanBillTag:   1,
+		SetAside:        1,
+		MissingTag:      1,
+		UnusedTag:       1,
+		PriceMismatch:   1,
+		InvalidDate:     2,
+		InvalidCurrency: 2,
+		InvalidCountry:  1,
+		DuplicateLink:   1,
+	}
+	for _, kind := range Kinds {
+		if got := report.Count(kind); got != want[kind] {
+			t.Errorf("%s: expected %d problems, got %d", kind, want[kind], got)
+		}
+	}
+	for _, p := range report.Problems {
+		if p.Id == "ok" || p.Id == "ok-1" || p.Id == "ok-2" {
+			t.Errorf("Valid row reported: %s %s %s", p.Kind, p.Id, p.Detail)
+		}
+		if p.Id == "trashed" || strings.Contains(p.Detail, "trashed") {
+			t.Errorf("Bill in the trash reported: %s %s %s", p.Kind, p.Id, p.Detail)
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	db := openDB(t)
+	seed(t, db)
+	ctx := context.Background()
+	checker := New(db)
+
+	report, err := checker.Repair(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != report.Repairable() {
+		t.Errorf("Expected %d repairs, got %d", report.Repairable(), report.Repaired)
+	}
+
+	var date, currency string
+	err = db.QueryRow(`SELECT invoice_date, invoice_currency FROM invoice WHERE invoice_id = 'values'`).
+		Scan(&date, &currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date != "2024-01-04" || currency != "rsd" {
+		t.Errorf("Expected normalized date and currency, got %s %s", date, currency)
+	}
+
+	// the repairs are in the history of the bills, as made by the system
+	history, err := repository.NewSqliteBillRepository(db).History(ctx, "values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := map[string]string{}
+	for _, e := range history {
+		if e.Source != audit.SourceSystem || e.Action != audit.Update {
+			t.Errorf("Expected system updates, got %s %s", e.Source, e.Action)
+		}
+		for _, change := range e.Changes() {
+			changes[change.Field] = change.Old + " -> " + change.New
+		}
+	}
+	want := map[string]string{"date": "04.01.2024 -> 2024-01-04", "currency": "RSD -> rsd"}
+	if !maps.Equal(changes, want) {
+		t.Errorf("Expected the changes %v in the history, got %v", want, changes)
+	}
+	var deleted int
+	err = db.QueryRow(`SELECT count(*) FROM audit WHERE audit_action = 'delete'`).Scan(&deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the orphan item, the item tag link, two bill tag links and the unused tag
+	if deleted != 5 {
+		t.Errorf("Expected 5 deletions in the audit log, got %d", deleted)
+	}
+
+	// only the problems without an automatic repair remain
+	report, err = checker.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKinds := map[Kind]int{
+		PriceMismatch:   1,
+		InvalidDate:     1,
+		InvalidCurrency: 1,
+		InvalidCountry:  1,
+		DuplicateLink:   1,
+	}
+	for _, kind := range Kinds {
+		if got := report.Count(kind); got != wantKinds[kind] {
+			t.Errorf("%s after repair: expected %d problems, got %d", kind, wantKinds[kind], got)
+		}
+	}
+	if report.Repairable() != 0 {
+		t.Errorf("Expected no repairable problems left, got %d", report.Repairable())
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	cases := map[string]string{
+		"2024-01-02":           "2024-01-02",
+		" 2024-01-02 ":         "2024-01-02",
+		"2024-01-02 10:11:12":  "2024-01-02",
+		"2024-01-02T10:11:12Z": "2024-01-02",
+		"02.01.2024":           "2024-01-02",
+		"20240102":             "2024-01-02",
+	}
+	for in, want := range cases {
+		got, ok := NormalizeDate(in)
+		if !ok || got != want {
+			t.Errorf("NormalizeDate(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := NormalizeDate("someday"); ok {
+		t.Error("Expected someday to be rejected")
+	}
+}
@@ -0,0 +1,492 @@
+// Package dedup finds the bills that may be a receipt entered twice.
+// Bills with the same fiscal key or link are the same receipt, bills
+// with different fiscal keys are different ones, and the others with the
+// same total are scored by how alike their merchants, times and items
+// are. The pairs found among the stored bills are reviewed by hand,
+// merged into one bill or dismissed.
+package dedup
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/item"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/search"
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Threshold is the score from which a bill is reported as a duplicate
+const Threshold = 0.6
+
+// weights of the scores of the fuzzy match,
+// a score missing for lack of data has no weight
+const (
+	merchantWeight = 0.4
+	timeWeight     = 0.3
+	itemsWeight    = 0.3
+)
+
+// priceTolerance is the difference of totals still the same total
+const priceTolerance = 0.005
+
+// timeWindow is how far apart receipt times score nothing
+const timeWindow = 2 * time.Hour
+
+// scores of the time of bills without a receipt time in their link
+const (
+	sameDayScore = 0.5
+	nextDayScore = 0.2
+)
+
+// unsureScore caps the score of two bills typed without items: the same
+// merchant, total and day happen every day. A bill with items or a link
+// may be the scan of a bill typed by hand and is scored as usual.
+const unsureScore = 0.5
+
+// Match is how likely two bills are the same receipt
+type Match struct {
+	// Score is from 0 to 1, 1 for the same fiscal key or link
+	Score float64
+	// Reasons explain the score
+	Reasons []string
+}
+
+// Sure reports whether the bills are the same receipt
+func (m Match) Sure() bool {
+	return m.Score >= 1
+}
+
+// Percent returns the score in percent
+func (m Match) Percent() int {
+	return percent(m.Score)
+}
+
+// Candidate is a stored bill that may be the receipt of a new one
+type Candidate struct {
+	Match
+	Bill *bl.Bill
+}
+
+// Pair is two stored bills that may be the same receipt
+type Pair struct {
+	Match
+	A *bl.Bill
+	B *bl.Bill
+}
+
+// Score returns how likely a and b are the same receipt
+func Score(a *bl.Bill, b *bl.Bill) Match {
+	keyA, keyB := a.FiscalKey(), b.FiscalKey()
+	switch {
+	case keyA != "" && keyA == keyB:
+		return Match{Score: 1, Reasons: []string{"same fiscal key"}}
+	case a.Link != "" && a.Link == b.Link:
+		return Match{Score: 1, Reasons: []string{"same link"}}
+	case keyA != "" && keyB != "":
+		// receipts the tax authority told apart
+		return Match{}
+	case a.Currency != b.Currency || math.Abs(a.Price-b.Price) > priceTolerance:
+		return Match{}
+	}
+
+	m := Match{Reasons: []string{"same total"}}
+	var sum, weights float64
+	add := func(weight float64, score float64, reason string) {
+		sum += weight * score
+		weights += weight
+		m.Reasons = append(m.Reasons, reason)
+	}
+
+	merchant := similarity(a.Name, b.Name)
+	add(merchantWeight, merchant, fmt.Sprintf("merchant %d%% alike", percent(merchant)))
+
+	timeA, okA := a.ReceiptTime()
+	timeB, okB := b.ReceiptTime()
+	if okA && okB {
+		apart := timeA.Sub(timeB).Abs()
+		add(timeWeight, max(0, 1-float64(apart)/float64(timeWindow)),
+			fmt.Sprintf("%s apart", apart.Round(time.Minute)))
+	} else {
+		switch days := daysApart(a, b); days {
+		case 0:
+			add(timeWeight, sameDayScore, "same day")
+		case 1:
+			add(timeWeight, nextDayScore, "a day apart")
+		default:
+			add(timeWeight, 0, fmt.Sprintf("%d days apart", days))
+		}
+	}
+
+	if len(a.Items) > 0 && len(b.Items) > 0 {
+		overlap := itemOverlap(a.Items, b.Items)
+		add(itemsWeight, overlap, fmt.Sprintf("items %d%% alike", percent(overlap)))
+	}
+
+	m.Score = sum / weights
+	if typed(a) && typed(b) && m.Score > unsureScore {
+		m.Score = unsureScore
+		m.Reasons = append(m.Reasons, "typed without items")
+	}
+	return m
+}
+
+// typed tells if b was typed by hand without items,
+// it has neither items nor a link to compare
+func typed(b *bl.Bill) bool {
+	return len(b.Items) == 0 && b.Link == ""
+}
+
+// Find returns the stored bills that may be the receipt of b,
+// scored from Threshold, the most likely first
+func Find(ctx context.Context, repo repository.BillRepository, b *bl.Bill) ([]*Candidate, error) {
+	bills, err := repo.DuplicateCandidates(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []*Candidate{}
+	for _, other := range bills {
+		// the items are compared, or tell a typed bill from a scan
+		if len(b.Items) > 0 || typed(b) {
+			other.Items, err = repo.GetItemsByID(ctx, other.Id)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if m := Score(b, other); m.Score >= Threshold {
+			candidates = append(candidates, &Candidate{Match: m, Bill: other})
+		}
+	}
+	slices.SortStableFunc(candidates, func(x, y *Candidate) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+	return candidates, nil
+}
+
+// FindLink returns the stored bills of the receipt of a link, found by
+// the link or its fiscal key, to skip parsing a receipt already stored
+func FindLink(ctx context.Context, repo repository.BillRepository, link string) ([]*Candidate, error) {
+	candidates, err := Find(ctx, repo, &bl.Bill{Link: link})
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(candidates, func(c *Candidate) bool {
+		return !c.Sure()
+	}), nil
+}
+
+// Review returns the pairs of stored bills that may be the same receipt
+// and were not dismissed, the most likely first
+func Review(ctx context.Context, repo repository.BillRepository) ([]*Pair, error) {
+	bills, err := repository.AllBills(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	dismissed, err := repo.DismissedDuplicates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[repository.DuplicatePair]bool)
+	for _, p := range dismissed {
+		seen[p] = true
+	}
+
+	items := make(map[string][]*item.Item)
+	withItems := func(b *bl.Bill) (*bl.Bill, error) {
+		if _, ok := items[b.Id]; !ok {
+			its, err := repo.GetItemsByID(ctx, b.Id)
+			if err != nil {
+				return nil, err
+			}
+			items[b.Id] = its
+		}
+		c := *b
+		c.Items = items[b.Id]
+		return &c, nil
+	}
+
+	pairs := []*Pair{}
+	check := func(a *bl.Bill, b *bl.Bill) error {
+		key := repository.NewDuplicatePair(a.Id, b.Id)
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		a, err := withItems(a)
+		if err != nil {
+			return err
+		}
+		b, err = withItems(b)
+		if err != nil {
+			return err
+		}
+		if m := Score(a, b); m.Score >= Threshold {
+			pairs = append(pairs, &Pair{Match: m, A: a, B: b})
+		}
+		return nil
+	}
+
+	// the same receipt whenever it was dated, by its fiscal key
+	// or the link of an unknown form
+	receipts := make(map[string][]*bl.Bill)
+	for _, b := range bills {
+		key := b.FiscalKey()
+		if key == "" {
+			key = b.Link
+		}
+		if key != "" {
+			receipts[key] = append(receipts[key], b)
+		}
+	}
+	for _, group := range receipts {
+		for i := range group {
+			for _, other := range group[i+1:] {
+				if err := check(group[i], other); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// the bills of the same total a day apart at most
+	slices.SortStableFunc(bills, func(a, b *bl.Bill) int {
+		if c := cmp.Compare(a.Currency, b.Currency); c != 0 {
+			return c
+		}
+		return a.Date.Compare(b.Date)
+	})
+	for i, b := range bills {
+		for _, other := range bills[i+1:] {
+			if other.Currency != b.Currency || daysApart(b, other) > 1 {
+				break
+			}
+			if math.Abs(b.Price-other.Price) > priceTolerance {
+				continue
+			}
+			if err := check(b, other); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	slices.SortStableFunc(pairs, func(x, y *Pair) int {
+		if c := cmp.Compare(y.Score, x.Score); c != 0 {
+			return c
+		}
+		return y.A.Date.Compare(x.A.Date)
+	})
+	return pairs, nil
+}
+
+// Merge keeps one bill of a pair and moves the other to the trash in one
+// unit of work. The kept bill takes the tag, link, fiscal key and
+// journal of the other one when it has none, and its items when it has
+// no items. It returns the kept bill.
+func Merge(ctx context.Context, repo repository.BillRepository, keep string, drop string) (*bl.Bill, error) {
+	var kept *bl.Bill
+	err := repo.WithTx(ctx, func(tx repository.BillRepository) error {
+		dropped, err := tx.GetBillByID(ctx, drop)
+		if err != nil {
+			return err
+		}
+		dropped.Items, err = tx.GetItemsByID(ctx, drop)
+		if err != nil {
+			return err
+		}
+		kept, err = attach(ctx, tx, dropped, keep)
+		if err != nil {
+			return err
+		}
+		return tx.DeleteBill(ctx, drop)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// Attach adds a new bill b, such as a scan of a receipt, to the stored
+// bill existing instead of inserting it, as Merge completes the kept
+// bill. It returns the stored bill.
+func Attach(ctx context.Context, repo repository.BillRepository, b *bl.Bill, existing string) (*bl.Bill, error) {
+	var kept *bl.Bill
+	err := repo.WithTx(ctx, func(tx repository.BillRepository) error {
+		var err error
+		kept, err = attach(ctx, tx, b, existing)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// Replace inserts a new bill b and moves the stored bill existing to the
+// trash in one unit of work, b is completed by it as Merge completes the
+// kept bill. It returns b.
+func Replace(ctx context.Context, repo repository.BillRepository, b *bl.Bill, existing string) (*bl.Bill, error) {
+	if b.Id == existing {
+		return nil, fmt.Errorf("%w: bill %s is not a duplicate of itself", repository.ErrConflict, existing)
+	}
+	err := repo.WithTx(ctx, func(tx repository.BillRepository) error {
+		replaced, err := tx.GetBillByID(ctx, existing)
+		if err != nil {
+			return err
+		}
+		complete(b, replaced)
+		if len(b.Items) == 0 {
+			items, err := tx.GetItemsByID(ctx, existing)
+			if err != nil {
+				return err
+			}
+			copyItems(b, items)
+		}
+		if err := tx.InsertBillWithItems(ctx, b); err != nil {
+			return err
+		}
+		return tx.DeleteBill(ctx, existing)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// attach completes the stored bill keep by other with tx
+// and returns it with its items
+func attach(ctx context.Context, tx repository.BillRepository, other *bl.Bill, keep string) (*bl.Bill, error) {
+	if other.Id == keep {
+		return nil, fmt.Errorf("%w: bill %s is not a duplicate of itself", repository.ErrConflict, keep)
+	}
+	kept, err := tx.GetBillByID(ctx, keep)
+	if err != nil {
+		return nil, err
+	}
+	if complete(kept, other) {
+		if err := tx.UpdateBill(ctx, kept); err != nil {
+			return nil, err
+		}
+	}
+
+	kept.Items, err = tx.GetItemsByID(ctx, keep)
+	if err != nil {
+		return nil, err
+	}
+	if len(kept.Items) == 0 && len(other.Items) > 0 {
+		copyItems(kept, other.Items)
+		if err := tx.InsertItems(ctx, kept.Items); err != nil {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
+
+// complete gives kept the tag, link, fiscal key and journal of other
+// it lacks and reports whether it changed
+func complete(kept *bl.Bill, other *bl.Bill) bool {
+	changed := false
+	if !kept.Tag.Valid && other.Tag.Valid {
+		kept.Tag = other.Tag
+		changed = true
+	}
+	if kept.Link == "" && other.Link != "" {
+		kept.Link = other.Link
+		changed = true
+	}
+	if kept.FiscalKey() == "" && other.FiscalKey() != "" {
+		kept.FiscalId = other.FiscalKey()
+		changed = true
+	}
+	if kept.BillText == "" && other.BillText != "" {
+		kept.BillText = other.BillText
+		changed = true
+	}
+	return changed
+}
+
+// copyItems adds copies of items with new ids to b
+func copyItems(b *bl.Bill, items []*item.Item) {
+	for _, it := range items {
+		b.AddItem(item.New(ksuid.New().String(), b.Id, it.Name, it.Price, it.PriceOne, it.Quantity))
+	}
+}
+
+// similarity returns how alike two merchant names are from 0 to 1, the
+// mean of the Dice coefficient of their letter pairs and of the share
+// of the pairs of the shorter name found in the longer one, which
+// scores high a name and its short form
+func similarity(a string, b string) float64 {
+	pa, pb := letterPairs(a), letterPairs(b)
+	if len(pa) == 0 || len(pb) == 0 {
+		if normalize(a) == normalize(b) {
+			return 1
+		}
+		return 0
+	}
+	common := 0
+	for p := range pa {
+		if pb[p] {
+			common++
+		}
+	}
+	dice := 2 * float64(common) / float64(len(pa)+len(pb))
+	overlap := float64(common) / float64(min(len(pa), len(pb)))
+	return (dice + overlap) / 2
+}
+
+// normalize folds a name to its letters and digits
+func normalize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, search.Fold(name))
+}
+
+func letterPairs(name string) map[string]bool {
+	runes := []rune(normalize(name))
+	pairs := make(map[string]bool)
+	for i := 0; i+1 < len(runes); i++ {
+		pairs[string(runes[i:i+2])] = true
+	}
+	return pairs
+}
+
+// itemOverlap returns the Dice coefficient of the item names of two bills
+func itemOverlap(a []*item.Item, b []*item.Item) float64 {
+	names := make(map[string]int)
+	for _, it := range b {
+		names[normalize(it.Name)]++
+	}
+	common := 0
+	for _, it := range a {
+		name := normalize(it.Name)
+		if names[name] > 0 {
+			names[name]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// daysApart returns the number of days between the dates of two bills
+func daysApart(a *bl.Bill, b *bl.Bill) int {
+	dayA, errA := bl.StringToDate(a.GetDateString())
+	dayB, errB := bl.StringToDate(b.GetDateString())
+	if errA != nil || errB != nil {
+		return math.MaxInt
+	}
+	return int(dayA.Sub(*dayB).Abs().Hours() / 24)
+}
+
+func percent(score float64) int {
+	return int(math.Round(score * 100))
+}
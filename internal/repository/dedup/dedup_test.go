@@ -0,0 +1,246 @@
+package dedup
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newBill(id string, name string, day int, price float64, link string, items ...string) *bl.Bill {
+	b := bl.New(id, name, time.Date(2024, 5, day, 0, 0, 0, 0, time.UTC), price,
+		currency.RUB, country.RUSSIA, nil, tag.New(""), link, "")
+	for i, name := range items {
+		b.AddItem(item.New(id+"-"+string(rune('a'+i)), id, name, 10, 10, 1))
+	}
+	return b
+}
+
+func insert(t *testing.T, repo repository.BillRepository, bills ...*bl.Bill) {
+	t.Helper()
+	for _, b := range bills {
+		if err := repo.InsertBillWithItems(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	receipt := "t=20240501T1200&s=10.00&fn=9960440300&i=1234&fp=567890&n=1"
+	for _, c := range []struct {
+		name    string
+		a, b    *bl.Bill
+		atLeast float64
+		below   float64
+	}{
+		{"same fiscal key",
+			newBill("a", "Magnit", 1, 10, receipt),
+			newBill("b", "Other", 3, 99, "t=20240501T1200&fn=9960440300&i=1234&fp=567890&s=10.00&n=1"),
+			1, 1.1},
+		{"different fiscal keys",
+			newBill("a", "Magnit", 1, 10, receipt),
+			newBill("b", "Magnit", 1, 10, "t=20240501T1201&s=10.00&fn=9960440300&i=1235&fp=111111&n=1"),
+			0, 0.01},
+		{"the same cafe a few minutes apart",
+			newBill("a", "Magnit", 1, 10, "t=20240501T1200&s=10.00"),
+			newBill("b", "MAGNIT 42", 1, 10, "t=20240501T1210&s=10.00"),
+			Threshold, 1},
+		{"the same items typed by hand",
+			newBill("a", "Lenta", 1, 30, "", "milk", "bread", "eggs"),
+			newBill("b", "Lenta hypermarket", 1, 30, "", "Milk", "bread", "eggs"),
+			Threshold, 1},
+		{"the same merchant and day only",
+			newBill("a", "Lidl", 1, 5, ""),
+			newBill("b", "Lidl", 1, 5, ""),
+			0, Threshold},
+		{"different merchants",
+			newBill("a", "Lenta", 1, 10, ""),
+			newBill("b", "Pyaterochka", 1, 10, ""),
+			0, Threshold},
+		{"different totals",
+			newBill("a", "Lenta", 1, 10, ""),
+			newBill("b", "Lenta", 1, 10.5, ""),
+			0, 0.01},
+		{"different items",
+			newBill("a", "Lenta", 1, 30, "", "milk"),
+			newBill("b", "Lenta", 1, 30, "", "soap"),
+			0, Threshold},
+	} {
+		m := Score(c.a, c.b)
+		if m.Score < c.atLeast || m.Score >= c.below {
+			t.Errorf("%s: expected a score in [%v, %v), got %v %v", c.name, c.atLeast, c.below, m.Score, m.Reasons)
+		}
+		if r := Score(c.b, c.a); r.Score != m.Score {
+			t.Errorf("%s: expected the same score both ways, got %v and %v", c.name, m.Score, r.Score)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryBillRepository()
+	insert(t, repo,
+		newBill("lenta", "Lenta", 1, 30, "", "milk", "bread"),
+		newBill("magnit", "Magnit", 1, 30, "t=20240501T1200&s=30.00&fn=1&i=2&fp=3"),
+		newBill("later", "Lenta", 5, 30, ""),
+	)
+
+	found, err := Find(ctx, repo, newBill("new", "LENTA", 1, 30, "", "milk", "bread"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Bill.Id != "lenta" {
+		t.Errorf("Expected the bill of the same day, got %v", found)
+	}
+
+	// a bill typed without items is compared with the items of the stored one
+	found, err = Find(ctx, repo, newBill("typed", "Lenta", 1, 30, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Bill.Id != "lenta" {
+		t.Errorf("Expected the bill with items, got %v", found)
+	}
+
+	found, err = FindLink(ctx, repo, "t=20240501T1200&fn=1&i=2&fp=3&s=30.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Bill.Id != "magnit" || !found[0].Sure() {
+		t.Errorf("Expected the receipt found by its fiscal key, got %v", found)
+	}
+}
+
+func TestReview(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryBillRepository()
+	insert(t, repo,
+		newBill("lenta", "Lenta", 1, 30, "", "milk", "bread"),
+		newBill("typed", "Lenta", 2, 30, "", "milk", "bread"),
+		// two coffees of the same price
+		newBill("lidl", "Lidl", 10, 5, ""),
+		newBill("lidl2", "Lidl", 10, 5, ""),
+		newBill("other", "Pyaterochka", 10, 5, ""),
+		newBill("receipt", "Magnit", 12, 7, "t=20240512T1200&s=7.00&fn=1&i=2&fp=3"),
+		newBill("rescan", "Magnit", 20, 7, "t=20240512T1200&fn=1&i=2&fp=3&s=7.00"),
+	)
+
+	pairs, err := Review(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[repository.DuplicatePair]bool)
+	for _, p := range pairs {
+		found[repository.NewDuplicatePair(p.A.Id, p.B.Id)] = true
+	}
+	for _, want := range []repository.DuplicatePair{
+		repository.NewDuplicatePair("lenta", "typed"),
+		repository.NewDuplicatePair("receipt", "rescan"),
+	} {
+		if !found[want] {
+			t.Errorf("Expected the pair %v, got %v", want, found)
+		}
+	}
+	if found[repository.NewDuplicatePair("lidl", "lidl2")] {
+		t.Errorf("Expected bills of the same merchant and day only not paired, got %v", found)
+	}
+	if len(pairs) != 2 || !pairs[0].Sure() {
+		t.Errorf("Expected 2 pairs, the same receipt first, got %v", found)
+	}
+
+	if err := repo.DismissDuplicate(ctx, "typed", "lenta"); err != nil {
+		t.Fatal(err)
+	}
+	if pairs, err := Review(ctx, repo); err != nil || len(pairs) != 1 {
+		t.Errorf("Expected the dismissed pair hidden, got %v, %v", pairs, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryBillRepository()
+	scanned := newBill("scanned", "Lenta", 1, 30, "t=20240501T1200&s=30.00&fn=1&i=2&fp=3", "milk", "bread")
+	scanned.Tag = tag.New("food")
+	insert(t, repo, scanned, newBill("typed", "Lenta, my local one", 1, 30, ""))
+
+	kept, err := Merge(ctx, repo, "typed", "scanned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Name != "Lenta, my local one" || kept.Tag.String != "food" || kept.Link != scanned.Link || len(kept.Items) != 2 {
+		t.Errorf("Expected the kept bill completed by the other one, got %+v", kept)
+	}
+	got, err := repo.GetBillByID(ctx, "typed")
+	if err != nil || got.FiscalKey() != "ru:1/2/3" || got.Tag.String != "food" {
+		t.Errorf("Expected the kept bill stored, got %+v, %v", got, err)
+	}
+	if items, err := repo.GetItemsByID(ctx, "typed"); err != nil || len(items) != 2 {
+		t.Errorf("Expected the items copied, got %v, %v", items, err)
+	}
+	if _, err := repo.GetBillByID(ctx, "scanned"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected the other bill in the trash, got %v", err)
+	}
+
+	if _, err := Merge(ctx, repo, "typed", "typed"); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Expected a bill not merged with itself, got %v", err)
+	}
+	if _, err := Merge(ctx, repo, "typed", "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected a missing bill, got %v", err)
+	}
+}
+
+func TestResolutions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryBillRepository()
+	stored := newBill("stored", "Lenta", 1, 30, "", "milk")
+	insert(t, repo, stored, newBill("other", "Magnit", 1, 5, ""))
+	scan := newBill("scan", "Lenta", 1, 30, "t=20240501T1200&s=30.00&fn=1&i=2&fp=3", "milk", "bread")
+	candidates := []*Candidate{{Match: Score(scan, stored), Bill: stored}}
+
+	r := NewResolutions()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	token, err := r.Hold(scan, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Resolve(ctx, repo, token, ActionReplace, "other"); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Expected a bill that is not a candidate refused, got %v", err)
+	}
+	if _, err := r.Resolve(ctx, repo, token, ActionAttach, "missing"); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Expected a missing bill refused, got %v", err)
+	}
+	b, err := r.Resolve(ctx, repo, token, ActionAttach, "stored")
+	if err != nil {
+		t.Fatalf("Expected the token usable after a failure: %v", err)
+	}
+	if b.Id != "stored" || b.FiscalKey() != "ru:1/2/3" || len(b.Items) != 1 {
+		t.Errorf("Expected the scan attached to the stored bill with its items, got %+v", b)
+	}
+	if _, err := repo.GetBillByID(ctx, "scan"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected the scan not inserted, got %v", err)
+	}
+	if _, err := r.Resolve(ctx, repo, token, ActionInsert, ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected a token used once, got %v", err)
+	}
+
+	token, err = r.Hold(scan, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(resolutionTTL + time.Minute)
+	if _, err := r.Resolve(ctx, repo, token, ActionInsert, ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected an expired token, got %v", err)
+	}
+
+	if _, err := ParseAction("force"); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Expected an unknown action, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package dedup
+
+import (
+	bl "billdb/internal/bill"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Action is how a new bill reported with duplicates is resolved
+type Action string
+
+const (
+	// ActionInsert stores the bill as a receipt of its own
+	ActionInsert Action = "insert"
+	// ActionAttach adds the bill to a duplicate, see Attach
+	ActionAttach Action = "attach"
+	// ActionReplace stores the bill instead of a duplicate, see Replace
+	ActionReplace Action = "replace"
+)
+
+var ErrUnknownAction = errors.New("unknown action")
+
+// ParseAction returns the action named s
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionInsert, ActionAttach, ActionReplace:
+		return a, nil
+	}
+	return "", fmt.Errorf("%w %q, expected insert, attach or replace", ErrUnknownAction, s)
+}
+
+// resolutionTTL is how long a bill waits for its resolution
+const resolutionTTL = 24 * time.Hour
+
+// Resolutions keeps the new bills reported with duplicates until the
+// client chooses how to resolve them. A token resolves once the bill and
+// the candidates it was reported with, so the client can neither change
+// the bill nor act on a stored bill it wasn't shown. The bills are kept
+// in memory, a restart of the server drops them.
+type Resolutions struct {
+	mu      sync.Mutex
+	pending map[string]*pending
+	now     func() time.Time
+}
+
+type pending struct {
+	bill       *bl.Bill
+	candidates []string
+	expires    time.Time
+}
+
+func NewResolutions() *Resolutions {
+	return &Resolutions{
+		pending: make(map[string]*pending),
+		now:     time.Now,
+	}
+}
+
+// Hold keeps b with its candidates and returns the token resolving it
+func (r *Resolutions) Hold(b *bl.Bill, candidates []*Candidate) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	p := &pending{bill: b}
+	for _, c := range candidates {
+		p.candidates = append(p.candidates, c.Bill.Id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for t, old := range r.pending {
+		if now.After(old.expires) {
+			delete(r.pending, t)
+		}
+	}
+	p.expires = now.Add(resolutionTTL)
+	r.pending[token] = p
+	return token, nil
+}
+
+// Resolve stores the bill of token by action, target is the candidate
+// it is attached to or replaces, ignored by ActionInsert. It returns the
+// bill stored: the held one, or target when it was attached to it.
+// The token is used up when the bill is stored, after a failure it can
+// be resolved again.
+func (r *Resolutions) Resolve(ctx context.Context, repo repository.BillRepository, token string, action Action, target string) (*bl.Bill, error) {
+	p, err := r.take(token)
+	if err != nil {
+		return nil, err
+	}
+	b, err := resolve(ctx, repo, p, action, target)
+	if err != nil {
+		r.mu.Lock()
+		r.pending[token] = p
+		r.mu.Unlock()
+		return nil, err
+	}
+	return b, nil
+}
+
+// take removes the bill of token, so it is resolved once
+func (r *Resolutions) take(token string) (*pending, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[token]
+	if !ok || r.now().After(p.expires) {
+		delete(r.pending, token)
+		return nil, fmt.Errorf("resolution %s: %w", token, repository.ErrNotFound)
+	}
+	delete(r.pending, token)
+	return p, nil
+}
+
+func resolve(ctx context.Context, repo repository.BillRepository, p *pending, action Action, target string) (*bl.Bill, error) {
+	// a copy, the held bill may be resolved again after a failure
+	b := *p.bill
+	b.Items = slices.Clone(b.Items)
+	if action == ActionInsert {
+		if err := repo.InsertBillWithItems(ctx, &b); err != nil {
+			return nil, err
+		}
+		return &b, nil
+	}
+	if !slices.Contains(p.candidates, target) {
+		return nil, fmt.Errorf("%w: bill %s is not a duplicate of the bill %s", repository.ErrConflict, target, b.Id)
+	}
+	switch action {
+	case ActionAttach:
+		return Attach(ctx, repo, &b, target)
+	case ActionReplace:
+		return Replace(ctx, repo, &b, target)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownAction, action)
+}
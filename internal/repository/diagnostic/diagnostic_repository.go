@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"billdb/internal/parser/diagnostic"
+	"time"
+)
+
+type DiagnosticRepository interface {
+	diagnostic.Recorder
+	InsertAttempt(a *diagnostic.Attempt) error
+	GetDailyStats(since time.Time) ([]*diagnostic.DayStats, error)
+	GetStats(from time.Time, to time.Time) (map[string]diagnostic.Stats, error)
+	GetRecentFailures(limit int) ([]*diagnostic.Attempt, error)
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"billdb/internal/parser/diagnostic"
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dates are stored as UTC RFC3339 strings,
+// so they can be compared as text inside the queries
+const timeLayout = time.RFC3339
+
+type SqliteDiagnosticRepository struct {
+	DB *sql.DB
+}
+
+func NewSqliteDiagnosticRepository(db *sql.DB) *SqliteDiagnosticRepository {
+	return &SqliteDiagnosticRepository{DB: db}
+}
+
+// Record implements diagnostic.Recorder.
+// Errors are only logged, diagnostics should never break parsing.
+func (r *SqliteDiagnosticRepository) Record(a *diagnostic.Attempt) {
+	err := r.InsertAttempt(a)
+	if err != nil {
+		log.WithField("parser", a.Parser).Error("Error recording parse attempt: ", err)
+	}
+}
+
+func (r *SqliteDiagnosticRepository) InsertAttempt(a *diagnostic.Attempt) error {
+	_, err := r.DB.Exec(`INSERT INTO parse_attempt (
+			attempt_parser,
+			attempt_link,
+			attempt_number,
+			attempt_http_status,
+			attempt_items_status,
+			attempt_failed_selector,
+			attempt_token,
+			attempt_success,
+			attempt_error,
+			attempt_duration_ms,
+			attempt_created
+		)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		a.Parser,
+		a.Link,
+		a.Attempt,
+		a.HTTPStatus,
+		a.ItemsStatus,
+		a.FailedSelector,
+		a.Token,
+		a.Success,
+		a.Error,
+		a.Duration.Milliseconds(),
+		a.CreatedAt.UTC().Format(timeLayout),
+	)
+	return err
+}
+
+// GetDailyStats returns attempts and failures per parser and day,
+// newest days first
+func (r *SqliteDiagnosticRepository) GetDailyStats(since time.Time) ([]*diagnostic.DayStats, error) {
+	rows, err := r.DB.Query(`SELECT
+			attempt_parser,
+			substr(attempt_created, 1, 10) AS day,
+			COUNT(*),
+			SUM(CASE WHEN attempt_success THEN 0 ELSE 1 END)
+		FROM parse_attempt
+		WHERE attempt_created >= ?
+		GROUP BY attempt_parser, day
+		ORDER BY attempt_parser, day DESC`,
+		since.UTC().Format(timeLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*diagnostic.DayStats{}
+	for rows.Next() {
+		var d diagnostic.DayStats
+		err := rows.Scan(&d.Parser, &d.Day, &d.Attempts, &d.Failures)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, &d)
+	}
+	return days, rows.Err()
+}
+
+// GetStats returns attempts and failures per parser in [from, to)
+func (r *SqliteDiagnosticRepository) GetStats(from time.Time, to time.Time) (map[string]diagnostic.Stats, error) {
+	rows, err := r.DB.Query(`SELECT
+			attempt_parser,
+			COUNT(*),
+			SUM(CASE WHEN attempt_success THEN 0 ELSE 1 END)
+		FROM parse_attempt
+		WHERE attempt_created >= ? AND attempt_created < ?
+		GROUP BY attempt_parser`,
+		from.UTC().Format(timeLayout),
+		to.UTC().Format(timeLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := map[string]diagnostic.Stats{}
+	for rows.Next() {
+		var (
+			parser string
+			s      diagnostic.Stats
+		)
+		err := rows.Scan(&parser, &s.Attempts, &s.Failures)
+		if err != nil {
+			return nil, err
+		}
+		stats[parser] = s
+	}
+	return stats, rows.Err()
+}
+
+func (r *SqliteDiagnosticRepository) GetRecentFailures(limit int) ([]*diagnostic.Attempt, error) {
+	rows, err := r.DB.Query(`SELECT
+			attempt_parser,
+			attempt_link,
+			attempt_number,
+			attempt_http_status,
+			attempt_items_status,
+			attempt_failed_selector,
+			attempt_token,
+			attempt_success,
+			attempt_error,
+			attempt_duration_ms,
+			attempt_created
+		FROM parse_attempt
+		WHERE NOT attempt_success
+		ORDER BY attempt_created DESC
+		LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*diagnostic.Attempt{}
+	for rows.Next() {
+		var (
+			a          diagnostic.Attempt
+			selector   sql.NullString
+			token      sql.NullString
+			errorMsg   sql.NullString
+			durationMs int64
+			created    string
+		)
+		err := rows.Scan(
+			&a.Parser,
+			&a.Link,
+			&a.Attempt,
+			&a.HTTPStatus,
+			&a.ItemsStatus,
+			&selector,
+			&token,
+			&a.Success,
+			&errorMsg,
+			&durationMs,
+			&created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		a.FailedSelector = selector.String
+		a.Token = token.String
+		a.Error = errorMsg.String
+		a.Duration = time.Duration(durationMs) * time.Millisecond
+		a.CreatedAt, err = time.Parse(timeLayout, created)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &a)
+	}
+	return attempts, rows.Err()
+}
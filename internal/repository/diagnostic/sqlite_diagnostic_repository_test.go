@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"billdb/internal/parser/diagnostic"
+	billRepository "billdb/internal/repository/bill"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var dbPath = "../../../test/db/test_diagnostic.db"
+var migrations = []string{
+	"../bill/migrations/001_initial_schema.sql",
+	"../bill/migrations/004_create_parse_attempt.sql",
+}
+
+func setUpDB(t *testing.T) *SqliteDiagnosticRepository {
+	os.Remove(dbPath)
+
+	db, err := billRepository.OpenSqlite(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	billRepo := billRepository.NewSqliteBillRepository(db)
+	for _, m := range migrations {
+		err = billRepo.ApplyMigration(context.Background(), m)
+		if err != nil {
+			t.Fatalf("Failed to apply migration %s: %v", m, err)
+		}
+	}
+	return NewSqliteDiagnosticRepository(db)
+}
+
+func TestRecordAndStats(t *testing.T) {
+	diagnosticRepo := setUpDB(t)
+
+	ok := diagnostic.NewAttempt("rs", "https://suf.purs.gov.rs/v/?vl=ok", 1)
+	ok.HTTPStatus = 200
+	ok.Token = diagnostic.TokenFound
+	ok.Finish(nil)
+	diagnosticRepo.Record(ok)
+
+	failed := diagnostic.NewAttempt("rs", "https://suf.purs.gov.rs/v/?vl=failed", 1)
+	failed.HTTPStatus = 200
+	failed.FailedSelector = "/html/head/script[9]"
+	failed.Token = diagnostic.TokenMissing
+	failed.Finish(errors.New("xpath /html/head/script[9] not found"))
+	diagnosticRepo.Record(failed)
+
+	ru := diagnostic.NewAttempt("ru", "t=20240101T1200", 1)
+	ru.Finish(nil)
+	diagnosticRepo.Record(ru)
+
+	now := time.Now()
+	stats, err := diagnosticRepo.GetStats(now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Errorf("Failed to get stats: %v", err)
+		return
+	}
+	if stats["rs"].Attempts != 2 || stats["rs"].Failures != 1 {
+		t.Errorf("Expected 2 attempts and 1 failure for rs, got %+v", stats["rs"])
+	}
+	if stats["ru"].Attempts != 1 || stats["ru"].Failures != 0 {
+		t.Errorf("Expected 1 attempt and 0 failures for ru, got %+v", stats["ru"])
+	}
+
+	days, err := diagnosticRepo.GetDailyStats(now.AddDate(0, 0, -1))
+	if err != nil {
+		t.Errorf("Failed to get daily stats: %v", err)
+		return
+	}
+	if len(days) != 2 {
+		t.Errorf("Expected 2 day rows, got %d", len(days))
+	}
+
+	failures, err := diagnosticRepo.GetRecentFailures(10)
+	if err != nil {
+		t.Errorf("Failed to get failures: %v", err)
+		return
+	}
+	if len(failures) != 1 {
+		t.Errorf("Expected 1 failure, got %d", len(failures))
+		return
+	}
+	if failures[0].FailedSelector != failed.FailedSelector {
+		t.Errorf("Expected selector '%s', got '%s'", failed.FailedSelector, failures[0].FailedSelector)
+	}
+	if failures[0].Token != diagnostic.TokenMissing {
+		t.Errorf("Expected token outcome 'missing', got '%s'", failures[0].Token)
+	}
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"billdb/internal/job"
+	"errors"
+	"time"
+)
+
+// ErrNotFailed is returned by RetryJob for a job that is missing or not failed
+var ErrNotFailed = errors.New("no failed job")
+
+type JobRepository interface {
+	InsertJob(j *job.Job) error
+	GetJobByID(id string) (*job.Job, error)
+	GetJobs(limit int) ([]*job.Job, error)
+	// CountJobs returns the number of jobs of every status
+	CountJobs() (map[job.Status]int, error)
+	// GetDueJobs returns up to limit due jobs, oldest first,
+	// and at most perHost of them for every host
+	GetDueJobs(now time.Time, perHost int, limit int) ([]*job.Job, error)
+	ClaimJob(id string) (bool, error)
+	UpdateJob(j *job.Job) error
+	RetryJob(id string) error
+	ResetRunningJobs() (int64, error)
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"billdb/internal/job"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// dates are stored as UTC RFC3339 strings,
+// so they can be compared as text inside the queries
+const timeLayout = time.RFC3339
+
+type SqliteJobRepository struct {
+	DB *sql.DB
+}
+
+func NewSqliteJobRepository(db *sql.DB) *SqliteJobRepository {
+	return &SqliteJobRepository{DB: db}
+}
+
+const jobColumns = `job_id,
+			job_link,
+			job_host,
+			job_status,
+			job_attempts,
+			job_error,
+			job_bill_id,
+			job_created,
+			job_updated,
+			job_next_run`
+
+func (r *SqliteJobRepository) InsertJob(j *job.Job) error {
+	_, err := r.DB.Exec(`INSERT INTO parse_job (`+jobColumns+`)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		j.Id,
+		j.Link,
+		j.Host,
+		j.GetStatusString(),
+		j.Attempts,
+		j.Error,
+		j.BillId,
+		formatTime(j.CreatedAt),
+		formatTime(j.UpdatedAt),
+		formatNullableTime(j.NextRun),
+	)
+	return err
+}
+
+func (r *SqliteJobRepository) GetJobByID(id string) (*job.Job, error) {
+	row := r.DB.QueryRow(
+		`SELECT `+jobColumns+` FROM parse_job WHERE job_id = ?`,
+		id,
+	)
+	return ScanToJob(row)
+}
+
+// GetJobs returns the latest jobs, newest first
+func (r *SqliteJobRepository) GetJobs(limit int) ([]*job.Job, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+jobColumns+`
+		FROM parse_job
+		ORDER BY job_created DESC
+		LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanJobs(rows)
+}
+
+func (r *SqliteJobRepository) CountJobs() (map[job.Status]int, error) {
+	rows, err := r.DB.Query(`SELECT job_status, count(*) FROM parse_job GROUP BY job_status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[job.Status]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		s, err := job.ParseStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		counts[s] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetDueJobs returns pending jobs and failed jobs whose retry time has
+// come, oldest first, the perHost oldest of every host, so that a backlog
+// of one host leaves room for the jobs of the others
+func (r *SqliteJobRepository) GetDueJobs(now time.Time, perHost int, limit int) ([]*job.Job, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+jobColumns+`
+		FROM (
+			SELECT *, row_number() OVER (PARTITION BY job_host ORDER BY job_created) AS host_rank
+			FROM parse_job
+			WHERE job_status = ?
+				OR (job_status = ? AND job_next_run IS NOT NULL AND job_next_run <= ?)
+		)
+		WHERE host_rank <= ?
+		ORDER BY job_created
+		LIMIT ?`,
+		job.PENDING.String(),
+		job.FAILED.String(),
+		formatTime(now),
+		perHost,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanJobs(rows)
+}
+
+// ClaimJob moves a due job into the running state.
+// Returns false if the job was already claimed by someone else.
+func (r *SqliteJobRepository) ClaimJob(id string) (bool, error) {
+	result, err := r.DB.Exec(
+		`UPDATE parse_job
+		SET job_status = ?, job_updated = ?
+		WHERE job_id = ? AND job_status IN (?, ?)`,
+		job.RUNNING.String(),
+		formatTime(time.Now()),
+		id,
+		job.PENDING.String(),
+		job.FAILED.String(),
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsUpdated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	return rowsUpdated == 1, nil
+}
+
+func (r *SqliteJobRepository) UpdateJob(j *job.Job) error {
+	result, err := r.DB.Exec(`UPDATE parse_job
+		SET
+			job_status = ?,
+			job_attempts = ?,
+			job_error = ?,
+			job_bill_id = ?,
+			job_updated = ?,
+			job_next_run = ?
+		WHERE job_id = ?`,
+		j.GetStatusString(),
+		j.Attempts,
+		j.Error,
+		j.BillId,
+		formatTime(j.UpdatedAt),
+		formatNullableTime(j.NextRun),
+		j.Id,
+	)
+	if err != nil {
+		return err
+	}
+	rowsUpdated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsUpdated == 0 {
+		return fmt.Errorf("no rows affected")
+	}
+	return nil
+}
+
+// RetryJob puts a failed job back into the queue with a fresh attempt counter
+func (r *SqliteJobRepository) RetryJob(id string) error {
+	result, err := r.DB.Exec(`UPDATE parse_job
+		SET
+			job_status = ?,
+			job_attempts = 0,
+			job_updated = ?,
+			job_next_run = NULL
+		WHERE job_id = ? AND job_status = ?`,
+		job.PENDING.String(),
+		formatTime(time.Now()),
+		id,
+		job.FAILED.String(),
+	)
+	if err != nil {
+		return err
+	}
+	rowsUpdated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsUpdated == 0 {
+		return fmt.Errorf("%w with id %s", ErrNotFailed, id)
+	}
+	return nil
+}
+
+// ResetRunningJobs returns jobs left in the running state
+// (e.g. after a crash or restart) back to pending
+func (r *SqliteJobRepository) ResetRunningJobs() (int64, error) {
+	result, err := r.DB.Exec(
+		`UPDATE parse_job SET job_status = ?, job_updated = ? WHERE job_status = ?`,
+		job.PENDING.String(),
+		formatTime(time.Now()),
+		job.RUNNING.String(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanJobs(rows *sql.Rows) ([]*job.Job, error) {
+	jobs := []*job.Job{}
+	for rows.Next() {
+		j, err := ScanToJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// ScanToJob scans a row (*sql.Row or *sql.Rows) selected with jobColumns
+func ScanToJob(row interface{ Scan(dest ...any) error }) (*job.Job, error) {
+	var (
+		j        job.Job
+		status   string
+		errorMsg sql.NullString
+		billId   sql.NullString
+		created  string
+		updated  string
+		nextRun  sql.NullString
+	)
+	err := row.Scan(
+		&j.Id,
+		&j.Link,
+		&j.Host,
+		&status,
+		&j.Attempts,
+		&errorMsg,
+		&billId,
+		&created,
+		&updated,
+		&nextRun,
+	)
+	if err != nil {
+		return nil, err
+	}
+	j.Status, err = job.ParseStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	j.Error = errorMsg.String
+	j.BillId = billId.String
+	j.CreatedAt, err = time.Parse(timeLayout, created)
+	if err != nil {
+		return nil, err
+	}
+	j.UpdatedAt, err = time.Parse(timeLayout, updated)
+	if err != nil {
+		return nil, err
+	}
+	if nextRun.Valid {
+		t, err := time.Parse(timeLayout, nextRun.String)
+		if err != nil {
+			return nil, err
+		}
+		j.NextRun = &t
+	}
+	return &j, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func formatNullableTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := formatTime(*t)
+	return &s
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"billdb/internal/job"
+	billRepository "billdb/internal/repository/bill"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var dbPath = "../../../test/db/test_job.db"
+var migrations = []string{
+	"../bill/migrations/001_initial_schema.sql",
+	"../bill/migrations/003_create_parse_job.sql",
+}
+
+func setUpDB(t *testing.T) *SqliteJobRepository {
+	os.Remove(dbPath)
+
+	db, err := billRepository.OpenSqlite(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	billRepo := billRepository.NewSqliteBillRepository(db)
+	for _, m := range migrations {
+		err = billRepo.ApplyMigration(context.Background(), m)
+		if err != nil {
+			t.Fatalf("Failed to apply migration %s: %v", m, err)
+		}
+	}
+	return NewSqliteJobRepository(db)
+}
+
+func TestInsertAndGetJob(t *testing.T) {
+	jobRepo := setUpDB(t)
+
+	j := job.New("https://suf.purs.gov.rs/v/?vl=abc")
+	err := jobRepo.InsertJob(j)
+	if err != nil {
+		t.Errorf("Failed to insert job: %v", err)
+		return
+	}
+
+	jobFromDb, err := jobRepo.GetJobByID(j.Id)
+	if err != nil {
+		t.Errorf("Failed to get job: %v", err)
+		return
+	}
+	if jobFromDb.Link != j.Link {
+		t.Errorf("Expected Link '%s', got '%s'", j.Link, jobFromDb.Link)
+	}
+	if jobFromDb.Host != "suf.purs.gov.rs" {
+		t.Errorf("Expected Host 'suf.purs.gov.rs', got '%s'", jobFromDb.Host)
+	}
+	if jobFromDb.Status != job.PENDING {
+		t.Errorf("Expected Status 'pending', got '%s'", jobFromDb.Status)
+	}
+	if jobFromDb.NextRun != nil {
+		t.Errorf("Expected empty NextRun, got %v", jobFromDb.NextRun)
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	jobRepo := setUpDB(t)
+
+	j := job.New("https://suf.purs.gov.rs/v/?vl=abc")
+	err := jobRepo.InsertJob(j)
+	if err != nil {
+		t.Errorf("Failed to insert job: %v", err)
+		return
+	}
+
+	claimed, err := jobRepo.ClaimJob(j.Id)
+	if err != nil {
+		t.Errorf("Failed to claim job: %v", err)
+		return
+	}
+	if !claimed {
+		t.Error("Expected job to be claimed")
+	}
+	claimed, err = jobRepo.ClaimJob(j.Id)
+	if err != nil {
+		t.Errorf("Failed to claim job: %v", err)
+		return
+	}
+	if claimed {
+		t.Error("Expected running job to not be claimed twice")
+	}
+
+	reset, err := jobRepo.ResetRunningJobs()
+	if err != nil {
+		t.Errorf("Failed to reset running jobs: %v", err)
+		return
+	}
+	if reset != 1 {
+		t.Errorf("Expected 1 reset job, got %d", reset)
+	}
+}
+
+func TestGetDueJobs(t *testing.T) {
+	jobRepo := setUpDB(t)
+	now := time.Now()
+
+	pending := job.New("https://example.com/pending")
+	retryLater := job.New("https://example.com/later")
+	retryNow := job.New("https://example.com/now")
+	failed := job.New("https://example.com/failed")
+	for _, j := range []*job.Job{pending, retryLater, retryNow, failed} {
+		err := jobRepo.InsertJob(j)
+		if err != nil {
+			t.Errorf("Failed to insert job: %v", err)
+			return
+		}
+	}
+
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Minute)
+	updates := map[*job.Job]*time.Time{
+		retryLater: &later,
+		retryNow:   &earlier,
+		failed:     nil,
+	}
+	for j, nextRun := range updates {
+		j.Status = job.FAILED
+		j.Attempts = 1
+		j.Error = "failed"
+		j.NextRun = nextRun
+		err := jobRepo.UpdateJob(j)
+		if err != nil {
+			t.Errorf("Failed to update job: %v", err)
+			return
+		}
+	}
+
+	due, err := jobRepo.GetDueJobs(now, 10, 10)
+	if err != nil {
+		t.Errorf("Failed to get due jobs: %v", err)
+		return
+	}
+	if len(due) != 2 {
+		t.Errorf("Expected 2 due jobs, got %d", len(due))
+		return
+	}
+	ids := map[string]bool{due[0].Id: true, due[1].Id: true}
+	if !ids[pending.Id] || !ids[retryNow.Id] {
+		t.Errorf("Expected pending and retryNow jobs to be due, got %v", ids)
+	}
+
+	// a backlog of one host leaves room for the others
+	other := job.New("https://other.example.com/pending")
+	err = jobRepo.InsertJob(other)
+	if err != nil {
+		t.Errorf("Failed to insert job: %v", err)
+		return
+	}
+	due, err = jobRepo.GetDueJobs(now, 1, 2)
+	if err != nil {
+		t.Errorf("Failed to get due jobs: %v", err)
+		return
+	}
+	if len(due) != 2 || due[0].Id != pending.Id || due[1].Id != other.Id {
+		t.Errorf("Expected the oldest job of every host, got %v", due)
+	}
+
+	err = jobRepo.RetryJob(failed.Id)
+	if err != nil {
+		t.Errorf("Failed to retry job: %v", err)
+		return
+	}
+	jobFromDb, err := jobRepo.GetJobByID(failed.Id)
+	if err != nil {
+		t.Errorf("Failed to get job: %v", err)
+		return
+	}
+	if jobFromDb.Status != job.PENDING || jobFromDb.Attempts != 0 {
+		t.Errorf(
+			"Expected retried job to be pending with 0 attempts, got %s with %d",
+			jobFromDb.Status,
+			jobFromDb.Attempts,
+		)
+	}
+	err = jobRepo.RetryJob(pending.Id)
+	if !errors.Is(err, ErrNotFailed) {
+		t.Errorf("Expected a pending job not retried, got %v", err)
+	}
+}
+
+func TestCountJobs(t *testing.T) {
+	jobRepo := setUpDB(t)
+	for i := 0; i < 5; i++ {
+		j := job.New("https://example.com/bill")
+		if i < 2 {
+			j.Status = job.FAILED
+		}
+		if err := jobRepo.InsertJob(j); err != nil {
+			t.Fatalf("Failed to insert job: %v", err)
+		}
+	}
+
+	counts, err := jobRepo.CountJobs()
+	if err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	if counts[job.PENDING] != 3 || counts[job.FAILED] != 2 || counts[job.DONE] != 0 {
+		t.Errorf("Expected 3 pending and 2 failed jobs, got %v", counts)
+	}
+}
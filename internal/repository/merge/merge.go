@@ -0,0 +1,288 @@
+// Package merge adds the bills of a second bill database to the
+// repository, for instances run apart such as at home and on a laptop.
+// Bills are matched by id, link and fiscal key, tags by name. A bill
+// changed differently in both databases is reported, not merged.
+package merge
+
+import (
+	"billdb/internal/audit"
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/item"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"fmt"
+	"slices"
+)
+
+type Outcome string
+
+const (
+	// Inserted is a new bill, added with its items and tag
+	Inserted Outcome = "inserted"
+	// Tagged is a bill found untagged in the repository,
+	// the tag of the merged database was added
+	Tagged Outcome = "tagged"
+	// Conflict is a bill with the id of a bill of the repository but
+	// different content, or deleted in the repository, it is left as it is
+	Conflict Outcome = "conflict"
+	// Matched is a receipt found in the repository under another id
+	Matched Outcome = "matched"
+	// Same is a bill found in the repository as it is
+	Same Outcome = "same"
+)
+
+// Outcomes lists every outcome in the order of the report
+var Outcomes = []Outcome{Inserted, Tagged, Conflict, Matched, Same}
+
+// Entry is what the merge did with a bill of the merged database
+type Entry struct {
+	Outcome Outcome
+	Bill    *bl.Bill
+	// Current is the matching bill of the repository, nil for Inserted
+	Current *bl.Bill
+	// By is how Current was found: id, link or fiscal key
+	By string
+	// Differences are the fields of Bill that differ from Current
+	Differences []string
+}
+
+type Report struct {
+	Entries []*Entry
+	// NewTags are the tags the merge added to the repository
+	NewTags []string
+	// Trashed is the number of bills in the trash of the merged
+	// database, they are not merged
+	Trashed int
+}
+
+// Count returns the number of bills with the outcome
+func (r *Report) Count(o Outcome) int {
+	return len(r.Of(o))
+}
+
+// Of returns the entries of the outcome
+func (r *Report) Of(o Outcome) []*Entry {
+	var entries []*Entry
+	for _, e := range r.Entries {
+		if e.Outcome == o {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Merge adds the bills of src missing from dst in one unit of work,
+// recorded in the audit log as an import
+func Merge(ctx context.Context, dst repository.BillRepository, src repository.BillRepository) (*Report, error) {
+	ctx = audit.WithSource(ctx, audit.SourceImport)
+	bills, err := repository.AllBills(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("merged database: %w", err)
+	}
+	srcTrash, err := src.ListTrash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("merged database: %w", err)
+	}
+	tags, err := dst.GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Trashed: len(srcTrash)}
+	err = dst.WithTx(ctx, func(tx repository.BillRepository) error {
+		idx, err := newIndex(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, listed := range bills {
+			// the listed bills lack the journal and items
+			b, err := src.GetBillByID(ctx, listed.Id)
+			if err != nil {
+				return fmt.Errorf("merged database: %w", err)
+			}
+			b.Items, err = src.GetItemsByID(ctx, b.Id)
+			if err != nil {
+				return fmt.Errorf("merged database: %w", err)
+			}
+			e, err := mergeBill(ctx, tx, idx, b)
+			if err != nil {
+				return err
+			}
+			report.Entries = append(report.Entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range report.Entries {
+		if e.Outcome != Inserted && e.Outcome != Tagged {
+			continue
+		}
+		if e.Bill.Tag.Valid && !slices.Contains(tags, e.Bill.Tag.String) {
+			tags = append(tags, e.Bill.Tag.String)
+			report.NewTags = append(report.NewTags, e.Bill.Tag.String)
+		}
+	}
+	return report, nil
+}
+
+func mergeBill(ctx context.Context, tx repository.BillRepository, idx *index, b *bl.Bill) (*Entry, error) {
+	if trashed, ok := idx.trash[b.Id]; ok {
+		return &Entry{Outcome: Conflict, Bill: b, Current: trashed, By: "id",
+			Differences: []string{"deleted in this database"}}, nil
+	}
+	if _, ok := idx.byId[b.Id]; ok {
+		current, err := tx.GetBillByID(ctx, b.Id)
+		if err != nil {
+			return nil, err
+		}
+		current.Items, err = tx.GetItemsByID(ctx, current.Id)
+		if err != nil {
+			return nil, err
+		}
+		diff := differences(b, current)
+		e := &Entry{Bill: b, Current: current, By: "id", Differences: diff}
+		switch {
+		case len(diff) == 0:
+			e.Outcome = Same
+		case slices.Equal(diff, []string{"tag"}) && !current.Tag.Valid:
+			e.Outcome = Tagged
+			if err := tx.UpdateTag(ctx, b); err != nil {
+				return nil, err
+			}
+		default:
+			e.Outcome = Conflict
+		}
+		return e, nil
+	}
+
+	if current, by := idx.receipt(b); current != nil {
+		e := &Entry{Outcome: Matched, Bill: b, Current: current, By: by}
+		if _, trashed := idx.trash[current.Id]; trashed {
+			e.Outcome = Conflict
+			e.Differences = []string{"deleted in this database"}
+		}
+		return e, nil
+	}
+
+	if err := tx.InsertBillWithItems(ctx, b); err != nil {
+		return nil, err
+	}
+	idx.add(b)
+	return &Entry{Outcome: Inserted, Bill: b}, nil
+}
+
+// differences returns the fields of b that differ from current
+func differences(b *bl.Bill, current *bl.Bill) []string {
+	var diff []string
+	if b.Name != current.Name {
+		diff = append(diff, "name")
+	}
+	if b.GetDateString() != current.GetDateString() {
+		diff = append(diff, "date")
+	}
+	if b.Price != current.Price {
+		diff = append(diff, "price")
+	}
+	if b.Currency != current.Currency {
+		diff = append(diff, "currency")
+	}
+	if b.Country != current.Country {
+		diff = append(diff, "country")
+	}
+	if b.Link != current.Link {
+		diff = append(diff, "link")
+	}
+	if b.BillText != current.BillText {
+		diff = append(diff, "journal")
+	}
+	if b.Tag.Valid != current.Tag.Valid || (b.Tag.Valid && b.Tag.String != current.Tag.String) {
+		diff = append(diff, "tag")
+	}
+	if !sameItems(b.Items, current.Items) {
+		diff = append(diff, "items")
+	}
+	return diff
+}
+
+func sameItems(a []*item.Item, b []*item.Item) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byId := make(map[string]item.Item, len(b))
+	for _, it := range b {
+		byId[it.ItemId] = *it
+	}
+	for _, it := range a {
+		if other, ok := byId[it.ItemId]; !ok || other != *it {
+			return false
+		}
+	}
+	return true
+}
+
+// index finds the bills of the repository by id, link and fiscal key
+type index struct {
+	byId   map[string]*bl.Bill
+	byLink map[string]*bl.Bill
+	byKey  map[string]*bl.Bill
+	trash  map[string]*bl.Bill
+}
+
+func newIndex(ctx context.Context, repo repository.BillRepository) (*index, error) {
+	idx := &index{
+		byId:   make(map[string]*bl.Bill),
+		byLink: make(map[string]*bl.Bill),
+		byKey:  make(map[string]*bl.Bill),
+		trash:  make(map[string]*bl.Bill),
+	}
+	trash, err := repo.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trash {
+		idx.trash[t.Id] = t.Bill
+		idx.addReceipt(t.Bill)
+	}
+	// a live bill takes the link of a deleted one
+	bills, err := repository.AllBills(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bills {
+		idx.add(b)
+	}
+	return idx, nil
+}
+
+func (idx *index) add(b *bl.Bill) {
+	idx.byId[b.Id] = b
+	idx.addReceipt(b)
+}
+
+func (idx *index) addReceipt(b *bl.Bill) {
+	if b.Link != "" {
+		idx.byLink[b.Link] = b
+	}
+	if key := b.FiscalKey(); key != "" {
+		idx.byKey[key] = b
+	}
+}
+
+// receipt returns the bill of the repository with the link
+// or fiscal key of b, and which one matched
+func (idx *index) receipt(b *bl.Bill) (*bl.Bill, string) {
+	if b.Link != "" {
+		if current, ok := idx.byLink[b.Link]; ok {
+			return current, "link"
+		}
+	}
+	if key := b.FiscalKey(); key != "" {
+		if current, ok := idx.byKey[key]; ok {
+			return current, "fiscal key"
+		}
+	}
+	return nil, ""
+}
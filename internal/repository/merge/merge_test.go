@@ -0,0 +1,198 @@
+package merge
+
+import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openSqlite(t *testing.T, name string) repository.BillRepository {
+	t.Helper()
+	db, err := repository.OpenSqlite(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := repository.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return repository.NewSqliteBillRepository(db)
+}
+
+func withJournal(b *bl.Bill, text string) *bl.Bill {
+	b.BillText = text
+	return b
+}
+
+func newBill(id string, name string, link string, tagName string) *bl.Bill {
+	b := bl.New(id, name, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 10,
+		currency.RUB, country.RUSSIA, nil, tag.New(tagName), link, "")
+	b.Items = []*item.Item{item.New(id+"-1", id, "milk", 10, 10, 1)}
+	return b
+}
+
+func insert(t *testing.T, repo repository.BillRepository, bills ...*bl.Bill) {
+	t.Helper()
+	for _, b := range bills {
+		if err := repo.InsertBillWithItems(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	dst := repository.NewMemoryBillRepository()
+	src := repository.NewMemoryBillRepository()
+	fiscal := "t=20240501T1200&s=10.00&fn=9960440300&i=1234&fp=567890&n=1"
+
+	insert(t, dst,
+		newBill("same", "Lenta", "", "food"),
+		newBill("untagged", "Lenta", "", ""),
+		newBill("edited", "Lenta", "", "food"),
+		newBill("home", "Magnit", fiscal, "food"),
+		newBill("deleted", "Lenta", "", ""),
+	)
+	if err := dst.DeleteBill(ctx, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+
+	insert(t, src,
+		newBill("same", "Lenta", "", "food"),
+		newBill("untagged", "Lenta", "", "food"),
+		newBill("edited", "Lenta Hypermarket", "", "food"),
+		// the same receipt parsed on the laptop, the QR parameters reordered
+		newBill("laptop", "Magnit", "t=20240501T1200&fn=9960440300&i=1234&fp=567890&s=10.00&n=1", "food"),
+		newBill("deleted", "Lenta", "", ""),
+		newBill("new", "Pyaterochka", "", "travel"),
+		newBill("gone", "Lenta", "", ""),
+	)
+	if err := src.DeleteBill(ctx, "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Merge(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := make(map[string]*Entry)
+	for _, e := range report.Entries {
+		outcomes[e.Bill.Id] = e
+	}
+	for id, want := range map[string]Outcome{
+		"same":     Same,
+		"untagged": Tagged,
+		"edited":   Conflict,
+		"laptop":   Matched,
+		"deleted":  Conflict,
+		"new":      Inserted,
+	} {
+		e, ok := outcomes[id]
+		if !ok || e.Outcome != want {
+			t.Errorf("%s: expected %s, got %+v", id, want, e)
+		}
+	}
+	if len(report.Entries) != 6 || report.Trashed != 1 {
+		t.Errorf("Expected 6 bills merged and 1 in the trash, got %d, %d", len(report.Entries), report.Trashed)
+	}
+	if e := outcomes["laptop"]; e != nil && (e.By != "fiscal key" || e.Current.Id != "home") {
+		t.Errorf("Expected the receipt matched by its fiscal key, got %+v", e)
+	}
+	if e := outcomes["edited"]; e != nil && !slices.Equal(e.Differences, []string{"name"}) {
+		t.Errorf("Expected the name conflict, got %v", e.Differences)
+	}
+	if !slices.Equal(report.NewTags, []string{"travel"}) {
+		t.Errorf("Expected the new tag, got %v", report.NewTags)
+	}
+
+	b, err := dst.GetBillByID(ctx, "new")
+	if err != nil || b.Tag.String != "travel" {
+		t.Fatalf("Expected the new bill inserted, got %v, %v", b, err)
+	}
+	if items, err := dst.GetItemsByID(ctx, "new"); err != nil || len(items) != 1 {
+		t.Errorf("Expected the items inserted, got %v, %v", items, err)
+	}
+	if b, _ := dst.GetBillByID(ctx, "untagged"); b == nil || b.Tag.String != "food" {
+		t.Errorf("Expected the tag added, got %v", b)
+	}
+	if b, _ := dst.GetBillByID(ctx, "edited"); b == nil || b.Name != "Lenta" {
+		t.Errorf("Expected the conflict left as it is, got %v", b)
+	}
+	if _, err := dst.GetBillByID(ctx, "laptop"); err == nil {
+		t.Errorf("Expected the matched receipt not inserted")
+	}
+
+	report, err = Merge(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(Inserted) != 0 || report.Count(Tagged) != 0 || report.Count(Same) != 3 {
+		t.Errorf("Expected a second merge to change nothing, got %+v", report.Entries)
+	}
+}
+
+func TestMergeJournal(t *testing.T) {
+	ctx := context.Background()
+	dst := openSqlite(t, "home.db")
+	src := openSqlite(t, "laptop.db")
+
+	insert(t, dst,
+		withJournal(newBill("same", "Lenta", "", "food"), "milk 10.00"),
+		withJournal(newBill("untagged", "Lenta", "", ""), "bread 10.00"),
+		withJournal(newBill("journal", "Lenta", "", "food"), "milk 10.00"),
+	)
+	insert(t, src,
+		withJournal(newBill("same", "Lenta", "", "food"), "milk 10.00"),
+		withJournal(newBill("untagged", "Lenta", "", "food"), "bread 10.00"),
+		withJournal(newBill("journal", "Lenta", "", "food"), "milk 10.00 discount"),
+		withJournal(newBill("new", "Pyaterochka", "", "travel"), "eggs 10.00"),
+	)
+
+	report, err := Merge(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := make(map[string]*Entry)
+	for _, e := range report.Entries {
+		outcomes[e.Bill.Id] = e
+	}
+	for id, want := range map[string]Outcome{
+		"same":     Same,
+		"untagged": Tagged,
+		"journal":  Conflict,
+		"new":      Inserted,
+	} {
+		e, ok := outcomes[id]
+		if !ok || e.Outcome != want {
+			t.Errorf("%s: expected %s, got %+v", id, want, e)
+		}
+	}
+	if e := outcomes["journal"]; e != nil && !slices.Equal(e.Differences, []string{"journal"}) {
+		t.Errorf("Expected the journal conflict, got %v", e.Differences)
+	}
+
+	for id, want := range map[string]string{
+		"untagged": "bread 10.00",
+		"journal":  "milk 10.00",
+		"new":      "eggs 10.00",
+	} {
+		b, err := dst.GetBillByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.BillText != want {
+			t.Errorf("%s: expected the journal %q, got %q", id, want, b.BillText)
+		}
+	}
+	if b, _ := dst.GetBillByID(ctx, "untagged"); b == nil || b.Tag.String != "food" {
+		t.Errorf("Expected the tag added, got %v", b)
+	}
+}
@@ -0,0 +1,241 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Migration is a versioned schema change, the name is the file name
+// and its numeric prefix defines the order.
+type Migration struct {
+	Name    string
+	Applied bool
+}
+
+// Migrator applies the .sql files of a directory in order,
+// the applied ones are recorded in the migration table.
+type Migrator struct {
+	DB  *sql.DB
+	FS  fs.FS
+	Dir string
+
+	// Funcs are migrations written in Go, for schema changes
+	// that depend on the database, ordered by name with the files
+	Funcs map[string]func(tx *sql.Tx) error
+
+	// Baseline migrations are marked as applied on databases
+	// created before the migration table existed,
+	// BaselineCheck is a query returning true for such databases.
+	Baseline      []string
+	BaselineCheck string
+}
+
+func New(db *sql.DB, fsys fs.FS, dir string) *Migrator {
+	return &Migrator{
+		DB:  db,
+		FS:  fsys,
+		Dir: dir,
+	}
+}
+
+// List returns the names of all migrations, in order
+func (m *Migrator) List() ([]string, error) {
+	entries, err := fs.ReadDir(m.FS, m.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	for name := range m.Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Status returns every migration with its applied state
+func (m *Migrator) Status() ([]Migration, error) {
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+	names, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		migrations = append(migrations, Migration{
+			Name:    name,
+			Applied: applied[name],
+		})
+	}
+	return migrations, nil
+}
+
+// Pending returns the names of the migrations that are not applied yet
+func (m *Migrator) Pending() ([]string, error) {
+	migrations, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, migration := range migrations {
+		if !migration.Applied {
+			pending = append(pending, migration.Name)
+		}
+	}
+	return pending, nil
+}
+
+// Unknown returns the applied migrations that are neither files nor Funcs,
+// found in databases migrated by a newer version
+func (m *Migrator) Unknown() ([]string, error) {
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+	names, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		delete(applied, name)
+	}
+	unknown := make([]string, 0, len(applied))
+	for name := range applied {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// Up applies all pending migrations, each in its own transaction.
+// Stops at the first failing one, the applied names are returned
+// in any case.
+func (m *Migrator) Up() ([]string, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var done []string
+	for _, name := range pending {
+		err = m.apply(name)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, name)
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(name string) error {
+	fn, ok := m.Funcs[name]
+	if !ok {
+		content, err := fs.ReadFile(m.FS, path.Join(m.Dir, name))
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+		fn = func(tx *sql.Tx) error {
+			if strings.TrimSpace(string(content)) == "" {
+				return nil
+			}
+			_, err := tx.Exec(string(content))
+			return err
+		}
+	}
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", name, err)
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", name, err)
+	}
+	_, err = tx.Exec(`INSERT INTO migration (name) VALUES ($1)`, name)
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+// init creates the migration table, and records the baseline
+// when the table is new or empty but the schema was already there.
+// The initial schema creates the table itself, a database made from
+// it has an empty table and the tables of the baseline.
+func (m *Migrator) init() error {
+	var count int
+	err := m.DB.QueryRow(`SELECT count(*) FROM migration`).Scan(&count)
+	if err == nil && count > 0 {
+		return nil
+	}
+
+	legacy := false
+	if m.BaselineCheck != "" {
+		err = m.DB.QueryRow(m.BaselineCheck).Scan(&legacy)
+		if err != nil {
+			return fmt.Errorf("baseline check: %w", err)
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS "migration" (
+	"name" TEXT UNIQUE,
+	PRIMARY KEY("name")
+)`)
+	if err != nil {
+		return fmt.Errorf("error creating migration table: %w", err)
+	}
+	if legacy {
+		for _, name := range m.Baseline {
+			_, err = tx.Exec(`INSERT INTO migration (name) VALUES ($1)`, name)
+			if err != nil {
+				return fmt.Errorf("baseline %s: %w", name, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) applied() (map[string]bool, error) {
+	rows, err := m.DB.Query(`SELECT name FROM migration`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,199 @@
+package migration
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+var testFS = fstest.MapFS{
+	"migrations/002_second.sql": {Data: []byte(`CREATE TABLE "b" ("id" TEXT);`)},
+	"migrations/001_first.sql":  {Data: []byte("CREATE TABLE \"a\" (\"id\" TEXT);\nINSERT INTO a (id) VALUES ('x;y');")},
+	"migrations/003_empty.sql":  {Data: []byte("\n")},
+	"migrations/README.md":      {Data: []byte("not a migration")},
+}
+
+func TestUp(t *testing.T) {
+	db := openDB(t)
+	m := New(db, testFS, "migrations")
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "001_first.sql,002_second.sql,003_empty.sql"
+	if strings.Join(applied, ",") != want {
+		t.Errorf("applied %v, want %s", applied, want)
+	}
+	if !tableExists(t, db, "a") || !tableExists(t, db, "b") {
+		t.Error("tables were not created")
+	}
+	// semicolons inside literals are not statement separators
+	var id string
+	err = db.QueryRow(`SELECT id FROM a`).Scan(&id)
+	if err != nil || id != "x;y" {
+		t.Errorf("unexpected row %q: %v", id, err)
+	}
+
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("nothing should be applied twice, got %v", applied)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	db := openDB(t)
+	m := New(db, testFS, "migrations")
+	m.Funcs = map[string]func(tx *sql.Tx) error{
+		"002_go": func(tx *sql.Tx) error {
+			// runs after 001_first.sql
+			_, err := tx.Exec(`INSERT INTO a (id) VALUES ('go')`)
+			return err
+		},
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "001_first.sql,002_go,002_second.sql,003_empty.sql"
+	if strings.Join(applied, ",") != want {
+		t.Errorf("applied %v, want %s", applied, want)
+	}
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM a WHERE id = 'go'`).Scan(&count)
+	if err != nil || count != 1 {
+		t.Errorf("Go migration did not run: %d, %v", count, err)
+	}
+}
+
+func TestUpFailureRollsBack(t *testing.T) {
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"m/001_ok.sql":     {Data: []byte(`CREATE TABLE "a" ("id" TEXT);`)},
+		"m/002_broken.sql": {Data: []byte(`CREATE TABLE "b" ("id" TEXT); INSERT INTO missing VALUES (1);`)},
+		"m/003_later.sql":  {Data: []byte(`CREATE TABLE "c" ("id" TEXT);`)},
+	}
+	m := New(db, fsys, "m")
+
+	applied, err := m.Up()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "002_broken.sql") {
+		t.Errorf("error should name the migration: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != "001_ok.sql" {
+		t.Errorf("unexpected applied %v", applied)
+	}
+	if tableExists(t, db, "b") || tableExists(t, db, "c") {
+		t.Error("failed migration was not rolled back")
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pending, ",") != "002_broken.sql,003_later.sql" {
+		t.Errorf("unexpected pending %v", pending)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	db := openDB(t)
+	// database created before migrations were tracked
+	_, err := db.Exec(`CREATE TABLE "a" ("id" TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(db, testFS, "migrations")
+	m.Baseline = []string{"001_first.sql"}
+	m.BaselineCheck = `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'a'`
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status[0].Applied || status[1].Applied {
+		t.Errorf("unexpected status %+v", status)
+	}
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(applied, ",") != "002_second.sql,003_empty.sql" {
+		t.Errorf("unexpected applied %v", applied)
+	}
+}
+
+func TestBaselineEmptyTable(t *testing.T) {
+	db := openDB(t)
+	// database created from the first migration, which made
+	// the migration table without recording itself
+	_, err := db.Exec(`CREATE TABLE "a" ("id" TEXT); CREATE TABLE "migration" ("name" TEXT UNIQUE, PRIMARY KEY("name"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(db, testFS, "migrations")
+	m.Baseline = []string{"001_first.sql"}
+	m.BaselineCheck = `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'a'`
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(applied, ",") != "002_second.sql,003_empty.sql" {
+		t.Errorf("unexpected applied %v", applied)
+	}
+	if pending, err := m.Pending(); err != nil || len(pending) != 0 {
+		t.Errorf("Expected every migration applied, got %v, %v", pending, err)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	db := openDB(t)
+	m := New(db, testFS, "migrations")
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := m.Unknown()
+	if err != nil || len(unknown) != 0 {
+		t.Fatalf("Expected no unknown migrations, got %v, %v", unknown, err)
+	}
+
+	_, err = db.Exec(`INSERT INTO migration (name) VALUES ('004_newer.sql')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err = m.Unknown()
+	if err != nil || strings.Join(unknown, ",") != "004_newer.sql" {
+		t.Errorf("Expected the newer migration, got %v, %v", unknown, err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package search normalizes text for the full-text search, so that
+// Serbian and Russian Cyrillic match their Latin transliteration and
+// letters with diacritics match the plain letters typed on any keyboard.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fold maps lowercase letters to their plain Latin spelling.
+// Serbian Cyrillic follows the official Latin alphabet with the
+// diacritics folded (ђ đ dj, ћ ć c, ч č c, џ dž dz, ж ž z, ш š s),
+// Russian letters without a Serbian counterpart use the same scheme.
+var fold = map[rune]string{
+	// Serbian and Russian Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'ђ': "dj", 'е': "e",
+	'ж': "z", 'з': "z", 'и': "i", 'ј': "j", 'к': "k", 'л': "l", 'љ': "lj",
+	'м': "m", 'н': "n", 'њ': "nj", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'ћ': "c", 'у': "u", 'ф': "f", 'х': "h", 'ц': "c", 'ч': "c",
+	'џ': "dz", 'ш': "s",
+	'ё': "e", 'й': "j", 'щ': "sc", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+	'ю': "ju", 'я': "ja",
+	// Latin with diacritics
+	'š': "s", 'đ': "dj", 'č': "c", 'ć': "c", 'ž': "z",
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a", 'ą': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e", 'ę': "e", 'ě': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i", 'ı': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ő': "o", 'ø': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u", 'ű': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y", 'ç': "c", 'ğ': "g", 'ş': "s", 'ñ': "n", 'ń': "n",
+	'ł': "l", 'ř': "r", 'ť': "t", 'ď': "d", 'ň': "n", 'ś': "s", 'ź': "z",
+	'ż': "z", 'ß': "ss",
+}
+
+// Fold returns text in lowercase plain Latin letters
+func Fold(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range strings.ToLower(text) {
+		if s, ok := fold[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Terms returns the folded words of a query
+func Terms(query string) []string {
+	return strings.FieldsFunc(Fold(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
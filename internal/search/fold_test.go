@@ -0,0 +1,35 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	cases := map[string]string{
+		"ХЛЕБ":            "hleb",
+		"Hleb":            "hleb",
+		"Ђачки хлеб 500г": "djacki hleb 500g",
+		"Đački hleb 500g": "djacki hleb 500g",
+		"ЧОКОЛАДА Ćevapi": "cokolada cevapi",
+		"Шљиве џем":       "sljive dzem",
+		"Šljive džem":     "sljive dzem",
+		"Молоко Щедрое":   "moloko scedroe",
+		"Объём йогурта":   "obem jogurta",
+		"Müller Çaykur":   "muller caykur",
+		"Lidl 100%, a.d.": "lidl 100%, a.d.",
+	}
+	for in, want := range cases {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms(`  "ХЛЕБ" OR mleko-3,2%`)
+	want := []string{"hleb", "or", "mleko", "3", "2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Terms = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+package search
+
+import (
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fields of the query language, in the order of the error messages
+var Fields = []string{"tag", "merchant", "date", "price", "currency", "country"}
+
+// Query is a parsed search query such as
+//
+//	tag:food merchant:maxi date:2024-01..2024-03 price:>2000 currency:rsd "mleko"
+//
+// Repeated tag, currency and country terms match any of the values,
+// the other fields can be given once. Words outside of the fields are
+// the full-text search, quoted words match as a phrase.
+type Query struct {
+	// Text is the full-text part with the quotes of the phrases kept
+	Text     string
+	Tags     []string
+	Merchant string
+	// From is inclusive, To is exclusive
+	From       time.Time
+	To         time.Time
+	MinPrice   *float64
+	MaxPrice   *float64
+	Currencies []currency.Currency
+	Countries  []country.Country
+}
+
+// SyntaxError is an invalid term of a query
+type SyntaxError struct {
+	// Column is the position of the term in characters, from 1
+	Column int
+	Term   string
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query at column %d (%s): %s", e.Column, e.Term, e.Msg)
+}
+
+// Parse parses a search query
+func Parse(q string) (*Query, error) {
+	p := &parser{query: &Query{}, seen: map[string]bool{}}
+	var text []string
+	for i := 0; i < len(q); {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		term, err := readTerm(q, i)
+		if err != nil {
+			return nil, err
+		}
+		key, value, isField := splitField(term)
+		if isField {
+			err = p.field(key, unquote(value))
+		} else {
+			text = append(text, term)
+		}
+		if err != nil {
+			return nil, &SyntaxError{
+				Column: utf8.RuneCountInString(q[:i]) + 1,
+				Term:   term,
+				Msg:    err.Error(),
+			}
+		}
+		i += len(term)
+	}
+	p.query.Text = strings.Join(text, " ")
+	return p.query, nil
+}
+
+// readTerm returns the term at i, up to a space outside of quotes
+func readTerm(q string, i int) (string, error) {
+	quoted := false
+	start := i
+	for i < len(q) {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		if r == '"' {
+			quoted = !quoted
+		} else if unicode.IsSpace(r) && !quoted {
+			break
+		}
+		i += size
+	}
+	if quoted {
+		return "", &SyntaxError{
+			Column: utf8.RuneCountInString(q[:start]) + 1,
+			Term:   q[start:],
+			Msg:    "missing closing quote",
+		}
+	}
+	return q[start:i], nil
+}
+
+// splitField splits key:value terms, the key is letters only
+func splitField(term string) (string, string, bool) {
+	key, value, found := strings.Cut(term, ":")
+	if !found || key == "" {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) {
+			return "", "", false
+		}
+	}
+	return strings.ToLower(key), value, true
+}
+
+func unquote(value string) string {
+	return strings.TrimSpace(strings.ReplaceAll(value, `"`, ""))
+}
+
+type parser struct {
+	query *Query
+	seen  map[string]bool
+}
+
+func (p *parser) field(key string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: missing value", key)
+	}
+	switch key {
+	case "tag", "currency", "country":
+	case "merchant", "date", "price":
+		if p.seen[key] {
+			return fmt.Errorf("%s: given more than once", key)
+		}
+	default:
+		return fmt.Errorf("unknown field %q, expected one of %s", key, strings.Join(Fields, ", "))
+	}
+	p.seen[key] = true
+
+	q := p.query
+	switch key {
+	case "tag":
+		q.Tags = append(q.Tags, value)
+	case "merchant":
+		q.Merchant = value
+	case "date":
+		from, to, err := parseDateRange(value)
+		if err != nil {
+			return fmt.Errorf("date: %w", err)
+		}
+		q.From, q.To = from, to
+	case "price":
+		low, high, err := parsePriceRange(value)
+		if err != nil {
+			return fmt.Errorf("price: %w", err)
+		}
+		q.MinPrice, q.MaxPrice = low, high
+	case "currency":
+		c, err := currency.Parse(strings.ToLower(value))
+		if err != nil {
+			return fmt.Errorf("currency: unknown currency %q", value)
+		}
+		q.Currencies = append(q.Currencies, c)
+	case "country":
+		c, err := country.Parse(strings.ToLower(value))
+		if err != nil {
+			return fmt.Errorf("country: unknown country %q", value)
+		}
+		q.Countries = append(q.Countries, c)
+	}
+	return nil
+}
+
+// parseDateRange parses a period or a range of periods a..b,
+// either end of a range can be left out
+func parseDateRange(value string) (time.Time, time.Time, error) {
+	a, b, isRange := strings.Cut(value, "..")
+	if !isRange {
+		return parsePeriod(value)
+	}
+	if a == "" && b == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("empty range")
+	}
+	var from, to time.Time
+	var err error
+	if a != "" {
+		from, _, err = parsePeriod(a)
+		if err != nil {
+			return from, to, err
+		}
+	}
+	if b != "" {
+		_, to, err = parsePeriod(b)
+		if err != nil {
+			return from, to, err
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("%s is after %s", a, b)
+	}
+	return from, to, nil
+}
+
+// parsePeriod returns the bounds of a year, month or day
+func parsePeriod(value string) (time.Time, time.Time, error) {
+	periods := []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+	for _, p := range periods {
+		if len(value) != len(p.layout) {
+			continue
+		}
+		t, err := time.Parse(p.layout, value)
+		if err != nil {
+			break
+		}
+		return t, p.next(t), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", value)
+}
+
+// parsePriceRange parses >n, >=n, <n, <=n, =n, n and ranges a..b,
+// the bounds are inclusive so > and < move them by the smallest step
+func parsePriceRange(value string) (*float64, *float64, error) {
+	if a, b, isRange := strings.Cut(value, ".."); isRange {
+		var low, high *float64
+		var err error
+		if a != "" {
+			if low, err = parsePrice(a); err != nil {
+				return nil, nil, err
+			}
+		}
+		if b != "" {
+			if high, err = parsePrice(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		if low == nil && high == nil {
+			return nil, nil, fmt.Errorf("empty range")
+		}
+		if low != nil && high != nil && *low > *high {
+			return nil, nil, fmt.Errorf("%s is more than %s", a, b)
+		}
+		return low, high, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		n, found := strings.CutPrefix(value, op)
+		if !found {
+			continue
+		}
+		price, err := parsePrice(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch op {
+		case ">=":
+			return price, nil, nil
+		case "<=":
+			return nil, price, nil
+		case ">":
+			*price = math.Nextafter(*price, math.Inf(1))
+			return price, nil, nil
+		case "<":
+			*price = math.Nextafter(*price, math.Inf(-1))
+			return nil, price, nil
+		}
+		return price, price, nil
+	}
+	price, err := parsePrice(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return price, price, nil
+}
+
+// parsePrice accepts a decimal comma as written on the receipts
+func parsePrice(value string) (*float64, error) {
+	price, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, fmt.Errorf("invalid price %q", value)
+	}
+	return &price, nil
+}
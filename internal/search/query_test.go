@@ -0,0 +1,149 @@
+package search
+
+import (
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`tag:food merchant:maxi date:2024-01..2024-03 price:>2000 currency:rsd "mleko 2,8%" hleb tag:"home office"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Text != `"mleko 2,8%" hleb` {
+		t.Errorf("Unexpected text %q", q.Text)
+	}
+	if !slices.Equal(q.Tags, []string{"food", "home office"}) {
+		t.Errorf("Unexpected tags %q", q.Tags)
+	}
+	if q.Merchant != "maxi" {
+		t.Errorf("Unexpected merchant %q", q.Merchant)
+	}
+	if !q.From.Equal(date("2024-01-01")) || !q.To.Equal(date("2024-04-01")) {
+		t.Errorf("Unexpected dates %v..%v", q.From, q.To)
+	}
+	if q.MinPrice == nil || *q.MinPrice <= 2000 || *q.MinPrice > 2000.000001 || q.MaxPrice != nil {
+		t.Errorf("Unexpected prices %v..%v", q.MinPrice, q.MaxPrice)
+	}
+	if !slices.Equal(q.Currencies, []currency.Currency{currency.RSD}) {
+		t.Errorf("Unexpected currencies %v", q.Currencies)
+	}
+
+	q, err = Parse("Country:Serbia country:turkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(q.Countries, []country.Country{country.SERBIA, country.TURKEY}) {
+		t.Errorf("Unexpected countries %v", q.Countries)
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	dates := map[string][2]string{
+		"date:2024":             {"2024-01-01", "2025-01-01"},
+		"date:2024-02":          {"2024-02-01", "2024-03-01"},
+		"date:2024-02-29":       {"2024-02-29", "2024-03-01"},
+		"date:2024-12..":        {"2024-12-01", ""},
+		"date:..2024":           {"", "2025-01-01"},
+		"date:2023-12-31..2024": {"2023-12-31", "2025-01-01"},
+	}
+	for in, want := range dates {
+		q, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if !q.From.Equal(date(want[0])) || !q.To.Equal(date(want[1])) {
+			t.Errorf("Parse(%q) = %v..%v, want %v", in, q.From, q.To, want)
+		}
+	}
+
+	inf := math.Inf(1)
+	prices := map[string][2]float64{
+		"price:>=10":     {10, inf},
+		"price:<=10":     {-inf, 10},
+		"price:=10,5":    {10.5, 10.5},
+		"price:10":       {10, 10},
+		"price:10..20.5": {10, 20.5},
+		"price:..20":     {-inf, 20},
+		"price:<10":      {-inf, math.Nextafter(10, -inf)},
+	}
+	for in, want := range prices {
+		q, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		low, high := -inf, inf
+		if q.MinPrice != nil {
+			low = *q.MinPrice
+		}
+		if q.MaxPrice != nil {
+			high = *q.MaxPrice
+		}
+		if low != want[0] || high != want[1] {
+			t.Errorf("Parse(%q) = %v..%v, want %v", in, low, high, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		column int
+		msg    string
+	}{
+		`mleko size:big`:        {7, `unknown field "size"`},
+		`tag:`:                  {1, "tag: missing value"},
+		`"mleko`:                {1, "missing closing quote"},
+		`хлеб tag:"food`:        {6, "missing closing quote"},
+		`date:2024-13`:          {1, `date: invalid date "2024-13"`},
+		`date:2024-03..2024-01`: {1, "date: 2024-03 is after 2024-01"},
+		`date:..`:               {1, "date: empty range"},
+		`price:>abc`:            {1, `price: invalid price "abc"`},
+		`price:30..20`:          {1, "price: 30 is more than 20"},
+		`merchant:a merchant:b`: {12, "merchant: given more than once"},
+		`currency:doubloon`:     {1, `currency: unknown currency "doubloon"`},
+		`country:atlantis`:      {1, `country: unknown country "atlantis"`},
+	}
+	for in, want := range cases {
+		_, err := Parse(in)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%q): expected a syntax error, got %v", in, err)
+			continue
+		}
+		if se.Column != want.column || !strings.HasPrefix(se.Msg, want.msg) {
+			t.Errorf("Parse(%q) = column %d %q, want column %d %q", in, se.Column, se.Msg, want.column, want.msg)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	// only letter keys are fields, quoted colons are text
+	for in, want := range map[string]string{
+		"":                 "",
+		"  hleb   beli ":   "hleb beli",
+		"12:30 2024":       "12:30 2024",
+		`"coca:cola" zero`: `"coca:cola" zero`,
+	} {
+		q, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if q.Text != want {
+			t.Errorf("Parse(%q).Text = %q, want %q", in, q.Text, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package search
+
+import "strings"
+
+// snippets mark the matched words with these
+const (
+	MarkStart = "\x02"
+	MarkEnd   = "\x03"
+)
+
+// Snippet returns up to size words of text around the first word
+// matching a term as a prefix, with the matches between MarkStart and
+// MarkEnd. Words are compared folded, the snippet keeps the original
+// script. It is empty when no word matches.
+func Snippet(text string, terms []string, size int) string {
+	words := strings.Fields(text)
+	matched := make([]bool, len(words))
+	first := -1
+	for i, w := range words {
+		matched[i] = matches(w, terms)
+		if matched[i] && first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+	start := max(0, min(first-size/4, len(words)-size))
+	end := min(len(words), start+size)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteByte(' ')
+		}
+		if matched[i] {
+			b.WriteString(MarkStart + words[i] + MarkEnd)
+		} else {
+			b.WriteString(words[i])
+		}
+	}
+	if end < len(words) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+// matches reports whether a part of word starts with one of the terms
+func matches(word string, terms []string) bool {
+	for _, part := range Terms(word) {
+		for _, t := range terms {
+			if strings.HasPrefix(part, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
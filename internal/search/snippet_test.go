@@ -0,0 +1,22 @@
+package search
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	text := "МАКСИ ХЛЕБ БЕЛИ 500г Mleko 2,8% čokolada"
+	cases := []struct {
+		terms []string
+		size  int
+		want  string
+	}{
+		{[]string{"hle"}, 12, "МАКСИ \x02ХЛЕБ\x03 БЕЛИ 500г Mleko 2,8% čokolada"},
+		{[]string{"coko", "mlek"}, 3, "… \x02Mleko\x03 2,8% \x02čokolada\x03"},
+		{[]string{"8"}, 2, "… \x022,8%\x03 čokolada"},
+		{[]string{"sir"}, 12, ""},
+	}
+	for _, c := range cases {
+		if got := Snippet(text, c.terms, c.size); got != c.want {
+			t.Errorf("Snippet(%q, %d) = %q, want %q", c.terms, c.size, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package api_test
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/tag"
+	"billdb/internal/server/api"
+	"billdb/internal/server/servertest"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const rsLink = "https://suf.purs.gov.rs/v/?vl="
+
+func decode(t *testing.T, body string) api.ResponseFlutter {
+	t.Helper()
+	var r api.ResponseFlutter
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatalf("Invalid response %s: %v", body, err)
+	}
+	return r
+}
+
+func TestQr(t *testing.T) {
+	s := servertest.New(t)
+	parser := servertest.FakeParser(t, rsLink)
+	maxi := servertest.NewBill(rsLink+"maxi", "Maxi", 250, "milk", "bread")
+	parser.Add(maxi)
+	ctx := context.Background()
+
+	resp, body := s.PostJSON(t, "/api/flutter/qr", fmt.Sprintf(`{"link": %q}`, maxi.Link))
+	r := decode(t, body)
+	if resp.StatusCode != http.StatusOK || r.Success != "success" || len(r.Bill) != 1 ||
+		r.Bill[0].Id != maxi.Id || r.Bill[0].Items != 2 || r.Bill[0].Currency != "rsd" {
+		t.Fatalf("Expected the parsed bill, got %d: %s", resp.StatusCode, body)
+	}
+	if _, err := s.BillRepo.GetBillByID(ctx, maxi.Id); err != nil {
+		t.Errorf("Expected the bill stored: %v", err)
+	}
+	if items, err := s.BillRepo.GetItemsByID(ctx, maxi.Id); err != nil || len(items) != 2 {
+		t.Errorf("Expected the items stored, got %v, %v", items, err)
+	}
+
+	_, body = s.PostJSON(t, "/api/flutter/qr", fmt.Sprintf(`{"link": %q}`, maxi.Link))
+	r = decode(t, body)
+	if r.Success != "duplicates" || r.Token == "" || len(r.Duplicates) != 1 || r.Bill[0].Duplicates != 1 {
+		t.Fatalf("Expected the duplicate reported with a token, got %s", body)
+	}
+	if d := r.Duplicates[0]; d.Id != maxi.Id || d.Score != 100 || d.Items != 2 || len(d.Reasons) == 0 {
+		t.Errorf("Expected the details of the stored bill, got %+v", d)
+	}
+	if _, err := s.BillRepo.GetBillByID(ctx, r.Bill[0].Id); err == nil {
+		t.Errorf("Expected the duplicate not inserted")
+	}
+
+	for _, c := range []struct {
+		body string
+		code int
+	}{
+		{`{"link": ""}`, http.StatusBadRequest},
+		{`{"link": "https://example.com/bill"}`, http.StatusInternalServerError},
+		{fmt.Sprintf(`{"link": %q}`, rsLink+"unknown"), http.StatusInternalServerError},
+	} {
+		resp, body := s.PostJSON(t, "/api/flutter/qr", c.body)
+		if r := decode(t, body); resp.StatusCode != c.code || r.Success != "error" {
+			t.Errorf("%s: expected an error with %d, got %d: %s", c.body, c.code, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestListAndDeleteBills(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	maxi := servertest.NewBill(rsLink+"maxi", "Maxi", 250, "milk")
+	lidl := servertest.NewBill(rsLink+"lidl", "Lidl", 100, "soap")
+	if err := s.BillRepo.InsertBillWithItems(ctx, maxi); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BillRepo.InsertBillWithItems(ctx, lidl); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body := s.Get(t, "/api/flutter/bills?q=milk")
+	var bills []api.BillApi
+	if err := json.Unmarshal([]byte(body), &bills); err != nil || len(bills) != 1 || bills[0].Id != maxi.Id {
+		t.Fatalf("Expected the bill with milk, got %s", body)
+	}
+
+	resp, _ := s.Do(t, http.MethodDelete, "/api/flutter/bill/"+maxi.Id, "", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the bill deleted, got %d", resp.StatusCode)
+	}
+	if _, err := s.BillRepo.GetBillByID(ctx, maxi.Id); err == nil {
+		t.Errorf("Expected the bill in the trash")
+	}
+	resp, _ = s.Do(t, http.MethodDelete, "/api/flutter/bill/"+maxi.Id, "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted bill, got %d", resp.StatusCode)
+	}
+}
+
+// report stores scan and returns the duplicate reported for the form
+// of the same bill typed by hand
+func report(t *testing.T, s *servertest.Server, scan *bill.Bill, form string) api.ResponseFlutter {
+	t.Helper()
+	if err := s.BillRepo.InsertBillWithItems(context.Background(), scan); err != nil {
+		t.Fatal(err)
+	}
+	_, body := s.PostJSON(t, "/api/flutter/form", form)
+	r := decode(t, body)
+	if r.Success != "duplicates" || r.Token == "" || len(r.Duplicates) != 1 {
+		t.Fatalf("Expected the duplicate reported, got %s", body)
+	}
+	return r
+}
+
+func resolve(t *testing.T, s *servertest.Server, token string, action string, billId string) (int, api.ResponseFlutter) {
+	t.Helper()
+	resp, body := s.PostJSON(t, "/api/flutter/resolve",
+		fmt.Sprintf(`{"token": %q, "action": %q, "bill_id": %q}`, token, action, billId))
+	return resp.StatusCode, decode(t, body)
+}
+
+func TestResolve(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	form := `{"name": "Maxi", "date": "2024-05-01", "price": 250, "currency": "rsd", "country": "serbia", "tags": "%s"}`
+
+	t.Run("insert", func(t *testing.T) {
+		r := report(t, s, servertest.NewBill(rsLink+"insert", "Maxi", 250, "milk"), fmt.Sprintf(form, "food"))
+		code, got := resolve(t, s, r.Token, "insert", "")
+		if code != http.StatusOK || got.Success != "success" || got.Bill[0].Id != r.Bill[0].Id {
+			t.Fatalf("Expected the bill inserted, got %d: %+v", code, got)
+		}
+		if _, err := s.BillRepo.GetBillByID(ctx, r.Bill[0].Id); err != nil {
+			t.Errorf("Expected the bill stored: %v", err)
+		}
+		if code, _ := resolve(t, s, r.Token, "insert", ""); code != http.StatusNotFound {
+			t.Errorf("Expected a token used once, got %d", code)
+		}
+	})
+
+	t.Run("attach", func(t *testing.T) {
+		maxi := servertest.NewBill(rsLink+"attach", "Maxi", 300, "milk", "bread")
+		if err := s.BillRepo.InsertBillWithItems(ctx, maxi); err != nil {
+			t.Fatal(err)
+		}
+		_, body := s.PostJSON(t, "/api/flutter/form",
+			`{"name": "MAXI", "date": "2024-05-01", "price": 300, "currency": "rsd", "country": "serbia", "tags": "food"}`)
+		r := decode(t, body)
+		if r.Success != "duplicates" || len(r.Duplicates) != 1 || r.Duplicates[0].Id != maxi.Id {
+			t.Fatalf("Expected the stored bill reported, got %s", body)
+		}
+
+		code, got := resolve(t, s, r.Token, "attach", maxi.Id)
+		if code != http.StatusOK || got.Bill[0].Id != maxi.Id || got.Bill[0].Items != 2 {
+			t.Fatalf("Expected the scan attached, got %d: %+v", code, got)
+		}
+		b, err := s.BillRepo.GetBillByID(ctx, maxi.Id)
+		if err != nil || b.Tag.String != "food" {
+			t.Errorf("Expected the tag of the form on the stored bill, got %+v, %v", b, err)
+		}
+		if _, err := s.BillRepo.GetBillByID(ctx, r.Bill[0].Id); err == nil {
+			t.Errorf("Expected the attached bill not inserted")
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		parser := servertest.FakeParser(t, rsLink)
+		lidl := servertest.NewBill(rsLink+"replace", "Lidl", 100, "soap")
+		parser.Add(lidl)
+		typed := servertest.NewBill("", "Lidl", 100)
+		typed.Tag = tag.New("home")
+		if err := s.BillRepo.InsertBillWithItems(ctx, typed); err != nil {
+			t.Fatal(err)
+		}
+		_, body := s.PostJSON(t, "/api/flutter/qr", fmt.Sprintf(`{"link": %q}`, lidl.Link))
+		r := decode(t, body)
+		if r.Success != "duplicates" || len(r.Duplicates) != 1 || r.Duplicates[0].Id != typed.Id || r.Duplicates[0].Tag != "home" {
+			t.Fatalf("Expected the typed bill reported, got %s", body)
+		}
+
+		if code, _ := resolve(t, s, r.Token, "replace", lidl.Id); code != http.StatusConflict {
+			t.Errorf("Expected a bill that is not a duplicate refused, got %d", code)
+		}
+		code, got := resolve(t, s, r.Token, "replace", typed.Id)
+		if code != http.StatusOK || got.Bill[0].Id != lidl.Id {
+			t.Fatalf("Expected the typed bill replaced, got %d: %+v", code, got)
+		}
+		b, err := s.BillRepo.GetBillByID(ctx, lidl.Id)
+		if err != nil || b.Tag.String != "home" {
+			t.Errorf("Expected the scan stored with the tag of the typed bill, got %+v, %v", b, err)
+		}
+		if items, err := s.BillRepo.GetItemsByID(ctx, lidl.Id); err != nil || len(items) != 1 {
+			t.Errorf("Expected the items of the scan, got %v, %v", items, err)
+		}
+		if _, err := s.BillRepo.GetBillByID(ctx, typed.Id); err == nil {
+			t.Errorf("Expected the typed bill in the trash")
+		}
+	})
+
+	for _, c := range []struct {
+		body string
+		code int
+	}{
+		{`{"token": "", "action": "insert"}`, http.StatusBadRequest},
+		{`{"token": "abc", "action": "force"}`, http.StatusBadRequest},
+		{`{"token": "abc", "action": "attach"}`, http.StatusBadRequest},
+		{`{"token": "abc", "action": "insert"}`, http.StatusNotFound},
+	} {
+		resp, body := s.PostJSON(t, "/api/flutter/resolve", c.body)
+		if r := decode(t, body); resp.StatusCode != c.code || r.Success != "error" {
+			t.Errorf("%s: expected an error with %d, got %d: %s", c.body, c.code, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"billdb/internal/qrcode"
+	"billdb/internal/server"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BillQrHandler returns the QR code of a stored bill,
+// ?format=svg for SVG, otherwise PNG of ?size pixels
+var BillQrHandler = server.Get(baseApiPath+"/bill/:id/qr", func(s *server.Server) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bill, err := s.BillRepo.GetBillByID(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		payload, err := qrcode.Payload(bill)
+		if errors.Is(err, qrcode.ErrNoLink) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return c.JSON(http.StatusUnprocessableEntity, err.Error())
+		}
+		return server.WriteQr(c, payload)
+	}
+})
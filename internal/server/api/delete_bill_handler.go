@@ -0,0 +1,20 @@
+package api
+
+import (
+	"billdb/internal/server"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DeleteBillHandler moves a bill to the trash, it can be restored
+// from the trash page until it is purged
+var DeleteBillHandler = server.Delete(baseApiPath+"/bill/:id", func(s *server.Server) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := s.BillRepo.DeleteBill(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+})
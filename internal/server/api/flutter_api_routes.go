@@ -17,11 +17,23 @@ type BillApi struct {
 	Duplicates   int     `json:"duplicates"`
 }
 
+// DuplicateApi is a stored bill the new bill may be the receipt of
+type DuplicateApi struct {
+	BillApi
+	Tag string `json:"tag"`
+	// Score is how likely it is the same receipt in percent
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
 type ResponseFlutter struct {
 	Success string    `json:"success"`
 	Message string    `json:"message"`
-	Force   bool      `json:"force"`
 	Bill    []BillApi `json:"bill"`
+	// Duplicates and Token are set when Success is "duplicates",
+	// the bill is stored by resolving the token, see ResolveHandler
+	Duplicates []DuplicateApi `json:"duplicates,omitempty"`
+	Token      string         `json:"token,omitempty"`
 }
 
 func ApiRoutes(s *server.Server) {
@@ -29,4 +41,8 @@ func ApiRoutes(s *server.Server) {
 	FormHandler(s)
 	GetTagsHandler(s)
 	GetCurrenciesHandler(s)
+	BillQrHandler(s)
+	ListBillsHandler(s)
+	DeleteBillHandler(s)
+	ResolveHandler(s)
 }
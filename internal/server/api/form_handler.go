@@ -6,6 +6,7 @@ import (
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
+	"billdb/internal/repository/dedup"
 	"billdb/internal/server"
 	"fmt"
 	"net/http"
@@ -22,7 +23,6 @@ type RequestForm struct {
 	ExchangeRate float64 `json:"exchange_rate"`
 	Country      string  `json:"country"`
 	Tags         string  `json:"tags"`
-	Force        bool    `json:"force"`
 }
 
 var FormHandler = server.Post(baseApiPath+"/form", func(s *server.Server) echo.HandlerFunc {
@@ -31,14 +31,12 @@ var FormHandler = server.Post(baseApiPath+"/form", func(s *server.Server) echo.H
 		r := new(ResponseFlutter)
 		r.Success = "error"
 		r.Bill = make([]BillApi, 0)
-		r.Force = false
 
 		err := c.Bind(req)
 		if err != nil {
 			r.Message = fmt.Sprintf("%v", err)
 			return c.JSON(http.StatusBadRequest, err)
 		}
-		r.Force = req.Force
 		billDate, err := bill.StringToDate(req.Date)
 		if err != nil {
 			r.Message = fmt.Sprintf("%v", err)
@@ -81,22 +79,20 @@ var FormHandler = server.Post(baseApiPath+"/form", func(s *server.Server) echo.H
 			Duplicates:   0,
 		}
 
-		billDupCount, err := s.BillRepo.CheckDuplicateBill(billAccepted)
+		duplicates, err := dedup.Find(c.Request().Context(), s.BillRepo, billAccepted)
 		if err != nil {
 			r.Message = fmt.Sprintf("%v", err)
 			return c.JSON(http.StatusInternalServerError, r)
 		}
-		if billDupCount != 0 {
-			r.Success = "duplicates"
-			billApi.Duplicates = billDupCount
-			r.Message = fmt.Sprintf("Find duplicates in the db = %d\n", billDupCount)
-			return c.JSON(http.StatusOK, r)
+		if len(duplicates) != 0 {
+			return respondDuplicates(c, s, r, billApi, billAccepted, duplicates)
 		}
 
-		err = s.BillRepo.InsertBill(billAccepted)
+		err = s.BillRepo.InsertBill(c.Request().Context(), billAccepted)
 		if err != nil {
 			r.Message = fmt.Sprintf("%v", err)
-			return c.JSON(http.StatusInternalServerError, r)
+			code, _ := server.StatusOf(err)
+			return c.JSON(code, r)
 		}
 
 		r.Success = "success"
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"billdb/internal/server"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListBillsHandler returns a page of the bills matching the query parameters,
+// see server.BillFilterFromRequest for the parameters. The cursor of the
+// next page is in the X-Next-Cursor header, missing on the last page.
+var ListBillsHandler = server.Get(baseApiPath+"/bills", func(s *server.Server) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filter, err := server.BillFilterFromRequest(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		page, err := s.BillRepo.SearchBills(c.Request().Context(), filter)
+		if err != nil {
+			return err
+		}
+		if page.Next != "" {
+			c.Response().Header().Set("X-Next-Cursor", page.Next)
+		}
+		r := make([]BillApi, 0, len(page.Hits))
+		for _, hit := range page.Hits {
+			bill := hit.Bill
+			r = append(r, BillApi{
+				Id:       bill.Id,
+				Name:     bill.Name,
+				Date:     bill.GetDateString(),
+				Price:    bill.Price,
+				Currency: bill.GetCurrencyString(),
+				Country:  bill.GetCountryString(),
+				Link:     bill.Link,
+			})
+		}
+		return c.JSON(http.StatusOK, r)
+	}
+})
@@ -2,6 +2,7 @@ package api
 
 import (
 	"billdb/internal/parser"
+	"billdb/internal/repository/dedup"
 	"billdb/internal/server"
 	"fmt"
 	"net/http"
@@ -10,8 +11,7 @@ import (
 )
 
 type RequestQr struct {
-	Link  string `json:"link"`
-	Force bool   `json:"force"`
+	Link string `json:"link"`
 }
 
 var QrHandler = server.Post(baseApiPath+"/qr", func(s *server.Server) echo.HandlerFunc {
@@ -25,7 +25,6 @@ var QrHandler = server.Post(baseApiPath+"/qr", func(s *server.Server) echo.Handl
 			r.Message = fmt.Sprintf("%v", err)
 			return c.JSON(http.StatusBadRequest, r)
 		}
-		r.Force = req.Force
 
 		if req.Link == "" {
 			r.Message = "Empty link"
@@ -58,29 +57,20 @@ var QrHandler = server.Post(baseApiPath+"/qr", func(s *server.Server) echo.Handl
 		}
 		r.Bill = []BillApi{b}
 
-		// TODO check in flutter app, do I need to send beck duplicates?
-		billDupCount, err := s.BillRepo.CheckDuplicateBill(bill)
+		duplicates, err := dedup.Find(c.Request().Context(), s.BillRepo, bill)
 		if err != nil {
 			r.Message = fmt.Sprintf("Duplicates error: %v", err)
 			return c.JSON(http.StatusInternalServerError, r)
 		}
-		if billDupCount != 0 {
-			r.Success = "duplicates"
-			b.Duplicates = billDupCount
-			r.Message = fmt.Sprintf("Find duplicates in the db = %d\n", billDupCount)
-			return c.JSON(http.StatusOK, r)
+		if len(duplicates) != 0 {
+			return respondDuplicates(c, s, r, b, bill, duplicates)
 		}
 
-		err = s.BillRepo.InsertBill(bill)
+		err = s.BillRepo.InsertBillWithItems(c.Request().Context(), bill)
 		if err != nil {
 			r.Message = fmt.Sprintf("Error while inserting a bill: %v", err)
-			return c.JSON(http.StatusInternalServerError, r)
-		}
-
-		err = s.BillRepo.InsertItems(bill.Items)
-		if err != nil {
-			r.Message = fmt.Sprintf("Error while inserting items: %v", err)
-			return c.JSON(http.StatusInternalServerError, r)
+			code, _ := server.StatusOf(err)
+			return c.JSON(code, r)
 		}
 
 		r.Success = "success"
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/repository/dedup"
+	"billdb/internal/server"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RequestResolve struct {
+	Token string `json:"token"`
+	// Action is insert, attach or replace
+	Action string `json:"action"`
+	// BillId is the duplicate the bill is attached to or replaces
+	BillId string `json:"bill_id"`
+}
+
+// ResolveHandler stores a bill reported with duplicates as the client
+// chose: insert stores it anyway, attach adds it to one of its
+// duplicates, replace stores it and moves the duplicate to the trash
+var ResolveHandler = server.Post(baseApiPath+"/resolve", func(s *server.Server) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(RequestResolve)
+		r := new(ResponseFlutter)
+		r.Success = "error"
+		r.Bill = make([]BillApi, 0)
+
+		if err := c.Bind(req); err != nil {
+			r.Message = fmt.Sprintf("%v", err)
+			return c.JSON(http.StatusBadRequest, r)
+		}
+		if req.Token == "" {
+			r.Message = "Empty token"
+			return c.JSON(http.StatusBadRequest, r)
+		}
+		action, err := dedup.ParseAction(req.Action)
+		if err != nil {
+			r.Message = fmt.Sprintf("%v", err)
+			return c.JSON(http.StatusBadRequest, r)
+		}
+		if action != dedup.ActionInsert && req.BillId == "" {
+			r.Message = fmt.Sprintf("The bill to %s is missing", action)
+			return c.JSON(http.StatusBadRequest, r)
+		}
+
+		b, err := s.Resolutions.Resolve(c.Request().Context(), s.BillRepo, req.Token, action, req.BillId)
+		if err != nil {
+			code, msg := server.StatusOf(err)
+			r.Message = msg
+			return c.JSON(code, r)
+		}
+
+		switch action {
+		case dedup.ActionInsert:
+			r.Message = "Bill inserted"
+		case dedup.ActionAttach:
+			r.Message = fmt.Sprintf("Attached to %s of %s", b.Name, b.GetDateString())
+		case dedup.ActionReplace:
+			r.Message = "Bill replaced, the duplicate is in the trash"
+		}
+		r.Success = "success"
+		r.Bill = []BillApi{newBillApi(b)}
+		return c.JSON(http.StatusOK, r)
+	}
+})
+
+// respondDuplicates holds the new bill for its resolution and answers
+// with b, its view, its duplicates and the token resolving it
+func respondDuplicates(c echo.Context, s *server.Server, r *ResponseFlutter, b BillApi, held *bill.Bill, duplicates []*dedup.Candidate) error {
+	token, err := s.Resolutions.Hold(held, duplicates)
+	if err != nil {
+		r.Message = fmt.Sprintf("Duplicates error: %v", err)
+		return c.JSON(http.StatusInternalServerError, r)
+	}
+	b.Duplicates = len(duplicates)
+	r.Success = "duplicates"
+	r.Message = fmt.Sprintf("Found %d duplicate bill(s)", len(duplicates))
+	r.Bill = []BillApi{b}
+	r.Token = token
+	r.Duplicates = make([]DuplicateApi, 0, len(duplicates))
+	for _, d := range duplicates {
+		if d.Bill.Items == nil {
+			d.Bill.Items, err = s.BillRepo.GetItemsByID(c.Request().Context(), d.Bill.Id)
+			if err != nil {
+				r.Message = fmt.Sprintf("Duplicates error: %v", err)
+				return c.JSON(http.StatusInternalServerError, r)
+			}
+		}
+		r.Duplicates = append(r.Duplicates, DuplicateApi{
+			BillApi: newBillApi(d.Bill),
+			Tag:     d.Bill.Tag.String,
+			Score:   d.Percent(),
+			Reasons: d.Reasons,
+		})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// newBillApi returns the API view of a stored bill
+func newBillApi(b *bill.Bill) BillApi {
+	return BillApi{
+		Id:       b.Id,
+		Name:     b.Name,
+		Date:     b.GetDateString(),
+		Price:    b.Price,
+		Currency: b.GetCurrencyString(),
+		Country:  b.GetCountryString(),
+		Items:    len(b.Items),
+		Link:     b.Link,
+	}
+}
@@ -0,0 +1,59 @@
+// Package app assembles the echo application of the server, shared by
+// the server command and the end-to-end tests of the handlers
+package app
+
+import (
+	"billdb/internal/repository/backup"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/check"
+	"billdb/internal/repository/dedup"
+	diagnosticRepository "billdb/internal/repository/diagnostic"
+	"billdb/internal/server"
+	"billdb/internal/server/api"
+	"billdb/internal/server/web"
+	"billdb/internal/worker"
+	"fmt"
+	"html/template"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Deps are the repositories and services used by the handlers
+type Deps struct {
+	BillRepo       repository.BillRepository
+	Jobs           *worker.Pool
+	DiagnosticRepo diagnosticRepository.DiagnosticRepository
+	Checker        *check.Checker
+	Backups        *backup.Manager
+}
+
+// New returns the application with the templates of cfg.TemplatesPath,
+// the static files and the routes of the web pages and the flutter API
+func New(cfg *server.Config, deps Deps) (*echo.Echo, error) {
+	pattern := filepath.Join(cfg.TemplatesPath, "*.html")
+	templates, err := template.ParseGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+
+	e := echo.New()
+	e.Renderer = &server.Template{Templates: templates}
+	e.HTTPErrorHandler = server.ErrorHandler
+	e.Use(server.AuditSource)
+
+	// call to /index-style.css will redirect to cfg.StaticPath/index-style.css
+	e.Static("/static", cfg.StaticPath)
+	e.Static("/uploaded", cfg.QrPath)
+
+	webGroup := e.Group("")
+	webHandlers := web.NewWebHandlers(cfg, e, deps.BillRepo, deps.Jobs, deps.DiagnosticRepo, deps.Checker, deps.Backups)
+	webHandlers.RegisterRoutes(webGroup)
+	api.ApiRoutes(&server.Server{
+		Config:      cfg,
+		Echo:        e,
+		BillRepo:    deps.BillRepo,
+		Resolutions: dedup.NewResolutions(),
+	})
+	return e, nil
+}
@@ -1,12 +1,16 @@
 package server
 
 import (
+	"billdb/internal/repository/backup"
 	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,8 +20,36 @@ type Config struct {
 	QrPath             string
 	Port               string
 	DbFileNameTemplate string
+	// optional, zero means the worker pool default
+	JobWorkers   int
+	JobHostLimit int
+	// optional, JSON file declaring external parser plugins
+	ParserPluginsPath string
+	// optional, decode with zbarimg when the built-in decoder fails
+	ZbarFallback bool
+	// optional, days deleted bills stay in the trash, zero means
+	// DefaultTrashRetentionDays and negative keeps them until purged
+	TrashRetentionDays int
+	// optional, stores the bills in PostgreSQL instead of the SQLite
+	// database of DbPath, which keeps the parse jobs and diagnostics
+	PostgresDsn string
+	// optional, directory of the database backups,
+	// empty means "backups" next to DbPath
+	BackupPath string
+	// optional, how many daily, weekly and monthly scheduled backups are
+	// kept, zero means backup.DefaultPolicy and negative keeps none,
+	// scheduled backups are off when all of them are negative
+	BackupDaily   int
+	BackupWeekly  int
+	BackupMonthly int
+	// optional, how many copies made before a restore, upload or merge
+	// are kept of each kind, zero means backup.DefaultPolicy and negative
+	// keeps them all
+	BackupSafety int
 }
 
+const DefaultTrashRetentionDays = 30
+
 var (
 	// env var names
 	envDbPath             = "BILLDB_DB_PATH"
@@ -26,6 +58,17 @@ var (
 	envQrPath             = "BILLDB_QR_TMP_PATH"
 	envPort               = "BILLDB_PORT"
 	envDbFileNameTemplate = "BILLDB_DB_FILENAME_TEMPLATE"
+	envJobWorkers         = "BILLDB_JOB_WORKERS"
+	envJobHostLimit       = "BILLDB_JOB_HOST_LIMIT"
+	envParserPluginsPath  = "BILLDB_PARSER_PLUGINS"
+	envZbarFallback       = "BILLDB_ZBAR_FALLBACK"
+	envTrashRetentionDays = "BILLDB_TRASH_RETENTION_DAYS"
+	envPostgresDsn        = "BILLDB_POSTGRES_DSN"
+	envBackupPath         = "BILLDB_BACKUP_PATH"
+	envBackupDaily        = "BILLDB_BACKUP_DAILY"
+	envBackupWeekly       = "BILLDB_BACKUP_WEEKLY"
+	envBackupMonthly      = "BILLDB_BACKUP_MONTHLY"
+	envBackupSafety       = "BILLDB_BACKUP_SAFETY"
 )
 
 // LoadConfig tries CLI flags first, then env vars, then a config file (if provided via CLI).
@@ -43,6 +86,17 @@ func LoadConfig() (*Config, error) {
 	cliQrPath := fs.String("qr-path", "", "path to qr tmp (BILLDB_QR_TMP_PATH)")
 	cliPort := fs.String("port", "8080", "server's port (BILLDB_PORT)")
 	cliDbTemplate := fs.String("db-filename-template", "", "write here")
+	cliJobWorkers := fs.Int("job-workers", 0, "number of background parse workers (BILLDB_JOB_WORKERS)")
+	cliJobHostLimit := fs.Int("job-host-limit", 0, "concurrent parse jobs per host (BILLDB_JOB_HOST_LIMIT)")
+	cliParserPluginsPath := fs.String("parser-plugins", "", "path to parser plugins JSON file (BILLDB_PARSER_PLUGINS)")
+	cliZbarFallback := fs.Bool("zbar-fallback", false, "decode QR codes with zbarimg when the built-in decoder fails (BILLDB_ZBAR_FALLBACK)")
+	cliTrashRetentionDays := fs.Int("trash-retention-days", 0, "days deleted bills stay in the trash, negative keeps them (BILLDB_TRASH_RETENTION_DAYS)")
+	cliPostgresDsn := fs.String("postgres-dsn", "", "PostgreSQL DSN to store the bills in (BILLDB_POSTGRES_DSN)")
+	cliBackupPath := fs.String("backup-path", "", "directory of the database backups (BILLDB_BACKUP_PATH)")
+	cliBackupDaily := fs.Int("backup-daily", 0, "daily backups kept, negative keeps none (BILLDB_BACKUP_DAILY)")
+	cliBackupWeekly := fs.Int("backup-weekly", 0, "weekly backups kept, negative keeps none (BILLDB_BACKUP_WEEKLY)")
+	cliBackupMonthly := fs.Int("backup-monthly", 0, "monthly backups kept, negative keeps none (BILLDB_BACKUP_MONTHLY)")
+	cliBackupSafety := fs.Int("backup-safety", 0, "backups made before a restore, upload or merge kept of each kind, negative keeps all (BILLDB_BACKUP_SAFETY)")
 
 	// config-file flag: path to KEY=VALUE file
 	cliConfigFile := fs.String("config-file", "", "path to config file with KEY=VALUE lines matching env var names")
@@ -79,6 +133,17 @@ func LoadConfig() (*Config, error) {
 		QrPath:             strings.TrimSpace(*cliQrPath),
 		Port:               strings.TrimSpace(*cliPort),
 		DbFileNameTemplate: strings.TrimSpace(*cliDbTemplate),
+		JobWorkers:         *cliJobWorkers,
+		JobHostLimit:       *cliJobHostLimit,
+		ParserPluginsPath:  strings.TrimSpace(*cliParserPluginsPath),
+		ZbarFallback:       *cliZbarFallback,
+		TrashRetentionDays: *cliTrashRetentionDays,
+		PostgresDsn:        strings.TrimSpace(*cliPostgresDsn),
+		BackupPath:         strings.TrimSpace(*cliBackupPath),
+		BackupDaily:        *cliBackupDaily,
+		BackupWeekly:       *cliBackupWeekly,
+		BackupMonthly:      *cliBackupMonthly,
+		BackupSafety:       *cliBackupSafety,
 	}
 
 	if len(missing(cliCfg)) == 0 {
@@ -110,6 +175,39 @@ func LoadConfig() (*Config, error) {
 	if v, ok := os.LookupEnv(envDbFileNameTemplate); ok {
 		envCfg.DbFileNameTemplate = strings.TrimSpace(v)
 	}
+	if v, ok := os.LookupEnv(envJobWorkers); ok {
+		envCfg.JobWorkers = parseIntValue(envJobWorkers, v)
+	}
+	if v, ok := os.LookupEnv(envJobHostLimit); ok {
+		envCfg.JobHostLimit = parseIntValue(envJobHostLimit, v)
+	}
+	if v, ok := os.LookupEnv(envParserPluginsPath); ok {
+		envCfg.ParserPluginsPath = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envZbarFallback); ok {
+		envCfg.ZbarFallback = parseBoolValue(envZbarFallback, v)
+	}
+	if v, ok := os.LookupEnv(envTrashRetentionDays); ok {
+		envCfg.TrashRetentionDays = parseIntValue(envTrashRetentionDays, v)
+	}
+	if v, ok := os.LookupEnv(envPostgresDsn); ok {
+		envCfg.PostgresDsn = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envBackupPath); ok {
+		envCfg.BackupPath = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envBackupDaily); ok {
+		envCfg.BackupDaily = parseIntValue(envBackupDaily, v)
+	}
+	if v, ok := os.LookupEnv(envBackupWeekly); ok {
+		envCfg.BackupWeekly = parseIntValue(envBackupWeekly, v)
+	}
+	if v, ok := os.LookupEnv(envBackupMonthly); ok {
+		envCfg.BackupMonthly = parseIntValue(envBackupMonthly, v)
+	}
+	if v, ok := os.LookupEnv(envBackupSafety); ok {
+		envCfg.BackupSafety = parseIntValue(envBackupSafety, v)
+	}
 
 	if len(missing(envCfg)) == 0 {
 		return envCfg, nil
@@ -167,6 +265,43 @@ func LoadConfig() (*Config, error) {
 	return nil, errors.New("incomplete configuration")
 }
 
+// TrashRetention returns how long deleted bills stay in the trash,
+// zero when they are kept until purged by hand
+func (c *Config) TrashRetention() time.Duration {
+	days := c.TrashRetentionDays
+	if days == 0 {
+		days = DefaultTrashRetentionDays
+	}
+	if days < 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// BackupDir returns the directory of the database backups
+func (c *Config) BackupDir() string {
+	if c.BackupPath != "" {
+		return c.BackupPath
+	}
+	return filepath.Join(filepath.Dir(c.DbPath), "backups")
+}
+
+// BackupPolicy returns the retention of the backups
+func (c *Config) BackupPolicy() backup.Policy {
+	keep := func(n int, def int) int {
+		if n == 0 {
+			return def
+		}
+		return max(n, 0)
+	}
+	return backup.Policy{
+		Daily:   keep(c.BackupDaily, backup.DefaultPolicy.Daily),
+		Weekly:  keep(c.BackupWeekly, backup.DefaultPolicy.Weekly),
+		Monthly: keep(c.BackupMonthly, backup.DefaultPolicy.Monthly),
+		Safety:  keep(c.BackupSafety, backup.DefaultPolicy.Safety),
+	}
+}
+
 // anySet returns true if any field in cfg is non-empty
 func anySet(cfg *Config) bool {
 	if cfg == nil {
@@ -175,6 +310,28 @@ func anySet(cfg *Config) bool {
 	return cfg.DbPath != "" || cfg.TemplatesPath != "" || cfg.StaticPath != "" || cfg.QrPath != ""
 }
 
+// parseIntValue parses an optional numeric setting.
+// Invalid values are reported to stdout and treated as unset.
+func parseIntValue(key string, val string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "invalid value for %s: %q\n", key, val)
+		return 0
+	}
+	return n
+}
+
+// parseBoolValue parses an optional boolean setting.
+// Invalid values are reported to stdout and treated as unset.
+func parseBoolValue(key string, val string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(val))
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "invalid value for %s: %q\n", key, val)
+		return false
+	}
+	return b
+}
+
 // readConfigFile reads KEY=VALUE lines from path and populates cfg.
 // Recognizes the same keys as env var names (BILLDB_DB_PATH, BILLDB_TEMPLATE_PATH, etc.).
 // Lines starting with # are treated as comments. Empty values are permitted but will be set as empty strings.
@@ -214,6 +371,28 @@ func readConfigFile(path string, cfg *Config) error {
 			cfg.Port = val
 		case envDbFileNameTemplate:
 			cfg.DbFileNameTemplate = val
+		case envJobWorkers:
+			cfg.JobWorkers = parseIntValue(key, val)
+		case envJobHostLimit:
+			cfg.JobHostLimit = parseIntValue(key, val)
+		case envParserPluginsPath:
+			cfg.ParserPluginsPath = val
+		case envZbarFallback:
+			cfg.ZbarFallback = parseBoolValue(key, val)
+		case envTrashRetentionDays:
+			cfg.TrashRetentionDays = parseIntValue(key, val)
+		case envPostgresDsn:
+			cfg.PostgresDsn = val
+		case envBackupPath:
+			cfg.BackupPath = val
+		case envBackupDaily:
+			cfg.BackupDaily = parseIntValue(key, val)
+		case envBackupWeekly:
+			cfg.BackupWeekly = parseIntValue(key, val)
+		case envBackupMonthly:
+			cfg.BackupMonthly = parseIntValue(key, val)
+		case envBackupSafety:
+			cfg.BackupSafety = parseIntValue(key, val)
 		default:
 			// ignore unknown keys
 		}
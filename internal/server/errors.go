@@ -0,0 +1,67 @@
+package server
+
+import (
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/search"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatusOf maps an error returned by a handler to the HTTP status
+// and the message shown to the client. Storage errors that are not
+// typed are reported as internal errors without their details.
+func StatusOf(err error) (int, string) {
+	var he *echo.HTTPError
+	var se *search.SyntaxError
+	switch {
+	case errors.As(err, &he):
+		if msg, ok := he.Message.(string); ok {
+			return he.Code, msg
+		}
+		return he.Code, http.StatusText(he.Code)
+	case errors.As(err, &se):
+		return http.StatusBadRequest, se.Error()
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, repository.ErrDuplicate), errors.Is(err, repository.ErrConflict):
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+}
+
+// ErrorHandler answers API requests with a JSON message
+// and web requests with the error.html partial,
+// which htmx swaps in place of the expected content.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	code, msg := StatusOf(err)
+	if code == http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(code)
+	} else if strings.HasPrefix(c.Request().URL.Path, "/api/") {
+		err = c.JSON(code, map[string]string{"message": msg})
+	} else {
+		err = c.Render(code, "error.html", map[string]any{
+			"status":  code,
+			"title":   http.StatusText(code),
+			"message": msg,
+			"partial": c.Request().Header.Get("HX-Request") == "true",
+		})
+		if err != nil {
+			err = c.String(code, msg)
+		}
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/search"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestStatusOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("bill 1: %w", repository.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("bill 1: %w", repository.ErrDuplicate), http.StatusConflict},
+		{repository.ErrConflict, http.StatusConflict},
+		{fmt.Errorf("%w: of another sort", repository.ErrInvalidCursor), http.StatusBadRequest},
+		{echo.NewHTTPError(http.StatusBadRequest, "invalid size"), http.StatusBadRequest},
+		{&search.SyntaxError{Column: 1, Term: "x:1", Msg: "unknown field"}, http.StatusBadRequest},
+		{errors.New("disk I/O error"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		code, msg := StatusOf(tc.err)
+		if code != tc.code {
+			t.Errorf("StatusOf(%v) = %d, want %d", tc.err, code, tc.code)
+		}
+		if code == http.StatusInternalServerError && msg == tc.err.Error() {
+			t.Errorf("StatusOf(%v) leaks the internal error", tc.err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/search"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BillFilterFromRequest reads the bill filter from query or form values:
+// q (a search.Query), merchant, from, to (2006-01-02), tag, currency,
+// country (repeatable), min_price, max_price, sort, limit (the page size),
+// cursor (the next page of a listing) and page (a page number).
+// The fields of q are added to the parameters, a field given
+// in both is taken from q. Searches are sorted by relevance unless
+// sort is set.
+func BillFilterFromRequest(c echo.Context) (repository.BillFilter, error) {
+	f := repository.BillFilter{
+		Merchant: strings.TrimSpace(c.FormValue("merchant")),
+		Tags:     formValues(c, "tag"),
+	}
+	var err error
+	f.From, f.To, err = dateRange(c)
+	if err != nil {
+		return f, err
+	}
+	for _, v := range formValues(c, "currency") {
+		cur, err := currency.Parse(strings.ToLower(v))
+		if err != nil {
+			return f, err
+		}
+		f.Currencies = append(f.Currencies, cur)
+	}
+	for _, v := range formValues(c, "country") {
+		cnt, err := country.Parse(strings.ToLower(v))
+		if err != nil {
+			return f, err
+		}
+		f.Countries = append(f.Countries, cnt)
+	}
+	f.MinPrice, f.MaxPrice, err = priceRange(c)
+	if err != nil {
+		return f, err
+	}
+	q, err := search.Parse(c.FormValue("q"))
+	if err != nil {
+		return f, err
+	}
+	f.Search = q.Text
+	f.Tags = append(f.Tags, q.Tags...)
+	f.Currencies = append(f.Currencies, q.Currencies...)
+	f.Countries = append(f.Countries, q.Countries...)
+	queryFields(q, &f.Merchant, &f.From, &f.To, &f.MinPrice, &f.MaxPrice)
+	f.Sort, err = parseSort(c, f.Search)
+	if err != nil {
+		return f, err
+	}
+	f.Limit, f.Offset, err = page(c)
+	f.Cursor = c.FormValue("cursor")
+	return f, err
+}
+
+// ItemFilterFromRequest reads the item filter, the same parameters
+// as BillFilterFromRequest without currency and country
+func ItemFilterFromRequest(c echo.Context) (repository.ItemFilter, error) {
+	f := repository.ItemFilter{
+		Merchant: strings.TrimSpace(c.FormValue("merchant")),
+		Tags:     formValues(c, "tag"),
+	}
+	var err error
+	f.From, f.To, err = dateRange(c)
+	if err != nil {
+		return f, err
+	}
+	f.MinPrice, f.MaxPrice, err = priceRange(c)
+	if err != nil {
+		return f, err
+	}
+	q, err := search.Parse(c.FormValue("q"))
+	if err != nil {
+		return f, err
+	}
+	if len(q.Currencies) > 0 || len(q.Countries) > 0 {
+		return f, fmt.Errorf("currency and country can't be searched for items")
+	}
+	f.Search = q.Text
+	f.Tags = append(f.Tags, q.Tags...)
+	queryFields(q, &f.Merchant, &f.From, &f.To, &f.MinPrice, &f.MaxPrice)
+	f.Sort, err = parseSort(c, f.Search)
+	if err != nil {
+		return f, err
+	}
+	f.Limit, f.Offset, err = page(c)
+	f.Cursor = c.FormValue("cursor")
+	return f, err
+}
+
+// queryFields sets the single valued fields given in the query
+func queryFields(q *search.Query, merchant *string, from *time.Time, to *time.Time, minPrice **float64, maxPrice **float64) {
+	if q.Merchant != "" {
+		*merchant = q.Merchant
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		*from, *to = q.From, q.To
+	}
+	if q.MinPrice != nil || q.MaxPrice != nil {
+		*minPrice, *maxPrice = q.MinPrice, q.MaxPrice
+	}
+}
+
+// parseSort reads sort, a search defaults to the best matches first
+func parseSort(c echo.Context, search string) (repository.Sort, error) {
+	v := c.FormValue("sort")
+	if v == "" && search != "" {
+		return repository.SortRelevance, nil
+	}
+	return repository.ParseSort(v)
+}
+
+// formValues returns the non-empty values of a repeatable parameter
+func formValues(c echo.Context, name string) []string {
+	var values []string
+	params, err := c.FormParams()
+	if err != nil {
+		return nil
+	}
+	for _, v := range params[name] {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// dateRange parses from and to, to is inclusive in the request
+func dateRange(c echo.Context) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if v := c.FormValue("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date: %s", v)
+		}
+		from = t
+	}
+	if v := c.FormValue("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date: %s", v)
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+func priceRange(c echo.Context) (*float64, *float64, error) {
+	var prices [2]*float64
+	for i, name := range []string{"min_price", "max_price"} {
+		v := c.FormValue(name)
+		if v == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %s", name, v)
+		}
+		prices[i] = &price
+	}
+	return prices[0], prices[1], nil
+}
+
+// page returns limit and offset, the offset of the page number
+// when the page parameter is set
+func page(c echo.Context) (int, int, error) {
+	limit := 0
+	if v := c.FormValue("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", v)
+		}
+		limit = min(l, repository.MaxPageSize)
+	}
+	v := c.FormValue("page")
+	if v == "" {
+		return limit, 0, nil
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil || p < 1 {
+		return 0, 0, fmt.Errorf("invalid page: %s", v)
+	}
+	if limit == 0 {
+		limit = repository.DefaultPageSize
+	}
+	return limit, (p - 1) * limit, nil
+}
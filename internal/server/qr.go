@@ -0,0 +1,37 @@
+package server
+
+import (
+	"billdb/internal/qrcode"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WriteQr writes the payload as a QR code image in the requested format
+func WriteQr(c echo.Context, payload string) error {
+	switch c.QueryParam("format") {
+	case "svg":
+		data, err := qrcode.EncodeSVG(payload)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "image/svg+xml", data)
+	case "", "png":
+		size := qrcode.DefaultSize
+		if s := c.QueryParam("size"); s != "" {
+			var err error
+			size, err = strconv.Atoi(s)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid size")
+			}
+		}
+		data, err := qrcode.EncodePNG(payload, size)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return c.Blob(http.StatusOK, "image/png", data)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown format")
+	}
+}
@@ -1,13 +1,16 @@
 package server
 
 import (
+	"billdb/internal/audit"
 	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/dedup"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/segmentio/ksuid"
@@ -17,6 +20,8 @@ type Server struct {
 	Config   *Config
 	Echo     *echo.Echo
 	BillRepo repository.BillRepository
+	// Resolutions keeps the bills of the API reported with duplicates
+	Resolutions *dedup.Resolutions
 }
 
 func Get(path string, handler func(s *Server) echo.HandlerFunc) func(s *Server) *echo.Route {
@@ -43,6 +48,12 @@ func Put(path string, handler func(s *Server) echo.HandlerFunc) func(s *Server)
 	}
 }
 
+func Delete(path string, handler func(s *Server) echo.HandlerFunc) func(s *Server) *echo.Route {
+	return func(s *Server) *echo.Route {
+		return s.Echo.DELETE(path, handler(s))
+	}
+}
+
 func IndexOf(slice []string, value string) int {
 	for i, v := range slice {
 		if v == value {
@@ -52,15 +63,35 @@ func IndexOf(slice []string, value string) int {
 	return -1 // Return -1 if the element is not found
 }
 
-func CheckFormFile(file *multipart.FileHeader) error {
-	if file.Size > 1048576 {
-		return fmt.Errorf("file size is more then 1Mb: %d", file.Size)
-	}
+const (
+	maxImageSize = 5 << 20
+	maxPdfSize   = 10 << 20
+)
+
+// ErrUnsupportedType is returned by CheckFormFile for a file that can't be decoded
+var ErrUnsupportedType = errors.New("file type is not supported")
+
+// CheckFormFile checks the size and type of an uploaded photo or PDF.
+// AVIF photos are read by zbarimg only, they are accepted when avif is set,
+// with the zbar fallback configured.
+func CheckFormFile(file *multipart.FileHeader, avif bool) error {
 	fileType := file.Header.Get("Content-Type")
-	typeArray := []string{"image/jpeg", "image/jpg", "image/png", "image/avif"}
+	if fileType == "application/pdf" {
+		if file.Size > maxPdfSize {
+			return fmt.Errorf("file size is more then 10Mb: %d", file.Size)
+		}
+		return nil
+	}
+	if file.Size > maxImageSize {
+		return fmt.Errorf("file size is more then 5Mb: %d", file.Size)
+	}
+	if fileType == "image/avif" && !avif {
+		return fmt.Errorf("%w: AVIF images are read with the zbar fallback only (-zbar-fallback)", ErrUnsupportedType)
+	}
+	typeArray := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/avif"}
 	typeSupported := IndexOf(typeArray, fileType)
 	if typeSupported == -1 {
-		return errors.New("file type is not supported")
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, fileType)
 	}
 	return nil
 }
@@ -92,3 +123,17 @@ func UploadFileToServer(folderPath string, file *multipart.FileHeader) (string,
 
 	return dstPath, nil
 }
+
+// AuditSource sets the source of the changes made by a request,
+// the flutter API or the web pages
+func AuditSource(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		source := audit.SourceWeb
+		if strings.HasPrefix(c.Request().URL.Path, "/api/") {
+			source = audit.SourceAPI
+		}
+		req := c.Request()
+		c.SetRequest(req.WithContext(audit.WithSource(req.Context(), source)))
+		return next(c)
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+func TestCheckFormFile(t *testing.T) {
+	file := func(contentType string, size int64) *multipart.FileHeader {
+		return &multipart.FileHeader{
+			Header: textproto.MIMEHeader{"Content-Type": {contentType}},
+			Size:   size,
+		}
+	}
+	if err := CheckFormFile(file("image/jpeg", 1<<20), false); err != nil {
+		t.Errorf("Expected a JPEG accepted, got %v", err)
+	}
+	if err := CheckFormFile(file("image/avif", 1<<20), false); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected an AVIF refused without zbar, got %v", err)
+	}
+	if err := CheckFormFile(file("image/avif", 1<<20), true); err != nil {
+		t.Errorf("Expected an AVIF accepted with zbar, got %v", err)
+	}
+	if err := CheckFormFile(file("image/webp", 1<<20), true); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected a WebP refused, got %v", err)
+	}
+	if err := CheckFormFile(file("image/png", maxImageSize+1), true); err == nil || errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected a large image refused for its size, got %v", err)
+	}
+}
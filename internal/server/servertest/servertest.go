@@ -0,0 +1,245 @@
+// Package servertest runs the application in an httptest server for the
+// end-to-end tests of the handlers. The bills are kept by an in-memory
+// repository and the links are parsed by canned bills instead of the
+// tax sites. Parse jobs, diagnostics and the database check use a
+// SQLite file of the test's temporary directory.
+package servertest
+
+import (
+	"billdb/internal/bill"
+	"billdb/internal/bill/country"
+	"billdb/internal/bill/currency"
+	"billdb/internal/bill/item"
+	"billdb/internal/bill/tag"
+	"billdb/internal/parser"
+	"billdb/internal/repository/backup"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/check"
+	diagnosticRepository "billdb/internal/repository/diagnostic"
+	jobRepository "billdb/internal/repository/job"
+	"billdb/internal/server"
+	"billdb/internal/server/app"
+	"billdb/internal/worker"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Server is the application running in an httptest server
+type Server struct {
+	*httptest.Server
+	Config   *server.Config
+	BillRepo *repository.MemoryBillRepository
+	Jobs     *worker.Pool
+}
+
+// New starts the application with an empty repository,
+// it is closed when the test ends
+func New(t testing.TB) *Server {
+	t.Helper()
+	root := moduleRoot(t)
+	dir := t.TempDir()
+	cfg := &server.Config{
+		DbPath:        filepath.Join(dir, "billdb.sqlite"),
+		TemplatesPath: filepath.Join(root, "web", "templates"),
+		StaticPath:    filepath.Join(root, "web", "static"),
+		QrPath:        filepath.Join(dir, "qr"),
+		Port:          "0",
+	}
+	if err := os.Mkdir(cfg.QrPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := repository.OpenSqlite(cfg.DbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := repository.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+
+	billRepo := repository.NewMemoryBillRepository()
+	jobs := worker.NewPool(
+		jobRepository.NewSqliteJobRepository(db),
+		worker.NewParseHandler(billRepo),
+		1,
+		1,
+	)
+	e, err := app.New(cfg, app.Deps{
+		BillRepo:       billRepo,
+		Jobs:           jobs,
+		DiagnosticRepo: diagnosticRepository.NewSqliteDiagnosticRepository(db),
+		Checker:        check.New(db),
+		Backups:        backup.New(db, cfg.BackupDir(), backup.DefaultPolicy),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		jobs.Run(ctx)
+		close(done)
+	}()
+	s := &Server{
+		Server:   httptest.NewServer(e),
+		Config:   cfg,
+		BillRepo: billRepo,
+		Jobs:     jobs,
+	}
+	t.Cleanup(func() {
+		s.Close()
+		cancel()
+		<-done
+	})
+	return s
+}
+
+// moduleRoot returns the directory of go.mod, where the templates are
+func moduleRoot(t testing.TB) string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("no caller information for the module root")
+	}
+	return filepath.Join(filepath.Dir(file), "..", "..", "..")
+}
+
+// Get requests a path and returns the response with its body read
+func (s *Server) Get(t testing.TB, path string) (*http.Response, string) {
+	t.Helper()
+	return s.Do(t, http.MethodGet, path, "", nil)
+}
+
+// PostForm sends the form to a path
+func (s *Server) PostForm(t testing.TB, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	return s.Do(t, http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+// PostJSON sends the JSON document to a path
+func (s *Server) PostJSON(t testing.TB, path string, body string) (*http.Response, string) {
+	t.Helper()
+	return s.Do(t, http.MethodPost, path, "application/json", strings.NewReader(body))
+}
+
+// Do sends a request as htmx does and returns the response with its body read
+func (s *Server) Do(t testing.TB, method string, path string, contentType string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("HX-Request", "true")
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp, string(b)
+}
+
+// WaitFor polls cond until it returns true, failing the test after a while
+func WaitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Parser is a parser returning canned bills by link
+type Parser struct {
+	mu     sync.Mutex
+	bills  map[string]*bill.Bill
+	parsed map[string]bool
+	calls  int
+}
+
+// FakeParser makes the links starting with prefix parsed by the returned
+// parser for the rest of the test, links without a canned bill fail
+func FakeParser(t testing.TB, prefix string) *Parser {
+	p := &Parser{bills: make(map[string]*bill.Bill), parsed: make(map[string]bool)}
+	t.Cleanup(parser.Override(prefix, p))
+	return p
+}
+
+// Add makes the parser return the bill for its link
+func (p *Parser) Add(b *bill.Bill) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bills[b.Link] = b
+}
+
+// Calls returns how many links were parsed
+func (p *Parser) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func (p *Parser) Type() string {
+	return "fake"
+}
+
+// Parse returns a copy of the bill of the link. A link parsed again
+// gives new ids, as a parser fetching the receipt again does.
+func (p *Parser) Parse(link string) (*bill.Bill, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	b, ok := p.bills[link]
+	if !ok {
+		return nil, fmt.Errorf("no canned bill for %s", link)
+	}
+	c := *b
+	if p.parsed[link] {
+		c.Id = ksuid.New().String()
+	}
+	p.parsed[link] = true
+	c.Items = make([]*item.Item, len(b.Items))
+	for i, it := range b.Items {
+		itemCopy := *it
+		if c.Id != b.Id {
+			itemCopy.ItemId = ksuid.New().String()
+			itemCopy.BillId = c.Id
+		}
+		c.Items[i] = &itemCopy
+	}
+	return &c, nil
+}
+
+// NewBill returns a Serbian bill of the link with items of the names,
+// as a canned bill of Parser or a bill stored by a test
+func NewBill(link string, name string, price float64, items ...string) *bill.Bill {
+	id := ksuid.New().String()
+	b := bill.New(id, name, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), price,
+		currency.RSD, country.SERBIA, nil, tag.New(""), link, "")
+	for _, name := range items {
+		b.Items = append(b.Items, item.New(ksuid.New().String(), id, name, 10, 10, 1))
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"billdb/internal/repository/backup"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (w *WebHandlers) BackupsPage(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	return c.Render(http.StatusOK, "backups.html", map[string]any{
+		"dir":    w.Backups.Dir(),
+		"policy": w.Backups.Policy(),
+	})
+}
+
+func (w *WebHandlers) BackupsList(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	return w.renderBackups(c, "")
+}
+
+// BackupCreate backs up the database by hand
+func (w *WebHandlers) BackupCreate(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	b, err := w.Backups.Create(c.Request().Context(), backup.Manual)
+	if err != nil {
+		return w.renderBackups(c, fmt.Sprintf("Error backing up the database: %v", err))
+	}
+	return w.renderBackups(c, fmt.Sprintf("Created %s", b.Name))
+}
+
+func (w *WebHandlers) BackupDownload(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	path, err := w.Backups.Path(c.Param("name"))
+	if err != nil {
+		return err
+	}
+	return c.Attachment(path, c.Param("name"))
+}
+
+// BackupRestore replaces the database by a backup,
+// the current database is backed up first
+func (w *WebHandlers) BackupRestore(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	previous, err := w.Backups.Restore(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return w.renderBackups(c, fmt.Sprintf("Error restoring %s: %v", c.Param("name"), err))
+	}
+	return w.renderBackups(c, fmt.Sprintf("Restored %s, the previous database is kept as %s",
+		c.Param("name"), previous.Name))
+}
+
+func (w *WebHandlers) BackupDelete(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	if err := w.Backups.Delete(c.Param("name")); err != nil {
+		return w.renderBackups(c, fmt.Sprintf("Error deleting %s: %v", c.Param("name"), err))
+	}
+	return w.renderBackups(c, fmt.Sprintf("Deleted %s", c.Param("name")))
+}
+
+func (w *WebHandlers) renderBackups(c echo.Context, message string) error {
+	r := make(map[string]any)
+	r["success"] = false
+	r["message"] = message
+
+	backups, err := w.Backups.List()
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while listing the backups: %v", err)
+		return c.Render(http.StatusOK, "backups-list.html", r)
+	}
+	r["backups"] = backups
+	r["success"] = true
+	return c.Render(http.StatusOK, "backups-list.html", r)
+}
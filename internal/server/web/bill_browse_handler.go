@@ -1,9 +1,12 @@
 package web
 
 import (
+	bl "billdb/internal/bill"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/server"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -14,93 +17,40 @@ func (w *WebHandlers) BillBrowseLanding(c echo.Context) error {
 	return c.Redirect(http.StatusMovedPermanently, "/browse/bills/"+timeNow.Format("2006/01"))
 }
 
+// BillBrowse lists the bills of a month, a year or a date range,
+// a page at a time in the selected sort
 func (w *WebHandlers) BillBrowse(c echo.Context) error {
 	r := make(map[string]any)
 	r["success"] = false
 
-	year, err := strconv.ParseInt(c.Param("y"), 10, 64)
+	period, err := browsePeriodOf(c)
 	if err != nil {
-		r["message"] = fmt.Sprintf("Invalid year: %s | URL: %s", c.Param("y"), c.Request().URL)
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-bills.html", r)
 	}
-	month, err := strconv.ParseInt(c.Param("m"), 10, 64)
+	filter, err := server.BillFilterFromRequest(c)
 	if err != nil {
-		r["message"] = fmt.Sprintf("Invalid month: %s | URL: %s", c.Param("m"), c.Request().URL)
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-bills.html", r)
 	}
+	period.apply(&filter.From, &filter.To)
 
-	timeNow := time.Now()
-	timeRequested := time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	if timeRequested.After(timeNow) {
-		r["message"] = "Requested date is in the future"
+	page, err := w.BillRepo.SearchBills(c.Request().Context(), filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-bills.html", r)
 	}
-
-	queryBase := `SELECT
-				invoice.invoice_id, 
-				invoice_name, 
-				invoice_date, 
-				invoice_price, 
-				invoice_currency, 
-				invoice_country,
-				tag.tag_name
-			FROM
-				invoice
-			LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
-			LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
-			WHERE
-				strftime('%%Y-%%m', invoice_date) = '%d-%02d'
-			ORDER BY
-				invoice_date DESC;`
-	query := fmt.Sprintf(queryBase, year, month)
-
-	db := w.BillRepo.GetDb()
-	rows, err := db.Query(query)
 	if err != nil {
 		r["message"] = fmt.Sprintf("Error while querying the database: %v; Db path: %s", err, w.Config.DbPath)
 		return c.Render(http.StatusOK, "browse-bills.html", r)
 	}
-	defer rows.Close()
-
-	var billsResponse []BillRequest
-	for rows.Next() {
-		var (
-			Id       string
-			Name     string
-			Date     string
-			Price    float64
-			Currency string
-			Country  string
-			Tag      string
-		)
-		err = rows.Scan(&Id, &Name, &Date, &Price, &Currency, &Country, &Tag)
-		if err != nil {
-			r["message"] = "Error while scanning the database"
-			return c.Render(http.StatusOK, "browse-bills.html", r)
-		}
-		billsResponse = append(billsResponse, BillRequest{
-			Id:       Id,
-			Name:     Name,
-			Date:     Date,
-			Price:    Price,
-			Currency: Currency,
-			// TODO echange rate
-			ExchangeRate: 0,
-			Country:      Country,
-			Tag:          Tag,
-		})
-	}
-
-	nextMonth := timeRequested.AddDate(0, 1, 0)
-	if nextMonth.Before(timeNow) {
-		r["nextPage"] = c.Echo().Reverse("browse-bills", nextMonth.Year(), int(nextMonth.Month()))
+	bills := make([]*bl.Bill, len(page.Hits))
+	for i, hit := range page.Hits {
+		bills[i] = hit.Bill
 	}
-	prevMonth := timeRequested.AddDate(0, -1, 0)
-	r["prevPage"] = c.Echo().Reverse("browse-bills", prevMonth.Year(), int(prevMonth.Month()))
 
-	r["bills"] = billsResponse
-	r["year"] = year
-	r["month"] = fmt.Sprintf("%02d", month)
+	browseLinks(c, r, period, "browse-bills", "browse-items", billSorts, page.Next)
+	r["bills"] = billRows(bills)
 	r["success"] = true
 	return c.Render(http.StatusOK, "browse-bills.html", r)
 }
@@ -11,13 +11,13 @@ import (
 
 func (w *WebHandlers) BillEditPage(c echo.Context) error {
 	billId := c.Param("id")
-	billRequested, err := w.BillRepo.GetBillByID(billId)
+	billRequested, err := w.BillRepo.GetBillByID(c.Request().Context(), billId)
 	if err != nil {
 		return err
 	}
 	currencies := currency.Available()
 	countries := country.Available()
-	tags, err := w.BillRepo.GetTags()
+	tags, err := w.BillRepo.GetTags(c.Request().Context())
 	if err != nil {
 		return err
 	}
@@ -44,7 +44,7 @@ func (w *WebHandlers) BillEditSubmit(c echo.Context) error {
 	r := make(map[string]interface{})
 	r["success"] = false
 	billId := c.Param("id")
-	billEdited, err := w.BillRepo.GetBillByID(billId)
+	billEdited, err := w.BillRepo.GetBillByID(c.Request().Context(), billId)
 	if err != nil {
 		c.Logger().Errorf("Error getting bill by id: %v", err)
 		return err
@@ -83,7 +83,7 @@ func (w *WebHandlers) BillEditSubmit(c echo.Context) error {
 			)
 		}
 	}
-	err = w.BillRepo.UpdateBill(billEdited)
+	err = w.BillRepo.UpdateBill(c.Request().Context(), billEdited)
 	if err != nil {
 		c.Logger().Errorf("Error updating bill: %v", err)
 		r["error"] = "Error updating bill in db."
@@ -93,7 +93,7 @@ func (w *WebHandlers) BillEditSubmit(c echo.Context) error {
 			r,
 		)
 	}
-	billNew, err := w.BillRepo.GetBillByID(billId)
+	billNew, err := w.BillRepo.GetBillByID(c.Request().Context(), billId)
 	if err != nil {
 		c.Logger().Errorf("Error getting bill by id: %v", err)
 		return err
@@ -6,6 +6,7 @@ import (
 	"billdb/internal/bill/currency"
 	"billdb/internal/bill/item"
 	"billdb/internal/bill/tag"
+	"billdb/internal/repository/dedup"
 	"fmt"
 	"net/http"
 
@@ -27,7 +28,7 @@ type BillRequest struct {
 func (w *WebHandlers) BillFormPage(c echo.Context) error {
 	currencies := currency.Available()
 	countries := country.Available()
-	tags, err := w.BillRepo.GetTags()
+	tags, err := w.BillRepo.GetTags(c.Request().Context())
 	if err != nil {
 		return err
 	}
@@ -96,7 +97,7 @@ func (w *WebHandlers) BillFormSubmit(c echo.Context) error {
 		"",
 	)
 
-	billDupCount, err := w.BillRepo.CheckDuplicateBill(billNew)
+	duplicates, err := dedup.Find(c.Request().Context(), w.BillRepo, billNew)
 	if err != nil {
 		result["message"] = fmt.Sprintf("Error checking duplicates: %v", err)
 		r["results"] = append(r["results"].([]map[string]any), result)
@@ -104,15 +105,15 @@ func (w *WebHandlers) BillFormSubmit(c echo.Context) error {
 		return c.Render(http.StatusOK, responseHtml, r)
 	}
 
-	if billDupCount != 0 {
+	if len(duplicates) != 0 {
 		result["message"] = "Found duplicate bills in database"
-		result["dupInt"] = billDupCount
+		result["duplicates"] = duplicates
 		r["results"] = append(r["results"].([]map[string]any), result)
-		r["message"] = fmt.Sprintf("Found %d duplicate bill(s)", billDupCount)
+		r["message"] = fmt.Sprintf("Found %d duplicate bill(s)", len(duplicates))
 		return c.Render(http.StatusOK, responseHtml, r)
 	}
 
-	err = w.BillRepo.InsertBill(billNew)
+	err = w.BillRepo.InsertBill(c.Request().Context(), billNew)
 	if err != nil {
 		result["message"] = fmt.Sprintf("Error inserting bill to database: %v", err)
 		r["results"] = append(r["results"].([]map[string]any), result)
@@ -120,7 +121,7 @@ func (w *WebHandlers) BillFormSubmit(c echo.Context) error {
 		return c.Render(http.StatusOK, responseHtml, r)
 	}
 
-	billFromDb, err := w.BillRepo.GetBillByID(billNew.Id)
+	billFromDb, err := w.BillRepo.GetBillByID(c.Request().Context(), billNew.Id)
 	if err != nil {
 		result["message"] = fmt.Sprintf("Error retrieving bill from database: %v", err)
 		r["results"] = append(r["results"].([]map[string]any), result)
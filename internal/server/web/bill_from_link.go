@@ -1,7 +1,6 @@
 package web
 
 import (
-	"billdb/internal/parser"
 	"fmt"
 	"net/http"
 	"strings"
@@ -38,70 +37,27 @@ func (w *WebHandlers) BillFromLinkResponse(c echo.Context) error {
 		r["message"] = "No valid links provided"
 		return c.Render(http.StatusOK, "bill-insert-response.html", r)
 	}
-	// Process each valid link
-	successCount := 0
-	for _, link := range validLinks {
+	// Links are parsed in the background, here we only queue them
+	jobs, err := w.Jobs.Enqueue(validLinks...)
+	for _, j := range jobs {
 		// Truncate link for display
-		linkDisplay := link
-		if len(link) > 10 {
-			linkDisplay = "..." + link[len(link)-10:]
+		linkDisplay := j.Link
+		if len(j.Link) > 10 {
+			linkDisplay = "..." + j.Link[len(j.Link)-10:]
 		}
-
-		linkResult := map[string]any{
-			"link":        link,
+		r["results"] = append(r["results"].([]map[string]any), map[string]any{
+			"link":        j.Link,
 			"linkDisplay": linkDisplay,
-			"success":     false,
-		}
-		p, err := parser.GetBillParser(link)
-		if err != nil {
-			linkResult["message"] = err.Error()
-			r["results"] = append(r["results"].([]map[string]any), linkResult)
-			continue
-		}
-		dupCheck := false
-		if p.Type() == "rs" {
-			dupCount, err := w.BillRepo.CheckDuplicateBillByUrl(link)
-			if err != nil {
-				linkResult["message"] = err.Error()
-				r["results"] = append(r["results"].([]map[string]any), linkResult)
-				continue
-			}
-			if dupCount != 0 {
-				linkResult["message"] = fmt.Sprintf("Found %d duplicate bills", dupCount)
-				linkResult["dupInt"] = dupCount
-				r["results"] = append(r["results"].([]map[string]any), linkResult)
-				continue
-			}
-			dupCheck = true
-		}
-		b, err := p.Parse(link)
-		if err != nil {
-			linkResult["message"] = "Error while parsing the site"
-			r["results"] = append(r["results"].([]map[string]any), linkResult)
-			continue
-		}
-		if dupCheck {
-			err = w.BillRepo.InsertBillWithItems(b)
-			if err != nil {
-				linkResult["message"] = err.Error()
-				r["results"] = append(r["results"].([]map[string]any), linkResult)
-				continue
-			}
-		} else {
-			linkResult["message"] = "Duplicates was not checked"
-			r["results"] = append(r["results"].([]map[string]any), linkResult)
-			continue
-		}
-		linkResult["success"] = true
-		linkResult["message"] = "Bill parsed successfully"
-		linkResult["bill"] = b
-		successCount++
-		r["results"] = append(r["results"].([]map[string]any), linkResult)
+			"success":     true,
+			"message":     "Queued for parsing",
+		})
 	}
-	if successCount > 0 {
-		r["success"] = true
+	if err != nil {
+		r["message"] = fmt.Sprintf("Queued %d of %d links: %v", len(jobs), len(validLinks), err)
+		return c.Render(http.StatusOK, "bill-insert-response.html", r)
 	}
-	r["message"] = fmt.Sprintf("Processed %d links: %d successful, %d failed",
-		len(validLinks), successCount, len(validLinks)-successCount)
+	r["success"] = true
+	r["jobsPage"] = c.Echo().Reverse("jobs")
+	r["message"] = fmt.Sprintf("Queued %d links for parsing", len(jobs))
 	return c.Render(http.StatusOK, "bill-insert-response.html", r)
 }
@@ -3,7 +3,11 @@ package web
 import (
 	"billdb/internal/parser"
 	"billdb/internal/qrcode"
+	"billdb/internal/repository/dedup"
 	"billdb/internal/server"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -24,7 +28,11 @@ func (w *WebHandlers) BillFromQrUpload(c echo.Context) error {
 		"success": false,
 	}
 
-	err = server.CheckFormFile(file)
+	err = server.CheckFormFile(file, w.Config.ZbarFallback)
+	if errors.Is(err, server.ErrUnsupportedType) {
+		r["message"] = err.Error()
+		return c.Render(http.StatusUnsupportedMediaType, "bill-insert-response.html", r)
+	}
 	if err != nil {
 		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "bill-insert-response.html", r)
@@ -39,7 +47,8 @@ func (w *WebHandlers) BillFromQrUpload(c echo.Context) error {
 		return err
 	}
 
-	qrString, err := qrcode.ParseImage(qrFilepath)
+	// one photo can hold several receipts, a pdf several pages
+	qrStrings, err := qrcode.ParseFile(qrFilepath)
 	if err != nil {
 		r["message"] = err.Error()
 		r["qrPath"] = filepath.Base(qrFilepath)
@@ -51,63 +60,85 @@ func (w *WebHandlers) BillFromQrUpload(c echo.Context) error {
 		return err
 	}
 
-	p, err := parser.GetBillParser(qrString)
-	if err != nil {
-		return err
-	}
-
-	dupCheck := false
-	// check for duplicates by url
-	if p.Type() == "rs" {
-		dupCount, err := w.BillRepo.CheckDuplicateBillByUrl(qrString)
+	results := make([]map[string]any, 0, len(qrStrings))
+	inserted := 0
+	for _, qrString := range qrStrings {
+		result, err := w.insertFromQr(c.Request().Context(), qrString)
 		if err != nil {
 			return err
 		}
-		if dupCount != 0 {
-			r["success"] = false
-			r["message"] = "Found duplicate bills"
-			r["dupInt"] = dupCount
-			return c.Render(http.StatusOK, "bill-insert-response.html", r)
+		if result["success"] == true {
+			inserted++
 		}
-		dupCheck = true
+		results = append(results, result)
+	}
+
+	r["results"] = results
+	r["success"] = inserted == len(results)
+	if len(results) == 1 {
+		r["message"] = results[0]["message"]
+	} else {
+		r["message"] = fmt.Sprintf("Found %d QR codes, inserted %d bills", len(results), inserted)
+	}
+	return c.Render(http.StatusOK, "bill-insert-response.html", r)
+}
+
+// insertFromQr parses and stores the bill of one QR code.
+// Parse failures and duplicates are reported in the result,
+// the returned error is for storage failures only.
+func (w *WebHandlers) insertFromQr(ctx context.Context, qrString string) (map[string]any, error) {
+	// Truncate link for display
+	linkDisplay := qrString
+	if len(qrString) > 10 {
+		linkDisplay = "..." + qrString[len(qrString)-10:]
+	}
+	r := map[string]any{
+		"link":        qrString,
+		"linkDisplay": linkDisplay,
+		"success":     false,
+	}
+
+	p, err := parser.GetBillParser(qrString)
+	if err != nil {
+		r["message"] = err.Error()
+		return r, nil
+	}
+
+	// a receipt already stored is not fetched again
+	duplicates, err := dedup.FindLink(ctx, w.BillRepo, qrString)
+	if err != nil {
+		return nil, err
+	}
+	if len(duplicates) != 0 {
+		r["message"] = "The bill is already stored"
+		r["duplicates"] = duplicates
+		return r, nil
 	}
 
 	b, err := p.Parse(qrString)
 	if err != nil {
-		r["success"] = false
 		r["message"] = "Error while parsing the site"
-		return c.Render(http.StatusOK, "bill-insert-response.html", r)
+		return r, nil
 	}
 
-	// if duplicates was not checked earlier
-	// check it with parsed data
-	if !dupCheck {
-		dupCount, err := w.BillRepo.CheckDuplicateBill(b)
-		if err != nil {
-			return err
-		}
-		if dupCount != 0 {
-			r["success"] = false
-			r["message"] = "Found duplicate bills"
-			r["dupInt"] = dupCount
-			return c.Render(http.StatusOK, "bill-insert-response.html", r)
-		}
-		dupCheck = true
+	duplicates, err = dedup.Find(ctx, w.BillRepo, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(duplicates) != 0 {
+		r["message"] = "Found duplicate bills"
+		r["duplicates"] = duplicates
+		r["bill"] = b
+		return r, nil
 	}
 
-	if dupCheck {
-		err = w.BillRepo.InsertBillWithItems(b)
-		if err != nil {
-			return err
-		}
-	} else {
-		r["success"] = false
-		r["message"] = "Duplicates was not checked"
-		return c.Render(http.StatusOK, "bill-insert-response.html", r)
+	err = w.BillRepo.InsertBillWithItems(ctx, b)
+	if err != nil {
+		return nil, err
 	}
 
 	r["success"] = true
 	r["message"] = "Bill parsed successfully"
 	r["bill"] = b
-	return c.Render(http.StatusOK, "bill-insert-response.html", r)
+	return r, nil
 }
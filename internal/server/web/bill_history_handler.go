@@ -0,0 +1,53 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BillHistoryPage shows the changes of a bill, of the trash
+// and of purged bills too
+func (w *WebHandlers) BillHistoryPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "bill-history.html", map[string]any{
+		"id": c.Param("id"),
+	})
+}
+
+func (w *WebHandlers) BillHistoryList(c echo.Context) error {
+	return w.renderHistory(c, c.Param("id"), "")
+}
+
+// AuditRevert undoes a single change and shows the history of its bill
+func (w *WebHandlers) AuditRevert(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid change id")
+	}
+	e, err := w.BillRepo.GetAuditEntry(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	err = w.BillRepo.Revert(c.Request().Context(), id)
+	if err != nil {
+		return w.renderHistory(c, e.BillId, fmt.Sprintf("Error reverting the change: %v", err))
+	}
+	return w.renderHistory(c, e.BillId, "Change reverted")
+}
+
+func (w *WebHandlers) renderHistory(c echo.Context, billId string, message string) error {
+	r := make(map[string]any)
+	r["success"] = false
+	r["message"] = message
+
+	entries, err := w.BillRepo.History(c.Request().Context(), billId)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying the history: %v", err)
+		return c.Render(http.StatusOK, "bill-history-list.html", r)
+	}
+	r["entries"] = entries
+	r["success"] = true
+	return c.Render(http.StatusOK, "bill-history-list.html", r)
+}
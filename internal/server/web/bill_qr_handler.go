@@ -0,0 +1,63 @@
+package web
+
+import (
+	"billdb/internal/qrcode"
+	"billdb/internal/server"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BillQr renders the verification link of the bill as a QR code.
+// Query parameters: format (png or svg, png by default) and size in pixels for png.
+func (w *WebHandlers) BillQr(c echo.Context) error {
+	bill, err := w.BillRepo.GetBillByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	payload, err := qrcode.Payload(bill)
+	if errors.Is(err, qrcode.ErrNoLink) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return server.WriteQr(c, payload)
+}
+
+// BillCard is a printable card with the bill summary and its QR code
+func (w *WebHandlers) BillCard(c echo.Context) error {
+	id := c.Param("id")
+	bill, err := w.BillRepo.GetBillByID(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	items, err := w.BillRepo.GetItemsByID(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	r := map[string]any{
+		"id":       bill.Id,
+		"date":     bill.GetDateString(),
+		"name":     bill.Name,
+		"price":    bill.Price,
+		"currency": bill.GetCurrencyString(),
+		"country":  bill.GetCountryString(),
+		"items":    items,
+	}
+	payload, err := qrcode.Payload(bill)
+	if err != nil {
+		r["qrError"] = err.Error()
+		return c.Render(http.StatusOK, "bill-card.html", r)
+	}
+	svg, err := qrcode.EncodeSVG(payload)
+	if err != nil {
+		return err
+	}
+	// generated by us, safe to inline
+	r["qr"] = template.HTML(svg)
+	r["payload"] = payload
+	return c.Render(http.StatusOK, "bill-card.html", r)
+}
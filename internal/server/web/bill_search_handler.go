@@ -1,7 +1,13 @@
 package web
 
 import (
+	bl "billdb/internal/bill"
+	"billdb/internal/bill/tag"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/server"
+	"html/template"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -14,67 +20,68 @@ func (w *WebHandlers) BillSearchQueary(c echo.Context) error {
 	r := make(map[string]any)
 	r["success"] = false
 
-	queryBase := `SELECT
-				invoice.invoice_id, 
-				invoice_name, 
-				invoice_date, 
-				invoice_price, 
-				invoice_currency, 
-				invoice_country,
-				tag.tag_name
-			FROM
-				invoice
-			LEFT JOIN invoice_tag ON invoice_tag.invoice_id = invoice.invoice_id
-			LEFT JOIN tag ON tag.tag_id = invoice_tag.tag_id
-			WHERE
-					invoice_name LIKE ?
-					OR tag.tag_name LIKE ?
-					OR invoice_date LIKE ?
-					OR invoice_currency LIKE ?
-					OR invoice_country LIKE ?
-			ORDER BY
-				invoice_date DESC;`
-
-	q := "%" + c.FormValue("q") + "%"
-
-	db := w.BillRepo.GetDb()
-	rows, err := db.Query(queryBase, q, q, q, q, q)
+	filter, err := server.BillFilterFromRequest(c)
+	if err != nil {
+		r["message"] = err.Error()
+		return c.Render(http.StatusOK, "search-bills-result.html", r)
+	}
+	page, err := w.BillRepo.SearchBills(c.Request().Context(), filter)
 	if err != nil {
 		r["message"] = "Error while querying the database"
 		return c.Render(http.StatusOK, "search-bills-result.html", r)
 	}
-	defer rows.Close()
 
-	var result []BillRequest
-	for rows.Next() {
-		var (
-			Id       string
-			Name     string
-			Date     string
-			Price    float64
-			Currency string
-			Country  string
-			Tag      string
-		)
-		rows.Scan(&Id, &Name, &Date, &Price, &Currency, &Country, &Tag)
-		if err != nil {
-			r["message"] = "Error while scanning the database"
-			return c.Render(http.StatusOK, "search-bills-result.html", r)
-		}
-		result = append(result, BillRequest{
-			Id:       Id,
-			Name:     Name,
-			Date:     Date,
-			Price:    Price,
-			Currency: Currency,
+	hits := page.Hits
+	bills := make([]*bl.Bill, len(hits))
+	for i, hit := range hits {
+		bills[i] = hit.Bill
+	}
+	rows := make([]BillSearchRow, len(hits))
+	for i, row := range billRows(bills) {
+		rows[i] = BillSearchRow{BillRequest: row, Snippet: highlight(hits[i].Snippet)}
+	}
+	r["result"] = rows
+	r["next"] = page.Next
+	r["success"] = true
+	return c.Render(http.StatusOK, "search-bills-result.html", r)
+}
+
+// BillSearchRow is a row of the search results with the matched text
+type BillSearchRow struct {
+	BillRequest
+	Snippet template.HTML
+}
+
+// highlight escapes a search snippet and marks the matches
+func highlight(snippet string) template.HTML {
+	s := template.HTMLEscapeString(snippet)
+	s = strings.ReplaceAll(s, repository.SnippetStart, "<mark>")
+	s = strings.ReplaceAll(s, repository.SnippetEnd, "</mark>")
+	return template.HTML(s)
+}
+
+// billRows converts bills to the rows of the bill tables
+func billRows(bills []*bl.Bill) []BillRequest {
+	rows := make([]BillRequest, 0, len(bills))
+	for _, b := range bills {
+		rows = append(rows, BillRequest{
+			Id:       b.Id,
+			Name:     b.Name,
+			Date:     b.GetDateString(),
+			Price:    b.Price,
+			Currency: b.GetCurrencyString(),
 			// TODO exchange rate system
 			ExchangeRate: 0,
-			Country:      Country,
-			Tag:          Tag,
+			Country:      b.GetCountryString(),
+			Tag:          tagString(b.Tag),
 		})
 	}
+	return rows
+}
 
-	r["result"] = result
-	r["success"] = true
-	return c.Render(http.StatusOK, "search-bills-result.html", r)
+func tagString(t *tag.Tag) string {
+	if t == nil || !t.Valid {
+		return ""
+	}
+	return t.String
 }
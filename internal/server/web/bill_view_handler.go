@@ -8,11 +8,11 @@ import (
 
 func (w *WebHandlers) BillView(c echo.Context) error {
 	id := c.Param("id")
-	bill, err := w.BillRepo.GetBillByID(id)
+	bill, err := w.BillRepo.GetBillByID(c.Request().Context(), id)
 	if err != nil {
 		return err
 	}
-	items, err := w.BillRepo.GetItemsByID(id)
+	items, err := w.BillRepo.GetItemsByID(c.Request().Context(), id)
 	if err != nil {
 		return err
 	}
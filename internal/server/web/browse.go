@@ -0,0 +1,157 @@
+package web
+
+import (
+	repository "billdb/internal/repository/bill"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// browsePeriod is the period of a browse page: a month (/:y/:m),
+// a year (/:y) or the from and to parameters (/range)
+type browsePeriod struct {
+	year  int
+	month time.Month
+	from  time.Time
+	to    time.Time
+}
+
+// browsePeriodOf reads the period of the request, from and to
+// are left zero for ranges, the filter reads them
+func browsePeriodOf(c echo.Context) (*browsePeriod, error) {
+	p := &browsePeriod{}
+	if c.Param("y") == "" {
+		return p, nil
+	}
+	year, err := strconv.Atoi(c.Param("y"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid year: %s | URL: %s", c.Param("y"), c.Request().URL)
+	}
+	p.year = year
+	p.from = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	p.to = p.from.AddDate(1, 0, 0)
+	if c.Param("m") != "" {
+		month, err := strconv.Atoi(c.Param("m"))
+		if err != nil || month < 1 || month > 12 {
+			return nil, fmt.Errorf("Invalid month: %s | URL: %s", c.Param("m"), c.Request().URL)
+		}
+		p.month = time.Month(month)
+		p.from, p.to = repository.MonthRange(year, p.month)
+	}
+	if p.from.After(time.Now()) {
+		return nil, fmt.Errorf("Requested date is in the future")
+	}
+	return p, nil
+}
+
+// apply sets the period to the filter bounds, ranges keep the filter's
+func (p *browsePeriod) apply(from *time.Time, to *time.Time) {
+	if p.year != 0 {
+		*from, *to = p.from, p.to
+	}
+}
+
+func (p *browsePeriod) title(c echo.Context) string {
+	switch {
+	case p.month != 0:
+		return fmt.Sprintf("%d-%02d", p.year, p.month)
+	case p.year != 0:
+		return strconv.Itoa(p.year)
+	}
+	from, to := c.FormValue("from"), c.FormValue("to")
+	if from == "" && to == "" {
+		return "all time"
+	}
+	return from + " – " + to
+}
+
+// url returns the page of the period in the browse view base,
+// "browse-bills" or "browse-items", shifted by step periods
+func (p *browsePeriod) url(c echo.Context, base string, step int) string {
+	switch {
+	case p.month != 0:
+		t := p.from.AddDate(0, step, 0)
+		return c.Echo().Reverse(base, t.Year(), int(t.Month()))
+	case p.year != 0:
+		return c.Echo().Reverse(base+"-year", p.year+step)
+	}
+	return withQuery(c, c.Echo().Reverse(base+"-range"), nil)
+}
+
+// browseLinks sets the links of a browse page: the neighbour periods,
+// the other view of the period, the sorts and the next page
+func browseLinks(c echo.Context, r map[string]any, p *browsePeriod, base string, other string, sorts []sortOption, next string) {
+	r["title"] = p.title(c)
+	r["from"], r["to"] = c.FormValue("from"), c.FormValue("to")
+	if p.year != 0 {
+		r["from"], r["to"] = p.from.Format("2006-01-02"), p.to.AddDate(0, 0, -1).Format("2006-01-02")
+		r["prevPage"] = p.url(c, base, -1)
+		if p.to.Before(time.Now()) {
+			r["nextPage"] = p.url(c, base, 1)
+		}
+	}
+	if p.month != 0 {
+		r["yearPage"] = c.Echo().Reverse(base+"-year", p.year)
+	}
+	r["otherPage"] = p.url(c, other, 0)
+	r["rangePage"] = c.Echo().Reverse(base + "-range")
+	r["sorts"] = sortLinks(c, sorts)
+	if next != "" {
+		r["morePage"] = withQuery(c, c.Request().URL.Path, map[string]string{"cursor": next})
+	}
+}
+
+type sortOption struct {
+	Sort  repository.Sort
+	Label string
+}
+
+var billSorts = []sortOption{
+	{repository.SortDateDesc, "newest"},
+	{repository.SortDateAsc, "oldest"},
+	{repository.SortPriceDesc, "most expensive"},
+	{repository.SortPriceAsc, "cheapest"},
+	{repository.SortMerchant, "merchant"},
+}
+
+var itemSorts = append(billSorts, sortOption{repository.SortName, "name"})
+
+// sortLinks returns the links to the first page of every sort
+func sortLinks(c echo.Context, sorts []sortOption) []map[string]any {
+	current := c.FormValue("sort")
+	if current == "" {
+		current = repository.SortDateDesc.String()
+	}
+	links := make([]map[string]any, len(sorts))
+	for i, s := range sorts {
+		links[i] = map[string]any{
+			"Label":  s.Label,
+			"Url":    withQuery(c, c.Request().URL.Path, map[string]string{"sort": s.Sort.String(), "cursor": ""}),
+			"Active": s.Sort.String() == current,
+		}
+	}
+	return links
+}
+
+// withQuery returns path with the query of the request,
+// the values of set replaced and removed when empty
+func withQuery(c echo.Context, path string, set map[string]string) string {
+	query := url.Values{}
+	for k, v := range c.QueryParams() {
+		query[k] = v
+	}
+	for k, v := range set {
+		if v == "" {
+			query.Del(k)
+		} else {
+			query.Set(k, v)
+		}
+	}
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
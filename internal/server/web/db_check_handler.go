@@ -0,0 +1,50 @@
+package web
+
+import (
+	"billdb/internal/repository/check"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CheckSummary struct {
+	Kind  check.Kind
+	Count int
+}
+
+func (w *WebHandlers) DbCheckPage(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	report, err := w.Checker.Run(c.Request().Context())
+	return w.renderDbCheck(c, report, err, false)
+}
+
+// DbCheckRepair applies the automatic repairs and shows what is left
+func (w *WebHandlers) DbCheckRepair(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	report, err := w.Checker.Repair(c.Request().Context())
+	return w.renderDbCheck(c, report, err, true)
+}
+
+func (w *WebHandlers) renderDbCheck(c echo.Context, report *check.Report, err error, repaired bool) error {
+	r := make(map[string]any)
+	r["success"] = false
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while checking the database: %v", err)
+		return c.Render(http.StatusOK, "db-check.html", r)
+	}
+
+	summary := make([]CheckSummary, 0, len(check.Kinds))
+	for _, kind := range check.Kinds {
+		summary = append(summary, CheckSummary{Kind: kind, Count: report.Count(kind)})
+	}
+	r["summary"] = summary
+	r["report"] = report
+	r["repaired"] = repaired
+	r["success"] = true
+	return c.Render(http.StatusOK, "db-check.html", r)
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"billdb/internal/repository/backup"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/merge"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (w *WebHandlers) MergeDb(c echo.Context) error {
+	return c.Render(http.StatusOK, "db-merge.html", map[string]any{})
+}
+
+// MergeDbSubmit adds the bills of the uploaded database missing from
+// this one and shows the merge report. The SQLite database is backed
+// up first.
+func (w *WebHandlers) MergeDbSubmit(c echo.Context) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "no file uploaded")
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	ctx := c.Request().Context()
+	failed := func(format string, err error) error {
+		return c.Render(http.StatusOK, "db-merge.html", map[string]any{
+			"message": fmt.Sprintf(format, err),
+		})
+	}
+	staged, err := w.Backups.Stage(ctx, src)
+	if err != nil {
+		return failed("The file can't be used: %v", err)
+	}
+	defer w.Backups.Discard(staged.Token)
+	db, err := w.Backups.OpenStaged(ctx, staged.Token)
+	if err != nil {
+		return failed("The file can't be used: %v", err)
+	}
+	defer db.Close()
+
+	r := make(map[string]any)
+	if w.Config.PostgresDsn == "" {
+		previous, err := w.Backups.Create(ctx, backup.Merge)
+		if err != nil {
+			return failed("Error backing up the database: %v", err)
+		}
+		r["backup"] = previous.Name
+	}
+	report, err := merge.Merge(ctx, w.BillRepo, repository.NewSqliteBillRepository(db))
+	if err != nil {
+		return failed("Error merging the databases, nothing was changed: %v", err)
+	}
+
+	var counts []map[string]any
+	for _, o := range merge.Outcomes {
+		counts = append(counts, map[string]any{"outcome": o, "count": report.Count(o)})
+	}
+	r["counts"] = counts
+	r["report"] = report
+	r["conflicts"] = report.Of(merge.Conflict)
+	r["matched"] = report.Of(merge.Matched)
+	r["inserted"] = report.Of(merge.Inserted)
+	r["tagged"] = report.Of(merge.Tagged)
+	return c.Render(http.StatusOK, "db-merge-report.html", r)
+}
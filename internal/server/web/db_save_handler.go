@@ -2,13 +2,24 @@ package web
 
 import (
 	"fmt"
+	"net/http"
+	"os"
 	"path"
+	"path/filepath"
 	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// errPostgresDb is returned by the handlers of the SQLite file
+// when the bills are stored in PostgreSQL
+var errPostgresDb = echo.NewHTTPError(http.StatusNotImplemented,
+	"the bills are stored in PostgreSQL, back them up with pg_dump")
+
 func (w *WebHandlers) SaveDb(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
 	filenameTemplate := w.Config.DbFileNameTemplate
 	if filenameTemplate == "" {
 		filenameTemplate = "billdb-20060102"
@@ -23,8 +34,20 @@ func (w *WebHandlers) SaveDb(c echo.Context) error {
 	c.Response().Header().Set("Pragma", "no-cache")
 	c.Response().Header().Set("Expires", "0")
 
+	// copy the database with the backup API, the file itself
+	// may be torn by a concurrent write
+	tmp, err := os.MkdirTemp("", "billdb-save-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	copyPath := filepath.Join(tmp, filename)
+	if err := w.Backups.Copy(c.Request().Context(), copyPath); err != nil {
+		return err
+	}
+
 	c.Response().Header().Set("Content-Disposition",
 		fmt.Sprintf(`attachment; filename="%s"`, filename))
 
-	return c.File(w.Config.DbPath)
+	return c.File(copyPath)
 }
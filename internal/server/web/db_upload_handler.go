@@ -1,22 +1,38 @@
 package web
 
 import (
-	"io"
+	"billdb/internal/repository/backup"
+	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/labstack/echo/v4"
 )
 
+// uploadChange is a row of the upload preview,
+// comparing a stat of the live and the uploaded database
+type uploadChange struct {
+	Name    string
+	Current string
+	Upload  string
+	Change  string
+}
+
 func (w *WebHandlers) UploadDb(c echo.Context) error {
-	return c.Render(http.StatusOK, "db-upload.html", nil)
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	return c.Render(http.StatusOK, "db-upload.html", map[string]any{})
 }
 
+// UploadDbSubmit keeps the uploaded database aside and shows how it
+// differs from the live one, it replaces the live one once confirmed
 func (w *WebHandlers) UploadDbSubmit(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
 	file, err := c.FormFile("file")
 	if err != nil {
-		return err
+		return echo.NewHTTPError(http.StatusBadRequest, "no file uploaded")
 	}
 	src, err := file.Open()
 	if err != nil {
@@ -24,38 +40,73 @@ func (w *WebHandlers) UploadDbSubmit(c echo.Context) error {
 	}
 	defer src.Close()
 
-	tmpDir := os.TempDir()
-	dstPath := filepath.Join(tmpDir, file.Filename)
-	dst, err := os.Create(dstPath)
+	ctx := c.Request().Context()
+	staged, err := w.Backups.Stage(ctx, src)
 	if err != nil {
-		return err
+		return c.Render(http.StatusOK, "db-upload.html", map[string]any{
+			"message": fmt.Sprintf("The file can't be used: %v", err),
+		})
 	}
-	defer dst.Close()
-
-	if _, err = io.Copy(dst, src); err != nil {
-		return err
-	}
-	src.Close()
-	dst.Close()
-
-	dbCurrent, err := os.Create(w.Config.DbPath)
+	current, err := w.Backups.Stats(ctx)
 	if err != nil {
+		w.Backups.Discard(staged.Token)
 		return err
 	}
+	return c.Render(http.StatusOK, "db-upload-preview.html", map[string]any{
+		"token":   staged.Token,
+		"pending": staged.Stats.Pending,
+		"changes": uploadChanges(current, staged.Stats),
+	})
+}
 
-	dbNew, err := os.Open(dstPath)
+// UploadDbConfirm replaces the live database by the upload,
+// the live one is backed up first
+func (w *WebHandlers) UploadDbConfirm(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
+	}
+	previous, err := w.Backups.Apply(c.Request().Context(), c.Param("token"))
 	if err != nil {
-		return err
+		return c.Render(http.StatusOK, "db-upload.html", map[string]any{
+			"message": fmt.Sprintf("Error replacing the database: %v", err),
+		})
 	}
+	return c.Render(http.StatusOK, "db-upload.html", map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("The uploaded database is in use, the previous one is kept as %s", previous.Name),
+	})
+}
 
-	if _, err = io.Copy(dbCurrent, dbNew); err != nil {
-		return err
+func (w *WebHandlers) UploadDbCancel(c echo.Context) error {
+	if w.Config.PostgresDsn != "" {
+		return errPostgresDb
 	}
-
-	err = os.Remove(dstPath)
-	if err != nil {
+	if err := w.Backups.Discard(c.Param("token")); err != nil {
 		return err
 	}
+	return c.Redirect(http.StatusSeeOther, c.Echo().Reverse("db-upload"))
+}
 
-	return c.Redirect(http.StatusFound, "/")
+func uploadChanges(current *backup.Stats, upload *backup.Stats) []uploadChange {
+	count := func(name string, current int, upload int) uploadChange {
+		change := ""
+		if upload != current {
+			change = fmt.Sprintf("%+d", upload-current)
+		}
+		return uploadChange{name, fmt.Sprint(current), fmt.Sprint(upload), change}
+	}
+	date := func(name string, current string, upload string) uploadChange {
+		change := ""
+		if upload != current {
+			change = "changed"
+		}
+		return uploadChange{name, current, upload, change}
+	}
+	return []uploadChange{
+		count("Bills", current.Bills, upload.Bills),
+		count("Items", current.Items, upload.Items),
+		count("Tags", current.Tags, upload.Tags),
+		date("Oldest bill", current.First, upload.First),
+		date("Newest bill", current.Last, upload.Last),
+	}
 }
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"billdb/internal/repository/dedup"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (w *WebHandlers) DuplicatesPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "duplicates.html", map[string]any{})
+}
+
+func (w *WebHandlers) DuplicatesList(c echo.Context) error {
+	return w.renderDuplicates(c, "")
+}
+
+// DuplicateMerge keeps the bill keep of a pair and moves drop to the trash
+func (w *WebHandlers) DuplicateMerge(c echo.Context) error {
+	kept, err := dedup.Merge(c.Request().Context(), w.BillRepo, c.FormValue("keep"), c.FormValue("drop"))
+	if err != nil {
+		return w.renderDuplicates(c, fmt.Sprintf("Error merging the bills: %v", err))
+	}
+	return w.renderDuplicates(c, fmt.Sprintf("Kept %s of %s, the other bill is in the trash", kept.Name, kept.GetDateString()))
+}
+
+// DuplicateDismiss hides a pair of different receipts from the review
+func (w *WebHandlers) DuplicateDismiss(c echo.Context) error {
+	err := w.BillRepo.DismissDuplicate(c.Request().Context(), c.FormValue("id"), c.FormValue("other"))
+	if err != nil {
+		return w.renderDuplicates(c, fmt.Sprintf("Error dismissing the bills: %v", err))
+	}
+	return w.renderDuplicates(c, "The bills are not duplicates")
+}
+
+func (w *WebHandlers) renderDuplicates(c echo.Context, message string) error {
+	r := make(map[string]any)
+	r["success"] = false
+	r["message"] = message
+
+	pairs, err := dedup.Review(c.Request().Context(), w.BillRepo)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while looking for duplicates: %v", err)
+		return c.Render(http.StatusOK, "duplicates-list.html", r)
+	}
+	r["pairs"] = pairs
+	r["success"] = true
+	return c.Render(http.StatusOK, "duplicates-list.html", r)
+}
@@ -1,101 +1,50 @@
 package web
 
 import (
-	"fmt"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/server"
+	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// ItemsBrowse lists the items of a month, a year or a date range,
+// a page at a time in the selected sort
 func (w *WebHandlers) ItemsBrowse(c echo.Context) error {
 	r := make(map[string]any)
 	r["success"] = false
+	r["CurrentMonthItemsPage"] = "/browse/items/" + time.Now().Format("2006/01")
 
-	year, err := strconv.ParseInt(c.Param("y"), 10, 64)
+	period, err := browsePeriodOf(c)
 	if err != nil {
-		r["message"] = fmt.Sprintf("Invalid year: %s | URL: %s", c.Param("y"), c.Request().URL)
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-items.html", r)
 	}
-	month, err := strconv.ParseInt(c.Param("m"), 10, 64)
+	filter, err := server.ItemFilterFromRequest(c)
 	if err != nil {
-		r["message"] = fmt.Sprintf("Invalid month: %s | URL: %s", c.Param("m"), c.Request().URL)
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-items.html", r)
 	}
+	period.apply(&filter.From, &filter.To)
 
-	timeNow := time.Now()
-	timeRequested := time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	if timeRequested.After(timeNow) {
-		r["message"] = "Requested date is in the future"
+	page, err := w.BillRepo.SearchItems(c.Request().Context(), filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		r["message"] = err.Error()
 		return c.Render(http.StatusOK, "browse-items.html", r)
 	}
-
-	queryBase := `SELECT
-				invoice.invoice_id, 
-				item_name, 
-				invoice_date, 
-				item_price, 
-				item_price_one, 
-				item_quantity, 
-				tag.tag_name
-			FROM
-				item
-			LEFT JOIN invoice ON item.invoice_id = invoice.invoice_id
-			LEFT JOIN item_tag ON item_tag.item_id = item.item_id
-			LEFT JOIN tag ON tag.tag_id = item_tag.tag_id
-			WHERE
-				strftime('%%Y-%%m', invoice_date) = '%d-%02d'
-			ORDER BY
-				invoice_date DESC;`
-	query := fmt.Sprintf(queryBase, year, month)
-
-	db := w.BillRepo.GetDb()
-	rows, err := db.Query(query)
 	if err != nil {
 		r["message"] = "Error while querying the database"
 		return c.Render(http.StatusOK, "browse-items.html", r)
 	}
-	defer rows.Close()
-
-	var itemsResponse []map[string]interface{}
-	for rows.Next() {
-		var (
-			Id       string
-			Name     string
-			Date     string
-			Price    float64
-			PriceOne string
-			Quantity float64
-			Tag      *string
-		)
-		rows.Scan(&Id, &Name, &Date, &Price, &PriceOne, &Quantity, &Tag)
-		if err != nil {
-			r["message"] = "Error while scanning the database"
-			return c.Render(http.StatusOK, "browse-items.html", r)
-		}
-		itemsResponse = append(itemsResponse, map[string]interface{}{
-			"Id":       Id,
-			"Name":     Name,
-			"Date":     Date,
-			"Price":    Price,
-			"PriceOne": PriceOne,
-			"Quantity": Quantity,
-			"Tag":      Tag,
-		})
-	}
-
-	nextMonth := timeRequested.AddDate(0, 1, 0)
-	if nextMonth.Before(timeNow) {
-		r["nextPage"] = c.Echo().Reverse("browse-items", nextMonth.Year(), int(nextMonth.Month()))
+	items := make([]*repository.ItemWithBill, len(page.Hits))
+	for i, hit := range page.Hits {
+		items[i] = hit.ItemWithBill
 	}
-	prevMonth := timeRequested.AddDate(0, -1, 0)
-	r["prevPage"] = c.Echo().Reverse("browse-items", prevMonth.Year(), int(prevMonth.Month()))
 
-	r["items"] = itemsResponse
-	r["CurrentMonthItemsPage"] = "/browse/bills/" + timeNow.Format("2006/01")
-	r["year"] = year
-	r["month"] = fmt.Sprintf("%02d", month)
+	browseLinks(c, r, period, "browse-items", "browse-bills", itemSorts, page.Next)
+	r["items"] = itemRows(items)
 	r["success"] = true
 	return c.Render(http.StatusOK, "browse-items.html", r)
 }
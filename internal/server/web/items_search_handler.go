@@ -1,6 +1,8 @@
 package web
 
 import (
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/server"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -14,63 +16,46 @@ func (w *WebHandlers) ItemsSearchQueary(c echo.Context) error {
 	r := make(map[string]any)
 	r["success"] = false
 
-	queryBase := `SELECT
-				invoice.invoice_id, 
-				item_name, 
-				invoice_date, 
-				item_price, 
-				item_price_one, 
-				item_quantity, 
-				tag.tag_name
-			FROM
-				item
-			LEFT JOIN invoice ON item.invoice_id = invoice.invoice_id
-			LEFT JOIN item_tag ON item_tag.item_id = item.item_id
-			LEFT JOIN tag ON tag.tag_id = item_tag.tag_id
-			WHERE
-				item_name LIKE ?
-				OR tag.tag_name LIKE ?
-				OR invoice_date LIKE ?
-			ORDER BY
-				invoice_date DESC;`
-	q := "%" + c.FormValue("q") + "%"
-
-	db := w.BillRepo.GetDb()
-	rows, err := db.Query(queryBase, q, q, q)
+	filter, err := server.ItemFilterFromRequest(c)
+	if err != nil {
+		r["message"] = err.Error()
+		return c.Render(http.StatusOK, "search-items-result.html", r)
+	}
+	page, err := w.BillRepo.SearchItems(c.Request().Context(), filter)
 	if err != nil {
 		r["message"] = "Error while querying the database"
 		return c.Render(http.StatusOK, "search-items-result.html", r)
 	}
-	defer rows.Close()
 
-	var result []map[string]any
-	for rows.Next() {
-		var (
-			Id       string
-			Name     string
-			Date     string
-			Price    float64
-			PriceOne string
-			Quantity string
-			Tag      *string
-		)
-		err = rows.Scan(&Id, &Name, &Date, &Price, &PriceOne, &Quantity, &Tag)
-		if err != nil {
-			r["message"] = "Error while scanning the database"
-			return c.Render(http.StatusOK, "search-items-result.html", r)
-		}
-		result = append(result, map[string]any{
-			"Id":       Id,
-			"Name":     Name,
-			"Date":     Date,
-			"Price":    Price,
-			"PriceOne": PriceOne,
-			"Quantity": Quantity,
-			"Tag":      Tag,
-		})
+	hits := page.Hits
+	items := make([]*repository.ItemWithBill, len(hits))
+	for i, hit := range hits {
+		items[i] = hit.ItemWithBill
 	}
-
-	r["result"] = result
+	rows := itemRows(items)
+	for i, row := range rows {
+		row["Snippet"] = highlight(hits[i].Snippet)
+	}
+	r["result"] = rows
+	r["next"] = page.Next
 	r["success"] = true
 	return c.Render(http.StatusOK, "search-items-result.html", r)
 }
+
+// itemRows converts items to the rows of the item tables,
+// Id is the bill id for the links to the bill
+func itemRows(items []*repository.ItemWithBill) []map[string]any {
+	rows := make([]map[string]any, 0, len(items))
+	for _, it := range items {
+		rows = append(rows, map[string]any{
+			"Id":       it.BillId,
+			"Name":     it.Name,
+			"Date":     it.GetDateString(),
+			"Price":    it.Price,
+			"PriceOne": it.PriceOne,
+			"Quantity": it.Quantity,
+			"Tag":      tagString(it.Tag),
+		})
+	}
+	return rows
+}
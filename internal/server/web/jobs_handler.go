@@ -0,0 +1,55 @@
+package web
+
+import (
+	"billdb/internal/job"
+	jobRepository "billdb/internal/repository/job"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const jobsListLimit = 200
+
+func (w *WebHandlers) JobsPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "jobs.html", map[string]any{})
+}
+
+func (w *WebHandlers) JobsList(c echo.Context) error {
+	r := make(map[string]any)
+	r["success"] = false
+
+	jobs, err := w.JobRepo.GetJobs(jobsListLimit)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying jobs: %v", err)
+		return c.Render(http.StatusOK, "jobs-list.html", r)
+	}
+
+	// the list is cut at jobsListLimit, the counts are of every job
+	counts, err := w.JobRepo.CountJobs()
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while counting jobs: %v", err)
+		return c.Render(http.StatusOK, "jobs-list.html", r)
+	}
+
+	r["jobs"] = jobs
+	r["pending"] = counts[job.PENDING]
+	r["running"] = counts[job.RUNNING]
+	r["done"] = counts[job.DONE]
+	r["failed"] = counts[job.FAILED]
+	r["success"] = true
+	return c.Render(http.StatusOK, "jobs-list.html", r)
+}
+
+func (w *WebHandlers) JobRetry(c echo.Context) error {
+	err := w.JobRepo.RetryJob(c.Param("id"))
+	if errors.Is(err, jobRepository.ErrNotFailed) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	w.Jobs.Notify()
+	return w.JobsList(c)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"billdb/internal/parser/diagnostic"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	healthDays           = 30
+	healthRecentWindow   = 24 * time.Hour
+	healthBaselineWindow = 7 * 24 * time.Hour
+	healthFailuresLimit  = 50
+)
+
+type ParserHealth struct {
+	Parser   string
+	Total    diagnostic.Stats
+	Recent   diagnostic.Stats
+	Baseline diagnostic.Stats
+	Spike    bool
+	Days     []*diagnostic.DayStats
+}
+
+func (w *WebHandlers) ParserHealthPage(c echo.Context) error {
+	r := make(map[string]any)
+	r["success"] = false
+
+	now := time.Now()
+	recentFrom := now.Add(-healthRecentWindow)
+	baselineFrom := recentFrom.Add(-healthBaselineWindow)
+
+	days, err := w.DiagnosticRepo.GetDailyStats(now.AddDate(0, 0, -healthDays))
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying parse attempts: %v", err)
+		return c.Render(http.StatusOK, "parser-health.html", r)
+	}
+	recent, err := w.DiagnosticRepo.GetStats(recentFrom, now)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying parse attempts: %v", err)
+		return c.Render(http.StatusOK, "parser-health.html", r)
+	}
+	baseline, err := w.DiagnosticRepo.GetStats(baselineFrom, recentFrom)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying parse attempts: %v", err)
+		return c.Render(http.StatusOK, "parser-health.html", r)
+	}
+	failures, err := w.DiagnosticRepo.GetRecentFailures(healthFailuresLimit)
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying parse attempts: %v", err)
+		return c.Render(http.StatusOK, "parser-health.html", r)
+	}
+
+	parsers := map[string]*ParserHealth{}
+	getParser := func(name string) *ParserHealth {
+		p, ok := parsers[name]
+		if !ok {
+			p = &ParserHealth{Parser: name}
+			parsers[name] = p
+		}
+		return p
+	}
+	for _, d := range days {
+		p := getParser(d.Parser)
+		p.Days = append(p.Days, d)
+		p.Total.Attempts += d.Attempts
+		p.Total.Failures += d.Failures
+	}
+	for name, s := range recent {
+		getParser(name).Recent = s
+	}
+	for name, s := range baseline {
+		getParser(name).Baseline = s
+	}
+
+	health := make([]*ParserHealth, 0, len(parsers))
+	spikes := []string{}
+	for _, p := range parsers {
+		p.Spike = diagnostic.IsSpike(p.Recent, p.Baseline)
+		if p.Spike {
+			spikes = append(spikes, p.Parser)
+		}
+		health = append(health, p)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Parser < health[j].Parser
+	})
+	sort.Strings(spikes)
+
+	r["parsers"] = health
+	r["spikes"] = spikes
+	r["failures"] = failures
+	r["days"] = healthDays
+	r["success"] = true
+	return c.Render(http.StatusOK, "parser-health.html", r)
+}
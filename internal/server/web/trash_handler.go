@@ -0,0 +1,73 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BillDelete moves a bill to the trash and opens the trash
+func (w *WebHandlers) BillDelete(c echo.Context) error {
+	err := w.BillRepo.DeleteBill(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+	trash := c.Echo().Reverse("trash")
+	if c.Request().Header.Get("HX-Request") == "true" {
+		c.Response().Header().Set("HX-Redirect", trash)
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.Redirect(http.StatusSeeOther, trash)
+}
+
+func (w *WebHandlers) TrashPage(c echo.Context) error {
+	return c.Render(http.StatusOK, "trash.html", map[string]any{
+		"retention": int(w.Config.TrashRetention().Hours() / 24),
+	})
+}
+
+func (w *WebHandlers) TrashList(c echo.Context) error {
+	return w.renderTrash(c, "")
+}
+
+func (w *WebHandlers) TrashRestore(c echo.Context) error {
+	err := w.BillRepo.RestoreBill(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return w.renderTrash(c, fmt.Sprintf("Error restoring the bill: %v", err))
+	}
+	return w.renderTrash(c, "Bill restored")
+}
+
+func (w *WebHandlers) TrashPurge(c echo.Context) error {
+	err := w.BillRepo.PurgeBill(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return w.renderTrash(c, fmt.Sprintf("Error purging the bill: %v", err))
+	}
+	return w.renderTrash(c, "Bill deleted for good")
+}
+
+// TrashEmpty purges every bill of the trash
+func (w *WebHandlers) TrashEmpty(c echo.Context) error {
+	purged, err := w.BillRepo.PurgeTrash(c.Request().Context(), time.Now())
+	if err != nil {
+		return w.renderTrash(c, fmt.Sprintf("Error emptying the trash: %v", err))
+	}
+	return w.renderTrash(c, fmt.Sprintf("%d bills deleted for good", purged))
+}
+
+func (w *WebHandlers) renderTrash(c echo.Context, message string) error {
+	r := make(map[string]any)
+	r["success"] = false
+	r["message"] = message
+
+	trash, err := w.BillRepo.ListTrash(c.Request().Context())
+	if err != nil {
+		r["message"] = fmt.Sprintf("Error while querying the trash: %v", err)
+		return c.Render(http.StatusOK, "trash-list.html", r)
+	}
+	r["bills"] = trash
+	r["success"] = true
+	return c.Render(http.StatusOK, "trash-list.html", r)
+}
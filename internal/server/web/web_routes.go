@@ -1,8 +1,13 @@
 package web
 
 import (
+	"billdb/internal/repository/backup"
 	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/check"
+	diagnosticRepository "billdb/internal/repository/diagnostic"
+	jobRepository "billdb/internal/repository/job"
 	"billdb/internal/server"
+	"billdb/internal/worker"
 
 	"github.com/labstack/echo/v4"
 )
@@ -11,13 +16,32 @@ type WebHandlers struct {
 	Config   *server.Config
 	Echo     *echo.Echo
 	BillRepo repository.BillRepository
+	JobRepo  jobRepository.JobRepository
+	Jobs     *worker.Pool
+
+	DiagnosticRepo diagnosticRepository.DiagnosticRepository
+	Checker        *check.Checker
+	Backups        *backup.Manager
 }
 
-func NewWebHandlers(config *server.Config, echo *echo.Echo, repo repository.BillRepository) *WebHandlers {
+func NewWebHandlers(
+	config *server.Config,
+	echo *echo.Echo,
+	repo repository.BillRepository,
+	jobs *worker.Pool,
+	diagnostics diagnosticRepository.DiagnosticRepository,
+	checker *check.Checker,
+	backups *backup.Manager,
+) *WebHandlers {
 	return &WebHandlers{
-		Config:   config,
-		Echo:     echo,
-		BillRepo: repo,
+		Config:         config,
+		Echo:           echo,
+		BillRepo:       repo,
+		JobRepo:        jobs.JobRepo,
+		Jobs:           jobs,
+		DiagnosticRepo: diagnostics,
+		Checker:        checker,
+		Backups:        backups,
 	}
 }
 
@@ -30,18 +54,57 @@ func (w *WebHandlers) RegisterRoutes(group *echo.Group) {
 	group.GET("/bill/qr", w.BillFromQr).Name = "bill-from-qr"
 	group.POST("/bill/qr", w.BillFromQrUpload)
 
+	group.GET("/jobs", w.JobsPage).Name = "jobs"
+	group.GET("/jobs/list", w.JobsList).Name = "jobs-list"
+	group.POST("/jobs/:id/retry", w.JobRetry).Name = "job-retry"
+	group.GET("/parsers/health", w.ParserHealthPage).Name = "parser-health"
+
 	group.GET("/db/save", w.SaveDb).Name = "db-save"
 	group.GET("/db/upload", w.UploadDb).Name = "db-upload"
 	group.POST("/db/upload", w.UploadDbSubmit)
+	group.POST("/db/upload/:token/confirm", w.UploadDbConfirm).Name = "db-upload-confirm"
+	group.POST("/db/upload/:token/cancel", w.UploadDbCancel).Name = "db-upload-cancel"
+	group.GET("/db/merge", w.MergeDb).Name = "db-merge"
+	group.POST("/db/merge", w.MergeDbSubmit)
+	group.GET("/db/check", w.DbCheckPage).Name = "db-check"
+	group.POST("/db/check", w.DbCheckRepair).Name = "db-check-repair"
+
+	group.GET("/backups", w.BackupsPage).Name = "backups"
+	group.GET("/backups/list", w.BackupsList).Name = "backups-list"
+	group.POST("/backups", w.BackupCreate).Name = "backup-create"
+	group.GET("/backups/:name", w.BackupDownload).Name = "backup-download"
+	group.POST("/backups/:name/restore", w.BackupRestore).Name = "backup-restore"
+	group.POST("/backups/:name/delete", w.BackupDelete).Name = "backup-delete"
 
 	group.GET("/browse/bills", w.BillBrowseLanding).Name = "browse-landing"
+	group.GET("/browse/bills/range", w.BillBrowse).Name = "browse-bills-range"
+	group.GET("/browse/bills/:y", w.BillBrowse).Name = "browse-bills-year"
 	group.GET("/browse/bills/:y/:m", w.BillBrowse).Name = "browse-bills"
 
+	group.GET("/browse/items/range", w.ItemsBrowse).Name = "browse-items-range"
+	group.GET("/browse/items/:y", w.ItemsBrowse).Name = "browse-items-year"
 	group.GET("/browse/items/:y/:m", w.ItemsBrowse).Name = "browse-items"
 	group.GET("/bill/:id", w.BillView).Name = "bill-view"
+	group.GET("/bill/:id/qr", w.BillQr).Name = "bill-qr"
+	group.GET("/bill/:id/card", w.BillCard).Name = "bill-card"
 
 	group.GET("/bill/:id/edit", w.BillEditPage).Name = "bill-edit"
 	group.PUT("/bill/:id/edit", w.BillEditSubmit)
+	group.POST("/bill/:id/delete", w.BillDelete).Name = "bill-delete"
+	group.GET("/bill/:id/history", w.BillHistoryPage).Name = "bill-history"
+	group.GET("/bill/:id/history/list", w.BillHistoryList).Name = "bill-history-list"
+	group.POST("/audit/:id/revert", w.AuditRevert).Name = "audit-revert"
+
+	group.GET("/trash", w.TrashPage).Name = "trash"
+	group.GET("/trash/list", w.TrashList).Name = "trash-list"
+	group.POST("/trash/purge", w.TrashEmpty).Name = "trash-empty"
+	group.POST("/trash/:id/restore", w.TrashRestore).Name = "trash-restore"
+	group.POST("/trash/:id/purge", w.TrashPurge).Name = "trash-purge"
+
+	group.GET("/duplicates", w.DuplicatesPage).Name = "duplicates"
+	group.GET("/duplicates/list", w.DuplicatesList).Name = "duplicates-list"
+	group.POST("/duplicates/merge", w.DuplicateMerge).Name = "duplicate-merge"
+	group.POST("/duplicates/dismiss", w.DuplicateDismiss).Name = "duplicate-dismiss"
 
 	group.GET("/search", w.SearchPage).Name = "search"
 	group.GET("/search/bills", w.BillsSearch).Name = "bills-search"
@@ -0,0 +1,331 @@
+package web_test
+
+import (
+	"billdb/internal/bill"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/server/servertest"
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const rsLink = "https://suf.purs.gov.rs/v/?vl="
+
+func TestBillForm(t *testing.T) {
+	s := servertest.New(t)
+
+	resp, body := s.Get(t, "/bill/form")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `name="currency"`) {
+		t.Fatalf("Expected the form, got %d: %s", resp.StatusCode, body)
+	}
+
+	form := url.Values{
+		"name":     {"Maxi"},
+		"tag":      {"food"},
+		"date":     {"2024-05-01"},
+		"price":    {"250.5"},
+		"currency": {"rsd"},
+		"country":  {"serbia"},
+	}
+	_, body = s.PostForm(t, "/bill/form", form)
+	if !strings.Contains(body, "Bill inserted successfully") {
+		t.Fatalf("Expected the bill inserted, got %s", body)
+	}
+	bills, err := s.BillRepo.ListBills(context.Background(), repository.BillFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bills) != 1 || bills[0].Name != "Maxi" || bills[0].Price != 250.5 || bills[0].Tag.String != "food" {
+		t.Fatalf("Expected the bill of the form, got %v", bills)
+	}
+
+	// two bills typed without items, of the same shop, day and total,
+	// are not taken for the same receipt
+	_, body = s.PostForm(t, "/bill/form", form)
+	if !strings.Contains(body, "Bill inserted successfully") {
+		t.Errorf("Expected the second bill inserted, got %s", body)
+	}
+	scan := servertest.NewBill("", "Maxi", 99, "milk")
+	if err := s.BillRepo.InsertBillWithItems(context.Background(), scan); err != nil {
+		t.Fatal(err)
+	}
+	form.Set("price", "99")
+	_, body = s.PostForm(t, "/bill/form", form)
+	if !strings.Contains(body, "Found 1 duplicate bill(s)") {
+		t.Errorf("Expected the bill with items reported, got %s", body)
+	}
+	form.Set("currency", "coins")
+	_, body = s.PostForm(t, "/bill/form", form)
+	if !strings.Contains(body, "Invalid currency") {
+		t.Errorf("Expected the currency rejected, got %s", body)
+	}
+	if bills, _ := s.BillRepo.ListBills(context.Background(), repository.BillFilter{}); len(bills) != 3 {
+		t.Errorf("Expected 3 bills, got %v", bills)
+	}
+}
+
+func TestBillFromLink(t *testing.T) {
+	s := servertest.New(t)
+	parser := servertest.FakeParser(t, rsLink)
+	maxi := servertest.NewBill(rsLink+"maxi", "Maxi", 250, "milk", "bread")
+	parser.Add(maxi)
+
+	_, body := s.PostForm(t, "/bill/link", url.Values{
+		"link": {maxi.Link + "\n\n" + rsLink + "unknown\n"},
+	})
+	if !strings.Contains(body, "Queued 2 links for parsing") {
+		t.Fatalf("Expected the links queued, got %s", body)
+	}
+
+	ctx := context.Background()
+	servertest.WaitFor(t, "the parsed bill", func() bool {
+		_, err := s.BillRepo.GetBillByID(ctx, maxi.Id)
+		return err == nil
+	})
+	items, err := s.BillRepo.GetItemsByID(ctx, maxi.Id)
+	if err != nil || len(items) != 2 {
+		t.Errorf("Expected the items of the bill, got %v, %v", items, err)
+	}
+	servertest.WaitFor(t, "both links parsed", func() bool {
+		return parser.Calls() == 2
+	})
+
+	_, body = s.Get(t, "/jobs/list")
+	if !strings.Contains(body, "no canned bill") {
+		t.Errorf("Expected the failed job listed, got %s", body)
+	}
+	if !strings.Contains(body, "Failed: 1") || !strings.Contains(body, "Done: 1") {
+		t.Errorf("Expected the jobs counted, got %s", body)
+	}
+
+	resp, _ := s.PostForm(t, "/jobs/missing/retry", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 retrying a missing job, got %d", resp.StatusCode)
+	}
+}
+
+func TestBillEdit(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	b := servertest.NewBill(rsLink+"maxi", "Maxi", 250, "milk")
+	if err := s.BillRepo.InsertBillWithItems(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := s.Get(t, "/bill/"+b.Id+"/edit")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "Maxi") {
+		t.Fatalf("Expected the edit page, got %d: %s", resp.StatusCode, body)
+	}
+
+	form := url.Values{"name": {"Lidl"}, "tag": {"food"}, "price": {""}}
+	_, body = s.Do(t, http.MethodPut, "/bill/"+b.Id+"/edit", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if !strings.Contains(body, "Successful update") || !strings.Contains(body, "`Lidl`") {
+		t.Fatalf("Expected the update shown, got %s", body)
+	}
+	got, err := s.BillRepo.GetBillByID(ctx, b.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Lidl" || got.Tag.String != "food" || got.Price != 250 {
+		t.Errorf("Expected the edited bill, got %+v", got)
+	}
+
+	_, body = s.Get(t, "/bill/"+b.Id+"/history/list")
+	if !strings.Contains(body, "Lidl") {
+		t.Errorf("Expected the change in the history, got %s", body)
+	}
+
+	resp, _ = s.Get(t, "/bill/missing/edit")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing bill, got %d", resp.StatusCode)
+	}
+}
+
+func TestBackups(t *testing.T) {
+	s := servertest.New(t)
+
+	resp, body := s.Get(t, "/db/save")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "SQLite format 3") {
+		t.Fatalf("Expected a copy of the database, got %d", resp.StatusCode)
+	}
+
+	_, body = s.PostForm(t, "/backups", nil)
+	if !strings.Contains(body, "Created manual-") {
+		t.Fatalf("Expected the backup created, got %s", body)
+	}
+	_, body = s.Get(t, "/backups/list")
+	start := strings.Index(body, "/backups/manual-")
+	if start < 0 {
+		t.Fatalf("Expected the backup listed, got %s", body)
+	}
+	name := body[start+len("/backups/"):]
+	name = name[:strings.Index(name, ".db")+len(".db")]
+
+	resp, body = s.Get(t, "/backups/"+name)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "SQLite format 3") {
+		t.Errorf("Expected the backup downloaded, got %d", resp.StatusCode)
+	}
+	_, body = s.PostForm(t, "/backups/"+name+"/restore", nil)
+	if !strings.Contains(body, "Restored "+name+", the previous database is kept as restore-") {
+		t.Errorf("Expected the backup restored, got %s", body)
+	}
+	resp, _ = s.Get(t, "/backups/..%2Fbilldb.sqlite")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 outside the backups, got %d", resp.StatusCode)
+	}
+}
+
+func TestDbCheckPostgres(t *testing.T) {
+	s := servertest.New(t)
+	// the SQLite file is still open, it must not be checked instead
+	s.Config.PostgresDsn = "postgres://billdb@localhost/billdb"
+
+	resp, _ := s.Get(t, "/db/check")
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected 501 for the check page, got %d", resp.StatusCode)
+	}
+	resp, _ = s.PostForm(t, "/db/check", nil)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected 501 for the repair, got %d", resp.StatusCode)
+	}
+}
+
+func TestDbUpload(t *testing.T) {
+	s := servertest.New(t)
+	upload := func(content string) string {
+		t.Helper()
+		return uploadFile(t, s, "/db/upload", content)
+	}
+
+	body := upload("not a database, but long enough to look like a header of a file")
+	if !strings.Contains(body, "The file can&#39;t be used") {
+		t.Fatalf("Expected the file rejected, got %s", body)
+	}
+
+	_, db := s.Get(t, "/db/save")
+	body = upload(db)
+	if !strings.Contains(body, "Replace the current database") || !strings.Contains(body, "<td>Bills</td>") {
+		t.Fatalf("Expected the preview, got %s", body)
+	}
+	start := strings.Index(body, "/db/upload/")
+	if start < 0 {
+		t.Fatalf("Expected the confirm form, got %s", body)
+	}
+	confirm := body[start:]
+	confirm = confirm[:strings.Index(confirm, "/confirm")+len("/confirm")]
+
+	_, body = s.PostForm(t, confirm, nil)
+	if !strings.Contains(body, "The uploaded database is in use, the previous one is kept as upload-") {
+		t.Fatalf("Expected the database replaced, got %s", body)
+	}
+	_, body = s.PostForm(t, confirm, nil)
+	if !strings.Contains(body, "Error replacing the database") {
+		t.Errorf("Expected the upload used once, got %s", body)
+	}
+}
+
+// uploadFile posts content as the file of the form of path
+func uploadFile(t *testing.T, s *servertest.Server, path string, content string) string {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "../../billdb.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	_, page := s.Do(t, http.MethodPost, path, mw.FormDataContentType(), &body)
+	return page
+}
+
+func TestDbMerge(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	maxi := servertest.NewBill(rsLink+"maxi", "Maxi", 250, "milk")
+	if err := s.BillRepo.InsertBillWithItems(ctx, maxi); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "laptop.sqlite")
+	db, err := repository.OpenSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+	laptop := repository.NewSqliteBillRepository(db)
+	lidl := servertest.NewBill(rsLink+"lidl", "Lidl", 100, "soap", "bread")
+	for _, b := range []*bill.Bill{maxi, lidl} {
+		if err := laptop.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := uploadFile(t, s, "/db/merge", string(content))
+	if !strings.Contains(body, "inserted: 1") || !strings.Contains(body, "same: 1") ||
+		!strings.Contains(body, "was backed up as") {
+		t.Fatalf("Expected the merge report, got %s", body)
+	}
+	if items, err := s.BillRepo.GetItemsByID(ctx, lidl.Id); err != nil || len(items) != 2 {
+		t.Errorf("Expected the bill of the laptop merged, got %v, %v", items, err)
+	}
+
+	body = uploadFile(t, s, "/db/merge", "not a database, but long enough to look like a header of a file")
+	if !strings.Contains(body, "The file can&#39;t be used") {
+		t.Errorf("Expected the file rejected, got %s", body)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	maxi := servertest.NewBill("", "Maxi", 250, "milk", "bread")
+	typed := servertest.NewBill("", "MAXI 042", 250)
+	typed.Tag = maxi.Tag
+	lidl := servertest.NewBill("", "Lidl", 100, "soap")
+	lidlAgain := servertest.NewBill("", "Lidl", 100, "soap")
+	for _, b := range []*bill.Bill{maxi, typed, lidl, lidlAgain} {
+		if err := s.BillRepo.InsertBillWithItems(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, body := s.Get(t, "/duplicates/list")
+	if strings.Count(body, "Not duplicates") != 2 {
+		t.Fatalf("Expected 2 pairs of duplicates, got %s", body)
+	}
+
+	_, body = s.PostForm(t, "/duplicates/dismiss", url.Values{"id": {lidl.Id}, "other": {lidlAgain.Id}})
+	if !strings.Contains(body, "The bills are not duplicates") || strings.Count(body, "Not duplicates") != 1 {
+		t.Fatalf("Expected the pair dismissed, got %s", body)
+	}
+
+	_, body = s.PostForm(t, "/duplicates/merge", url.Values{"keep": {typed.Id}, "drop": {maxi.Id}})
+	if !strings.Contains(body, "Kept MAXI 042") || !strings.Contains(body, "No duplicates found") {
+		t.Fatalf("Expected the bills merged, got %s", body)
+	}
+	if items, err := s.BillRepo.GetItemsByID(ctx, typed.Id); err != nil || len(items) != 2 {
+		t.Errorf("Expected the items of the dropped bill, got %v, %v", items, err)
+	}
+	if _, err := s.BillRepo.GetBillByID(ctx, maxi.Id); err == nil {
+		t.Errorf("Expected the dropped bill in the trash")
+	}
+
+	_, body = s.PostForm(t, "/duplicates/merge", url.Values{"keep": {typed.Id}, "drop": {typed.Id}})
+	if !strings.Contains(body, "Error merging the bills") {
+		t.Errorf("Expected a bill not merged with itself, got %s", body)
+	}
+}
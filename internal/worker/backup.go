@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"billdb/internal/repository/backup"
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const backupInterval = time.Hour
+
+// Backup makes the daily scheduled backup of the database and prunes the
+// older ones past the retention policy, once at start and then every hour
+// until ctx is done. A policy keeping no backup turns them off.
+func Backup(ctx context.Context, backups *backup.Manager) {
+	if !backups.Policy().Enabled() {
+		return
+	}
+	ticker := time.NewTicker(backupInterval)
+	defer ticker.Stop()
+	for {
+		created, expired, err := backups.Schedule(ctx, time.Now())
+		if err != nil {
+			log.Error("Error making the scheduled backup: ", err)
+		}
+		if created != nil {
+			log.WithField("backup", created.Name).Info("Backed up the database")
+		}
+		if len(expired) > 0 {
+			log.WithField("backups", len(expired)).Info("Deleted expired backups")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"billdb/internal/audit"
+	"billdb/internal/job"
+	"billdb/internal/parser"
+	repository "billdb/internal/repository/bill"
+	"billdb/internal/repository/dedup"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NewParseHandler returns a Handler which parses the job link
+// and inserts the bill with its items if it is not a duplicate.
+func NewParseHandler(billRepo repository.BillRepository) Handler {
+	return func(ctx context.Context, j *job.Job) (string, error) {
+		ctx = audit.WithSource(ctx, audit.SourceParser)
+		p, err := parser.GetBillParser(j.Link)
+		if err != nil {
+			return "", Permanent(err)
+		}
+
+		// a receipt already stored is not fetched again
+		duplicates, err := dedup.FindLink(ctx, billRepo, j.Link)
+		if err != nil {
+			return "", err
+		}
+		if len(duplicates) != 0 {
+			return "", Permanent(duplicateError(duplicates))
+		}
+
+		b, err := p.Parse(j.Link)
+		if err != nil {
+			return "", fmt.Errorf("error while parsing the site: %w", err)
+		}
+
+		duplicates, err = dedup.Find(ctx, billRepo, b)
+		if err != nil {
+			return "", err
+		}
+		if len(duplicates) != 0 {
+			return "", Permanent(duplicateError(duplicates))
+		}
+
+		err = billRepo.InsertBillWithItems(ctx, b)
+		if err != nil {
+			return "", err
+		}
+		return b.Id, nil
+	}
+}
+
+// duplicateError names the most likely duplicate of a bill
+func duplicateError(duplicates []*dedup.Candidate) error {
+	d := duplicates[0]
+	return fmt.Errorf("found %d duplicate bills, %s of %s (%d%%: %s)", len(duplicates),
+		d.Bill.Name, d.Bill.GetDateString(), d.Percent(), strings.Join(d.Reasons, ", "))
+}
@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"billdb/internal/job"
+	repository "billdb/internal/repository/job"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultWorkers   = 4
+	DefaultHostLimit = 2
+	MaxAttempts      = 5
+	pollInterval     = 5 * time.Second
+	backoffBase      = 30 * time.Second
+	backoffMax       = time.Hour
+)
+
+// Handler processes a single job and returns the id of the created bill
+type Handler func(ctx context.Context, j *job.Job) (string, error)
+
+// PermanentError marks a job failure which should not be retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// Pool runs queued jobs with a limited number of workers
+// and a limited number of concurrent requests per host.
+type Pool struct {
+	JobRepo   repository.JobRepository
+	Handler   Handler
+	Workers   int
+	HostLimit int
+
+	slots chan struct{}
+	wake  chan struct{}
+	mu    sync.Mutex
+	hosts map[string]int
+	wg    sync.WaitGroup
+}
+
+func NewPool(jobRepo repository.JobRepository, handler Handler, workers int, hostLimit int) *Pool {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if hostLimit <= 0 {
+		hostLimit = DefaultHostLimit
+	}
+	return &Pool{
+		JobRepo:   jobRepo,
+		Handler:   handler,
+		Workers:   workers,
+		HostLimit: hostLimit,
+		slots:     make(chan struct{}, workers),
+		wake:      make(chan struct{}, 1),
+		hosts:     make(map[string]int),
+	}
+}
+
+// Enqueue stores new pending jobs for the links and wakes up the dispatcher
+func (p *Pool) Enqueue(links ...string) ([]*job.Job, error) {
+	jobs := make([]*job.Job, 0, len(links))
+	for _, link := range links {
+		j := job.New(link)
+		err := p.JobRepo.InsertJob(j)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, j)
+	}
+	p.Notify()
+	return jobs, nil
+}
+
+// Notify asks the dispatcher to look for due jobs without waiting for the next poll
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches due jobs until ctx is cancelled,
+// then waits for the running jobs to finish.
+func (p *Pool) Run(ctx context.Context) {
+	reset, err := p.JobRepo.ResetRunningJobs()
+	if err != nil {
+		log.Error("Error resetting running jobs: ", err)
+	} else if reset > 0 {
+		log.WithField("jobs", reset).Info("Requeued interrupted jobs")
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		p.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			p.wg.Wait()
+			return
+		case <-ticker.C:
+		case <-p.wake:
+		}
+	}
+}
+
+// dispatch starts the due jobs the workers and host limits allow. At most
+// HostLimit jobs of a host are fetched: the hosts at their limit hold
+// fewer than Workers of the fetched jobs, the others are for free hosts.
+func (p *Pool) dispatch(ctx context.Context) {
+	jobs, err := p.JobRepo.GetDueJobs(time.Now(), p.HostLimit, p.Workers*p.HostLimit)
+	if err != nil {
+		log.Error("Error getting due jobs: ", err)
+		return
+	}
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if !p.acquireHost(j.Host) {
+			continue
+		}
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			// every worker is busy, the rest waits for the next round
+			p.releaseHost(j.Host)
+			return
+		}
+		claimed, err := p.JobRepo.ClaimJob(j.Id)
+		if err != nil || !claimed {
+			if err != nil {
+				log.WithField("job", j.Id).Error("Error claiming job: ", err)
+			}
+			<-p.slots
+			p.releaseHost(j.Host)
+			continue
+		}
+		j.Status = job.RUNNING
+		p.wg.Add(1)
+		go p.run(ctx, j)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, j *job.Job) {
+	defer func() {
+		<-p.slots
+		p.releaseHost(j.Host)
+		p.wg.Done()
+		p.Notify()
+	}()
+
+	billId, err := p.Handler(ctx, j)
+	j.Attempts++
+	j.UpdatedAt = time.Now().UTC()
+	if err == nil {
+		j.Status = job.DONE
+		j.Error = ""
+		j.BillId = billId
+		j.NextRun = nil
+	} else {
+		j.Status = job.FAILED
+		j.Error = err.Error()
+		j.NextRun = nil
+		var permanent *PermanentError
+		if !errors.As(err, &permanent) && j.Attempts < MaxAttempts {
+			nextRun := j.UpdatedAt.Add(Backoff(j.Attempts))
+			j.NextRun = &nextRun
+		}
+		log.WithField("job", j.Id).
+			WithField("attempt", j.Attempts).
+			WithField("retry", j.NextRun != nil).
+			Error("Job failed: ", err)
+	}
+
+	err = p.JobRepo.UpdateJob(j)
+	if err != nil {
+		log.WithField("job", j.Id).Error("Error updating job: ", err)
+	}
+}
+
+// Backoff returns the delay before the next attempt,
+// doubling with every failed attempt up to backoffMax
+func Backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+func (p *Pool) acquireHost(host string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts[host] >= p.HostLimit {
+		return false
+	}
+	p.hosts[host]++
+	return true
+}
+
+func (p *Pool) releaseHost(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts[host]--
+	if p.hosts[host] <= 0 {
+		delete(p.hosts, host)
+	}
+}
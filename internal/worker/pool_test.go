@@ -0,0 +1,269 @@
+package worker
+
+import (
+	"billdb/internal/job"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryJobRepository is a minimal JobRepository for pool tests
+type memoryJobRepository struct {
+	mu   sync.Mutex
+	jobs map[string]*job.Job
+	ids  []string
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{jobs: make(map[string]*job.Job)}
+}
+
+func (r *memoryJobRepository) InsertJob(j *job.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := *j
+	r.jobs[j.Id] = &c
+	r.ids = append(r.ids, j.Id)
+	return nil
+}
+
+func (r *memoryJobRepository) GetJobByID(id string) (*job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	c := *j
+	return &c, nil
+}
+
+func (r *memoryJobRepository) GetJobs(limit int) ([]*job.Job, error) {
+	return r.GetDueJobs(time.Time{}, limit, limit)
+}
+
+func (r *memoryJobRepository) GetDueJobs(now time.Time, perHost int, limit int) ([]*job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var jobs []*job.Job
+	hosts := make(map[string]int)
+	for _, id := range r.ids {
+		j := r.jobs[id]
+		if j.Status == job.PENDING || (j.Retrying() && !j.NextRun.After(now)) {
+			if hosts[j.Host] == perHost {
+				continue
+			}
+			hosts[j.Host]++
+			c := *j
+			jobs = append(jobs, &c)
+		}
+		if len(jobs) == limit {
+			break
+		}
+	}
+	return jobs, nil
+}
+
+func (r *memoryJobRepository) CountJobs() (map[job.Status]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[job.Status]int)
+	for _, j := range r.jobs {
+		counts[j.Status]++
+	}
+	return counts, nil
+}
+
+func (r *memoryJobRepository) ClaimJob(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j := r.jobs[id]
+	if j.Status != job.PENDING && j.Status != job.FAILED {
+		return false, nil
+	}
+	j.Status = job.RUNNING
+	return true, nil
+}
+
+func (r *memoryJobRepository) UpdateJob(j *job.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := *j
+	r.jobs[j.Id] = &c
+	return nil
+}
+
+func (r *memoryJobRepository) RetryJob(id string) error {
+	return nil
+}
+
+func (r *memoryJobRepository) ResetRunningJobs() (int64, error) {
+	return 0, nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Condition was not met in time")
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != backoffBase {
+		t.Errorf("Expected %s, got %s", backoffBase, Backoff(1))
+	}
+	if Backoff(3) != 4*backoffBase {
+		t.Errorf("Expected %s, got %s", 4*backoffBase, Backoff(3))
+	}
+	if Backoff(100) != backoffMax {
+		t.Errorf("Expected %s, got %s", backoffMax, Backoff(100))
+	}
+}
+
+func TestPoolJobStates(t *testing.T) {
+	jobRepo := newMemoryJobRepository()
+	handler := func(ctx context.Context, j *job.Job) (string, error) {
+		switch j.Link {
+		case "https://example.com/ok":
+			return "bill-id", nil
+		case "https://example.com/duplicate":
+			return "", Permanent(errors.New("duplicate"))
+		default:
+			return "", errors.New("timeout")
+		}
+	}
+	pool := NewPool(jobRepo, handler, 2, 2)
+	jobs, err := pool.Enqueue(
+		"https://example.com/ok",
+		"https://example.com/duplicate",
+		"https://example.com/timeout",
+	)
+	if err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	waitFor(t, func() bool {
+		for _, j := range jobs {
+			jobFromRepo, _ := jobRepo.GetJobByID(j.Id)
+			if jobFromRepo.Status != job.DONE && jobFromRepo.Status != job.FAILED {
+				return false
+			}
+		}
+		return true
+	})
+	cancel()
+	<-done
+
+	ok, _ := jobRepo.GetJobByID(jobs[0].Id)
+	if ok.Status != job.DONE || ok.BillId != "bill-id" {
+		t.Errorf("Expected done job with bill id, got %s with '%s'", ok.Status, ok.BillId)
+	}
+	duplicate, _ := jobRepo.GetJobByID(jobs[1].Id)
+	if duplicate.Status != job.FAILED || duplicate.Retrying() {
+		t.Errorf("Expected failed job without retry, got %s, retry %v", duplicate.Status, duplicate.Retrying())
+	}
+	timeout, _ := jobRepo.GetJobByID(jobs[2].Id)
+	if !timeout.Retrying() || timeout.Attempts != 1 {
+		t.Errorf("Expected failed job scheduled for retry, got %s, attempts %d", timeout.Status, timeout.Attempts)
+	}
+}
+
+func TestPoolHostLimit(t *testing.T) {
+	jobRepo := newMemoryJobRepository()
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	handler := func(ctx context.Context, j *job.Job) (string, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "bill-id", nil
+	}
+	pool := NewPool(jobRepo, handler, 4, 1)
+	jobs, err := pool.Enqueue(
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/3",
+	)
+	if err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	waitFor(t, func() bool {
+		for _, j := range jobs {
+			jobFromRepo, _ := jobRepo.GetJobByID(j.Id)
+			if jobFromRepo.Status != job.DONE {
+				return false
+			}
+		}
+		return true
+	})
+	cancel()
+	<-done
+
+	if maxRunning != 1 {
+		t.Errorf("Expected at most 1 concurrent job per host, got %d", maxRunning)
+	}
+}
+
+func TestPoolHostBacklog(t *testing.T) {
+	jobRepo := newMemoryJobRepository()
+	release := make(chan struct{})
+	handler := func(ctx context.Context, j *job.Job) (string, error) {
+		if j.Host == "busy.example.com" {
+			<-release
+		}
+		return "bill-id", nil
+	}
+	pool := NewPool(jobRepo, handler, 2, 1)
+	links := make([]string, 0, 6)
+	for _, path := range []string{"1", "2", "3", "4", "5"} {
+		links = append(links, "https://busy.example.com/"+path)
+	}
+	links = append(links, "https://other.example.com/1")
+	jobs, err := pool.Enqueue(links...)
+	if err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	// the job of the other host runs while the busy host is at its limit
+	waitFor(t, func() bool {
+		other, _ := jobRepo.GetJobByID(jobs[len(jobs)-1].Id)
+		return other.Status == job.DONE
+	})
+	close(release)
+	cancel()
+	<-done
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"billdb/internal/audit"
+	repository "billdb/internal/repository/bill"
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const trashPurgeInterval = time.Hour
+
+// PurgeTrash deletes for good the bills kept in the trash for longer
+// than retention, once at start and then every hour until ctx is done.
+// Zero or negative retention keeps the bills until purged by hand.
+func PurgeTrash(ctx context.Context, repo repository.BillRepository, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	ctx = audit.WithSource(ctx, audit.SourceSystem)
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := repo.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Error("Error purging the trash: ", err)
+		} else if purged > 0 {
+			log.WithField("bills", purged).Info("Purged expired bills from the trash")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -113,7 +113,7 @@ for GOOS in "${GOOS_LIST[@]}"; do
     esac
 
     if [[ -n "$CC_COMPILER" ]]; then
-      env GOOS="$GOOS" GOARCH="$GOARCH" CGO_ENABLED=1 CC="$CC_COMPILER" go build -ldflags "-X main.version=${VERSION}" -o "$OUT_PATH" "$MAIN_PKG"
+      env GOOS="$GOOS" GOARCH="$GOARCH" CGO_ENABLED=1 CC="$CC_COMPILER" go build -tags sqlite_fts5 -ldflags "-X main.version=${VERSION}" -o "$OUT_PATH" "$MAIN_PKG"
     else
       env GOOS="$GOOS" GOARCH="$GOARCH" CGO_ENABLED=0 go build -ldflags "-X main.version=${VERSION}" -o "$OUT_PATH" "$MAIN_PKG"
     fi